@@ -2,62 +2,105 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"time"
 
 	"github.com/susu3304/nkmzbot/internal/api"
 	"github.com/susu3304/nkmzbot/internal/bot"
 	"github.com/susu3304/nkmzbot/internal/config"
 	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/lifecycle"
+	"github.com/susu3304/nkmzbot/internal/metrics"
 )
 
+// shutdownTimeout bounds how long in-flight HTTP requests, reminder ticks and scheduled tasks may take to finish.
+const shutdownTimeout = 20 * time.Second
+
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	setupLogger(cfg)
+	lc := lifecycle.New()
 
-	// Connect to database
+	// Connect to database (registered first so it is closed last)
 	database, err := db.New(context.Background(), cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("failed to connect to database", err)
 	}
-	defer database.Close()
+	lc.OnStop("database", func(ctx context.Context) error {
+		database.Close()
+		return nil
+	})
 
 	// Run migrations
 	if err := database.RunMigrations(context.Background()); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("failed to run migrations", err)
 	}
 
 	// Initialize Discord bot
 	discordBot, err := bot.New(cfg.DiscordToken, database)
 	if err != nil {
-		log.Fatalf("Failed to create discord bot: %v", err)
+		fatal("failed to create discord bot", err)
 	}
 
 	// Initialize API server
 	apiServer := api.New(cfg, database)
+	apiServer.AddReadinessCheck("discord_gateway", func(ctx context.Context) error {
+		if !discordBot.Connected() {
+			return errors.New("gateway not connected")
+		}
+		return nil
+	})
+
+	metrics.RegisterDBPool(database.Stat)
+	metrics.RegisterGaugeFunc("discord_heartbeat_latency_seconds", "Round trip of the latest gateway heartbeat.", func() float64 {
+		return discordBot.HeartbeatLatency().Seconds()
+	})
 
 	// Start Discord bot
 	if err := discordBot.Start(); err != nil {
-		log.Fatalf("Failed to start discord bot: %v", err)
+		fatal("failed to start discord bot", err)
 	}
-	defer discordBot.Stop()
+	lc.OnStop("discord", discordBot.Stop)
 
 	// Start API server
-	go func() {
-		if err := apiServer.Start(); err != nil {
-			log.Printf("API server error: %v", err)
-		}
-	}()
+	lc.Go("api", func(ctx context.Context) error {
+		return apiServer.Start()
+	})
+	lc.OnStop("api", apiServer.Shutdown)
 
-	// Wait for signal to stop
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-	<-stop
+	// Wait for a signal, then stop the API, the bot and finally the database
+	if err := lc.Wait(shutdownTimeout); err != nil {
+		fatal("shutdown finished with errors", err)
+	}
+	slog.Info("shutdown complete")
+}
+
+// setupLogger installs the default slog logger; log.Printf output from dependencies goes through it too.
+func setupLogger(cfg *config.Config) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.LogFormat, "json") {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
 
-	log.Println("Shutting down...")
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
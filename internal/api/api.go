@@ -1,30 +1,45 @@
 package api
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 	"github.com/susu3304/nkmzbot/internal/config"
 	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/guess"
+	"github.com/susu3304/nkmzbot/internal/metrics"
 	"golang.org/x/oauth2"
 )
 
 type API struct {
 	router      *mux.Router
 	db          *db.DB
+	guess       *guess.Service
 	config      *config.Config
 	oauthConfig *oauth2.Config
 	jwtSecret   []byte
+	readiness   []readinessCheck
+	server      *http.Server
+	// discordClient makes the Discord API calls; nil means http.DefaultClient.
+	discordClient *http.Client
+	// metricsToken is the bearer token /metrics requires; /metrics is not served without one.
+	metricsToken []byte
 }
 
 func New(cfg *config.Config, database *db.DB) *API {
 	api := &API{
-		router:    mux.NewRouter(),
-		db:        database,
-		config:    cfg,
-		jwtSecret: []byte(cfg.JWTSecret),
+		router:       mux.NewRouter(),
+		db:           database,
+		guess:        guess.NewService(database),
+		config:       cfg,
+		jwtSecret:    []byte(cfg.JWTSecret),
+		metricsToken: []byte(cfg.MetricsToken),
 		oauthConfig: &oauth2.Config{
 			ClientID:     cfg.DiscordClientID,
 			ClientSecret: cfg.DiscordClientSecret,
@@ -38,10 +53,22 @@ func New(cfg *config.Config, database *db.DB) *API {
 	}
 
 	api.setupRoutes()
+	api.server = &http.Server{
+		Addr:              cfg.WebBind,
+		Handler:           api.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 	return api
 }
 
 func (a *API) setupRoutes() {
+	// Operational endpoints (health checks are unauthenticated, metrics need METRICS_TOKEN)
+	if len(a.metricsToken) > 0 {
+		a.router.Handle("/metrics", a.metricsAuth(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))).Methods("GET")
+	}
+	a.router.HandleFunc("/healthz", a.handleHealthz).Methods("GET")
+	a.router.HandleFunc("/readyz", a.handleReadyz).Methods("GET")
+
 	// Auth endpoints
 	a.router.HandleFunc("/api/auth/login", a.handleLogin).Methods("GET")
 	a.router.HandleFunc("/api/auth/callback", a.handleAuthCallback).Methods("GET")
@@ -50,6 +77,7 @@ func (a *API) setupRoutes() {
 	// Web interface pages (login page is public, command list requires auth via cookie)
 	a.router.HandleFunc("/login", a.handleLoginPage).Methods("GET")
 	a.router.HandleFunc("/guilds/{guild_id}", a.handleCommandListPage).Methods("GET")
+	a.router.HandleFunc("/guilds/{guild_id}/guess", a.handleGuessHistoryPage).Methods("GET")
 
 	// Protected endpoints - all endpoints require authentication
 	protected := a.router.PathPrefix("/api").Subrouter()
@@ -61,9 +89,17 @@ func (a *API) setupRoutes() {
 	protected.HandleFunc("/guilds/{guild_id}/commands/{name}", a.handleUpdateCommand).Methods("PUT")
 	protected.HandleFunc("/guilds/{guild_id}/commands/{name}", a.handleDeleteCommand).Methods("DELETE")
 	protected.HandleFunc("/guilds/{guild_id}/commands/bulk-delete", a.handleBulkDeleteCommands).Methods("POST")
+	protected.HandleFunc("/guilds/{guild_id}/settings/replies", a.handleListReplySettings).Methods("GET")
+	protected.HandleFunc("/guilds/{guild_id}/settings/replies/{command}", a.handleSetReplySetting).Methods("PUT")
+	protected.HandleFunc("/guilds/{guild_id}/settings/replies/{command}", a.handleDeleteReplySetting).Methods("DELETE")
+	protected.HandleFunc("/guilds/{guild_id}/guess/leaderboard", a.handleGuessLeaderboard).Methods("GET")
+	protected.HandleFunc("/guilds/{guild_id}/guess/leaderboard/teams", a.handleGuessTeamLeaderboard).Methods("GET")
+	protected.HandleFunc("/guilds/{guild_id}/guess/sessions", a.handleGuessSessions).Methods("GET")
+	protected.HandleFunc("/guilds/{guild_id}/guess/sessions/{session_id}", a.handleGuessSession).Methods("GET")
+	protected.HandleFunc("/guilds/{guild_id}/guess/pool/import", a.handleGuessPoolImport).Methods("POST")
 }
 
-func (a *API) Start() error {
+func (a *API) handler() http.Handler {
 	// Setup CORS - allow all origins for development, restrict in production
 	// Note: When AllowedOrigins is "*", AllowCredentials must be false for security
 	corsOptions := cors.Options{
@@ -76,8 +112,19 @@ func (a *API) Start() error {
 	// For production, set specific origins and enable credentials:
 	// Example: AllowedOrigins: []string{"https://yourdomain.com"}, AllowCredentials: true
 
-	handler := cors.New(corsOptions).Handler(a.router)
+	return cors.New(corsOptions).Handler(a.router)
+}
+
+// Start serves until Shutdown is called. It returns nil after a graceful shutdown.
+func (a *API) Start() error {
+	slog.Info("API server listening", "addr", "http://"+a.config.WebBind)
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
 
-	log.Printf("API server listening on http://%s", a.config.WebBind)
-	return http.ListenAndServe(a.config.WebBind, handler)
+// Shutdown stops accepting connections and waits for in-flight requests until ctx expires.
+func (a *API) Shutdown(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
 }
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
 )
 
 type DiscordUser struct {
@@ -17,6 +20,29 @@ type DiscordGuild struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Owner *bool  `json:"owner,omitempty"`
+	// Permissions is the user's permission bit set in the guild, as a decimal string.
+	Permissions string `json:"permissions,omitempty"`
+}
+
+// CanManage reports whether the user owns the guild or has the Manage Server permission there,
+// which the /settings command requires.
+func (g DiscordGuild) CanManage() bool {
+	if g.Owner != nil && *g.Owner {
+		return true
+	}
+	perms, err := strconv.ParseInt(g.Permissions, 10, 64)
+	if err != nil {
+		return false
+	}
+	return perms&(discordgo.PermissionManageServer|discordgo.PermissionAdministrator) != 0
+}
+
+// discordHTTP is the client for Discord API calls made with the user's access token.
+func (a *API) discordHTTP() *http.Client {
+	if a.discordClient != nil {
+		return a.discordClient
+	}
+	return http.DefaultClient
 }
 
 func (a *API) getDiscordUser(accessToken string) (*DiscordUser, error) {
@@ -27,7 +53,7 @@ func (a *API) getDiscordUser(accessToken string) (*DiscordUser, error) {
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("User-Agent", "nkmzbot/1.0 (+https://github.com/susu3304/nkmzbot)")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := a.discordHTTP().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +80,7 @@ func (a *API) getDiscordGuilds(accessToken string) ([]DiscordGuild, error) {
 	req.Header.Set("User-Agent", "nkmzbot/1.0 (+https://github.com/susu3304/nkmzbot)")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := a.discordHTTP().Do(req)
 	if err != nil {
 		return nil, err
 	}
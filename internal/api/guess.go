@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+// leaderboardOptions reads the period, sort and limit query parameters of a leaderboard request.
+func leaderboardOptions(w http.ResponseWriter, r *http.Request) (guess.LeaderboardOptions, bool) {
+	q := r.URL.Query()
+	var opts guess.LeaderboardOptions
+	var err error
+	if opts.Period, err = guess.ParsePeriod(q.Get("period")); err != nil {
+		http.Error(w, "invalid period", http.StatusBadRequest)
+		return opts, false
+	}
+	if opts.Sort, err = guess.ParseLeaderboardSort(q.Get("sort")); err != nil {
+		http.Error(w, "invalid sort", http.StatusBadRequest)
+		return opts, false
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > guess.MaxLeaderboardLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return opts, false
+		}
+		opts.Limit = limit
+	}
+	return opts, true
+}
+
+func (a *API) handleGuessLeaderboard(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.guildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	opts, ok := leaderboardOptions(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := a.guess.Leaderboard(context.Background(), guildID, opts)
+	if err != nil {
+		http.Error(w, "failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []guess.LeaderboardEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"period":  opts.Period,
+		"sort":    opts.Sort,
+		"entries": entries,
+	})
+}
+
+func (a *API) handleGuessTeamLeaderboard(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.guildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	opts, ok := leaderboardOptions(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := a.guess.TeamLeaderboard(context.Background(), guildID, opts)
+	if err == guess.ErrTeamLeaderboardSort {
+		http.Error(w, "invalid sort", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load leaderboard", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []guess.TeamLeaderboardEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"period":  opts.Period,
+		"sort":    opts.Sort,
+		"entries": entries,
+	})
+}
+
+func (a *API) handleGuessSessions(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.guildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	var before int64
+	if v := q.Get("before"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		before = id
+	}
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > guess.MaxHistoryLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	games, err := a.guess.History(context.Background(), guildID, before, limit)
+	if err != nil {
+		http.Error(w, "failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+	if games == nil {
+		games = []guess.GameSummary{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sessions": games,
+	})
+}
+
+// handleGuessSession returns a game with its answered rounds, or with format=geojson its
+// answers and guesses as a GeoJSON download.
+func (a *API) handleGuessSession(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.guildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	sessionID, err := strconv.ParseInt(mux.Vars(r)["session_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid session_id", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "geojson" {
+		http.Error(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+
+	game, err := a.guess.Game(context.Background(), guildID, sessionID)
+	if err == guess.ErrGameNotFound {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load session", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "geojson" {
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="guess-%d.geojson"`, game.ID))
+		json.NewEncoder(w).Encode(game.GeoJSON())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(game)
+}
+
+const (
+	// maxPoolImportBytes bounds the body of a pool import.
+	maxPoolImportBytes = 4 << 20
+	// poolResolveTimeout bounds how long a pool import spends expanding short links.
+	poolResolveTimeout = time.Minute
+)
+
+// handleGuessPoolImport adds the locations of a CSV or GeoJSON body to the guild's location
+// pool as the user. The format is taken from format=csv|geojson, or else the Content-Type.
+func (a *API) handleGuessPoolImport(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.guildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	claims := r.Context().Value("claims").(*Claims)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = "csv"
+		case "application/geo+json", "application/json":
+			format = "geojson"
+		}
+	}
+	parse := guess.ParsePoolCSV
+	switch format {
+	case "csv":
+	case "geojson":
+		parse = guess.ParsePoolGeoJSON
+	default:
+		http.Error(w, "unsupported format: use text/csv or application/geo+json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	entries, err := parse(http.MaxBytesReader(w, r.Body, maxPoolImportBytes))
+	if err != nil {
+		http.Error(w, "invalid "+format+": "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := guess.ValidatePoolEntries(entries); err != nil {
+		http.Error(w, "invalid locations: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), poolResolveTimeout)
+	defer cancel()
+	if err := guess.ResolvePoolEntries(ctx, entries); err != nil {
+		http.Error(w, "failed to resolve locations: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ids, err := a.guess.AddPoolLocations(context.Background(), guildID, claims.UserID, entries)
+	if err != nil {
+		http.Error(w, "failed to import locations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"imported": len(ids),
+		"ids":      ids,
+	})
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGuessHistoryPage lists the guild's past guess games with their rounds, loaded from the
+// sessions API.
+func (a *API) handleGuessHistoryPage(w http.ResponseWriter, r *http.Request) {
+	guildID := mux.Vars(r)["guild_id"]
+	if _, err := strconv.ParseInt(guildID, 10, 64); err != nil {
+		http.Error(w, "invalid guild_id", http.StatusBadRequest)
+		return
+	}
+
+	html := `<!DOCTYPE html>
+<html lang="ja">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>nkmzbot - ジオゲッサーの履歴</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+            min-height: 100vh;
+            padding: 20px;
+        }
+        .container {
+            max-width: 1200px;
+            margin: 0 auto;
+        }
+        header {
+            text-align: center;
+            color: white;
+            margin-bottom: 40px;
+        }
+        h1 {
+            font-size: 2.5rem;
+            margin-bottom: 10px;
+            text-shadow: 2px 2px 4px rgba(0,0,0,0.3);
+        }
+        .subtitle {
+            font-size: 1.1rem;
+            opacity: 0.9;
+        }
+        .game-card {
+            background: white;
+            padding: 20px;
+            border-radius: 10px;
+            box-shadow: 0 5px 15px rgba(0,0,0,0.1);
+            margin-bottom: 20px;
+        }
+        .game-header {
+            display: flex;
+            flex-wrap: wrap;
+            gap: 10px 20px;
+            align-items: center;
+            cursor: pointer;
+        }
+        .game-title {
+            font-size: 1.2rem;
+            font-weight: bold;
+            color: #667eea;
+        }
+        .game-meta {
+            color: #666;
+            font-size: 0.9rem;
+        }
+        .game-status {
+            font-size: 0.8rem;
+            padding: 2px 10px;
+            border-radius: 10px;
+            background: #eee;
+            color: #555;
+        }
+        .game-status.active {
+            background: #e3f7e3;
+            color: #2a7a2a;
+        }
+        .game-detail {
+            margin-top: 15px;
+            border-top: 1px solid #eee;
+            padding-top: 15px;
+        }
+        .round {
+            margin-bottom: 20px;
+        }
+        .round h3 {
+            font-size: 1rem;
+            color: #333;
+            margin-bottom: 8px;
+        }
+        table {
+            width: 100%;
+            border-collapse: collapse;
+            font-size: 0.9rem;
+        }
+        th, td {
+            text-align: left;
+            padding: 6px 8px;
+            border-bottom: 1px solid #f0f0f0;
+            word-break: break-all;
+        }
+        th {
+            color: #888;
+            font-weight: normal;
+        }
+        a {
+            color: #667eea;
+        }
+        .export-link {
+            display: inline-block;
+            margin-bottom: 15px;
+        }
+        button {
+            padding: 12px 30px;
+            background: #667eea;
+            color: white;
+            border: none;
+            border-radius: 8px;
+            font-size: 1rem;
+            cursor: pointer;
+            transition: background 0.3s;
+        }
+        button:hover {
+            background: #5568d3;
+        }
+        .more {
+            text-align: center;
+        }
+        .loading, .empty {
+            text-align: center;
+            color: white;
+            font-size: 1.2rem;
+            padding: 40px;
+        }
+        .error {
+            background: #ff5252;
+            color: white;
+            padding: 20px;
+            border-radius: 10px;
+            text-align: center;
+            margin-bottom: 20px;
+        }
+        .back-link {
+            display: inline-block;
+            color: white;
+            text-decoration: none;
+            margin-bottom: 20px;
+            font-size: 1rem;
+        }
+        .back-link:hover {
+            text-decoration: underline;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <a href="/guilds/` + guildID + `" class="back-link">← コマンド一覧に戻る</a>
+
+        <header>
+            <h1>🌏 ジオゲッサー</h1>
+            <p class="subtitle">過去のゲーム</p>
+        </header>
+
+        <div id="error"></div>
+        <div id="games"></div>
+        <div id="loading" class="loading" style="display: none;">読み込み中...</div>
+        <div class="more"><button id="more" style="display: none;" onclick="loadGames()">もっと見る</button></div>
+    </div>
+
+    <script>
+        const guildId = '` + guildID + `';
+        const modeLabels = {distance: '距離', region: '国・都道府県当て', streak: 'ストリーク', duel: 'デュエル'};
+        let before = 0;
+
+        async function fetchJSON(url) {
+            const response = await fetch(url, {credentials: 'same-origin'});
+            if (response.status === 401) {
+                window.location.href = '/login';
+                throw new Error('ログインが必要です');
+            }
+            if (!response.ok) {
+                throw new Error('履歴の取得に失敗しました');
+            }
+            return response.json();
+        }
+
+        async function loadGames() {
+            const loading = document.getElementById('loading');
+            const more = document.getElementById('more');
+            loading.style.display = 'block';
+            more.style.display = 'none';
+            try {
+                let url = '/api/guilds/' + guildId + '/guess/sessions?limit=20';
+                if (before) {
+                    url += '&before=' + before;
+                }
+                const data = await fetchJSON(url);
+                loading.style.display = 'none';
+                const games = data.sessions;
+                if (games.length === 0 && !before) {
+                    document.getElementById('games').innerHTML = '<div class="empty">まだゲームがありません</div>';
+                    return;
+                }
+                games.forEach(renderGame);
+                if (games.length === 20) {
+                    before = games[games.length - 1].id;
+                    more.style.display = 'inline-block';
+                }
+            } catch (error) {
+                loading.style.display = 'none';
+                showError(error.message);
+            }
+        }
+
+        function renderGame(game) {
+            const card = document.createElement('div');
+            card.className = 'game-card';
+            const active = game.status === 'active';
+            card.innerHTML =
+                '<div class="game-header">' +
+                    '<span class="game-title">#' + game.id + ' ' + escapeHtml(game.map_label) + '</span>' +
+                    '<span class="game-meta">' + formatDate(game.created_at) + '</span>' +
+                    '<span class="game-meta">' + escapeHtml(modeLabels[game.mode] || game.mode) + '</span>' +
+                    '<span class="game-meta">' + game.rounds_played + 'ラウンド / ' + game.players + '人</span>' +
+                    '<span class="game-status' + (active ? ' active' : '') + '">' + (active ? '進行中' : '終了') + '</span>' +
+                '</div>' +
+                '<div class="game-detail" style="display: none;"></div>';
+            const detail = card.querySelector('.game-detail');
+            card.querySelector('.game-header').addEventListener('click', () => toggleGame(game.id, detail));
+            document.getElementById('games').appendChild(card);
+        }
+
+        async function toggleGame(id, detail) {
+            if (detail.style.display !== 'none') {
+                detail.style.display = 'none';
+                return;
+            }
+            detail.style.display = 'block';
+            if (detail.dataset.loaded) {
+                return;
+            }
+            detail.textContent = '読み込み中...';
+            try {
+                const url = '/api/guilds/' + guildId + '/guess/sessions/' + id;
+                const game = await fetchJSON(url);
+                detail.dataset.loaded = 'true';
+                let html = '<a class="export-link" href="' + url + '?format=geojson">📥 GeoJSON をダウンロード</a>';
+                if (game.rounds.length === 0) {
+                    html += '<p>正解が発表されたラウンドはありません</p>';
+                }
+                game.rounds.forEach(round => {
+                    html += '<div class="round"><h3>ラウンド ' + round.round + ' — 正解: ' + link(round.answer_url) + '</h3>';
+                    if (round.guesses.length === 0) {
+                        html += '<p>推測はありませんでした</p></div>';
+                        return;
+                    }
+                    html += '<table><tr><th>順位</th><th>ユーザー</th><th>スコア</th><th>距離</th><th>推測</th></tr>';
+                    round.guesses.forEach((guess, idx) => {
+                        html += '<tr><td>' + (idx + 1) + '</td>' +
+                            '<td>' + escapeHtml(guess.user_id) + '</td>' +
+                            '<td>' + (guess.score + guess.time_bonus) + '点</td>' +
+                            '<td>' + formatDistance(guess.distance_meters) + '</td>' +
+                            '<td>' + link(guess.url) + '</td></tr>';
+                    });
+                    html += '</table></div>';
+                });
+                detail.innerHTML = html;
+            } catch (error) {
+                detail.textContent = error.message;
+            }
+        }
+
+        function link(url) {
+            if (!/^https?:\/\//.test(url)) {
+                return escapeHtml(url);
+            }
+            return '<a href="' + escapeHtml(url) + '" target="_blank" rel="noopener noreferrer">' + escapeHtml(url) + '</a>';
+        }
+
+        function formatDistance(meters) {
+            if (meters < 1000) {
+                return Math.round(meters) + ' m';
+            }
+            return (meters / 1000).toFixed(meters < 10000 ? 2 : 1) + ' km';
+        }
+
+        function formatDate(value) {
+            return new Date(value).toLocaleString('ja-JP');
+        }
+
+        function showError(message) {
+            document.getElementById('error').innerHTML = '<div class="error">' + escapeHtml(message) + '</div>';
+        }
+
+        function escapeHtml(text) {
+            const div = document.createElement('div');
+            div.textContent = text;
+            return div.innerHTML.replace(/"/g, '&quot;');
+        }
+
+        loadGames();
+    </script>
+</body>
+</html>`
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
@@ -610,6 +610,7 @@ func (a *API) handleCommandListPage(w http.ResponseWriter, r *http.Request) {
             <div class="stats">
                 <span id="commandCount">コマンド数: -</span>
                 <span id="guildInfo">Guild ID: ` + guildID + `</span>
+                <a href="/guilds/` + guildID + `/guess">🌏 ジオゲッサーの履歴</a>
             </div>
         </div>
 
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// AddReadinessCheck registers a dependency that must be healthy for /readyz to succeed.
+func (a *API) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	a.readiness = append(a.readiness, readinessCheck{name: name, check: check})
+}
+
+// handleHealthz reports liveness: the process is serving and can reach the database.
+func (a *API) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	a.writeChecks(w, r, []readinessCheck{{name: "database", check: a.db.Ping}})
+}
+
+// handleReadyz reports readiness: the database and every registered dependency are healthy.
+func (a *API) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := append([]readinessCheck{{name: "database", check: a.db.Ping}}, a.readiness...)
+	a.writeChecks(w, r, checks)
+}
+
+func (a *API) writeChecks(w http.ResponseWriter, r *http.Request, checks []readinessCheck) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	results := make(map[string]string, len(checks))
+	for _, c := range checks {
+		if err := c.check(ctx); err != nil {
+			results[c.name] = err.Error()
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		results[c.name] = "ok"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": http.StatusText(status),
+		"checks": results,
+	})
+}
+
+// metricsAuth lets through only requests that send the metrics token as a bearer token.
+func (a *API) metricsAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), a.metricsToken) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid metrics token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMetricsRequireToken(t *testing.T) {
+	tests := []struct {
+		name, token, auth string
+		want              int
+	}{
+		{"disabled", "", "", http.StatusNotFound},
+		{"disabled with header", "", "Bearer ", http.StatusNotFound},
+		{"missing", "s3cret", "", http.StatusUnauthorized},
+		{"wrong", "s3cret", "Bearer nope", http.StatusUnauthorized},
+		{"not bearer", "s3cret", "s3cret", http.StatusUnauthorized},
+		{"valid", "s3cret", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		a := &API{router: mux.NewRouter(), metricsToken: []byte(tt.token)}
+		a.setupRoutes()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
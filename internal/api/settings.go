@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/susu3304/nkmzbot/internal/commands"
+	"github.com/susu3304/nkmzbot/internal/db"
+)
+
+// guildIDFromRequest parses the guild_id path variable and checks the caller can access the guild.
+// On failure it writes the error response and returns false.
+func (a *API) guildIDFromRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	claims := r.Context().Value("claims").(*Claims)
+	guildID, err := strconv.ParseInt(mux.Vars(r)["guild_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid guild_id", http.StatusBadRequest)
+		return 0, false
+	}
+	if !a.userHasGuildAccess(claims.AccessToken, guildID) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return 0, false
+	}
+	return guildID, true
+}
+
+// managedGuildIDFromRequest is guildIDFromRequest for changes that /settings limits to server
+// managers: the caller also needs the Manage Server permission in the guild.
+func (a *API) managedGuildIDFromRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	claims := r.Context().Value("claims").(*Claims)
+	guildID, err := strconv.ParseInt(mux.Vars(r)["guild_id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid guild_id", http.StatusBadRequest)
+		return 0, false
+	}
+	guilds, err := a.getDiscordGuilds(claims.AccessToken)
+	if err != nil {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return 0, false
+	}
+	for _, g := range guilds {
+		if g.ID != strconv.FormatInt(guildID, 10) {
+			continue
+		}
+		if !g.CanManage() {
+			http.Error(w, "manage server permission required", http.StatusForbidden)
+			return 0, false
+		}
+		return guildID, true
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return 0, false
+}
+
+func (a *API) handleListReplySettings(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.guildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	settings, err := a.db.ListReplySettings(context.Background(), guildID)
+	if err != nil {
+		http.Error(w, "failed to list reply settings", http.StatusInternalServerError)
+		return
+	}
+	if settings == nil {
+		settings = []db.ReplySetting{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings": settings,
+		"defaults": commands.DefaultVisibilities(),
+		"targets":  commands.ReplyTargets(),
+	})
+}
+
+func (a *API) handleSetReplySetting(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.managedGuildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	command := mux.Vars(r)["command"]
+	if !commands.IsReplyTarget(command) {
+		http.Error(w, "unknown command", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Visibility string `json:"visibility"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	vis, err := commands.ParseVisibility(req.Visibility)
+	if err != nil {
+		http.Error(w, "visibility must be one of public, ephemeral, dm", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.db.SetReplySetting(context.Background(), guildID, command, string(vis)); err != nil {
+		http.Error(w, "failed to save reply setting", http.StatusInternalServerError)
+		return
+	}
+	commands.InvalidateReplySettings(guildID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "reply setting updated",
+	})
+}
+
+func (a *API) handleDeleteReplySetting(w http.ResponseWriter, r *http.Request) {
+	guildID, ok := a.managedGuildIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.db.DeleteReplySetting(context.Background(), guildID, mux.Vars(r)["command"]); err != nil {
+		http.Error(w, "reply setting not found", http.StatusNotFound)
+		return
+	}
+	commands.InvalidateReplySettings(guildID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "reply setting reset",
+	})
+}
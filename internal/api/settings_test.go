@@ -0,0 +1,78 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// guildsTransport answers the Discord guild list with the guild permissions the access token
+// names.
+type guildsTransport map[string]string
+
+func (gt guildsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := "[]"
+	if perms, ok := gt[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")]; ok {
+		body = `[{"id": "123", "name": "guild", "permissions": "` + perms + `"}]`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestReplySettingsRequireManageServer(t *testing.T) {
+	a := &API{
+		router:    mux.NewRouter(),
+		jwtSecret: []byte("secret"),
+		discordClient: &http.Client{Transport: guildsTransport{
+			"member":  "1024", // View Channel
+			"manager": "32",   // Manage Server
+			"admin":   "8",    // Administrator
+		}},
+	}
+	a.setupRoutes()
+
+	token := func(accessToken string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+			UserID:      "1",
+			AccessToken: accessToken,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}).SignedString(a.jwtSecret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		method, user string
+		want         int
+	}{
+		{http.MethodPut, "member", http.StatusForbidden},
+		{http.MethodDelete, "member", http.StatusForbidden},
+		{http.MethodPut, "stranger", http.StatusForbidden},
+		// Managers get past the permission check to the body validation.
+		{http.MethodPut, "manager", http.StatusBadRequest},
+		{http.MethodPut, "admin", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/guilds/123/settings/replies/nomikai", strings.NewReader(`{"visibility": "loud"}`))
+		req.Header.Set("Authorization", "Bearer "+token(tt.user))
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s by %s = %d %q, want %d", tt.method, tt.user, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+}
@@ -2,8 +2,9 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/susu3304/nkmzbot/internal/commands"
 	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/geourl"
 	"github.com/susu3304/nkmzbot/internal/guess"
 	"github.com/susu3304/nkmzbot/internal/nomikai"
 )
@@ -21,6 +23,8 @@ type Bot struct {
 	nomikai  *nomikai.Service
 	guess    *guess.Service
 	reminder *reminderWorker
+	timers   *guessTimerWorker
+	daily    *guessDailyWorker
 }
 
 func New(token string, database *db.DB) (*Bot, error) {
@@ -56,6 +60,10 @@ func New(token string, database *db.DB) (*Bot, error) {
 		guess:   guess.NewService(database),
 	}
 	bot.reminder = newReminderWorker(session, database, bot.nomikai)
+	bot.timers = newGuessTimerWorker(session, bot.guess)
+	bot.daily = newGuessDailyWorker(session, bot.guess)
+	commands.SetReplySettingsStore(database)
+	geourl.SetDefaultExpander(geourl.NewExpander(geourl.Options{Cache: database}))
 
 	// Register event handlers
 	session.AddHandler(bot.onReady)
@@ -72,21 +80,40 @@ func (b *Bot) Start() error {
 	if err := b.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
 	}
-	log.Println("Discord bot is running")
+	slog.Info("discord bot is running")
 	
 	// Restore scheduled tasks from database
 	ctx := context.Background()
 	if err := commands.RestoreScheduledTasks(ctx, b.session, b.nomikai, b.db); err != nil {
-		log.Printf("Warning: failed to restore scheduled tasks: %v", err)
+		slog.Warn("failed to restore scheduled tasks", "err", err)
 	}
 	
 	b.reminder.start()
+	b.timers.start()
+	b.daily.start()
 	return nil
 }
 
-func (b *Bot) Stop() error {
-	if b.reminder != nil {
-		b.reminder.stop()
-	}
-	return b.session.Close()
+// Connected reports whether the gateway connection is up.
+func (b *Bot) Connected() bool {
+	b.session.RLock()
+	defer b.session.RUnlock()
+	return b.session.DataReady
+}
+
+// HeartbeatLatency returns the round trip of the latest gateway heartbeat.
+func (b *Bot) HeartbeatLatency() time.Duration {
+	return b.session.HeartbeatLatency()
+}
+
+// Stop closes the gateway so no new interactions arrive, then waits for the reminder, guess
+// timer and daily challenge workers' current ticks and any running scheduled tasks to finish, or
+// until ctx expires.
+func (b *Bot) Stop(ctx context.Context) error {
+	closeErr := b.session.Close()
+	reminderErr := b.reminder.stop(ctx)
+	timerErr := b.timers.stop(ctx)
+	dailyErr := b.daily.stop(ctx)
+	schedulerErr := commands.StopScheduledTasks(ctx)
+	return errors.Join(closeErr, reminderErr, timerErr, dailyErr, schedulerErr)
 }
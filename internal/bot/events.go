@@ -3,29 +3,32 @@ package bot
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/susu3304/nkmzbot/internal/commands"
+	"github.com/susu3304/nkmzbot/internal/metrics"
+	"github.com/susu3304/nkmzbot/internal/nomikai"
 )
 
 func (b *Bot) onReady(s *discordgo.Session, event *discordgo.Ready) {
-	log.Printf("%s is connected!", event.User.Username)
+	slog.Info("connected to discord", "user", event.User.Username, "guilds", len(event.Guilds))
 
 	// Register commands for all guilds
 	for _, guild := range event.Guilds {
 		if err := b.registerGuildCommands(guild.ID); err != nil {
-			log.Printf("Failed to register commands for guild %s: %v", guild.ID, err)
+			slog.Error("failed to register commands", "guild_id", guild.ID, "err", err)
 		}
 	}
 }
 
 func (b *Bot) onGuildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
-	log.Printf("Guild available/joined: %s (id=%s) — ensuring commands", event.Name, event.ID)
+	slog.Info("guild available, ensuring commands", "guild_id", event.ID, "guild", event.Name)
 	if err := b.registerGuildCommands(event.ID); err != nil {
-		log.Printf("Failed to register commands for guild %s: %v", event.ID, err)
+		slog.Error("failed to register commands", "guild_id", event.ID, "err", err)
 	}
 }
 
@@ -37,30 +40,21 @@ func (b *Bot) registerGuildCommands(guildID string) error {
 		return err
 	}
 
-	log.Printf("Registered application commands for guild %s", guildID)
+	slog.Info("registered application commands", "guild_id", guildID)
 	return nil
 }
 
 func (b *Bot) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	// Ignore bot messages
-	if m.Author.Bot {
-		return
-	}
-
-	content := strings.TrimSpace(m.Content)
-	if strings.HasPrefix(content, "!") && len(content) > 1 {
-		cmdName := content[1:]
-		if m.GuildID != "" {
-			guildID := commands.ParseGuildID(m.GuildID)
-			cmd, err := b.db.GetCommand(context.Background(), guildID, cmdName)
-			if err == nil && cmd != nil {
-				s.ChannelMessageSend(m.ChannelID, cmd.Response)
-			}
-		}
-	}
+	commands.HandleCustomCommand(s, m, b.db)
 }
 
 func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	started := time.Now()
+	defer func() {
+		metrics.ObserveHandler(metricLabel(i), i.Type.String(), started)
+		commands.InteractionLogger(i).Info("interaction handled", "type", i.Type.String(), "duration", time.Since(started))
+	}()
+
 	switch i.Type {
 	case discordgo.InteractionApplicationCommand:
 		b.handleApplicationCommand(s, i)
@@ -71,8 +65,31 @@ func (b *Bot) onInteractionCreate(s *discordgo.Session, i *discordgo.Interaction
 	}
 }
 
+// metricLabel returns a low-cardinality name for i: the top-level command, or the modal ID prefix.
+func metricLabel(i *discordgo.InteractionCreate) string {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		return i.ApplicationCommandData().Name
+	case discordgo.InteractionModalSubmit:
+		id := i.ModalSubmitData().CustomID
+		if idx := strings.Index(id, ":"); idx >= 0 {
+			return id[:idx]
+		}
+		return id
+	}
+	return "unknown"
+}
+
 func (b *Bot) handleApplicationCommandAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	data := i.ApplicationCommandData()
+	switch data.Name {
+	case "settings":
+		commands.HandleSettingsAutocomplete(s, i)
+		return
+	case "guess":
+		commands.HandleGuessAutocomplete(s, i, b.guess)
+		return
+	}
 	if data.Name != "nomikai" {
 		return
 	}
@@ -80,9 +97,6 @@ func (b *Bot) handleApplicationCommandAutocomplete(s *discordgo.Session, i *disc
 		return
 	}
 	sub := data.Options[0]
-	if sub.Name != "seisan" {
-		return
-	}
 
 	// Find focused option in the subcommand.
 	focusedName := ""
@@ -94,12 +108,21 @@ func (b *Bot) handleApplicationCommandAutocomplete(s *discordgo.Session, i *disc
 			break
 		}
 	}
-	if focusedName != "amount" {
+	userID := ""
+	if i.Member != nil && i.Member.User != nil {
+		userID = i.Member.User.ID
+	}
+	if focusedName == "event" {
+		b.autocompleteNomikaiEvent(s, i, userID, userInput)
+		return
+	}
+	if sub.Name != "seisan" || focusedName != "amount" {
 		return
 	}
 
 	payeeID := ""
 	payerID := ""
+	target := nomikai.Target{ChannelID: i.ChannelID, UserID: userID}
 	for _, opt := range sub.Options {
 		switch opt.Name {
 		case "to":
@@ -110,10 +133,12 @@ func (b *Bot) handleApplicationCommandAutocomplete(s *discordgo.Session, i *disc
 			if id, ok := opt.Value.(string); ok {
 				payerID = id
 			}
+		case "event":
+			target.Event = opt.StringValue()
 		}
 	}
-	if payerID == "" && i.Member != nil && i.Member.User != nil {
-		payerID = i.Member.User.ID
+	if payerID == "" {
+		payerID = userID
 	}
 
 	choices := []*discordgo.ApplicationCommandOptionChoice{
@@ -122,7 +147,7 @@ func (b *Bot) handleApplicationCommandAutocomplete(s *discordgo.Session, i *disc
 
 	// If we can compute outstanding amount for the pair, also offer it as a one-click numeric choice.
 	if payerID != "" && payeeID != "" {
-		ev, err := b.db.ActiveEventByChannel(context.Background(), i.ChannelID)
+		ev, err := b.nomikai.ResolveEvent(context.Background(), target)
 		if err == nil && ev != nil {
 			out, err := b.db.OutstandingSettlementAmount(context.Background(), ev.ID, payerID, payeeID)
 			if err == nil && out > 0 {
@@ -147,6 +172,41 @@ func (b *Bot) handleApplicationCommandAutocomplete(s *discordgo.Session, i *disc
 	})
 }
 
+// autocompleteNomikaiEvent offers the channel's active events, the user's current one first.
+func (b *Bot) autocompleteNomikaiEvent(s *discordgo.Session, i *discordgo.InteractionCreate, userID, input string) {
+	events, current, err := b.nomikai.ActiveEvents(context.Background(), i.ChannelID, userID)
+	if err != nil {
+		slog.Warn("nomikai: failed to list events for autocomplete", "channel_id", i.ChannelID, "err", err)
+	}
+	input = strings.ToLower(strings.TrimSpace(input))
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, ev := range events {
+		ref := fmt.Sprintf("#%d", ev.ID)
+		label := ref
+		if ev.Name != "" {
+			ref = ev.Name
+			label += " " + ev.Name
+		}
+		if input != "" && !strings.Contains(strings.ToLower(label), input) {
+			continue
+		}
+		choice := &discordgo.ApplicationCommandOptionChoice{Name: label, Value: ref}
+		if ev.ID == current {
+			choice.Name += "（選択中）"
+			choices = append([]*discordgo.ApplicationCommandOptionChoice{choice}, choices...)
+			continue
+		}
+		choices = append(choices, choice)
+	}
+	if len(choices) > 25 {
+		choices = choices[:25]
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+}
+
 func (b *Bot) handleApplicationCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	data := i.ApplicationCommandData()
 
@@ -165,11 +225,17 @@ func (b *Bot) handleApplicationCommand(s *discordgo.Session, i *discordgo.Intera
 		commands.HandleGuess(s, i, b.guess)
 	case "jikan":
 		commands.HandleJikan(s, i, b.nomikai, b.db)
+	case "settings":
+		commands.HandleSettings(s, i, b.db)
 	case "Register as Response":
 		commands.HandleRegisterAsResponse(s, i)
 	}
 }
 
 func (b *Bot) handleModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if commands.IsGuessModal(i.ModalSubmitData().CustomID) {
+		commands.HandleGuessModalSubmit(s, i, b.guess)
+		return
+	}
 	commands.HandleModalSubmit(s, i, b.db)
 }
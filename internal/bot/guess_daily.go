@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/susu3304/nkmzbot/internal/commands"
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+// guessDailyWorker posts each guild's daily challenge once its post time has passed, after
+// announcing the results of the previous one. Which days have run is stored, so a restart
+// neither skips nor repeats a challenge.
+type guessDailyWorker struct {
+	guess    *guess.Service
+	session  guessTimerSession
+	stopChan chan struct{}
+	done     chan struct{}
+	ticker   *time.Ticker
+	interval time.Duration
+	// busy remembers the day a guild's channel was taken by another game, so it is logged once.
+	busy map[int64]time.Time
+}
+
+func newGuessDailyWorker(session guessTimerSession, svc *guess.Service) *guessDailyWorker {
+	return &guessDailyWorker{
+		guess:    svc,
+		session:  session,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+		interval: time.Minute,
+		busy:     make(map[int64]time.Time),
+	}
+}
+
+func (w *guessDailyWorker) start() {
+	if w == nil {
+		return
+	}
+	w.ticker = time.NewTicker(w.interval)
+	go w.loop()
+}
+
+// stop ends the loop and waits for an in-flight tick to finish, or until ctx expires.
+func (w *guessDailyWorker) stop(ctx context.Context) error {
+	if w == nil || w.ticker == nil {
+		return nil
+	}
+	close(w.stopChan)
+	w.ticker.Stop()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *guessDailyWorker) loop() {
+	defer close(w.done)
+	ctx := context.Background()
+	// Catch up right away instead of a minute after a restart.
+	w.tick(ctx)
+	for {
+		select {
+		case <-w.ticker.C:
+			w.tick(ctx)
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+func (w *guessDailyWorker) tick(ctx context.Context) {
+	now := time.Now()
+	due, err := w.guess.DueDailyChallenges(ctx, now)
+	if err != nil {
+		slog.Error("guess daily: failed to load due challenges", "err", err)
+		return
+	}
+	date := guess.DailyDate(now)
+	for _, ds := range due {
+		w.run(ctx, ds, date)
+	}
+}
+
+// run closes the guild's open challenge and posts its results, then starts the day's challenge.
+func (w *guessDailyWorker) run(ctx context.Context, ds guess.DailySettings, date time.Time) {
+	results, err := w.guess.CloseDailyChallenges(ctx, ds.GuildID)
+	if err != nil {
+		slog.Error("guess daily: failed to close challenges", "guild_id", ds.GuildID, "err", err)
+		return
+	}
+	for _, res := range results {
+		w.send(res.ChannelID, commands.FormatDailyResult(&res), res.Result)
+	}
+
+	ch, err := w.guess.StartDailyChallenge(ctx, ds.GuildID, date)
+	switch err {
+	case nil:
+		delete(w.busy, ds.GuildID)
+		w.send(ch.ChannelID, commands.FormatDailyChallenge(ch), nil)
+	case guess.ErrPoolEmpty:
+		w.send(ds.ChannelID, "📅 今日のデイリーチャレンジはお休みです\n"+err.Error(), nil)
+	case guess.ErrSessionAlreadyExists:
+		// Retried every tick until the game in the channel ends.
+		if !w.busy[ds.GuildID].Equal(date) {
+			w.busy[ds.GuildID] = date
+			slog.Info("guess daily: channel has a game running, waiting", "guild_id", ds.GuildID, "channel_id", ds.ChannelID)
+		}
+	case guess.ErrDailyDone, guess.ErrDailyNotConfigured:
+	default:
+		slog.Error("guess daily: failed to start challenge", "guild_id", ds.GuildID, "err", err)
+	}
+}
+
+// send posts content, with the map of res if it is set, logging failures.
+func (w *guessDailyWorker) send(channelID, content string, res *guess.RoundResult) {
+	var err error
+	if res != nil {
+		err = sendGuessMessage(w.session, channelID, content, commands.RoundResultFiles(res)...)
+	} else {
+		err = sendGuessMessage(w.session, channelID, content)
+	}
+	if err != nil {
+		slog.Error("guess daily: failed to send message", "channel_id", channelID, "err", err)
+	}
+}
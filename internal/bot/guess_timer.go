@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/commands"
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+// guessWarnings are the countdown announcements posted before a round's deadline, in seconds.
+// Only the smallest one that applies is posted, so a late tick doesn't post several at once.
+var guessWarnings = []int{10, 60}
+
+// guessTimerWorker posts countdowns for timed guess rounds and closes them at the deadline.
+// Deadlines and posted announcements are stored with the round, so a restart picks up where it left off.
+type guessTimerWorker struct {
+	guess    *guess.Service
+	session  guessTimerSession
+	stopChan chan struct{}
+	done     chan struct{}
+	ticker   *time.Ticker
+	interval time.Duration
+}
+
+// guessTimerSession is the session interface the timer worker posts with; results carry a map image.
+type guessTimerSession interface {
+	reminderSession
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+func newGuessTimerWorker(session guessTimerSession, svc *guess.Service) *guessTimerWorker {
+	return &guessTimerWorker{
+		guess:    svc,
+		session:  session,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+		interval: time.Second,
+	}
+}
+
+func (w *guessTimerWorker) start() {
+	if w == nil {
+		return
+	}
+	w.ticker = time.NewTicker(w.interval)
+	go w.loop()
+}
+
+// stop ends the loop and waits for an in-flight tick to finish, or until ctx expires.
+func (w *guessTimerWorker) stop(ctx context.Context) error {
+	if w == nil || w.ticker == nil {
+		return nil
+	}
+	close(w.stopChan)
+	w.ticker.Stop()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *guessTimerWorker) loop() {
+	defer close(w.done)
+	ctx := context.Background()
+	for {
+		select {
+		case <-w.ticker.C:
+			w.tick(ctx)
+		case <-w.stopChan:
+			return
+		}
+	}
+}
+
+func (w *guessTimerWorker) tick(ctx context.Context) {
+	maxWarning := guessWarnings[len(guessWarnings)-1]
+	timers, err := w.guess.DueTimers(ctx, time.Duration(maxWarning)*time.Second)
+	if err != nil {
+		slog.Error("guess timer: failed to load timers", "err", err)
+		return
+	}
+	for _, t := range timers {
+		if t.Remaining <= 0 {
+			w.expire(ctx, t)
+			continue
+		}
+		w.warn(ctx, t)
+	}
+}
+
+func (w *guessTimerWorker) warn(ctx context.Context, t guess.RoundTimer) {
+	for _, secs := range guessWarnings {
+		// A round shorter than the announcement would get it right at the start.
+		if secs >= t.RoundSeconds || t.Remaining > time.Duration(secs)*time.Second {
+			continue
+		}
+		if t.LastWarning != nil && *t.LastWarning <= secs {
+			return
+		}
+		ok, err := w.guess.MarkWarned(ctx, t.SessionID, t.Round, secs)
+		if err != nil {
+			slog.Error("guess timer: failed to record warning", "session_id", t.SessionID, "round", t.Round, "err", err)
+			return
+		}
+		if ok {
+			w.send(t.ChannelID, formatGuessWarning(t))
+		}
+		return
+	}
+}
+
+func (w *guessTimerWorker) expire(ctx context.Context, t guess.RoundTimer) {
+	res, err := w.guess.ExpireRound(ctx, t.SessionID, t.Round)
+	if err != nil {
+		if err != guess.ErrRoundClosed {
+			slog.Error("guess timer: failed to close round", "session_id", t.SessionID, "round", t.Round, "err", err)
+		}
+		return
+	}
+	msg := "⌛ **時間切れ！** 推測を締め切りました。\n"
+	var files []*discordgo.File
+	if res == nil {
+		msg += "`/guess answer <URL>` で正解を発表してください"
+	} else {
+		msg += commands.FormatRoundResult(res)
+		files = commands.RoundResultFiles(res)
+	}
+	w.send(t.ChannelID, msg, files...)
+}
+
+// send posts content with files, logging failures.
+func (w *guessTimerWorker) send(channelID, content string, files ...*discordgo.File) {
+	if err := sendGuessMessage(w.session, channelID, content, files...); err != nil {
+		slog.Error("guess timer: failed to send message", "channel_id", channelID, "err", err)
+	}
+}
+
+// sendGuessMessage posts content, attaching files to the last chunk.
+func sendGuessMessage(session guessTimerSession, channelID, content string, files ...*discordgo.File) error {
+	chunks := commands.SplitMessage(content)
+	for idx, chunk := range chunks {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var err error
+		if idx == len(chunks)-1 && len(files) > 0 {
+			_, err = session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: chunk, Files: files}, discordgo.WithContext(ctx))
+		} else {
+			_, err = session.ChannelMessageSend(channelID, chunk, discordgo.WithContext(ctx))
+		}
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatGuessWarning(t guess.RoundTimer) string {
+	secs := int((t.Remaining + time.Second - 1) / time.Second)
+	round := ""
+	if t.TotalRounds > 1 {
+		round = guess.FormatRound(t.Round, t.TotalRounds, t.Mode) + " "
+	}
+	if secs > 30 {
+		return fmt.Sprintf("⏰ %s残り1分です", round)
+	}
+	return fmt.Sprintf("⏳ %s残り%d秒！", round, secs)
+}
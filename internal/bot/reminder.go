@@ -2,13 +2,14 @@ package bot
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/metrics"
 	"github.com/susu3304/nkmzbot/internal/nomikai"
 )
 
@@ -18,6 +19,7 @@ type reminderWorker struct {
 	nomikai  *nomikai.Service
 	session  reminderSession
 	stopChan chan struct{}
+	done     chan struct{}
 	ticker   *time.Ticker
 	interval time.Duration
 }
@@ -33,6 +35,7 @@ func newReminderWorker(session reminderSession, database *db.DB, svc *nomikai.Se
 		nomikai:  svc,
 		session:  session,
 		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
 		interval: time.Minute,
 	}
 }
@@ -45,17 +48,24 @@ func (w *reminderWorker) start() {
 	go w.loop()
 }
 
-func (w *reminderWorker) stop() {
-	if w == nil {
-		return
+// stop ends the loop and waits for an in-flight tick to finish, or until ctx expires.
+func (w *reminderWorker) stop(ctx context.Context) error {
+	if w == nil || w.ticker == nil {
+		return nil
 	}
 	close(w.stopChan)
-	if w.ticker != nil {
-		w.ticker.Stop()
+	w.ticker.Stop()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
 func (w *reminderWorker) loop() {
+	defer close(w.done)
+	// A tick in progress is allowed to finish during shutdown, so it doesn't share the stop signal.
 	ctx := context.Background()
 	for {
 		select {
@@ -71,14 +81,16 @@ func (w *reminderWorker) tick(ctx context.Context) {
 	now := time.Now()
 	targets, err := w.db.DueReminders(ctx, now)
 	if err != nil {
-		log.Printf("reminder: failed to load due reminders: %v", err)
+		metrics.ReminderFailures.WithLabelValues("load").Inc()
+		slog.Error("reminder: failed to load due reminders", "err", err)
 		return
 	}
 
 	for _, t := range targets {
-		msg, err := w.nomikai.ReminderMessageByEventID(ctx, t.EventID)
+		msg, err := w.nomikai.ReminderMessageByEventID(ctx, t.EventID, t.EventName)
 		if err != nil {
-			log.Printf("reminder: failed to build message for event %d: %v", t.EventID, err)
+			metrics.ReminderFailures.WithLabelValues("build").Inc()
+			slog.Error("reminder: failed to build message", "event_id", t.EventID, "err", err)
 			continue
 		}
 		if msg == "" {
@@ -86,7 +98,8 @@ func (w *reminderWorker) tick(ctx context.Context) {
 		}
 		autoMsg := msg + "\n\n※このメッセージは自動投稿です"
 		if err := w.sendWithRetry(ctx, t.ChannelID, autoMsg); err != nil {
-			log.Printf("reminder: failed to send message to channel %s: %v", t.ChannelID, err)
+			metrics.ReminderFailures.WithLabelValues("send").Inc()
+			slog.Error("reminder: failed to send message", "event_id", t.EventID, "channel_id", t.ChannelID, "err", err)
 			// Back off so we don't hammer Discord (or a bad edge) every minute.
 			backoff := 2 * time.Minute
 			if t.IntervalMinutes > 0 {
@@ -97,13 +110,16 @@ func (w *reminderWorker) tick(ctx context.Context) {
 			}
 			next := now.Add(backoff)
 			if derr := w.db.DelayReminder(ctx, t.EventID, next); derr != nil {
-				log.Printf("reminder: failed to delay reminder for event %d: %v", t.EventID, derr)
+				metrics.ReminderFailures.WithLabelValues("update").Inc()
+				slog.Error("reminder: failed to delay reminder", "event_id", t.EventID, "err", derr)
 			}
 			continue
 		}
+		metrics.ReminderSends.Inc()
 		next := now.Add(time.Duration(t.IntervalMinutes) * time.Minute)
 		if err := w.db.MarkReminderSent(ctx, t.EventID, now, next); err != nil {
-			log.Printf("reminder: failed to mark reminder sent for event %d: %v", t.EventID, err)
+			metrics.ReminderFailures.WithLabelValues("update").Inc()
+			slog.Error("reminder: failed to mark reminder sent", "event_id", t.EventID, "err", err)
 		}
 	}
 }
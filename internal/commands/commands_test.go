@@ -0,0 +1,1140 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/discordtest"
+	"github.com/susu3304/nkmzbot/internal/geourl"
+	"github.com/susu3304/nkmzbot/internal/guess"
+	"github.com/susu3304/nkmzbot/internal/nomikai"
+)
+
+var _ Session = (*discordtest.Session)(nil)
+
+// memStore is an in-memory CommandStore and TaskStore.
+type memStore struct {
+	mu       sync.Mutex
+	commands map[string]string
+	tasks    map[int]*db.ScheduledTask
+	nextTask int
+}
+
+func newMemStore() *memStore {
+	return &memStore{commands: make(map[string]string), tasks: make(map[int]*db.ScheduledTask)}
+}
+
+func cmdKey(guildID int64, name string) string { return fmt.Sprintf("%d/%s", guildID, name) }
+
+func (m *memStore) GetCommand(ctx context.Context, guildID int64, name string) (*db.Command, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resp, ok := m.commands[cmdKey(guildID, name)]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &db.Command{GuildID: guildID, Name: name, Response: resp}, nil
+}
+
+func (m *memStore) AddCommand(ctx context.Context, guildID int64, name, response string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.commands[cmdKey(guildID, name)]; !ok {
+		m.commands[cmdKey(guildID, name)] = response
+	}
+	return nil
+}
+
+func (m *memStore) UpdateCommand(ctx context.Context, guildID int64, name, response string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.commands[cmdKey(guildID, name)]; !ok {
+		return errors.New("command not found")
+	}
+	m.commands[cmdKey(guildID, name)] = response
+	return nil
+}
+
+func (m *memStore) RemoveCommand(ctx context.Context, guildID int64, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.commands[cmdKey(guildID, name)]; !ok {
+		return errors.New("command not found")
+	}
+	delete(m.commands, cmdKey(guildID, name))
+	return nil
+}
+
+func (m *memStore) ListCommands(ctx context.Context, guildID int64, pattern string) ([]db.Command, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []db.Command
+	prefix := fmt.Sprintf("%d/", guildID)
+	for k, v := range m.commands {
+		if strings.HasPrefix(k, prefix) {
+			out = append(out, db.Command{GuildID: guildID, Name: strings.TrimPrefix(k, prefix), Response: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *memStore) AddScheduledTask(ctx context.Context, command string, execTime time.Time, repeat bool, channelID string, guildID int64, userID string) (*db.ScheduledTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextTask++
+	t := &db.ScheduledTask{ID: m.nextTask, Command: command, Time: execTime, Repeat: repeat, ChannelID: channelID, GuildID: guildID, UserID: userID}
+	m.tasks[t.ID] = t
+	return t, nil
+}
+
+func (m *memStore) ListAllScheduledTasks(ctx context.Context) ([]*db.ScheduledTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*db.ScheduledTask
+	for _, t := range m.tasks {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (m *memStore) UpdateScheduledTaskTime(ctx context.Context, id int, newTime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.tasks[id]; ok {
+		t.Time = newTime
+	}
+	return nil
+}
+
+func (m *memStore) DeleteScheduledTask(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tasks, id)
+	return nil
+}
+
+func TestCustomCommandFlow(t *testing.T) {
+	s := discordtest.NewSession()
+	store := newMemStore()
+	c := discordtest.DefaultContext
+
+	HandleAdd(s, c.Command("add", discordtest.String("name", "hello"), discordtest.String("response", "Hello!")), store)
+	if got := s.LastReply().Content; got != "コマンド 'hello' を追加しました。" {
+		t.Fatalf("add reply = %q", got)
+	}
+
+	s.Reset()
+	HandleCustomCommand(s, c.MessageCreate("!hello"), store)
+	if got := s.LastReply(); got.Kind != discordtest.KindMessage || got.Content != "Hello!" || got.ChannelID != c.ChannelID {
+		t.Fatalf("custom command reply = %+v", got)
+	}
+
+	HandleUpdate(s, c.Command("update", discordtest.String("name", "hello"), discordtest.String("response", "Hi!")), store)
+	s.Reset()
+	HandleCustomCommand(s, c.MessageCreate("!hello"), store)
+	if got := s.LastReply().Content; got != "Hi!" {
+		t.Fatalf("updated reply = %q", got)
+	}
+
+	s.Reset()
+	HandleList(s, c.Command("list"), store)
+	if got := s.Contents(); len(got) != 1 || got[0] != "!hello: Hi!" {
+		t.Fatalf("list replies = %q", got)
+	}
+
+	HandleRemove(s, c.Command("remove", discordtest.String("name", "hello")), store)
+	s.Reset()
+	HandleCustomCommand(s, c.MessageCreate("!hello"), store)
+	if got := s.Replies(); len(got) != 0 {
+		t.Fatalf("removed command still replied: %+v", got)
+	}
+}
+
+func TestCustomCommandIgnoresBots(t *testing.T) {
+	s := discordtest.NewSession()
+	store := newMemStore()
+	c := discordtest.DefaultContext
+	_ = store.AddCommand(context.Background(), ParseGuildID(c.GuildID), "hello", "Hello!")
+
+	m := c.MessageCreate("!hello")
+	m.Author.Bot = true
+	HandleCustomCommand(s, m, store)
+	if got := s.Replies(); len(got) != 0 {
+		t.Fatalf("bot message got replies: %+v", got)
+	}
+}
+
+func TestRegisterAsResponseFlow(t *testing.T) {
+	s := discordtest.NewSession()
+	store := newMemStore()
+	c := discordtest.DefaultContext
+	msg := &discordgo.Message{
+		ID:          "555",
+		ChannelID:   c.ChannelID,
+		Content:     "saved text",
+		Attachments: []*discordgo.MessageAttachment{{URL: "https://cdn.example/a.png"}},
+	}
+	s.AddMessage(msg)
+
+	HandleRegisterAsResponse(s, c.MessageCommand("Register as Response", msg))
+	modal := s.LastReply()
+	if modal.Type != discordgo.InteractionResponseModal || modal.Response.Data.CustomID != "reg_resp:555" {
+		t.Fatalf("expected modal, got %+v", modal)
+	}
+
+	HandleModalSubmit(s, c.ModalSubmit("reg_resp:555", map[string]string{"command_name": "saved"}), store)
+	cmd, err := store.GetCommand(context.Background(), ParseGuildID(c.GuildID), "saved")
+	if err != nil {
+		t.Fatalf("command not stored: %v", err)
+	}
+	if cmd.Response != "saved text\nhttps://cdn.example/a.png" {
+		t.Fatalf("stored response = %q", cmd.Response)
+	}
+}
+
+func TestJikanFlow(t *testing.T) {
+	s := discordtest.NewSession()
+	store := newMemStore()
+	c := discordtest.DefaultContext
+
+	HandleJikan(s, c.Command("jikan", discordtest.SubCommand("add",
+		discordtest.String("command", "hello"),
+		discordtest.String("time", "tomorrow"),
+	)), nil, store)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, "時間の形式が正しくありません") {
+		t.Fatalf("bad time reply = %q", got)
+	}
+
+	HandleJikan(s, c.Command("jikan", discordtest.SubCommand("add",
+		discordtest.String("command", "hello"),
+		discordtest.String("time", "2099-01-01 09:00"),
+	)), nil, store)
+	if got := s.LastReply().Content; !strings.Contains(got, "2099-01-01 09:00 に実行するように予約しました") {
+		t.Fatalf("add reply = %q", got)
+	}
+	tasks, _ := store.ListAllScheduledTasks(context.Background())
+	if len(tasks) != 1 {
+		t.Fatalf("stored tasks = %d", len(tasks))
+	}
+	id := tasks[0].ID
+
+	HandleJikan(s, c.Command("jikan", discordtest.SubCommand("list")), nil, store)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("ID: %d", id)) {
+		t.Fatalf("list reply = %q", got)
+	}
+
+	HandleJikan(s, c.Command("jikan", discordtest.SubCommand("delete", discordtest.Int("id", int64(id)))), nil, store)
+	if got := s.LastReply().Content; got != fmt.Sprintf("タスク ID %d を削除しました", id) {
+		t.Fatalf("delete reply = %q", got)
+	}
+	if tasks, _ := store.ListAllScheduledTasks(context.Background()); len(tasks) != 0 {
+		t.Fatalf("task not deleted from store")
+	}
+}
+
+func TestExecuteScheduledCommand(t *testing.T) {
+	s := discordtest.NewSession()
+	store := newMemStore()
+	c := discordtest.DefaultContext
+	_ = store.AddCommand(context.Background(), ParseGuildID(c.GuildID), "hello", "Hello!")
+
+	executeScheduledCommand(s, nil, store, c.ChannelID, c.GuildID, c.UserID, "!hello")
+	executeScheduledCommand(s, nil, store, c.ChannelID, c.GuildID, c.UserID, "good morning")
+	executeScheduledCommand(s, nil, store, c.ChannelID, c.GuildID, c.UserID, "nomikai")
+
+	want := []string{"Hello!", "good morning", "nomikai コマンドにはサブコマンドが必要です"}
+	got := s.Contents()
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("scheduled messages = %q, want %q", got, want)
+	}
+}
+
+func TestNomikaiValidation(t *testing.T) {
+	c := discordtest.DefaultContext
+	tests := []struct {
+		name string
+		ic   *discordgo.InteractionCreate
+		want string
+	}{
+		{"no subcommand", c.Command("nomikai"), "サブコマンドが指定されていません"},
+		{"member without ids", c.Command("nomikai", discordtest.SubCommand("member", discordtest.String("users", "nobody"))), "ユーザーのメンション/IDを認識できませんでした"},
+		{"seisan bad amount", c.Command("nomikai", discordtest.SubCommand("seisan",
+			discordtest.User("to", "400000000000000001"),
+			discordtest.String("amount", "-5"),
+		)), "amount は正の数、または all を指定してください"},
+		{"remind bad interval", c.Command("nomikai", discordtest.SubCommand("remind", discordtest.String("interval", "soon"))), "interval は 1d2h3m の形式で指定してください (例: 1d / 2h / 30m / 1d2h3m)"},
+		{"payment edit without changes", c.Command("nomikai", discordtest.SubCommandGroup("payment",
+			discordtest.SubCommand("edit", discordtest.Int("id", 1)),
+		)), "変更する項目を指定してください"},
+		{"payment edit to zero", c.Command("nomikai", discordtest.SubCommandGroup("payment",
+			discordtest.SubCommand("edit", discordtest.Int("id", 1), discordtest.Int("amount", 0)),
+		)), "金額に 0 は指定できません。取り消すには /nomikai payment delete を使ってください"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := discordtest.NewSession()
+			HandleNomikai(s, tt.ic, nil)
+			if got := s.LastReply().Content; got != tt.want {
+				t.Fatalf("reply = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuessValidation(t *testing.T) {
+	s := discordtest.NewSession()
+	HandleGuess(s, discordtest.DefaultContext.Command("guess"), nil)
+	if got := s.LastReply().Content; got != "サブコマンドが指定されていません" {
+		t.Fatalf("reply = %q", got)
+	}
+	HandleGuess(s, discordtest.DefaultContext.Command("guess", discordtest.SubCommand("guess")), nil)
+	if got := s.LastReply(); got.Type != discordgo.InteractionResponseModal || got.Response.Data.CustomID != guessModalID {
+		t.Fatalf("guess without url = %+v", got)
+	}
+	HandleGuess(s, discordtest.DefaultContext.Command("guess", discordtest.SubCommand("recompute_ratings")), nil)
+	if got := s.LastReply().Content; got != "レーティングの再計算はサーバー管理者のみ実行できます" {
+		t.Fatalf("recompute reply = %q", got)
+	}
+	HandleGuess(s, discordtest.DefaultContext.Command("guess", discordtest.SubCommand("start",
+		discordtest.String("scoring", "linear"), discordtest.String("scoring_params", "decay=3"))), nil)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, "採点方法の指定が正しくありません") {
+		t.Fatalf("start with bad scoring = %q", got)
+	}
+}
+
+// testDB connects to NKMZBOT_TEST_DATABASE_URL and applies migrations, skipping when it is unset.
+func testDB(t *testing.T) *db.DB {
+	t.Helper()
+	url := os.Getenv("NKMZBOT_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("NKMZBOT_TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	database, err := db.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(database.Close)
+	if err := database.RunMigrationsDir(ctx, "../../migrations"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return database
+}
+
+// uniqueContext returns IDs that do not collide with earlier runs against the same database.
+func uniqueContext() discordtest.Context {
+	n := time.Now().UnixNano()
+	return discordtest.Context{
+		GuildID:   strconv.FormatInt(n, 10),
+		ChannelID: strconv.FormatInt(n+1, 10),
+		UserID:    strconv.FormatInt(n+2, 10),
+	}
+}
+
+func TestNomikaiFlow(t *testing.T) {
+	database := testDB(t)
+	svc := nomikai.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	other := strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start")), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("member", discordtest.String("users", "<@"+other+">"))), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("tatekae", discordtest.Int("amount", 1000))), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("settle")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s> → <@%s>: 500 円", other, c.UserID)) {
+		t.Fatalf("settle reply = %q", got)
+	}
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("seisan",
+		discordtest.User("to", c.UserID),
+		discordtest.String("amount", "all"),
+		discordtest.User("payer", other),
+	)), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "未払い全額 500 円") {
+		t.Fatalf("seisan reply = %q", got)
+	}
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop")), svc)
+	if got := s.LastReply().Content; got != "セッションを終了しました" {
+		t.Fatalf("stop reply = %q", got)
+	}
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("history")), svc)
+	history := s.LastReply().Content
+	m := regexp.MustCompile(`#(\d+) \S+ 終了 参加 2名 / 総支出 1000 円\n`).FindStringSubmatch(history)
+	if m == nil {
+		t.Fatalf("history reply = %q", history)
+	}
+	eventID, _ := strconv.ParseInt(m[1], 10, 64)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("show", discordtest.Int("id", eventID))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "(終了)") || !strings.Contains(got, fmt.Sprintf("精算の支払い:\n・<@%s> → <@%s> 500 円", other, c.UserID)) {
+		t.Fatalf("show reply = %q", got)
+	}
+
+	// A new event with the same (empty) name blocks reopening the old one until it stops.
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start")), svc)
+	reopen := c.Command("nomikai", discordtest.SubCommand("reopen", discordtest.Int("id", eventID)))
+	HandleNomikai(s, reopen, svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "では同じ名前の飲み会 #") {
+		t.Fatalf("reopen with an active event = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop")), svc)
+	byOther := c
+	byOther.UserID = other
+	HandleNomikai(s, byOther.Command("nomikai", discordtest.SubCommand("reopen", discordtest.Int("id", eventID))), svc)
+	if got := s.LastReply().Content; got != "再開できるのは主催者かサーバー管理者のみです" {
+		t.Fatalf("reopen by member = %q", got)
+	}
+	HandleNomikai(s, reopen, svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, fmt.Sprintf("飲み会 #%d を <#%s> で再開しました", eventID, c.ChannelID)) {
+		t.Fatalf("reopen reply = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("seisan",
+		discordtest.User("to", c.UserID),
+		discordtest.String("amount", "100"),
+		discordtest.User("payer", other),
+	)), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("show", discordtest.Int("id", eventID))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "(開催中)") || !strings.Contains(got, fmt.Sprintf("・<@%s> → <@%s> 100 円", other, c.UserID)) {
+		t.Fatalf("show after reopen = %q", got)
+	}
+}
+
+func TestNomikaiNamedEventsFlow(t *testing.T) {
+	database := testDB(t)
+	svc := nomikai.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	other := c
+	other.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start", discordtest.String("name", "一次会"))), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("tatekae", discordtest.Int("amount", 3000))), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start", discordtest.String("name", "二次会"))), svc)
+	if got := s.LastReply().Content; got != "このチャンネルで飲み会「二次会」を開始しました" {
+		t.Fatalf("second start reply = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start", discordtest.String("name", "#3"))), svc)
+	if got := s.LastReply().Content; got != "飲み会の名前は # で始められません" {
+		t.Fatalf("start with # = %q", got)
+	}
+
+	// The organizer's commands go to the event they started last; others default to the newest
+	// until they join or pick one.
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("tatekae", discordtest.Int("amount", 1000))), svc)
+	HandleNomikai(s, other.Command("nomikai", discordtest.SubCommand("join", discordtest.String("event", "一次会"))), svc)
+	HandleNomikai(s, other.Command("nomikai", discordtest.SubCommand("status")), svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, "飲み会「一次会」\n総支出: 3000 円") {
+		t.Fatalf("status after join = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("status", discordtest.String("event", "二次会"))), svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, "飲み会「二次会」\n総支出: 1000 円") {
+		t.Fatalf("status of 二次会 = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("status", discordtest.String("event", "三次会"))), svc)
+	if got := s.LastReply().Content; got != "飲み会「三次会」は開催されていません" {
+		t.Fatalf("status of a missing event = %q", got)
+	}
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("use", discordtest.String("event", "一次会"))), svc)
+	if got := s.LastReply().Content; got != "このチャンネルでは飲み会「一次会」を対象にします" {
+		t.Fatalf("use reply = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("use")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "「一次会」") || !regexp.MustCompile(`▶ #\d+「一次会」`).MatchString(got) {
+		t.Fatalf("use list = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("settle")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s> → <@%s>: 1500 円", other.UserID, c.UserID)) {
+		t.Fatalf("settle 一次会 = %q", got)
+	}
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop", discordtest.String("event", "二次会"))), svc)
+	if got := s.LastReply().Content; got != "飲み会「二次会」を終了しました" {
+		t.Fatalf("stop 二次会 = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop")), svc)
+	if got := s.LastReply().Content; got != "飲み会「一次会」を終了しました" {
+		t.Fatalf("stop 一次会 = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop")), svc)
+	if got := s.LastReply().Content; got != "セッションが存在しません" {
+		t.Fatalf("stop without events = %q", got)
+	}
+
+	// A reopened event becomes its reopener's current one, even with another event active.
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("history")), svc)
+	m := regexp.MustCompile(`#(\d+) 「一次会」`).FindStringSubmatch(s.LastReply().Content)
+	if m == nil {
+		t.Fatalf("history reply = %q", s.LastReply().Content)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start", discordtest.String("name", "三次会"))), svc)
+	eventID, _ := strconv.ParseInt(m[1], 10, 64)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("reopen", discordtest.Int("id", eventID))), svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, fmt.Sprintf("飲み会 #%d「一次会」を <#%s> で再開しました", eventID, c.ChannelID)) ||
+		!strings.Contains(got, fmt.Sprintf("/nomikai stop event:#%d", eventID)) {
+		t.Fatalf("reopen reply = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop")), svc)
+	if got := s.LastReply().Content; got != "飲み会「一次会」を終了しました" {
+		t.Fatalf("stop after reopen = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("stop")), svc)
+	if got := s.LastReply().Content; got != "飲み会「三次会」を終了しました" {
+		t.Fatalf("stop 三次会 = %q", got)
+	}
+}
+
+func TestNomikaiPaymentEditFlow(t *testing.T) {
+	database := testDB(t)
+	svc := nomikai.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	other := c
+	other.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("start")), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("member", discordtest.String("users", "<@"+other.UserID+">"))), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("tatekae", discordtest.Int("amount", 1000))), svc)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("payments")), svc)
+	m := regexp.MustCompile(`#(\d+) <@` + c.UserID + `> 1000 円\n`).FindStringSubmatch(s.LastReply().Content)
+	if m == nil {
+		t.Fatalf("payments reply = %q", s.LastReply().Content)
+	}
+	paymentID, _ := strconv.ParseInt(m[1], 10, 64)
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("settle")), svc)
+
+	payment := func(sub string, options ...*discordtest.Option) *discordtest.Option {
+		return discordtest.SubCommandGroup("payment", discordtest.SubCommand(sub, append([]*discordtest.Option{discordtest.Int("id", paymentID)}, options...)...))
+	}
+	HandleNomikai(s, other.Command("nomikai", payment("delete")), svc)
+	if got := s.LastReply().Content; got != "立替を変更できるのは支払者・記録した人・主催者のみです" {
+		t.Fatalf("delete by member = %q", got)
+	}
+
+	// Editing clears the settlement computed above.
+	HandleNomikai(s, c.Command("nomikai", payment("edit", discordtest.Int("amount", 2000), discordtest.String("memo", "居酒屋"))), svc)
+	want := fmt.Sprintf("立替 #%d を編集しました\n変更前: <@%s> 1000 円\n変更後: <@%s> 2000 円 (居酒屋)\nこれまでの精算結果は無効になりました", paymentID, c.UserID, c.UserID)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, want) {
+		t.Fatalf("edit reply = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("payments")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("#%d <@%s> 2000 円 (居酒屋) ✏️", paymentID, c.UserID)) || !strings.Contains(got, "変更履歴:") {
+		t.Fatalf("payments after edit = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("settle")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s> → <@%s>: 1000 円", other.UserID, c.UserID)) {
+		t.Fatalf("settle after edit = %q", got)
+	}
+
+	HandleNomikai(s, c.Command("nomikai", payment("delete")), svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, fmt.Sprintf("立替 #%d を削除しました", paymentID)) {
+		t.Fatalf("delete reply = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", discordtest.SubCommand("payments")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "立替 (0件)") || !strings.Contains(got, fmt.Sprintf("#%d <@%s>: 削除 <@%s> 2000 円 (居酒屋)", paymentID, c.UserID, c.UserID)) {
+		t.Fatalf("payments after delete = %q", got)
+	}
+	HandleNomikai(s, c.Command("nomikai", payment("delete")), svc)
+	if got := s.LastReply().Content; got != fmt.Sprintf("立替 #%d が見つかりません", paymentID) {
+		t.Fatalf("second delete = %q", got)
+	}
+}
+
+func TestGuessFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+
+	link := newShortLink(t)
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", discordtest.Int("rounds", 2), discordtest.String("map", "kanto"))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "🗺️ マップ: 関東") {
+		t.Fatalf("start reply = %q", got)
+	}
+	for round := 1; round <= 2; round++ {
+		if round == 1 {
+			HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+		} else {
+			HandleGuessModalSubmit(s, c.ModalSubmit(guessModalID, map[string]string{"url": link}), svc)
+		}
+		replies := s.Replies()
+		ack, status := replies[len(replies)-1], replies[len(replies)-2]
+		if !ack.Ephemeral || !strings.HasPrefix(ack.Content, "✅ 推測を記録しました") {
+			t.Fatalf("round %d guess reply = %+v", round, ack)
+		}
+		// Nobody is known to play before the first guess; afterwards the first round's players are.
+		want := fmt.Sprintf("📝 ラウンド %d/2 **1名**が推測済み", round)
+		if round == 2 {
+			want = "📝 ラウンド 2/2 **1/1名**が推測済み"
+		}
+		if status.Kind != discordtest.KindMessage || status.Ephemeral || !strings.HasPrefix(status.Content, want) || strings.Contains(status.Content, "http") {
+			t.Fatalf("round %d status = %+v", round, status)
+		}
+		// Guessing again edits the status message instead of posting another.
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+		if replies := s.Replies(); replies[len(replies)-2].Kind != discordtest.KindMessageEdit {
+			t.Fatalf("round %d reguess status = %+v", round, replies[len(replies)-2])
+		}
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer", discordtest.String("url", link))), svc)
+		got := s.LastReply().Content
+		if files := s.LastReply().Files; len(files) != 1 || files[0] != "result.png" {
+			t.Fatalf("round %d answer files = %v", round, files)
+		}
+		if !strings.Contains(got, fmt.Sprintf("ラウンド %d/2 の正解", round)) || !strings.Contains(got, "5000点") || !strings.Contains(got, "関東") {
+			t.Fatalf("round %d answer reply = %q", round, got)
+		}
+		if total := fmt.Sprintf("<@%s>: **%d点** (%dラウンド)", c.UserID, 5000*round, round); !strings.Contains(got, total) {
+			t.Fatalf("round %d totals missing %q: %q", round, total, got)
+		}
+	}
+	if got := s.LastReply().Content; !strings.Contains(got, "最終結果") {
+		t.Fatalf("final reply = %q", got)
+	}
+
+	// The game ended with the last round.
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("stop")), svc)
+	if got := s.LastReply().Content; got != "このチャンネルにはアクティブなセッションがありません" {
+		t.Fatalf("stop reply = %q", got)
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("leaderboard", discordtest.String("period", "week"), discordtest.String("sort", "perfect"))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s>: 5000点 **2回** (合計 10000点", c.UserID)) {
+		t.Fatalf("leaderboard reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("stats")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "ゲーム数: 1 (2ラウンド)") || !strings.Contains(got, "5000点: 2回") || !strings.Contains(got, "ベストラウンド: **5000点**") {
+		t.Fatalf("stats reply = %q", got)
+	}
+
+	games, err := svc.History(context.Background(), ParseGuildID(c.GuildID), 0, 0)
+	if err != nil || len(games) != 1 || games[0].RoundsPlayed != 2 || games[0].Players != 1 {
+		t.Fatalf("History = %+v, %v", games, err)
+	}
+	game, err := svc.Game(context.Background(), ParseGuildID(c.GuildID), games[0].ID)
+	if err != nil || len(game.Rounds) != 2 || len(game.Rounds[1].Guesses) != 1 || game.Rounds[1].Guesses[0].Score != 5000 {
+		t.Fatalf("Game = %+v, %v", game, err)
+	}
+	if _, err := svc.Game(context.Background(), ParseGuildID(c.GuildID)+1, games[0].ID); err != guess.ErrGameNotFound {
+		t.Fatalf("Game of another guild: %v", err)
+	}
+}
+
+// TestGuessPerfectCountsDistanceOnly plays a one-round game in each scoring setup with a guess
+// on the answer: only the distance game on the default curve makes a perfect 5k.
+func TestGuessMapFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	other := c
+	other.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+
+	mapCommand := func(c discordtest.Context, sub string, opts ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+		return c.Command("guess", discordtest.SubCommandGroup("map", discordtest.SubCommand(sub, opts...)))
+	}
+	HandleGuess(s, mapCommand(c, "save", discordtest.String("name", "pacific"), discordtest.String("bounds", "-20,170,-10,-170")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "日付変更線") {
+		t.Fatalf("save across the antimeridian = %q", got)
+	}
+	HandleGuess(s, mapCommand(c, "save", discordtest.String("name", "yamanote"), discordtest.String("bounds", "35.6,139.68,35.74,139.8")), svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, "✅ マップ 'yamanote' を保存しました") {
+		t.Fatalf("save reply = %q", got)
+	}
+
+	deleteByOther := mapCommand(other, "delete", discordtest.String("name", "yamanote"))
+	HandleGuess(s, deleteByOther, svc)
+	if got := s.LastReply().Content; got != guess.ErrNotMapOwner.Error() {
+		t.Fatalf("delete by member = %q", got)
+	}
+	deleteByOther.Member.Permissions = discordgo.PermissionManageServer
+	HandleGuess(s, deleteByOther, svc)
+	if got := s.LastReply().Content; got != "🗑️ マップ 'yamanote' を削除しました" {
+		t.Fatalf("delete by manager = %q", got)
+	}
+}
+
+func TestGuessPerfectCountsDistanceOnly(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	link := newShortLink(t)
+
+	for _, opts := range [][]*discordtest.Option{
+		nil,
+		{discordtest.String("mode", string(guess.ModeRegion))},
+		{discordtest.String("scoring", "linear")},
+	} {
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", opts...)), svc)
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer", discordtest.String("url", link))), svc)
+		if got := s.LastReply().Content; !strings.Contains(got, "5000点") {
+			t.Fatalf("answer reply with %v = %q", opts, got)
+		}
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("leaderboard", discordtest.String("sort", "perfect"))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s>: 5000点 **1回** (合計 15000点", c.UserID)) {
+		t.Fatalf("leaderboard reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("stats")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "ゲーム数: 3 (3ラウンド)") || !strings.Contains(got, "5000点: 1回") {
+		t.Fatalf("stats reply = %q", got)
+	}
+}
+
+// newShortLink returns a short link that resolves to Tokyo Station. Requests go to a local
+// stand-in server through the expander's transport: the short link redirects to a Google Maps
+// URL that carries the coordinates.
+func newShortLink(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/short" {
+			http.Redirect(w, r, "https://www.google.com/maps/@35.681236,139.767125,15z", http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	prev := geourl.DefaultExpander()
+	geourl.SetDefaultExpander(geourl.NewExpander(geourl.Options{Transport: serverTransport{srv}}))
+	t.Cleanup(func() { geourl.SetDefaultExpander(prev) })
+	return "https://maps.app.goo.gl/short"
+}
+
+// serverTransport sends every request to srv, whatever its host.
+type serverTransport struct {
+	srv *httptest.Server
+}
+
+func (st serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = "http"
+	out.URL.Host = st.srv.Listener.Addr().String()
+	resp, err := st.srv.Client().Transport.RoundTrip(out)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+// TestGuessRacesAnswer checks that a guess sent while the round is being answered is either
+// scored in that round or kept for the next one, never left unscored in a closed round.
+func TestGuessRacesAnswer(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	ctx := context.Background()
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", discordtest.Int("rounds", 2))), svc)
+	sess, err := svc.GetActiveSession(ctx, c.ChannelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const players = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	guessed := 0
+	for n := 0; n < players; n++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			if err := svc.AddGuess(ctx, c.ChannelID, userID, 35.681236, 139.767125, ""); err == nil {
+				mu.Lock()
+				guessed++
+				mu.Unlock()
+			}
+		}(strconv.Itoa(n + 1))
+	}
+	for round := 1; round <= 2; round++ {
+		if round == 2 {
+			wg.Wait()
+		}
+		if _, err := svc.SetAnswer(ctx, c.ChannelID, c.UserID, 35.681236, 139.767125, ""); err != nil {
+			t.Fatalf("round %d: answer: %v", round, err)
+		}
+	}
+
+	standings, err := svc.Standings(ctx, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(standings) != guessed {
+		t.Fatalf("%d players scored, %d guesses accepted", len(standings), guessed)
+	}
+}
+
+func TestGuessRatesNewPlayersConcurrently(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	ctx := context.Background()
+	players := []string{c.UserID + "1", c.UserID + "2"}
+
+	// The same new players finish rounds in several channels at once.
+	const games = 4
+	channels := make([]discordtest.Context, games)
+	for n := range channels {
+		channels[n] = c
+		channels[n].ChannelID = c.ChannelID + strconv.Itoa(n)
+		HandleGuess(s, channels[n].Command("guess", discordtest.SubCommand("start")), svc)
+		for idx, userID := range players {
+			if err := svc.AddGuess(ctx, channels[n].ChannelID, userID, 35.681236+float64(idx), 139.767125, ""); err != nil {
+				t.Fatalf("game %d: guess: %v", n, err)
+			}
+		}
+	}
+	var wg sync.WaitGroup
+	for _, ch := range channels {
+		wg.Add(1)
+		go func(channelID string) {
+			defer wg.Done()
+			if _, err := svc.SetAnswer(ctx, channelID, c.UserID, 35.681236, 139.767125, ""); err != nil {
+				t.Errorf("answer: %v", err)
+			}
+		}(ch.ChannelID)
+	}
+	wg.Wait()
+
+	for _, userID := range players {
+		p, err := svc.PlayerRating(ctx, ParseGuildID(c.GuildID), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Rounds != games {
+			t.Errorf("player %s was rated in %d rounds, want %d", userID, p.Rounds, games)
+		}
+	}
+}
+
+func TestGuessSealedFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	player := c
+	player.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+	link := newShortLink(t)
+
+	// Answers are sealed privately before the game and attached when it starts.
+	HandleGuessModalSubmit(s, c.ModalSubmit(guessSealModalID, map[string]string{"answers": link}), svc)
+	if r := s.LastReply(); !r.Ephemeral || !strings.Contains(r.Content, "次のゲーム用に 1 件") {
+		t.Fatalf("seal reply = %+v", r)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", discordtest.Int("rounds", 2))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "1ラウンド分の正解が封印されています") || strings.Contains(got, link) {
+		t.Fatalf("start reply = %q", got)
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	if got := s.LastReply().Content; got != guess.ErrOwnRound.Error() {
+		t.Fatalf("organizer guess reply = %q", got)
+	}
+	HandleGuess(s, player.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("queue")), svc)
+	if r := s.LastReply(); !r.Ephemeral || !strings.Contains(r.Content, "ラウンド 1:") {
+		t.Fatalf("queue reply = %+v", r)
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "封印された正解を公開します") || !strings.Contains(got, fmt.Sprintf("<@%s>: **5000点**", player.UserID)) {
+		t.Fatalf("reveal reply = %q", got)
+	}
+
+	// Round 2 has no sealed answer.
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "封印された正解がありません") {
+		t.Fatalf("unsealed answer reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("stop")), svc)
+}
+
+func TestGuessTeamFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	player := c
+	player.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+	link := newShortLink(t)
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommandGroup("team", discordtest.SubCommand("list"))), svc)
+	if got := s.LastReply().Content; got != guess.ErrNoActiveSession.Error() {
+		t.Fatalf("list without a game reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", discordtest.String("teams", "average"))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "チーム戦（チームのスコア: メンバーの平均）") {
+		t.Fatalf("start reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommandGroup("team", discordtest.SubCommand("create", discordtest.String("name", "Red")))), svc)
+	if got := s.LastReply().Content; got != fmt.Sprintf("👥 <@%s> がチーム **Red** を作成して参加しました（1名）", c.UserID) {
+		t.Fatalf("create reply = %q", got)
+	}
+	HandleGuess(s, player.Command("guess", discordtest.SubCommandGroup("team", discordtest.SubCommand("join", discordtest.String("name", "red")))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "チーム **Red** に参加しました（2名）") {
+		t.Fatalf("join reply = %q", got)
+	}
+	HandleGuess(s, player.Command("guess", discordtest.SubCommandGroup("team", discordtest.SubCommand("create", discordtest.String("name", "RED")))), svc)
+	if got := s.LastReply().Content; got != guess.ErrTeamExists.Error() {
+		t.Fatalf("create with a name differing in case = %q", got)
+	}
+	HandleGuess(s, player.Command("guess", discordtest.SubCommandGroup("team", discordtest.SubCommand("balance", discordtest.Int("teams", 2)))), svc)
+	if got := s.LastReply().Content; got != guess.ErrNotTeamOrganizer.Error() {
+		t.Fatalf("balance by player reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommandGroup("team", discordtest.SubCommand("balance", discordtest.Int("teams", 2)))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "・**Red** (1名)") || !strings.Contains(got, "・**チームA** (1名)") {
+		t.Fatalf("balance reply = %q", got)
+	}
+
+	HandleGuess(s, player.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer", discordtest.String("url", link))), svc)
+	got := s.LastReply().Content
+	if !strings.Contains(got, "👥 **チーム結果** (メンバーの平均)") || !strings.Contains(got, "**5000点** (推測 1/1名)") || !strings.Contains(got, "**0点** (推測 0/1名)") {
+		t.Fatalf("answer reply = %q", got)
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("leaderboard", discordtest.Bool("teams", true))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "🥇 1. Red: **5000点** (合計 5000点・平均 5000点・1勝・1ゲーム/1ラウンド)") ||
+		!strings.Contains(got, "2. チームA: **0点**") {
+		t.Fatalf("team leaderboard = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("leaderboard", discordtest.Bool("teams", true), discordtest.String("sort", "perfect"))), svc)
+	if got := s.LastReply().Content; got != guess.ErrTeamLeaderboardSort.Error() {
+		t.Fatalf("team leaderboard by perfects = %q", got)
+	}
+}
+
+func TestGuessDuelFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	opponent, host := c, c
+	opponent.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+	host.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+2, 10)
+	link := newShortLink(t)
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("duel", discordtest.User("opponent", opponent.UserID))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s> vs <@%s> のデュエルを開始しました", c.UserID, opponent.UserID)) {
+		t.Fatalf("duel reply = %q", got)
+	}
+	HandleGuess(s, host.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	if got := s.LastReply().Content; got != guess.ErrNotDuelist.Error() {
+		t.Fatalf("host guess reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer", discordtest.String("url", link))), svc)
+	if got := s.LastReply().Content; got != guess.ErrDuelistAnswer.Error() {
+		t.Fatalf("duelist answer reply = %q", got)
+	}
+
+	HandleGuess(s, host.Command("guess", discordtest.SubCommand("answer", discordtest.String("url", link))), svc)
+	got := s.LastReply().Content
+	if !strings.Contains(got, fmt.Sprintf("<@%s> に **5000** ダメージ！", opponent.UserID)) || !strings.Contains(got, "1000/6000") || !strings.Contains(got, "ラウンド 2 を開始しました") {
+		t.Fatalf("answer reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("stop")), svc)
+}
+
+func TestGuessRatingFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	opponent, host := c, c
+	opponent.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+	host.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+2, 10)
+	link := newShortLink(t)
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("duel")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "(レーティング 1500) が対戦相手を待っています") {
+		t.Fatalf("queue reply = %q", got)
+	}
+	HandleGuess(s, opponent.Command("guess", discordtest.SubCommand("duel")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("<@%s> vs <@%s> のデュエルを開始しました", c.UserID, opponent.UserID)) {
+		t.Fatalf("match reply = %q", got)
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	HandleGuess(s, opponent.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	HandleGuess(s, host.Command("guess", discordtest.SubCommand("answer", discordtest.String("url", link))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, fmt.Sprintf("📈 レーティング: <@%s> 1500 (+0)", c.UserID)) {
+		t.Fatalf("answer reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("stop")), svc)
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("rating")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "サーバー内 1位 / 2人 (1ラウンド)") {
+		t.Fatalf("rating reply = %q", got)
+	}
+	players, rounds, err := svc.RecomputeRatings(context.Background(), ParseGuildID(c.GuildID))
+	if err != nil || players != 2 || rounds != 1 {
+		t.Fatalf("RecomputeRatings = %d, %d, %v", players, rounds, err)
+	}
+}
+
+func TestGuessDailyFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	adder := c
+	adder.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+	link := newShortLink(t)
+	ctx := context.Background()
+	gid := ParseGuildID(c.GuildID)
+
+	enable := c.Command("guess", discordtest.SubCommandGroup("daily", discordtest.SubCommand("enable", discordtest.String("time", "00:00"))))
+	HandleGuess(s, enable, svc)
+	if got := s.LastReply().Content; got != "デイリーチャレンジの設定はサーバー管理者のみ実行できます" {
+		t.Fatalf("enable by member = %q", got)
+	}
+	enable.Member.Permissions = discordgo.PermissionManageServer
+	HandleGuess(s, enable, svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "毎日 00:00（日本時間）に場所プールからデイリーチャレンジを出題します") {
+		t.Fatalf("enable reply = %q", got)
+	}
+
+	date := guess.DailyDate(time.Now())
+	if _, err := svc.StartDailyChallenge(ctx, gid, date); err != guess.ErrPoolEmpty {
+		t.Fatalf("start with an empty pool: %v", err)
+	}
+	HandleGuess(s, adder.Command("guess", discordtest.SubCommandGroup("pool", discordtest.SubCommand("add",
+		discordtest.String("url", link), discordtest.String("clue", "https://example.com/photo.jpg")))), svc)
+	if got := s.LastReply().Content; !strings.HasPrefix(got, "✅ 場所 #") {
+		t.Fatalf("pool add reply = %q", got)
+	}
+
+	for day := 1; day <= 2; day++ {
+		ch, err := svc.StartDailyChallenge(ctx, gid, date.AddDate(0, 0, day))
+		if err != nil {
+			t.Fatalf("day %d: start: %v", day, err)
+		}
+		if got := FormatDailyChallenge(ch); !strings.Contains(got, "🖼️ ヒント: https://example.com/photo.jpg") {
+			t.Fatalf("day %d: challenge = %q", day, got)
+		}
+		if _, err := svc.StartDailyChallenge(ctx, gid, date.AddDate(0, 0, day)); err != guess.ErrDailyDone {
+			t.Fatalf("day %d: second start: %v", day, err)
+		}
+		HandleGuess(s, adder.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+		if got := s.LastReply().Content; got != guess.ErrOwnRound.Error() {
+			t.Fatalf("day %d: adder guess reply = %q", day, got)
+		}
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer")), svc)
+		if got := s.LastReply().Content; got != guess.ErrDailyAnswer.Error() {
+			t.Fatalf("day %d: answer reply = %q", day, got)
+		}
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("stop")), svc)
+		if got := s.LastReply().Content; got != guess.ErrDailyStop.Error() {
+			t.Fatalf("day %d: stop reply = %q", day, got)
+		}
+		HandleGuess(s, c.Command("guess", discordtest.SubCommand("start")), svc)
+		if got := s.LastReply().Content; got != guess.ErrDailyRunning.Error() {
+			t.Fatalf("day %d: start reply = %q", day, got)
+		}
+
+		results, err := svc.CloseDailyChallenges(ctx, gid)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("day %d: close = %+v, %v", day, results, err)
+		}
+		if got, want := FormatDailyResult(&results[0]), fmt.Sprintf("🔥 連続参加: <@%s> %d日", c.UserID, day); !strings.Contains(got, want) {
+			t.Fatalf("day %d: result = %q, want %q", day, got, want)
+		}
+	}
+
+	disable := c.Command("guess", discordtest.SubCommandGroup("daily", discordtest.SubCommand("disable")))
+	disable.Member.Permissions = discordgo.PermissionManageServer
+	HandleGuess(s, disable, svc)
+	if got := s.LastReply().Content; got != "✅ デイリーチャレンジを停止しました" {
+		t.Fatalf("disable reply = %q", got)
+	}
+}
+
+func TestGuessPoolGameFlow(t *testing.T) {
+	database := testDB(t)
+	svc := guess.NewService(database)
+	s := discordtest.NewSession()
+	c := uniqueContext()
+	adder := c
+	adder.UserID = strconv.FormatInt(ParseGuildID(c.UserID)+1, 10)
+	link := newShortLink(t)
+
+	for _, clue := range []string{"photo 1", "photo 2"} {
+		tags := "japan"
+		if clue == "photo 1" {
+			tags = "Japan, urban"
+		}
+		HandleGuess(s, adder.Command("guess", discordtest.SubCommandGroup("pool", discordtest.SubCommand("add",
+			discordtest.String("url", link), discordtest.String("clue", clue), discordtest.String("tags", tags)))), svc)
+		if got := s.LastReply().Content; !strings.HasPrefix(got, "✅ 場所 #") {
+			t.Fatalf("pool add reply = %q", got)
+		}
+	}
+
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", discordtest.Int("rounds", 2), discordtest.String("pool", "urban"))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "タグ「urban」のヒント付きの場所が 1 件しかありません") {
+		t.Fatalf("start with a short pool = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("start", discordtest.Int("rounds", 2), discordtest.String("pool", "japan"))), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "📦 場所プール（タグ: japan）から出題します") || !strings.Contains(got, "🖼️ ヒント: photo") {
+		t.Fatalf("start reply = %q", got)
+	}
+
+	HandleGuess(s, adder.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	if got := s.LastReply().Content; got != guess.ErrOwnRound.Error() {
+		t.Fatalf("adder guess reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("guess", discordtest.String("url", link))), svc)
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "封印された正解を公開します") || !strings.Contains(got, "🖼️ ヒント: photo") {
+		t.Fatalf("round 1 reply = %q", got)
+	}
+	HandleGuess(s, c.Command("guess", discordtest.SubCommand("answer")), svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "🏁 **最終結果**") {
+		t.Fatalf("round 2 reply = %q", got)
+	}
+
+	list := c.Command("guess", discordtest.SubCommandGroup("pool", discordtest.SubCommand("list", discordtest.String("tag", "urban"))))
+	HandleGuess(s, list, svc)
+	if got := s.LastReply().Content; !strings.Contains(got, "**タグ「urban」の場所** 1 件") || strings.Contains(got, link) {
+		t.Fatalf("member list reply = %q", got)
+	}
+	locs, err := svc.PoolLocations(context.Background(), ParseGuildID(c.GuildID), "urban")
+	if err != nil || len(locs) != 1 || locs[0].LastUsedAt == nil {
+		t.Fatalf("PoolLocations = %+v, %v", locs, err)
+	}
+	remove := c.Command("guess", discordtest.SubCommandGroup("pool", discordtest.SubCommand("remove", discordtest.Int("id", locs[0].ID))))
+	HandleGuess(s, remove, svc)
+	if got := s.LastReply().Content; got != guess.ErrNotPoolOwner.Error() {
+		t.Fatalf("remove by member = %q", got)
+	}
+	remove.Member.Permissions = discordgo.PermissionManageServer
+	HandleGuess(s, remove, svc)
+	if got := s.LastReply().Content; got != fmt.Sprintf("🗑️ 場所 #%d を削除しました", locs[0].ID) {
+		t.Fatalf("remove by manager = %q", got)
+	}
+}
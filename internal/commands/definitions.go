@@ -1,6 +1,11 @@
 package commands
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+	"github.com/susu3304/nkmzbot/internal/guess"
+	"github.com/susu3304/nkmzbot/internal/nomikai"
+)
 
 func GetCommands() []*discordgo.ApplicationCommand {
 	return []*discordgo.ApplicationCommand{
@@ -118,17 +123,40 @@ func GetCommands() []*discordgo.ApplicationCommand {
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "start",
-					Description: "このチャンネルでセッションを開始",
+					Description: "このチャンネルでセッションを開始（名前を付けると複数同時に開催できます）",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "name",
+							Description: "飲み会の名前 (例: 二次会)",
+							Required:    false,
+							MaxLength:   nomikai.MaxEventNameLength,
+						},
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "stop",
 					Description: "このチャンネルのセッションを終了",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "use",
+					Description: "以降のコマンドの対象にする飲み会を選ぶ（未指定なら開催中の一覧）",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "join",
 					Description: "自分を参加者に追加",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -141,6 +169,7 @@ func GetCommands() []*discordgo.ApplicationCommand {
 							Description: "追加するユーザー（メンション/IDをスペース区切り。単一も可）",
 							Required:    true,
 						},
+						nomikaiEventOption(),
 					},
 				},
 				{
@@ -160,6 +189,7 @@ func GetCommands() []*discordgo.ApplicationCommand {
 							Description: "比率 (例: 1.5)",
 							Required:    true,
 						},
+						nomikaiEventOption(),
 					},
 				},
 				{
@@ -191,22 +221,97 @@ func GetCommands() []*discordgo.ApplicationCommand {
 							Description: "メモ",
 							Required:    false,
 						},
+						nomikaiEventOption(),
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "payments",
+					Description: "記録した立替の一覧 (番号・変更履歴つき)",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "payment",
+					Description: "記録した立替を修正 (支払者・記録した人・主催者のみ)",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "edit",
+							Description: "立替の金額・支払者・対象・メモを変更",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "id",
+									Description: "立替の番号 (/nomikai payments で確認)",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "amount",
+									Description: "新しい金額（円）",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionUser,
+									Name:        "payer",
+									Description: "新しい支払者",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "for",
+									Description: "新しい対象ユーザー（メンション/ID。all で全員に戻す）",
+									Required:    false,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "memo",
+									Description: "新しいメモ",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "delete",
+							Description: "立替を取り消す",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "id",
+									Description: "立替の番号 (/nomikai payments で確認)",
+									Required:    true,
+								},
+							},
+						},
 					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "settle",
 					Description: "ネット精算を計算",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "status",
 					Description: "現在の状況を表示",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "memberlist",
 					Description: "参加中のメンバーを表示",
+					Options: []*discordgo.ApplicationCommandOption{
+						nomikaiEventOption(),
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -229,6 +334,7 @@ func GetCommands() []*discordgo.ApplicationCommand {
 								{Name: "off", Value: "off"},
 							},
 						},
+						nomikaiEventOption(),
 					},
 				},
 				{
@@ -261,6 +367,50 @@ func GetCommands() []*discordgo.ApplicationCommand {
 							Description: "メモ",
 							Required:    false,
 						},
+						nomikaiEventOption(),
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "history",
+					Description: "過去の飲み会の一覧 (総支出・未払い)",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "scope",
+							Description: "表示する範囲 (既定: このチャンネル)",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "このチャンネル", Value: "channel"},
+								{Name: "サーバー全体", Value: "guild"},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "show",
+					Description: "飲み会の詳細 (終了した飲み会も表示できます)",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "id",
+							Description: "飲み会の番号 (/nomikai history で確認)",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "reopen",
+					Description: "終了した飲み会を再開して後から精算を記録 (主催者・サーバー管理者用)",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "id",
+							Description: "飲み会の番号 (/nomikai history で確認)",
+							Required:    true,
+						},
 					},
 				},
 			},
@@ -274,6 +424,112 @@ func GetCommands() []*discordgo.ApplicationCommand {
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "start",
 					Description: "このチャンネルでジオゲッサーセッションを開始",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "rounds",
+							Description: "ラウンド数（既定: 1）",
+							Required:    false,
+							MinValue:    float64Ptr(1),
+							MaxValue:    guess.MaxRounds,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "map",
+							Description:  "マップ（スコアの基準になる範囲。既定: 世界）",
+							Required:     false,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "bounds",
+							Description: "カスタム範囲（南西の緯度,経度,北東の緯度,経度 または対角2地点のURL）",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "time_limit",
+							Description: "各ラウンドの制限時間（秒）。過ぎると推測を締め切ります",
+							Required:    false,
+							MinValue:    float64Ptr(guess.MinRoundSeconds),
+							MaxValue:    guess.MaxRoundSeconds,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "time_bonus",
+							Description: "早い推測ほど加点・遅いほど減点する（time_limit が必要）",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "mode",
+							Description: "採点方法（既定: 距離）。日本のマップでは都道府県で判定します",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "距離", Value: string(guess.ModeDistance)},
+								{Name: "国・都道府県当て", Value: string(guess.ModeRegion)},
+								{Name: "ストリーク（誰かが外すまで続く）", Value: string(guess.ModeStreak)},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "teams",
+							Description: "チーム戦にする（チームのスコアの計算方法）",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "メンバーのベスト", Value: string(guess.TeamBest)},
+								{Name: "メンバーの平均", Value: string(guess.TeamAverage)},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "scoring",
+							Description: "距離からスコアへの換算方法（既定: 指数）。国・都道府県当てとストリークでは使えません",
+							Required:    false,
+							Choices:     scorerChoices(),
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "scoring_params",
+							Description: "採点のパラメータ（例: decay=5 / zero=0.5 / scale=25km / 1km=5000,10km=4000,100km=1000）",
+							Required:    false,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "pool",
+							Description:  "場所プールから出題するタグ（all ですべての場所から）",
+							Required:     false,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "duel",
+					Description: "1対1のデュエルを開始（HP制・スコア差がダメージ）",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "opponent",
+							Description: "対戦相手（省略するとレーティングの近い相手を待ちます）",
+							Required:    false,
+						},
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "map",
+							Description:  "マップ（スコアの基準になる範囲。既定: 世界）",
+							Required:     false,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "time_limit",
+							Description: "各ラウンドの制限時間（秒）。過ぎると推測を締め切ります",
+							Required:    false,
+							MinValue:    float64Ptr(guess.MinRoundSeconds),
+							MaxValue:    guess.MaxRoundSeconds,
+						},
+					},
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -283,13 +539,13 @@ func GetCommands() []*discordgo.ApplicationCommand {
 				{
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Name:        "guess",
-					Description: "推測を送信",
+					Description: "推測を送信（自分にだけ表示・正解の発表まで非公開）",
 					Options: []*discordgo.ApplicationCommandOption{
 						{
 							Type:        discordgo.ApplicationCommandOptionString,
 							Name:        "url",
-							Description: "Google Mapsの短縮URL",
-							Required:    true,
+							Description: "地図のURL（Google Maps・OSM・Apple・Bing）、座標、DMS、Plus Code など（省略時は入力フォーム）",
+							Required:    false,
 						},
 					},
 				},
@@ -301,11 +557,337 @@ func GetCommands() []*discordgo.ApplicationCommand {
 						{
 							Type:        discordgo.ApplicationCommandOptionString,
 							Name:        "url",
-							Description: "正解のGoogle Maps URL",
+							Description: "正解の地図URLまたは座標（省略時は封印された正解を公開）",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "seal",
+					Description: "正解を事前に封印して登録（主催者用）",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "map",
+					Description: "カスタムマップを管理",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "save",
+							Description: "このサーバー用のマップを保存",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "name",
+									Description: "マップ名",
+									Required:    true,
+									MaxLength:   32,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "bounds",
+									Description: "範囲（南西の緯度,経度,北東の緯度,経度 または対角2地点のURL）",
+									Required:    true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "list",
+							Description: "使えるマップの一覧",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "delete",
+							Description: "保存したマップを削除（保存した本人かサーバー管理者のみ）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "name",
+									Description:  "マップ名",
+									Required:     true,
+									Autocomplete: true,
+								},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "team",
+					Description: "チーム戦のチームを管理",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "create",
+							Description: "チームを作成して参加",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "name",
+									Description: "チーム名",
+									Required:    true,
+									MaxLength:   guess.MaxTeamNameLength,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "join",
+							Description: "チームに参加（別のチームからの移籍も可）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "name",
+									Description: "チーム名",
+									Required:    true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "list",
+							Description: "チームとメンバーの一覧",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "balance",
+							Description: "過去の平均スコアから実力が均等になるようにチーム分け（主催者用）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "teams",
+									Description: "チーム数",
+									Required:    true,
+									MinValue:    float64Ptr(2),
+									MaxValue:    guess.MaxTeams,
+								},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "pool",
+					Description: "ゲームやデイリーチャレンジで出題する場所を管理",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "add",
+							Description: "出題する場所を登録（自分が登録した場所には推測できません）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "url",
+									Description: "正解の場所（地図のURLまたは座標）",
+									Required:    true,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "clue",
+									Description: "出題時に表示するヒント（写真のURLなど。場所が分からないもの）",
+									Required:    true,
+									MaxLength:   guess.MaxClueLength,
+								},
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "tags",
+									Description: "タグ（カンマ区切り。例: japan,urban,hard）",
+									Required:    false,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "remove",
+							Description: "登録した場所を削除（サーバー管理者はすべての場所を削除可）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionInteger,
+									Name:        "id",
+									Description: "場所の番号（/guess pool list で確認）",
+									Required:    true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "list",
+							Description: "登録された場所の一覧（自分にだけ表示）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "tag",
+									Description:  "このタグの場所だけを表示",
+									Required:     false,
+									Autocomplete: true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "import",
+							Description: "複数の場所をまとめて登録（入力フォームを開きます）",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+					Name:        "daily",
+					Description: "毎日決まった時刻に出題するデイリーチャレンジ",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "enable",
+							Description: "このチャンネルでデイリーチャレンジを開始（サーバー管理者用）",
+							Options: []*discordgo.ApplicationCommandOption{
+								{
+									Type:        discordgo.ApplicationCommandOptionString,
+									Name:        "time",
+									Description: "出題する時刻（HH:MM、日本時間）",
+									Required:    true,
+								},
+								{
+									Type:         discordgo.ApplicationCommandOptionString,
+									Name:         "tag",
+									Description:  "このタグの場所から出題（既定: すべての場所）",
+									Required:     false,
+									Autocomplete: true,
+								},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "disable",
+							Description: "デイリーチャレンジを停止して出題中のチャレンジの結果を発表（サーバー管理者用）",
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionSubCommand,
+							Name:        "status",
+							Description: "デイリーチャレンジの設定を確認",
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "queue",
+					Description: "封印した正解を確認（自分にだけ表示）",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "clear",
+							Description: "次のゲーム用に保存した正解を削除",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "leaderboard",
+					Description: "サーバーのランキングを表示",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "period",
+							Description: "集計期間（既定: 全期間）",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "全期間", Value: string(guess.PeriodAll)},
+								{Name: "今月", Value: string(guess.PeriodMonth)},
+								{Name: "今週", Value: string(guess.PeriodWeek)},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "sort",
+							Description: "並び順（既定: 合計スコア）",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "合計スコア", Value: string(guess.SortTotal)},
+								{Name: "平均スコア", Value: string(guess.SortAverage)},
+								{Name: "5000点の回数", Value: string(guess.SortPerfect)},
+								{Name: "ゲーム数", Value: string(guess.SortGames)},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "teams",
+							Description: "チーム戦のチームのランキングを表示",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "stats",
+					Description: "プレイヤーの成績を表示",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "user",
+							Description: "対象のユーザー（既定: 自分）",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "rating",
+					Description: "プレイヤーのレーティングを表示",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionUser,
+							Name:        "user",
+							Description: "対象のユーザー（既定: 自分）",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "recompute_ratings",
+					Description: "過去の推測からレーティングを再計算（サーバー管理者用）",
+				},
+			},
+		},
+		{
+			Name:                     "settings",
+			Description:              "ボットの設定を変更します",
+			DMPermission:             boolPtr(false),
+			DefaultMemberPermissions: int64Ptr(discordgo.PermissionManageServer),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "visibility",
+					Description: "返信の表示方法を設定",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "command",
+							Description:  "対象のコマンド（例: nomikai status, *: 全コマンド, error: エラー返信）",
+							Required:     true,
+							Autocomplete: true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "mode",
+							Description: "表示方法",
 							Required:    true,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "公開", Value: string(VisibilityPublic)},
+								{Name: "本人のみ", Value: string(VisibilityEphemeral)},
+								{Name: "DM", Value: string(VisibilityDM)},
+								{Name: "既定に戻す", Value: "default"},
+							},
 						},
 					},
 				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "list",
+					Description: "返信の表示方法の設定一覧",
+				},
 			},
 		},
 		{
@@ -318,3 +900,32 @@ func GetCommands() []*discordgo.ApplicationCommand {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+// scorerChoices offers the scoring models of geoscore by their display names.
+func scorerChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(geoscore.ScorerKinds))
+	for idx, kind := range geoscore.ScorerKinds {
+		choices[idx] = &discordgo.ApplicationCommandOptionChoice{Name: guess.ScorerLabel(kind), Value: string(kind)}
+	}
+	return choices
+}
+
+// nomikaiEventOption lets a /nomikai subcommand act on an event other than the user's current
+// one in the channel.
+func nomikaiEventOption() *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "event",
+		Description:  "対象の飲み会（未指定なら /nomikai use で選んだもの、なければ最新）",
+		Required:     false,
+		Autocomplete: true,
+	}
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
@@ -1,21 +1,26 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"sort"
+	"log/slog"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
 	"github.com/susu3304/nkmzbot/internal/geourl"
 	"github.com/susu3304/nkmzbot/internal/guess"
+	"github.com/susu3304/nkmzbot/internal/guessmap"
 )
 
-func HandleGuess(s *discordgo.Session, i *discordgo.InteractionCreate, svc *guess.Service) {
+func HandleGuess(s Session, i *discordgo.InteractionCreate, svc *guess.Service) {
 	data := i.ApplicationCommandData()
 	if len(data.Options) == 0 {
-		respondText(s, i, "サブコマンドが指定されていません")
+		respondError(s, i, "サブコマンドが指定されていません")
 		return
 	}
 
@@ -28,160 +33,1317 @@ func HandleGuess(s *discordgo.Session, i *discordgo.InteractionCreate, svc *gues
 		// Parse guild ID to int64
 		gid, errParse := strconv.ParseInt(i.GuildID, 10, 64)
 		if errParse != nil || gid == 0 {
-			respondText(s, i, "ギルドIDの取得に失敗しました")
+			respondError(s, i, "ギルドIDの取得に失敗しました")
 			return
 		}
-		err := svc.StartSession(context.Background(), channelID, gid, userID)
-		if err != nil {
-			if err == guess.ErrSessionAlreadyExists {
-				respondText(s, i, "このチャンネルには既にセッションが開始されています")
-			} else {
-				respondText(s, i, "セッションの開始に失敗しました: "+err.Error())
+		rounds := 1
+		if opt := getIntOption(sub.Options, "rounds"); opt != nil {
+			rounds = int(*opt)
+		}
+		mapOpt := getStringOption(sub.Options, "map")
+		boundsOpt := getStringOption(sub.Options, "bounds")
+		timeLimit := 0
+		if opt := getIntOption(sub.Options, "time_limit"); opt != nil {
+			timeLimit = int(*opt)
+		}
+		timeBonus := false
+		if opt := getBoolOption(sub.Options, "time_bonus"); opt != nil {
+			timeBonus = *opt
+		}
+		if timeBonus && timeLimit == 0 {
+			respondError(s, i, "タイムボーナスを使うには time_limit で制限時間を指定してください")
+			return
+		}
+		mode := guess.ModeDistance
+		if opt := getStringOption(sub.Options, "mode"); opt != nil {
+			var err error
+			if mode, err = guess.ParseMode(*opt); err != nil {
+				respondError(s, i, err.Error())
+				return
 			}
+		}
+		if mode == guess.ModeStreak && rounds > 1 {
+			respondError(s, i, "ストリークは誰かが外すまで続くため、ラウンド数は指定できません")
 			return
 		}
-		respondText(s, i, "✅ ジオゲッサーセッションを開始しました！\n`/guess <Google Maps URL>` で推測を送信してください")
+		var teams guess.TeamScoring
+		if opt := getStringOption(sub.Options, "teams"); opt != nil {
+			var err error
+			if teams, err = guess.ParseTeamScoring(*opt); err != nil {
+				respondError(s, i, err.Error())
+				return
+			}
+		}
+		var scorer geoscore.Scorer
+		scoringOpt := getStringOption(sub.Options, "scoring")
+		scoringParamsOpt := getStringOption(sub.Options, "scoring_params")
+		if scoringOpt != nil || scoringParamsOpt != nil {
+			kind, params := "", ""
+			if scoringOpt != nil {
+				kind = *scoringOpt
+			}
+			if scoringParamsOpt != nil {
+				params = *scoringParamsOpt
+			}
+			var err error
+			if scorer, err = guess.ParseScorer(kind, params); err != nil {
+				respondError(s, i, err.Error())
+				return
+			}
+		}
+		fromPool, poolTag := false, ""
+		if opt := getStringOption(sub.Options, "pool"); opt != nil {
+			fromPool = true
+			if tag := strings.ToLower(strings.TrimSpace(*opt)); tag != "all" {
+				poolTag = tag
+			}
+		}
+
+		// Map corners given at start need URL expansion, which might take time. Answers are
+		// sealed beforehand with /guess seal, never through options that everyone can see.
+		respondSlow(s, i, func() (string, error) {
+			scale, err := resolveGameMap(svc, gid, mapOpt, boundsOpt)
+			if err != nil {
+				return "", err
+			}
+
+			sealed, err := svc.StartSession(context.Background(), channelID, gid, userID, guess.StartOptions{
+				Rounds:       rounds,
+				Map:          scale,
+				RoundSeconds: timeLimit,
+				TimeBonus:    timeBonus,
+				Mode:         mode,
+				Teams:        teams,
+				Scorer:       scorer,
+				FromPool:     fromPool,
+				PoolTag:      poolTag,
+			})
+			if err != nil {
+				if err == guess.ErrSessionAlreadyExists {
+					return "", sessionExistsError(svc, channelID)
+				}
+				return "", fmt.Errorf("セッションの開始に失敗しました: %w", err)
+			}
+			msg := "✅ ジオゲッサーセッションを開始しました！"
+			if rounds > 1 {
+				msg += fmt.Sprintf("（全%dラウンド）\nラウンド 1/%d", rounds, rounds)
+			}
+			msg += "\n🗺️ マップ: " + guess.FormatMapScale(scale.Label, scale.MaxErrorDistance())
+			if scorer != nil {
+				msg += "\n📐 採点: " + guess.FormatScorer(scorer)
+			}
+			if mode.ByRegion() {
+				msg += "\n🎯 モード: " + mode.Label(scale.InJapan())
+				if mode == guess.ModeStreak {
+					msg += "（全員が正解する限りラウンドが続きます。誰かが外したら終了）"
+				}
+			}
+			if teams != "" {
+				msg += fmt.Sprintf("\n👥 チーム戦（チームのスコア: メンバーの%s）\n`/guess team create` でチームを作るか `/guess team join` で参加してください", teams.Label())
+			}
+			if timeLimit > 0 {
+				msg += "\n⏱️ 制限時間: 各ラウンド" + formatSeconds(timeLimit)
+				if timeBonus {
+					msg += fmt.Sprintf("（タイムボーナスあり: 早いほど最大+%.0f%%、締切間際は最大-%.0f%%）", guess.MaxTimeBonusRate*100, guess.MaxTimeBonusRate*100)
+				}
+			}
+			if fromPool {
+				msg += "\n📦 場所プール"
+				if poolTag != "" {
+					msg += fmt.Sprintf("（タグ: %s）", poolTag)
+				}
+				msg += "から出題します。正解はラウンド終了時に公開されます"
+				clue, err := svc.CurrentClue(context.Background(), channelID)
+				if err != nil {
+					return "", fmt.Errorf("セッションを開始しましたが、ヒントの取得に失敗しました: %w", err)
+				}
+				msg += "\n🖼️ ヒント: " + clue
+			} else if sealed > 0 {
+				msg += fmt.Sprintf("\n🔒 %dラウンド分の正解が封印されています。ラウンド終了時に公開されます", sealed)
+			}
+			return msg + "\n`/guess guess <地図のURLまたは座標>` で推測を送信してください", nil
+		})
+
+	case "duel":
+		handleGuessDuel(s, i, svc, data, sub)
 
 	case "stop":
-		err := svc.StopSession(context.Background(), channelID)
+		standings, teamStandings, err := svc.StopSession(context.Background(), channelID)
 		if err != nil {
 			if err == guess.ErrNoActiveSession {
-				respondText(s, i, "このチャンネルにはアクティブなセッションがありません")
+				respondError(s, i, "このチャンネルにはアクティブなセッションがありません")
+			} else if err == guess.ErrDailyStop {
+				respondError(s, i, err.Error())
 			} else {
-				respondText(s, i, "セッションの終了に失敗しました: "+err.Error())
+				respondError(s, i, "セッションの終了に失敗しました: "+err.Error())
 			}
 			return
 		}
-		respondText(s, i, "✅ セッションを終了しました")
+		msg := "✅ セッションを終了しました"
+		if len(teamStandings) > 0 {
+			msg += "\n\n" + formatTeamStandings("🏁 **チーム最終結果**", teamStandings)
+		}
+		if len(standings) > 0 {
+			msg += "\n\n" + formatStandings("🏁 **最終結果**", standings)
+		}
+		respondText(s, i, msg)
 
 	case "guess":
+		// Guesses are acknowledged privately and the form keeps the URL out of the channel.
+		if urlOpt := getStringOption(sub.Options, "url"); urlOpt != nil {
+			submitGuess(s, i, svc, *urlOpt)
+		} else {
+			respondGuessModal(s, i)
+		}
+
+	case "answer":
 		urlOpt := getStringOption(sub.Options, "url")
-		if urlOpt == nil {
-			respondText(s, i, "URLの指定が必要です")
+
+		respondSlowWithFiles(s, i, resolveVisibility(i, false), func() (string, []*discordgo.File, error) {
+			var res *guess.RoundResult
+			var err error
+			if urlOpt == nil {
+				// Reveal the answer sealed for this round
+				res, err = svc.RevealAnswer(context.Background(), channelID)
+			} else {
+				lat, lng, finalURL, errExtract := geourl.ExpandAndExtractCoords(*urlOpt)
+				if errExtract != nil {
+					return "", nil, fmt.Errorf("座標の抽出に失敗しました: %w", errExtract)
+				}
+				// Set answer, score the round and move on to the next one
+				res, err = svc.SetAnswer(context.Background(), channelID, userID, lat, lng, finalURL)
+			}
+			if err != nil {
+				switch err {
+				case guess.ErrNoActiveSession:
+					return "", nil, fmt.Errorf("このチャンネルにはアクティブなセッションがありません")
+				case guess.ErrAnswerNotSet:
+					return "", nil, fmt.Errorf("このラウンドには封印された正解がありません。URLを指定してください")
+				case guess.ErrDuelistAnswer, guess.ErrDailyAnswer:
+					return "", nil, err
+				}
+				return "", nil, fmt.Errorf("スコアの計算に失敗しました: %w", err)
+			}
+			return FormatRoundResult(res), RoundResultFiles(res), nil
+		})
+
+	case "seal":
+		respondSealModal(s, i)
+
+	case "map":
+		handleGuessMap(s, i, svc, sub)
+
+	case "team":
+		handleGuessTeam(s, i, svc, sub)
+
+	case "pool":
+		handleGuessPool(s, i, svc, sub)
+
+	case "daily":
+		handleGuessDaily(s, i, svc, sub)
+
+	case "queue":
+		if opt := getBoolOption(sub.Options, "clear"); opt != nil && *opt {
+			n, err := svc.ClearQueuedAnswers(context.Background(), channelID, userID)
+			if err != nil {
+				respondError(s, i, "キューの削除に失敗しました: "+err.Error())
+				return
+			}
+			respondPrivate(s, i, fmt.Sprintf("🗑️ 次のゲーム用の正解 %d 件を削除しました", n))
 			return
 		}
+		answers, err := svc.PendingSealedAnswers(context.Background(), channelID, userID)
+		if err != nil {
+			respondError(s, i, "正解の取得に失敗しました: "+err.Error())
+			return
+		}
+		respondPrivate(s, i, formatSealedAnswers(answers))
 
-		// First, defer the response since URL expansion might take time
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-		})
+	case "leaderboard":
+		var opts guess.LeaderboardOptions
+		var err error
+		if opt := getStringOption(sub.Options, "period"); opt != nil {
+			if opts.Period, err = guess.ParsePeriod(*opt); err != nil {
+				respondError(s, i, err.Error())
+				return
+			}
+		}
+		if opt := getStringOption(sub.Options, "sort"); opt != nil {
+			if opts.Sort, err = guess.ParseLeaderboardSort(*opt); err != nil {
+				respondError(s, i, err.Error())
+				return
+			}
+		}
+		if teams := getBoolOption(sub.Options, "teams"); teams != nil && *teams {
+			entries, err := svc.TeamLeaderboard(context.Background(), ParseGuildID(i.GuildID), opts)
+			if err == guess.ErrTeamLeaderboardSort {
+				respondError(s, i, err.Error())
+				return
+			}
+			if err != nil {
+				respondError(s, i, "ランキングの取得に失敗しました: "+err.Error())
+				return
+			}
+			respondText(s, i, formatTeamLeaderboard(opts, entries))
+			return
+		}
+		entries, err := svc.Leaderboard(context.Background(), ParseGuildID(i.GuildID), opts)
 		if err != nil {
+			respondError(s, i, "ランキングの取得に失敗しました: "+err.Error())
 			return
 		}
+		respondText(s, i, formatLeaderboard(opts, entries))
 
-		// Expand URL and extract coordinates
-		lat, lng, finalURL, err := geourl.ExpandAndExtractCoords(*urlOpt)
+	case "stats":
+		target := getUserID(data, sub, "user")
+		if target == "" {
+			target = userID
+		}
+		st, err := svc.PlayerStats(context.Background(), ParseGuildID(i.GuildID), target)
 		if err != nil {
-			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-				Content: strPtr("座標の抽出に失敗しました: " + err.Error()),
-			})
+			respondError(s, i, "成績の取得に失敗しました: "+err.Error())
 			return
 		}
+		respondText(s, i, formatPlayerStats(st))
 
-		// Add guess to session
-		err = svc.AddGuess(context.Background(), channelID, userID, lat, lng, finalURL)
+	case "rating":
+		target := getUserID(data, sub, "user")
+		if target == "" {
+			target = userID
+		}
+		rating, err := svc.PlayerRating(context.Background(), ParseGuildID(i.GuildID), target)
 		if err != nil {
-			if err == guess.ErrNoActiveSession {
-				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-					Content: strPtr("このチャンネルにはアクティブなセッションがありません\n`/guess start` でセッションを開始してください"),
-				})
-			} else {
-				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-					Content: strPtr("推測の記録に失敗しました: " + err.Error()),
-				})
-			}
+			respondError(s, i, "レーティングの取得に失敗しました: "+err.Error())
 			return
 		}
+		respondText(s, i, formatPlayerRating(rating))
 
-		msg := fmt.Sprintf("✅ <@%s> の推測を記録しました！", userID)
-		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: &msg,
+	case "recompute_ratings":
+		if !canManageServer(i) {
+			respondError(s, i, "レーティングの再計算はサーバー管理者のみ実行できます")
+			return
+		}
+		gid := ParseGuildID(i.GuildID)
+		respondSlow(s, i, func() (string, error) {
+			players, rounds, err := svc.RecomputeRatings(context.Background(), gid)
+			if err != nil {
+				return "", fmt.Errorf("レーティングの再計算に失敗しました: %w", err)
+			}
+			return fmt.Sprintf("🔁 %dラウンドの結果から %d名のレーティングを再計算しました", rounds, players), nil
 		})
 
-	case "answer":
-		urlOpt := getStringOption(sub.Options, "url")
-		if urlOpt == nil {
-			respondText(s, i, "URLの指定が必要です")
-			return
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+	}
+}
+
+// RoundResultFiles renders the result map of res as an attachment. The map is a nice-to-have:
+// if it cannot be drawn, the result is posted as text only.
+func RoundResultFiles(res *guess.RoundResult) []*discordgo.File {
+	img, err := guessmap.RenderRound(res)
+	if err != nil {
+		slog.Warn("failed to render guess result map", "session_id", res.SessionID, "round", res.Round, "err", err)
+		return nil
+	}
+	return []*discordgo.File{{Name: "result.png", ContentType: "image/png", Reader: bytes.NewReader(img)}}
+}
+
+// FormatRoundResult renders the answer and ranking of a round, and for multi-round games the
+// running totals, or the final standings after the last round.
+func FormatRoundResult(res *guess.RoundResult) string {
+	multi := res.TotalRounds > 1
+
+	var b strings.Builder
+	if res.Sealed {
+		b.WriteString("🔓 封印された正解を公開します\n")
+	}
+	if multi {
+		fmt.Fprintf(&b, "📍 **%s の正解**: %s\n", guess.FormatRound(res.Round, res.TotalRounds, res.Mode), res.AnswerURL)
+	} else {
+		fmt.Fprintf(&b, "📍 **正解**: %s\n", res.AnswerURL)
+	}
+	if res.Mode.ByRegion() {
+		fmt.Fprintf(&b, "🎯 %s: **%s**\n", res.Mode.Label(res.ByPrefecture), guess.FormatPlace(res.AnswerPlace))
+	}
+	fmt.Fprintf(&b, "🗺️ マップ: %s\n", guess.FormatMapScale(guess.MapLabel(res.MapName), res.MaxErrorDistance))
+	if !res.Mode.ByRegion() {
+		fmt.Fprintf(&b, "📐 採点: %s\n", guess.FormatScorer(res.Scorer))
+	}
+	b.WriteString("\n")
+
+	if len(res.Results) == 0 {
+		b.WriteString("まだ誰も推測していません\n")
+	} else {
+		fmt.Fprintf(&b, "🏆 **結果** (%d名)\n", len(res.Results))
+		fmt.Fprintf(&b, "```\n")
+		for idx, r := range res.Results {
+			rank := idx + 1
+			fmt.Fprintf(&b, "%s %d位: %5d点 (%s)%s\n", rankEmoji(rank), rank, r.Score+r.TimeBonus, guess.FormatDistance(r.DistanceMeters), formatTimeBonus(r.TimeBonus))
+		}
+		fmt.Fprintf(&b, "```\n")
+		for idx, r := range res.Results {
+			rank := idx + 1
+			fmt.Fprintf(&b, "%d. <@%s>: **%d点** (距離: %s)%s\n", rank, r.UserID, r.Score+r.TimeBonus, guess.FormatDistance(r.DistanceMeters), formatTimeBonus(r.TimeBonus))
+			if res.Mode.ByRegion() {
+				fmt.Fprintf(&b, "   %s（%s）\n", formatRegionMatch(r.Match, res.ByPrefecture), guess.FormatPlace(r.Place))
+			}
+			fmt.Fprintf(&b, "   推測: %s\n", r.GuessURL)
+		}
+	}
+
+	if res.TeamScoring != "" {
+		b.WriteString("\n")
+		b.WriteString(formatTeamResults(res.TeamScoring, res.Teams))
+	}
+
+	if len(res.RatingChanges) > 0 {
+		b.WriteString("\n")
+		b.WriteString(formatRatingChanges(res.RatingChanges))
+	}
+
+	if res.Duel != nil && res.DuelRound != nil {
+		b.WriteString("\n")
+		b.WriteString(formatDuelRound(res.Duel, res.DuelRound, res.Finished))
+		if !res.Finished {
+			b.WriteString("\n" + formatNextRound(res))
 		}
+		return b.String()
+	}
 
-		// First, defer the response since URL expansion might take time
-		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-		})
+	if res.Mode == guess.ModeStreak {
+		b.WriteString("\n")
+		switch {
+		case !res.Finished:
+			fmt.Fprintf(&b, "🔥 ストリーク **%d** 継続中！\n", res.Streak)
+		case res.Streak >= res.TotalRounds:
+			fmt.Fprintf(&b, "🏅 ストリーク **%d** 達成！上限に達したため終了します\n", res.Streak)
+		default:
+			fmt.Fprintf(&b, "💥 ストリーク終了！記録: **%d**\n", res.Streak)
+		}
+	}
+	if !multi {
+		return b.String()
+	}
+	b.WriteString("\n")
+	if res.Finished {
+		if res.TeamScoring != "" {
+			b.WriteString(formatTeamStandings("🏁 **チーム最終結果**", res.TeamStandings))
+			b.WriteString("\n")
+		}
+		b.WriteString(formatStandings("🏁 **最終結果**", res.Standings))
+		return b.String()
+	}
+	if len(res.TeamStandings) > 0 {
+		b.WriteString(formatTeamStandings(fmt.Sprintf("📊 **チーム累計スコア** (%dラウンド終了)", res.Round), res.TeamStandings))
+		b.WriteString("\n")
+	}
+	if len(res.Standings) > 0 {
+		b.WriteString(formatStandings(fmt.Sprintf("📊 **累計スコア** (%dラウンド終了)", res.Round), res.Standings))
+		b.WriteString("\n")
+	}
+	b.WriteString(formatNextRound(res))
+	return b.String()
+}
+
+// formatNextRound announces the round res opened, with its clue if it was drawn from the pool.
+func formatNextRound(res *guess.RoundResult) string {
+	msg := fmt.Sprintf("➡️ %s を開始しました。推測を送信してください", guess.FormatRound(res.Round+1, res.TotalRounds, res.Mode))
+	if res.NextClue != "" {
+		msg += "\n🖼️ ヒント: " + res.NextClue
+	}
+	return msg
+}
+
+// formatRegionMatch shows how a region mode guess was judged.
+func formatRegionMatch(m geoscore.RegionMatch, byPrefecture bool) string {
+	switch m {
+	case geoscore.RegionExact:
+		return "✅ 正解"
+	case geoscore.RegionCountry:
+		return "🟡 国は正解・都道府県は不正解"
+	}
+	if byPrefecture {
+		return "❌ 国も不正解"
+	}
+	return "❌ 不正解"
+}
+
+// formatTimeBonus shows a time bonus or penalty after a score, or nothing when there is none.
+func formatTimeBonus(bonus int) string {
+	if bonus == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" ⏱️%+d", bonus)
+}
+
+// formatSeconds renders a duration in seconds as minutes and seconds.
+func formatSeconds(secs int) string {
+	switch {
+	case secs < 60:
+		return fmt.Sprintf("%d秒", secs)
+	case secs%60 == 0:
+		return fmt.Sprintf("%d分", secs/60)
+	}
+	return fmt.Sprintf("%d分%d秒", secs/60, secs%60)
+}
+
+// formatLeaderboard renders a guild leaderboard, showing the sorted column first.
+func formatLeaderboard(opts guess.LeaderboardOptions, entries []guess.LeaderboardEntry) string {
+	if opts.Period == "" {
+		opts.Period = guess.PeriodAll
+	}
+	if opts.Sort == "" {
+		opts.Sort = guess.SortTotal
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏆 **ランキング** (%s・%s順)\n", opts.Period.Label(), opts.Sort.Label())
+	if len(entries) == 0 {
+		b.WriteString("まだ記録がありません")
+		return b.String()
+	}
+	for idx, e := range entries {
+		rank := idx + 1
+		var main string
+		switch opts.Sort {
+		case guess.SortAverage:
+			main = fmt.Sprintf("平均 **%.0f点**", e.AverageScore)
+		case guess.SortPerfect:
+			main = fmt.Sprintf("5000点 **%d回**", e.Perfect)
+		case guess.SortGames:
+			main = fmt.Sprintf("**%dゲーム**", e.Games)
+		default:
+			main = fmt.Sprintf("**%d点**", e.TotalScore)
+		}
+		fmt.Fprintf(&b, "%s %d. <@%s>: %s (合計 %d点・平均 %.0f点・5000点 %d回・%dゲーム/%dラウンド)\n",
+			rankEmoji(rank), rank, e.UserID, main, e.TotalScore, e.AverageScore, e.Perfect, e.Games, e.Rounds)
+	}
+	return b.String()
+}
+
+// formatTeamLeaderboard renders the guild's team ranking.
+func formatTeamLeaderboard(opts guess.LeaderboardOptions, entries []guess.TeamLeaderboardEntry) string {
+	if opts.Period == "" {
+		opts.Period = guess.PeriodAll
+	}
+	if opts.Sort == "" {
+		opts.Sort = guess.SortTotal
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏆 **チームランキング** (%s・%s順)\n", opts.Period.Label(), opts.Sort.Label())
+	if len(entries) == 0 {
+		b.WriteString("まだ記録がありません")
+		return b.String()
+	}
+	for idx, e := range entries {
+		rank := idx + 1
+		var main string
+		switch opts.Sort {
+		case guess.SortAverage:
+			main = fmt.Sprintf("平均 **%.0f点**", e.AverageScore)
+		case guess.SortGames:
+			main = fmt.Sprintf("**%dゲーム**", e.Games)
+		default:
+			main = fmt.Sprintf("**%d点**", e.TotalScore)
+		}
+		fmt.Fprintf(&b, "%s %d. %s: %s (合計 %d点・平均 %.0f点・%d勝・%dゲーム/%dラウンド)\n",
+			rankEmoji(rank), rank, e.Name, main, e.TotalScore, e.AverageScore, e.Wins, e.Games, e.Rounds)
+	}
+	return b.String()
+}
+
+// formatPlayerStats renders a player's statistics in the guild.
+func formatPlayerStats(st *guess.PlayerStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 <@%s> の成績\n", st.UserID)
+	if st.Rounds == 0 {
+		b.WriteString("まだ記録がありません")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "ゲーム数: %d (%dラウンド)\n", st.Games, st.Rounds)
+	fmt.Fprintf(&b, "合計スコア: %d点 / 平均スコア: %.0f点\n", st.TotalScore, st.AverageScore)
+	fmt.Fprintf(&b, "平均距離: %s\n", guess.FormatDistance(st.AverageDistance))
+	fmt.Fprintf(&b, "5000点: %d回\n", st.Perfect)
+	if best := st.Best; best != nil {
+		fmt.Fprintf(&b, "🥇 ベストラウンド: **%d点** (距離: %s, %s)\n   推測: %s\n",
+			best.Score, guess.FormatDistance(best.DistanceMeters), best.PlayedAt.Format("2006-01-02"), best.GuessURL)
+		if best.AnswerURL != "" {
+			fmt.Fprintf(&b, "   正解: %s\n", best.AnswerURL)
+		}
+	}
+	return b.String()
+}
+
+// formatPlayerRating renders a player's rating, rank in the guild and latest changes.
+func formatPlayerRating(p *guess.PlayerRating) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 <@%s> のレーティング\n", p.UserID)
+	if p.Rounds == 0 {
+		fmt.Fprintf(&b, "まだレーティング対象のラウンドがありません（初期値 %.0f）", p.Rating.Rating)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "レーティング: **%.0f** ±%.0f", p.Rating.Rating, 2*p.RD)
+	if p.Provisional() {
+		b.WriteString(" (暫定)")
+	}
+	fmt.Fprintf(&b, "\nサーバー内 %d位 / %d人 (%dラウンド)\n", p.Rank, p.Players, p.Rounds)
+	if len(p.History) > 0 {
+		b.WriteString("最近の変動:\n")
+		for _, c := range p.History {
+			fmt.Fprintf(&b, "・%s %.0f → %.0f (%s)\n", c.At.Format("2006-01-02"), c.Before, c.After, formatRatingDelta(c))
+		}
+	}
+	return b.String()
+}
+
+// formatRatingChanges renders the rating changes of a round on one line.
+func formatRatingChanges(changes []guess.RatingChange) string {
+	parts := make([]string, len(changes))
+	for idx, c := range changes {
+		parts[idx] = fmt.Sprintf("<@%s> %.0f (%s)", c.UserID, c.After, formatRatingDelta(c))
+	}
+	return "📈 レーティング: " + strings.Join(parts, " / ") + "\n"
+}
+
+// formatRatingDelta shows how much a rating moved, rounded the same way as the ratings.
+func formatRatingDelta(c guess.RatingChange) string {
+	return fmt.Sprintf("%+d", int(math.Round(c.After))-int(math.Round(c.Before)))
+}
+
+// formatStandings renders cumulative scores under title, best first.
+func formatStandings(title string, standings []guess.Standing) string {
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	if len(standings) == 0 {
+		b.WriteString("スコアのあるプレイヤーはいません\n")
+		return b.String()
+	}
+	for idx, st := range standings {
+		rank := idx + 1
+		fmt.Fprintf(&b, "%s %d. <@%s>: **%d点** (%dラウンド)\n", rankEmoji(rank), rank, st.UserID, st.TotalScore, st.RoundsPlayed)
+	}
+	return b.String()
+}
+
+// hpBarWidth is the number of cells in a duel HP bar.
+const hpBarWidth = 20
+
+// formatDuelRound renders the damage of a duel round, both players' HP and the winner once the
+// duel is over.
+func formatDuelRound(d *guess.Duel, dr *guess.DuelRound, finished bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚔️ **デュエル** (ダメージ倍率 ×%s)\n", strconv.FormatFloat(dr.Multiplier, 'f', -1, 64))
+	if dr.DamagedID == "" {
+		b.WriteString("🤝 同点のためダメージなし\n")
+	} else {
+		fmt.Fprintf(&b, "💥 <@%s> に **%d** ダメージ！ (スコア差 %d)\n", dr.DamagedID, dr.Damage, abs(dr.ChallengerScore-dr.OpponentScore))
+	}
+	fmt.Fprintf(&b, "<@%s> %s\n", d.ChallengerID, formatHPBar(d.ChallengerHP))
+	fmt.Fprintf(&b, "<@%s> %s\n", d.OpponentID, formatHPBar(d.OpponentHP))
+	switch {
+	case d.WinnerID != "":
+		fmt.Fprintf(&b, "🏆 <@%s> の勝利！\n", d.WinnerID)
+	case finished:
+		b.WriteString("🤝 ラウンドの上限に達しました。HPが同じため引き分けです\n")
+	}
+	return b.String()
+}
+
+// formatHPBar draws hp out of guess.DuelStartHP as a bar; any HP left shows at least one cell.
+func formatHPBar(hp int) string {
+	filled := (hp*hpBarWidth + guess.DuelStartHP - 1) / guess.DuelStartHP
+	filled = max(0, min(hpBarWidth, filled))
+	return fmt.Sprintf("❤️ `%s%s` %d/%d", strings.Repeat("█", filled), strings.Repeat("░", hpBarWidth-filled), hp, guess.DuelStartHP)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// resolveGameMap returns the map of a new game: custom bounds, a named map, or the world.
+func resolveGameMap(svc *guess.Service, gid int64, mapOpt, boundsOpt *string) (guess.MapScale, error) {
+	switch {
+	case boundsOpt != nil:
+		scale, err := parseBounds(guess.CustomMapName, *boundsOpt)
 		if err != nil {
+			return guess.MapScale{}, err
+		}
+		scale.Label = guess.MapLabel(guess.CustomMapName)
+		return scale, nil
+	case mapOpt != nil:
+		scale, err := svc.ResolveMap(context.Background(), gid, *mapOpt)
+		if err != nil {
+			if err == guess.ErrMapNotFound {
+				return guess.MapScale{}, fmt.Errorf("マップ '%s' が見つかりません。`/guess map list` で確認できます", *mapOpt)
+			}
+			return guess.MapScale{}, fmt.Errorf("マップの取得に失敗しました: %w", err)
+		}
+		return scale, nil
+	}
+	return guess.World, nil
+}
+
+// handleGuessDuel starts a duel between the invoker and the opponent in the channel. Without an
+// opponent, the invoker is matched with a waiting player of similar rating, or waits for one.
+func handleGuessDuel(s Session, i *discordgo.InteractionCreate, svc *guess.Service, data discordgo.ApplicationCommandInteractionData, sub *discordgo.ApplicationCommandInteractionDataOption) {
+	gid := ParseGuildID(i.GuildID)
+	if gid == 0 {
+		respondError(s, i, "ギルドIDの取得に失敗しました")
+		return
+	}
+	channelID := i.ChannelID
+	userID := InteractionUserID(i)
+	opponentID := getUserID(data, sub, "opponent")
+	if opponentID != "" && data.Resolved != nil {
+		if u := data.Resolved.Users[opponentID]; u != nil && u.Bot {
+			respondError(s, i, "ボットとはデュエルできません")
 			return
 		}
+	}
+	timeLimit := 0
+	if opt := getIntOption(sub.Options, "time_limit"); opt != nil {
+		timeLimit = int(*opt)
+	}
+	mapOpt := getStringOption(sub.Options, "map")
 
-		// Expand URL and extract coordinates
-		lat, lng, finalURL, err := geourl.ExpandAndExtractCoords(*urlOpt)
+	respondSlow(s, i, func() (string, error) {
+		scale, err := resolveGameMap(svc, gid, mapOpt, nil)
 		if err != nil {
-			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-				Content: strPtr("座標の抽出に失敗しました: " + err.Error()),
-			})
-			return
+			return "", err
+		}
+		opts := guess.StartOptions{Map: scale, RoundSeconds: timeLimit}
+		if opponentID == "" {
+			duel, rating, err := svc.MatchDuel(context.Background(), channelID, gid, userID, opts)
+			if err != nil {
+				return "", duelStartError(svc, channelID, err)
+			}
+			if duel == nil {
+				return fmt.Sprintf("⏳ <@%s> (レーティング %.0f) が対戦相手を待っています。\n"+
+					"%s以内にレーティングの近いメンバーが `/guess duel` を opponent なしで実行するとデュエルが始まります"+
+					"（マップと制限時間は後から実行したメンバーの指定になります）",
+					userID, rating, formatSeconds(int(guess.DuelQueueTimeout.Seconds()))), nil
+			}
+			return "🤝 マッチしました！\n" + formatDuelStart(duel.ChallengerID, duel.OpponentID, scale, timeLimit), nil
 		}
+		if err := svc.StartDuel(context.Background(), channelID, gid, userID, opponentID, opts); err != nil {
+			return "", duelStartError(svc, channelID, err)
+		}
+		return formatDuelStart(userID, opponentID, scale, timeLimit), nil
+	})
+}
+
+// sessionExistsError explains why no game can start in a channel that already has one. The
+// daily challenge keeps its channel busy until the next challenge is posted.
+func sessionExistsError(svc *guess.Service, channelID string) error {
+	if sess, err := svc.GetActiveSession(context.Background(), channelID); err == nil && sess.Daily {
+		return guess.ErrDailyRunning
+	}
+	return fmt.Errorf("このチャンネルには既にセッションが開始されています")
+}
+
+// duelStartError explains why a duel could not start.
+func duelStartError(svc *guess.Service, channelID string, err error) error {
+	switch err {
+	case guess.ErrSessionAlreadyExists:
+		return sessionExistsError(svc, channelID)
+	case guess.ErrDuelSelf:
+		return err
+	}
+	return fmt.Errorf("デュエルの開始に失敗しました: %w", err)
+}
+
+// formatDuelStart announces a duel and its rules.
+func formatDuelStart(challengerID, opponentID string, scale guess.MapScale, timeLimit int) string {
+	msg := fmt.Sprintf("⚔️ <@%s> vs <@%s> のデュエルを開始しました！\n", challengerID, opponentID)
+	msg += "🗺️ マップ: " + guess.FormatMapScale(scale.Label, scale.MaxErrorDistance())
+	if timeLimit > 0 {
+		msg += "\n⏱️ 制限時間: 各ラウンド" + formatSeconds(timeLimit)
+	}
+	msg += fmt.Sprintf("\n❤️ HP %d からスタート。毎ラウンド、スコアの低い方が差分のダメージを受けます（後半ほど倍率アップ）", guess.DuelStartHP)
+	msg += "\n対戦者は `/guess guess` で推測し、対戦者以外のメンバーが `/guess answer` で正解を発表してください"
+	return msg
+}
+
+// formatTeamResults renders the teams' scores in a round, best first.
+func formatTeamResults(scoring guess.TeamScoring, teams []guess.TeamResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "👥 **チーム結果** (メンバーの%s)\n", scoring.Label())
+	if len(teams) == 0 {
+		b.WriteString("チームがありません\n")
+		return b.String()
+	}
+	for idx, t := range teams {
+		rank := idx + 1
+		fmt.Fprintf(&b, "%s %d. %s: **%d点** (推測 %d/%d名)\n", rankEmoji(rank), rank, t.Name, t.Score, t.Guesses, t.Members)
+	}
+	return b.String()
+}
+
+// formatTeamStandings renders cumulative team scores under title, best first.
+func formatTeamStandings(title string, standings []guess.TeamStanding) string {
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	if len(standings) == 0 {
+		b.WriteString("スコアのあるチームはありません\n")
+		return b.String()
+	}
+	for idx, st := range standings {
+		rank := idx + 1
+		fmt.Fprintf(&b, "%s %d. %s: **%d点** (%dラウンド)\n", rankEmoji(rank), rank, st.Name, st.TotalScore, st.RoundsPlayed)
+	}
+	return b.String()
+}
+
+// formatTeams lists the teams of a game and their members.
+func formatTeams(title string, teams []guess.Team) string {
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	if len(teams) == 0 {
+		b.WriteString("まだチームがありません\n`/guess team create` で作成できます")
+		return b.String()
+	}
+	for _, t := range teams {
+		members := make([]string, len(t.Members))
+		for idx, userID := range t.Members {
+			members[idx] = "<@" + userID + ">"
+		}
+		if len(members) == 0 {
+			members = []string{"（メンバーなし）"}
+		}
+		fmt.Fprintf(&b, "・**%s** (%d名): %s\n", t.Name, len(t.Members), strings.Join(members, ", "))
+	}
+	return b.String()
+}
+
+// handleGuessTeam handles the /guess team subcommands of team games.
+func handleGuessTeam(s Session, i *discordgo.InteractionCreate, svc *guess.Service, group *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(group.Options) == 0 {
+		respondError(s, i, "サブコマンドが指定されていません")
+		return
+	}
+	sub := group.Options[0]
+	channelID := i.ChannelID
+	userID := InteractionUserID(i)
+	ctx := context.Background()
 
-		// Set answer and calculate scores
-		results, err := svc.SetAnswer(context.Background(), channelID, lat, lng, finalURL)
+	teamError := func(err error, action string) {
+		switch err {
+		case guess.ErrNoActiveSession, guess.ErrNotTeamGame, guess.ErrTeamNotFound, guess.ErrTeamExists,
+			guess.ErrTooManyTeams, guess.ErrTeamsLocked, guess.ErrNotTeamOrganizer:
+			respondError(s, i, err.Error())
+		default:
+			respondError(s, i, action+"に失敗しました: "+err.Error())
+		}
+	}
+
+	switch sub.Name {
+	case "create", "join":
+		nameOpt := getStringOption(sub.Options, "name")
+		if nameOpt == nil {
+			respondError(s, i, "name の指定が必要です")
+			return
+		}
+		var team *guess.Team
+		var err error
+		if sub.Name == "create" {
+			team, err = svc.CreateTeam(ctx, channelID, userID, *nameOpt)
+		} else {
+			team, err = svc.JoinTeam(ctx, channelID, userID, *nameOpt)
+		}
 		if err != nil {
-			if err == guess.ErrNoActiveSession {
-				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-					Content: strPtr("このチャンネルにはアクティブなセッションがありません"),
-				})
+			if sub.Name == "create" {
+				teamError(err, "チームの作成")
 			} else {
-				s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-					Content: strPtr("スコアの計算に失敗しました: " + err.Error()),
-				})
+				teamError(err, "チームへの参加")
 			}
 			return
 		}
+		verb := "に参加しました"
+		if sub.Name == "create" {
+			verb = "を作成して参加しました"
+		}
+		respondText(s, i, fmt.Sprintf("👥 <@%s> がチーム **%s** %s（%d名）", userID, team.Name, verb, len(team.Members)))
 
-		if len(results) == 0 {
-			msg := fmt.Sprintf("📍 正解: %s\n\nまだ誰も推測していません", finalURL)
-			s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-				Content: &msg,
-			})
+	case "list":
+		teams, err := svc.Teams(ctx, channelID)
+		if err != nil {
+			teamError(err, "チームの取得")
 			return
 		}
+		respondText(s, i, formatTeams("👥 **チーム一覧**", teams))
+
+	case "balance":
+		count := 0
+		if opt := getIntOption(sub.Options, "teams"); opt != nil {
+			count = int(*opt)
+		}
+		teams, err := svc.BalanceTeams(ctx, channelID, userID, count)
+		if err != nil {
+			teamError(err, "チーム分け")
+			return
+		}
+		respondText(s, i, formatTeams("⚖️ **過去の平均スコアでチーム分けしました**", teams))
+
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+	}
+}
+
+func rankEmoji(rank int) string {
+	switch rank {
+	case 1:
+		return "🥇"
+	case 2:
+		return "🥈"
+	case 3:
+		return "🥉"
+	}
+	return ""
+}
+
+// guessSealModalID is the custom ID of the modal opened by /guess seal.
+const guessSealModalID = "guess_seal"
+
+// maxSealedAnswers caps how many answers one submission may contain.
+const maxSealedAnswers = guess.MaxRounds
+
+// parseLocations reads one or more answer URLs, one per line (URLs on one line may also be
+// separated by spaces), and resolves each to coordinates.
+func parseLocations(text string) ([]guess.Location, error) {
+	var urls []string
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ';' }) {
+		fields := strings.Fields(line)
+		allURLs := len(fields) > 1
+		for _, f := range fields {
+			allURLs = allURLs && strings.Contains(f, "://")
+		}
+		if allURLs {
+			urls = append(urls, fields...)
+		} else if line = strings.TrimSpace(line); line != "" {
+			urls = append(urls, line)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("正解のURLが入力されていません")
+	}
+	if len(urls) > maxSealedAnswers {
+		return nil, fmt.Errorf("一度に登録できる正解は %d 件までです", maxSealedAnswers)
+	}
+
+	locs := make([]guess.Location, 0, len(urls))
+	for idx, u := range urls {
+		lat, lng, finalURL, err := geourl.ExpandAndExtractCoords(u)
+		if err != nil {
+			return nil, fmt.Errorf("%d 件目の座標の抽出に失敗しました: %w", idx+1, err)
+		}
+		locs = append(locs, guess.Location{Lat: lat, Lng: lng, URL: finalURL})
+	}
+	return locs, nil
+}
+
+// respondSealModal opens the modal in which the organizer enters sealed answers.
+func respondSealModal(s Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: guessSealModalID,
+			Title:    "正解を封印",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "answers",
+							Label:       "正解の地図URLまたは座標（1行に1つ）",
+							Style:       discordgo.TextInputParagraph,
+							Placeholder: "https://maps.app.goo.gl/...",
+							Required:    true,
+							MaxLength:   4000,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		InteractionLogger(i).Error("failed to create modal", "err", err)
+	}
+}
+
+// guessModalID is the custom ID of the modal opened by /guess guess without a URL.
+const guessModalID = "guess_guess"
+
+// respondGuessModal opens the modal in which a player enters their guess.
+func respondGuessModal(s Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: guessModalID,
+			Title:    "推測を送信",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "url",
+							Label:       "地図のURLまたは座標",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "https://maps.app.goo.gl/...",
+							Required:    true,
+							MaxLength:   1000,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		InteractionLogger(i).Error("failed to create modal", "err", err)
+	}
+}
+
+// submitGuess records the invoker's guess. Only the invoker sees the acknowledgement; the channel
+// sees how many players have guessed.
+func submitGuess(s Session, i *discordgo.InteractionCreate, svc *guess.Service, rawURL string) {
+	channelID := i.ChannelID
+	userID := InteractionUserID(i)
+	// URL expansion might take time; respondSlowPrivate defers the response if it does
+	respondSlowPrivate(s, i, func() (string, error) {
+		lat, lng, finalURL, err := geourl.ExpandAndExtractCoords(rawURL)
+		if err != nil {
+			return "", fmt.Errorf("座標の抽出に失敗しました: %w", err)
+		}
+
+		err = svc.AddGuess(context.Background(), channelID, userID, lat, lng, finalURL)
+		if err != nil {
+			switch err {
+			case guess.ErrNoActiveSession:
+				return "", fmt.Errorf("このチャンネルにはアクティブなセッションがありません\n`/guess start` でセッションを開始してください")
+			case guess.ErrOwnRound, guess.ErrRoundClosed, guess.ErrNotDuelist:
+				return "", err
+			}
+			return "", fmt.Errorf("推測の記録に失敗しました: %w", err)
+		}
+		updateGuessStatus(s, svc, channelID)
+		return "✅ 推測を記録しました（正解の発表まで他のメンバーには公開されません）\n推測: " + finalURL, nil
+	})
+}
+
+// guessStatusMu serializes status updates, so that guesses arriving together post one status
+// message per round and the last edit has the latest count.
+var guessStatusMu sync.Mutex
+
+// updateGuessStatus posts or edits the channel's message counting the guesses of the current
+// round. The count is a courtesy, so failures are only logged.
+func updateGuessStatus(s Session, svc *guess.Service, channelID string) {
+	guessStatusMu.Lock()
+	defer guessStatusMu.Unlock()
+
+	ctx := context.Background()
+	st, err := svc.GuessStatus(ctx, channelID)
+	if err != nil {
+		slog.Warn("failed to load guess status", "channel_id", channelID, "err", err)
+		return
+	}
+	content := formatGuessStatus(st)
+	if st.MessageID != "" {
+		_, err := s.ChannelMessageEdit(channelID, st.MessageID, content)
+		if err == nil {
+			return
+		}
+		// The message may have been deleted; post a new one.
+		slog.Warn("failed to edit guess status", "channel_id", channelID, "message_id", st.MessageID, "err", err)
+	}
+	msg, err := s.ChannelMessageSend(channelID, content)
+	if err != nil {
+		slog.Warn("failed to post guess status", "channel_id", channelID, "err", err)
+		return
+	}
+	if err := svc.SetGuessStatusMessage(ctx, st.SessionID, st.Round, msg.ID); err != nil {
+		slog.Warn("failed to record guess status message", "session_id", st.SessionID, "round", st.Round, "err", err)
+	}
+}
+
+// formatGuessStatus shows how many players have guessed, without any of the guesses.
+func formatGuessStatus(st *guess.GuessStatus) string {
+	round := ""
+	if st.TotalRounds > 1 {
+		round = guess.FormatRound(st.Round, st.TotalRounds, st.Mode) + " "
+	}
+	count := fmt.Sprintf("%d名", st.Guessed)
+	if st.Players > 0 {
+		count = fmt.Sprintf("%d/%d名", st.Guessed, st.Players)
+	}
+	return fmt.Sprintf("📝 %s**%s**が推測済み\n推測の内容は正解の発表まで非公開です。`/guess guess` で送信してください", round, count)
+}
+
+// IsGuessModal reports whether a modal custom ID belongs to /guess.
+func IsGuessModal(customID string) bool {
+	return customID == guessSealModalID || customID == guessModalID || customID == guessPoolImportModalID
+}
+
+// modalValue returns the value of the modal's text input with the custom ID.
+func modalValue(data discordgo.ModalSubmitInteractionData, customID string) string {
+	for _, component := range data.Components {
+		if actionRow, ok := component.(*discordgo.ActionsRow); ok {
+			for _, c := range actionRow.Components {
+				if input, ok := c.(*discordgo.TextInput); ok && input.CustomID == customID {
+					return input.Value
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// HandleGuessModalSubmit handles the /guess modals: a guess, the answers to seal, or locations
+// to add to the pool.
+func HandleGuessModalSubmit(s Session, i *discordgo.InteractionCreate, svc *guess.Service) {
+	data := i.ModalSubmitData()
+	switch data.CustomID {
+	case guessModalID:
+		submitGuess(s, i, svc, strings.TrimSpace(modalValue(data, "url")))
+	case guessSealModalID:
+		sealAnswers(s, i, svc, modalValue(data, "answers"))
+	case guessPoolImportModalID:
+		importPool(s, i, svc, modalValue(data, "locations"), modalValue(data, "tags"))
+	}
+}
+
+// sealAnswers stores the answers entered in the /guess seal modal.
+func sealAnswers(s Session, i *discordgo.InteractionCreate, svc *guess.Service, text string) {
+	channelID := i.ChannelID
+	userID := InteractionUserID(i)
+	respondSlowPrivate(s, i, func() (string, error) {
+		answers, err := parseLocations(text)
+		if err != nil {
+			return "", err
+		}
+		attached, queued, err := svc.SealAnswers(context.Background(), channelID, userID, answers)
+		if err != nil {
+			if err == guess.ErrNotOrganizer || err == guess.ErrDuelSealed {
+				return "", err
+			}
+			return "", fmt.Errorf("正解の登録に失敗しました: %w", err)
+		}
+		var parts []string
+		if attached > 0 {
+			parts = append(parts, fmt.Sprintf("進行中のゲームの %d ラウンド分", attached))
+		}
+		if queued > 0 {
+			parts = append(parts, fmt.Sprintf("次のゲーム用に %d 件", queued))
+		}
+		return "🔒 正解を封印しました: " + strings.Join(parts, "、") + "\nラウンド終了時に公開されます", nil
+	})
+}
+
+// formatSealedAnswers lists the invoker's unrevealed answers. Only ever send this privately.
+func formatSealedAnswers(answers []guess.SealedAnswer) string {
+	if len(answers) == 0 {
+		return "封印された正解はありません\n`/guess seal` で登録できます"
+	}
+	var b strings.Builder
+	b.WriteString("🔒 **封印された正解**（あなたにだけ表示されています）\n")
+	queued := 0
+	for _, a := range answers {
+		if a.RoundNumber == nil {
+			queued++
+			fmt.Fprintf(&b, "・次のゲーム #%d: <%s>\n", queued, a.URL)
+			continue
+		}
+		fmt.Fprintf(&b, "・ラウンド %d: <%s>\n", *a.RoundNumber, a.URL)
+	}
+	return b.String()
+}
 
-		// Sort results by score (descending)
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].Score > results[j].Score
+// handleGuessMap handles the /guess map subcommands that manage the guild's custom maps.
+func handleGuessMap(s Session, i *discordgo.InteractionCreate, svc *guess.Service, group *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(group.Options) == 0 {
+		respondError(s, i, "サブコマンドが指定されていません")
+		return
+	}
+	gid := ParseGuildID(i.GuildID)
+	if gid == 0 {
+		respondError(s, i, "ギルドIDの取得に失敗しました")
+		return
+	}
+	sub := group.Options[0]
+	userID := InteractionUserID(i)
+
+	switch sub.Name {
+	case "save":
+		nameOpt := getStringOption(sub.Options, "name")
+		boundsOpt := getStringOption(sub.Options, "bounds")
+		if nameOpt == nil || boundsOpt == nil {
+			respondError(s, i, "name と bounds の指定が必要です")
+			return
+		}
+		respondSlow(s, i, func() (string, error) {
+			scale, err := parseBounds(strings.ToLower(strings.TrimSpace(*nameOpt)), *boundsOpt)
+			if err != nil {
+				return "", err
+			}
+			if err := svc.SaveMap(context.Background(), gid, userID, scale); err != nil {
+				switch err {
+				case guess.ErrBuiltinMap, guess.ErrMapAlreadyExists:
+					return "", err
+				}
+				return "", fmt.Errorf("マップの保存に失敗しました: %w", err)
+			}
+			return fmt.Sprintf("✅ マップ '%s' を保存しました: %s\n`/guess start map:%s` で使えます",
+				scale.Name, guess.FormatMapScale(scale.Label, scale.MaxErrorDistance()), scale.Name), nil
 		})
 
+	case "list":
+		maps, err := svc.ListMaps(context.Background(), gid)
+		if err != nil {
+			respondError(s, i, "マップの取得に失敗しました: "+err.Error())
+			return
+		}
 		var b strings.Builder
-		fmt.Fprintf(&b, "📍 **正解**: %s\n\n", finalURL)
-		fmt.Fprintf(&b, "🏆 **結果** (%d名)\n", len(results))
-		fmt.Fprintf(&b, "```\n")
-		for idx, r := range results {
-			rank := idx + 1
-			emoji := ""
-			if rank == 1 {
-				emoji = "🥇"
-			} else if rank == 2 {
-				emoji = "🥈"
-			} else if rank == 3 {
-				emoji = "🥉"
+		b.WriteString("🗺️ **マップ一覧**\n")
+		for _, m := range maps {
+			fmt.Fprintf(&b, "・`%s`: %s", m.Name, guess.FormatMapScale(m.Label, m.MaxErrorDistance()))
+			if !m.IsWorld() {
+				fmt.Fprintf(&b, " [%.4f,%.4f → %.4f,%.4f]", m.SWLat, m.SWLng, m.NELat, m.NELng)
 			}
-			fmt.Fprintf(&b, "%s %d位: %5d点 (%s)\n", emoji, rank, r.Score, guess.FormatDistance(r.DistanceMeters))
+			b.WriteString("\n")
 		}
-		fmt.Fprintf(&b, "```\n")
-		for idx, r := range results {
-			rank := idx + 1
-			fmt.Fprintf(&b, "%d. <@%s>: **%d点** (距離: %s)\n   推測: %s\n", rank, r.UserID, r.Score, guess.FormatDistance(r.DistanceMeters), r.GuessURL)
+		respondText(s, i, b.String())
+
+	case "delete":
+		nameOpt := getStringOption(sub.Options, "name")
+		if nameOpt == nil {
+			respondError(s, i, "name の指定が必要です")
+			return
+		}
+		if err := svc.DeleteMap(context.Background(), gid, *nameOpt, userID, canManageServer(i)); err != nil {
+			switch err {
+			case guess.ErrBuiltinMap, guess.ErrMapNotFound, guess.ErrNotMapOwner:
+				respondError(s, i, err.Error())
+			default:
+				respondError(s, i, "マップの削除に失敗しました: "+err.Error())
+			}
+			return
 		}
+		respondText(s, i, fmt.Sprintf("🗑️ マップ '%s' を削除しました", *nameOpt))
 
-		msg := b.String()
-		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
-			Content: &msg,
-		})
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+	}
+}
+
+// parseBounds reads a bounding box as "lat1,lng1,lat2,lng2" or as two map URLs of opposite corners.
+func parseBounds(name, text string) (guess.MapScale, error) {
+	if strings.Contains(text, "://") {
+		urls := strings.Fields(text)
+		if len(urls) != 2 {
+			return guess.MapScale{}, fmt.Errorf("範囲は対角の2地点のURLをスペース区切りで指定してください")
+		}
+		var corners [2]guess.Location
+		for idx, u := range urls {
+			lat, lng, _, err := geourl.ExpandAndExtractCoords(u)
+			if err != nil {
+				return guess.MapScale{}, fmt.Errorf("%d 点目の座標の抽出に失敗しました: %w", idx+1, err)
+			}
+			corners[idx] = guess.Location{Lat: lat, Lng: lng}
+		}
+		return guess.NewMapScale(name, corners[0].Lat, corners[0].Lng, corners[1].Lat, corners[1].Lng)
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(text, ",", " "))
+	if len(fields) != 4 {
+		return guess.MapScale{}, fmt.Errorf("範囲は「南西の緯度,経度,北東の緯度,経度」か、対角の2地点のURLで指定してください")
+	}
+	var v [4]float64
+	for idx, f := range fields {
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return guess.MapScale{}, fmt.Errorf("数値として読み取れません: %s", f)
+		}
+		v[idx] = n
+	}
+	return guess.NewMapScale(name, v[0], v[1], v[2], v[3])
+}
 
+// HandleGuessAutocomplete suggests map names for the map options of /guess.
+func HandleGuessAutocomplete(s Session, i *discordgo.InteractionCreate, svc *guess.Service) {
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return
+	}
+	opts := data.Options[0].Options
+	if data.Options[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup && len(opts) > 0 {
+		opts = opts[0].Options
+	}
+	input := ""
+	focused := ""
+	for _, opt := range opts {
+		if opt.Focused {
+			input = strings.ToLower(strings.TrimSpace(opt.StringValue()))
+			focused = opt.Name
+		}
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	switch focused {
+	case "map", "name":
+		choices = mapChoices(i, svc, input)
+	case "pool", "tag":
+		choices = tagChoices(i, svc, input, focused == "pool")
 	default:
-		respondText(s, i, "未知のサブコマンドです")
+		return
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+}
+
+func mapChoices(i *discordgo.InteractionCreate, svc *guess.Service, input string) []*discordgo.ApplicationCommandOptionChoice {
+	maps, err := svc.ListMaps(context.Background(), ParseGuildID(i.GuildID))
+	if err != nil {
+		InteractionLogger(i).Warn("failed to list maps for autocomplete", "err", err)
+		maps = guess.BuiltinMaps
+	}
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, m := range maps {
+		if input != "" && !strings.Contains(m.Name, input) && !strings.Contains(m.Label, input) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  guess.FormatMapScale(m.Label, m.MaxErrorDistance()),
+			Value: m.Name,
+		})
+		if len(choices) == 25 {
+			break
+		}
 	}
+	return choices
 }
 
-func strPtr(s string) *string {
-	return &s
+// tagChoices suggests the tags of the guild's location pool; withAll adds "all" for the whole pool.
+func tagChoices(i *discordgo.InteractionCreate, svc *guess.Service, input string, withAll bool) []*discordgo.ApplicationCommandOptionChoice {
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	if withAll && strings.HasPrefix("all", input) {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: "all（すべての場所）", Value: "all"})
+	}
+	tags, err := svc.PoolTags(context.Background(), ParseGuildID(i.GuildID))
+	if err != nil {
+		InteractionLogger(i).Warn("failed to list pool tags for autocomplete", "err", err)
+		return choices
+	}
+	for _, tc := range tags {
+		if input != "" && !strings.Contains(tc.Tag, input) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  fmt.Sprintf("%s（%d件）", tc.Tag, tc.Count),
+			Value: tc.Tag,
+		})
+		if len(choices) == 25 {
+			break
+		}
+	}
+	return choices
 }
@@ -0,0 +1,376 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+func handleGuessPool(s Session, i *discordgo.InteractionCreate, svc *guess.Service, group *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(group.Options) == 0 {
+		respondError(s, i, "サブコマンドが指定されていません")
+		return
+	}
+	gid := ParseGuildID(i.GuildID)
+	if gid == 0 {
+		respondError(s, i, "ギルドIDの取得に失敗しました")
+		return
+	}
+	sub := group.Options[0]
+	userID := InteractionUserID(i)
+
+	switch sub.Name {
+	case "add":
+		urlOpt := getStringOption(sub.Options, "url")
+		clueOpt := getStringOption(sub.Options, "clue")
+		if urlOpt == nil || clueOpt == nil {
+			respondError(s, i, "url と clue の指定が必要です")
+			return
+		}
+		entry := guess.PoolEntry{Input: *urlOpt, Clue: strings.TrimSpace(*clueOpt)}
+		if opt := getStringOption(sub.Options, "tags"); opt != nil {
+			var err error
+			if entry.Tags, err = guess.ParseTags(*opt); err != nil {
+				respondError(s, i, err.Error())
+				return
+			}
+		}
+		// The answer must not show up in the channel, and URL expansion might take time.
+		respondSlowPrivate(s, i, func() (string, error) {
+			entries := []guess.PoolEntry{entry}
+			if err := guess.ResolvePoolEntries(context.Background(), entries); err != nil {
+				return "", fmt.Errorf("座標の抽出に失敗しました: %w", err)
+			}
+			ids, err := svc.AddPoolLocations(context.Background(), gid, userID, entries)
+			if err != nil {
+				return "", fmt.Errorf("場所の登録に失敗しました: %w", err)
+			}
+			msg := fmt.Sprintf("✅ 場所 #%d を登録しました: <%s>", ids[0], entries[0].URL)
+			if len(entry.Tags) > 0 {
+				msg += "\n🏷️ タグ: " + strings.Join(entry.Tags, ", ")
+			}
+			return msg, nil
+		})
+
+	case "remove":
+		idOpt := getIntOption(sub.Options, "id")
+		if idOpt == nil {
+			respondError(s, i, "id の指定が必要です")
+			return
+		}
+		if err := svc.RemovePoolLocation(context.Background(), gid, *idOpt, userID, canManageServer(i)); err != nil {
+			if err == guess.ErrPoolLocationNotFound || err == guess.ErrNotPoolOwner {
+				respondError(s, i, err.Error())
+				return
+			}
+			respondError(s, i, "場所の削除に失敗しました: "+err.Error())
+			return
+		}
+		respondPrivate(s, i, fmt.Sprintf("🗑️ 場所 #%d を削除しました", *idOpt))
+
+	case "list":
+		tag := ""
+		if opt := getStringOption(sub.Options, "tag"); opt != nil {
+			tag = strings.ToLower(strings.TrimSpace(*opt))
+		}
+		locs, err := svc.PoolLocations(context.Background(), gid, tag)
+		if err != nil {
+			respondError(s, i, "場所の取得に失敗しました: "+err.Error())
+			return
+		}
+		tags, err := svc.PoolTags(context.Background(), gid)
+		if err != nil {
+			respondError(s, i, "タグの取得に失敗しました: "+err.Error())
+			return
+		}
+		respondPrivate(s, i, formatPoolLocations(locs, tags, tag, userID, canManageServer(i)))
+
+	case "import":
+		respondPoolImportModal(s, i)
+
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+	}
+}
+
+// formatPoolLocations lists the pool, or the locations with tag, after the tags in use. The
+// answers are shown only for the user's own locations, or for all of them to server managers;
+// only ever send this privately.
+func formatPoolLocations(locs []guess.PoolLocation, tags []guess.TagCount, tag, userID string, all bool) string {
+	if len(locs) == 0 {
+		if tag != "" {
+			return fmt.Sprintf("タグ「%s」の場所はありません", tag)
+		}
+		return "登録された場所はありません\n`/guess pool add` で登録できます"
+	}
+	var b strings.Builder
+	if tag != "" {
+		fmt.Fprintf(&b, "📦 **タグ「%s」の場所** %d 件\n", tag, len(locs))
+	} else {
+		fmt.Fprintf(&b, "📦 **登録された場所** %d 件\n", len(locs))
+	}
+	if len(tags) > 0 {
+		parts := make([]string, len(tags))
+		for idx, tc := range tags {
+			parts[idx] = fmt.Sprintf("%s (%d)", tc.Tag, tc.Count)
+		}
+		b.WriteString("🏷️ タグ: " + strings.Join(parts, ", ") + "\n")
+	}
+	if !all {
+		b.WriteString("あなたが登録した場所だけを表示しています\n")
+	}
+	for _, l := range locs {
+		if !all && l.AddedBy != userID {
+			continue
+		}
+		fmt.Fprintf(&b, "・#%d <%s>", l.ID, l.URL)
+		if l.Clue != "" {
+			fmt.Fprintf(&b, " ヒント: %s", l.Clue)
+		}
+		if len(l.Tags) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(l.Tags, ", "))
+		}
+		if all {
+			fmt.Fprintf(&b, " （<@%s>）", l.AddedBy)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// guessPoolImportModalID is the custom ID of the modal opened by /guess pool import.
+const guessPoolImportModalID = "guess_pool_import"
+
+// respondPoolImportModal opens the modal in which locations are entered to add to the pool.
+func respondPoolImportModal(s Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: guessPoolImportModalID,
+			Title:    "場所をまとめて登録",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "locations",
+							Label:       "地図URLまたは座標 | ヒント | タグ（1行に1つ）",
+							Style:       discordgo.TextInputParagraph,
+							Placeholder: "35.681,139.767 | https://example.com/photo.jpg | japan,urban",
+							Required:    true,
+							MaxLength:   4000,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "tags",
+							Label:       "すべての場所に付けるタグ（カンマ区切り）",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "japan,hard",
+							Required:    false,
+							MaxLength:   200,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		InteractionLogger(i).Error("failed to create modal", "err", err)
+	}
+}
+
+// parsePoolLines reads the locations entered in the import modal, one per line as
+// "location | clue | tags", adding common to the tags of each.
+func parsePoolLines(text, common string) ([]guess.PoolEntry, error) {
+	var entries []guess.PoolEntry
+	for n, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "|", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%d 行目: 「場所 | ヒント」の形式で入力してください", n+1)
+		}
+		e := guess.PoolEntry{Input: strings.TrimSpace(fields[0]), Clue: strings.TrimSpace(fields[1])}
+		if e.Input == "" || e.Clue == "" {
+			return nil, fmt.Errorf("%d 行目: 場所とヒントの両方を入力してください", n+1)
+		}
+		tags := common
+		if len(fields) == 3 {
+			tags += "," + fields[2]
+		}
+		var err error
+		if e.Tags, err = guess.ParseTags(tags); err != nil {
+			return nil, fmt.Errorf("%d 行目: %w", n+1, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("場所が入力されていません")
+	}
+	return entries, nil
+}
+
+// importPool adds the locations entered in the /guess pool import modal.
+func importPool(s Session, i *discordgo.InteractionCreate, svc *guess.Service, text, tags string) {
+	gid := ParseGuildID(i.GuildID)
+	if gid == 0 {
+		respondError(s, i, "ギルドIDの取得に失敗しました")
+		return
+	}
+	userID := InteractionUserID(i)
+	respondSlowPrivate(s, i, func() (string, error) {
+		entries, err := parsePoolLines(text, tags)
+		if err != nil {
+			return "", err
+		}
+		if err := guess.ResolvePoolEntries(context.Background(), entries); err != nil {
+			return "", err
+		}
+		ids, err := svc.AddPoolLocations(context.Background(), gid, userID, entries)
+		if err != nil {
+			return "", fmt.Errorf("場所の登録に失敗しました: %w", err)
+		}
+		return fmt.Sprintf("✅ %d 件の場所を登録しました（#%d〜#%d）", len(ids), ids[0], ids[len(ids)-1]), nil
+	})
+}
+
+func handleGuessDaily(s Session, i *discordgo.InteractionCreate, svc *guess.Service, group *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(group.Options) == 0 {
+		respondError(s, i, "サブコマンドが指定されていません")
+		return
+	}
+	gid := ParseGuildID(i.GuildID)
+	if gid == 0 {
+		respondError(s, i, "ギルドIDの取得に失敗しました")
+		return
+	}
+	sub := group.Options[0]
+	userID := InteractionUserID(i)
+
+	switch sub.Name {
+	case "enable":
+		if !canManageServer(i) {
+			respondError(s, i, "デイリーチャレンジの設定はサーバー管理者のみ実行できます")
+			return
+		}
+		timeOpt := getStringOption(sub.Options, "time")
+		if timeOpt == nil {
+			respondError(s, i, "time の指定が必要です")
+			return
+		}
+		minute, err := guess.ParsePostTime(*timeOpt)
+		if err != nil {
+			respondError(s, i, err.Error())
+			return
+		}
+		tag := ""
+		if opt := getStringOption(sub.Options, "tag"); opt != nil {
+			tag = strings.ToLower(strings.TrimSpace(*opt))
+		}
+		if err := svc.SetDailySettings(context.Background(), gid, i.ChannelID, minute, tag, userID); err != nil {
+			respondError(s, i, "デイリーチャレンジの設定に失敗しました: "+err.Error())
+			return
+		}
+		from := "場所プール"
+		if tag != "" {
+			from = fmt.Sprintf("場所プールのタグ「%s」の場所", tag)
+		}
+		respondText(s, i, fmt.Sprintf("📅 このチャンネルで毎日 %s（日本時間）に%sからデイリーチャレンジを出題します\n"+
+			"出題する場所は `/guess pool add` で登録してください。前日のチャレンジの結果は次の出題時に発表します\n"+
+			"チャレンジは `/guess stop` では終了できず、出題中はこのチャンネルで他のゲームを開始できません",
+			guess.FormatPostTime(minute), from))
+
+	case "disable":
+		if !canManageServer(i) {
+			respondError(s, i, "デイリーチャレンジの設定はサーバー管理者のみ実行できます")
+			return
+		}
+		respondSlowWithFiles(s, i, resolveVisibility(i, false), func() (string, []*discordgo.File, error) {
+			if err := svc.DisableDaily(context.Background(), gid, userID); err != nil {
+				if err == guess.ErrDailyNotConfigured {
+					return "", nil, err
+				}
+				return "", nil, fmt.Errorf("デイリーチャレンジの停止に失敗しました: %w", err)
+			}
+			results, err := svc.CloseDailyChallenges(context.Background(), gid)
+			if err != nil {
+				return "", nil, fmt.Errorf("デイリーチャレンジを停止しましたが、結果の発表に失敗しました: %w", err)
+			}
+			msg := "✅ デイリーチャレンジを停止しました"
+			var files []*discordgo.File
+			for _, res := range results {
+				msg += "\n\n" + FormatDailyResult(&res)
+				files = append(files, RoundResultFiles(res.Result)...)
+			}
+			return msg, files, nil
+		})
+
+	case "status":
+		settings, err := svc.DailySettings(context.Background(), gid)
+		if err != nil && err != guess.ErrDailyNotConfigured {
+			respondError(s, i, "設定の取得に失敗しました: "+err.Error())
+			return
+		}
+		if settings == nil || !settings.Enabled {
+			respondText(s, i, "デイリーチャレンジは設定されていません\n`/guess daily enable` で開始できます")
+			return
+		}
+		locs, err := svc.PoolLocations(context.Background(), gid, settings.Tag)
+		if err != nil {
+			respondError(s, i, "場所の取得に失敗しました: "+err.Error())
+			return
+		}
+		withClue := 0
+		for _, l := range locs {
+			if l.Clue != "" {
+				withClue++
+			}
+		}
+		msg := fmt.Sprintf("📅 デイリーチャレンジ: <#%s> で毎日 %s（日本時間）に出題\n📦 出題できる場所: %d 件",
+			settings.ChannelID, guess.FormatPostTime(settings.PostMinute), withClue)
+		if settings.Tag != "" {
+			msg += fmt.Sprintf("（タグ: %s）", settings.Tag)
+		}
+		respondText(s, i, msg)
+
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+	}
+}
+
+// FormatDailyChallenge announces a daily challenge.
+func FormatDailyChallenge(ch *guess.DailyChallenge) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📅 **デイリーチャレンジ（%s）**\n", formatDailyDate(ch.Date))
+	fmt.Fprintf(&b, "🖼️ ヒント: %s\n", ch.Clue)
+	fmt.Fprintf(&b, "🗺️ マップ: %s\n", guess.FormatMapScale(guess.World.Label, guess.World.MaxErrorDistance()))
+	fmt.Fprintf(&b, "`/guess guess` で推測してください。締め切りは次のチャレンジの出題時（%s）です\n", guess.FormatPostTime(ch.PostMinute))
+	fmt.Fprintf(&b, "（出題者の <@%s> は推測できません）", ch.AddedBy)
+	return b.String()
+}
+
+// FormatDailyResult renders the result of a daily challenge with the players' streaks.
+func FormatDailyResult(res *guess.DailyResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📅 **デイリーチャレンジ（%s）の結果**\n", formatDailyDate(res.Date))
+	b.WriteString(strings.TrimRight(FormatRoundResult(res.Result), "\n"))
+	if len(res.Streaks) > 0 {
+		parts := make([]string, len(res.Streaks))
+		for idx, st := range res.Streaks {
+			parts[idx] = fmt.Sprintf("<@%s> %d日", st.UserID, st.Days)
+		}
+		b.WriteString("\n🔥 連続参加: " + strings.Join(parts, " / "))
+	}
+	return b.String()
+}
+
+func formatDailyDate(date time.Time) string {
+	return date.Format("2006-01-02")
+}
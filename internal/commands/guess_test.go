@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/susu3304/nkmzbot/internal/georegion"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+func TestParseBounds(t *testing.T) {
+	m, err := parseBounds("x", "35.82, 139.92, 35.52,139.56")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SWLat != 35.52 || m.NELng != 139.92 {
+		t.Fatalf("bounds = %+v", m)
+	}
+	for _, bad := range []string{"35.5,139.5", "a,b,c,d", "https://example.com/only-one"} {
+		if _, err := parseBounds("x", bad); err == nil {
+			t.Errorf("parseBounds(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFormatLeaderboard(t *testing.T) {
+	got := formatLeaderboard(guess.LeaderboardOptions{Sort: guess.SortAverage}, []guess.LeaderboardEntry{
+		{UserID: "1", TotalScore: 9000, AverageScore: 4500, Rounds: 2, Games: 1},
+		{UserID: "2", TotalScore: 12000, AverageScore: 4000, Perfect: 1, Rounds: 3, Games: 2},
+	})
+	if !strings.HasPrefix(got, "🏆 **ランキング** (全期間・平均スコア順)") {
+		t.Fatalf("title = %q", got)
+	}
+	if !strings.Contains(got, "🥇 1. <@1>: 平均 **4500点** (合計 9000点") || !strings.Contains(got, "🥈 2. <@2>: 平均 **4000点**") {
+		t.Fatalf("leaderboard = %q", got)
+	}
+	if got := formatLeaderboard(guess.LeaderboardOptions{Period: guess.PeriodWeek}, nil); !strings.Contains(got, "今週・合計スコア順") || !strings.Contains(got, "まだ記録がありません") {
+		t.Fatalf("empty = %q", got)
+	}
+}
+
+func TestFormatTeamLeaderboard(t *testing.T) {
+	got := formatTeamLeaderboard(guess.LeaderboardOptions{}, []guess.TeamLeaderboardEntry{
+		{Name: "Red", TotalScore: 12000, AverageScore: 4000, Wins: 2, Rounds: 3, Games: 2},
+		{Name: "Blue", TotalScore: 9000, AverageScore: 3000, Rounds: 3, Games: 2},
+	})
+	if !strings.HasPrefix(got, "🏆 **チームランキング** (全期間・合計スコア順)") {
+		t.Fatalf("title = %q", got)
+	}
+	if !strings.Contains(got, "🥇 1. Red: **12000点** (合計 12000点・平均 4000点・2勝・2ゲーム/3ラウンド)") || !strings.Contains(got, "🥈 2. Blue: **9000点**") {
+		t.Fatalf("leaderboard = %q", got)
+	}
+}
+
+func TestFormatRoundResultRegion(t *testing.T) {
+	tokyo := &georegion.Place{Country: georegion.Region{Code: "JP", Name: "日本"}, Prefecture: &georegion.Region{Code: "JP-13", Name: "東京都"}}
+	chiba := &georegion.Place{Country: tokyo.Country, Prefecture: &georegion.Region{Code: "JP-12", Name: "千葉県"}}
+	res := &guess.RoundResult{
+		AnswerURL:    "https://example.com/a",
+		Mode:         guess.ModeStreak,
+		ByPrefecture: true,
+		AnswerPlace:  tokyo,
+		Round:        3,
+		TotalRounds:  guess.MaxStreakRounds,
+		Streak:       2,
+		Finished:     true,
+		Results: []guess.GuessResult{
+			{UserID: "1", Score: 5000, Match: geoscore.RegionExact, Place: tokyo},
+			{UserID: "2", Score: 1000, Match: geoscore.RegionCountry, Place: chiba},
+			{UserID: "3", Match: geoscore.RegionMiss},
+		},
+	}
+	got := FormatRoundResult(res)
+	for _, want := range []string{
+		"📍 **ラウンド 3 の正解**",
+		"🎯 都道府県ストリーク: **東京都（日本）**",
+		"✅ 正解（東京都（日本））",
+		"🟡 国は正解・都道府県は不正解（千葉県（日本））",
+		"❌ 国も不正解（海上）",
+		"💥 ストリーク終了！記録: **2**",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %q", want, got)
+		}
+	}
+	if strings.Contains(got, "📐") {
+		t.Errorf("region result shows a scorer: %q", got)
+	}
+}
+
+func TestFormatRoundResultScorer(t *testing.T) {
+	res := &guess.RoundResult{AnswerURL: "https://example.com/a", Round: 1, TotalRounds: 1}
+	if got := FormatRoundResult(res); !strings.Contains(got, "📐 採点: 指数（GeoGuessr方式）\n") {
+		t.Errorf("default scorer missing in %q", got)
+	}
+	res.Scorer = geoscore.Stepped{Bands: []geoscore.Band{{MaxMeters: 500, Score: 5000}, {MaxMeters: 10000, Score: 2500}}}
+	if got := FormatRoundResult(res); !strings.Contains(got, "📐 採点: 段階 (〜500m: 5000点 / 〜10km: 2500点)\n") {
+		t.Errorf("stepped scorer missing in %q", got)
+	}
+}
+
+func TestFormatDuelRound(t *testing.T) {
+	d := &guess.Duel{ChallengerID: "1", OpponentID: "2", ChallengerHP: 0, OpponentHP: 4500, WinnerID: "2"}
+	got := formatDuelRound(d, &guess.DuelRound{Round: 4, Multiplier: 1.5, ChallengerScore: 1000, OpponentScore: 3000, Damage: 3000, DamagedID: "1"}, true)
+	for _, want := range []string{
+		"ダメージ倍率 ×1.5",
+		"<@1> に **3000** ダメージ！ (スコア差 2000)",
+		"<@1> ❤️ `░░░░░░░░░░░░░░░░░░░░` 0/6000",
+		"<@2> ❤️ `███████████████░░░░░` 4500/6000",
+		"🏆 <@2> の勝利！",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %q", want, got)
+		}
+	}
+	if got := formatHPBar(1); !strings.Contains(got, "`█░") {
+		t.Errorf("1 HP bar = %q", got)
+	}
+}
+
+func TestFormatGuessStatus(t *testing.T) {
+	tests := []struct {
+		st   guess.GuessStatus
+		want string
+	}{
+		{guess.GuessStatus{Round: 1, TotalRounds: 1, Guessed: 2}, "📝 **2名**が推測済み"},
+		{guess.GuessStatus{Round: 2, TotalRounds: 3, Guessed: 2, Players: 4}, "📝 ラウンド 2/3 **2/4名**が推測済み"},
+		{guess.GuessStatus{Round: 4, TotalRounds: guess.MaxDuelRounds, Mode: guess.ModeDuel, Guessed: 1, Players: 2}, "📝 ラウンド 4 **1/2名**が推測済み"},
+	}
+	for _, tt := range tests {
+		if got := formatGuessStatus(&tt.st); !strings.HasPrefix(got, tt.want+"\n") {
+			t.Errorf("formatGuessStatus(%+v) = %q, want prefix %q", tt.st, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPoolLocations(t *testing.T) {
+	locs := []guess.PoolLocation{
+		{ID: 1, Location: guess.Location{URL: "https://example.com/mine"}, Clue: "photo", Tags: []string{"japan", "hard"}, AddedBy: "1"},
+		{ID: 2, Location: guess.Location{URL: "https://example.com/theirs"}, Clue: "sign", AddedBy: "2"},
+	}
+	tags := []guess.TagCount{{Tag: "japan", Count: 1}, {Tag: "hard", Count: 1}}
+	got := formatPoolLocations(locs, tags, "", "1", false)
+	if !strings.Contains(got, "登録された場所** 2 件") || !strings.Contains(got, "🏷️ タグ: japan (1), hard (1)") ||
+		!strings.Contains(got, "#1 <https://example.com/mine> ヒント: photo [japan, hard]") || strings.Contains(got, "theirs") {
+		t.Errorf("member list = %q", got)
+	}
+	if got := formatPoolLocations(locs, tags, "", "1", true); !strings.Contains(got, "#2 <https://example.com/theirs> ヒント: sign （<@2>）") {
+		t.Errorf("manager list = %q", got)
+	}
+	if got := formatPoolLocations(nil, tags, "urban", "1", true); got != "タグ「urban」の場所はありません" {
+		t.Errorf("empty tag list = %q", got)
+	}
+}
+
+func TestParsePoolLines(t *testing.T) {
+	entries, err := parsePoolLines("35.68,139.76 | photo 1 | Urban\n\nhttps://maps.app.goo.gl/x|photo 2", "japan")
+	if err != nil {
+		t.Fatalf("parsePoolLines: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Input != "35.68,139.76" || e.Clue != "photo 1" || strings.Join(e.Tags, ",") != "japan,urban" {
+		t.Errorf("entry 1 = %+v", e)
+	}
+	if e := entries[1]; e.Input != "https://maps.app.goo.gl/x" || e.Clue != "photo 2" || strings.Join(e.Tags, ",") != "japan" {
+		t.Errorf("entry 2 = %+v", e)
+	}
+	for _, text := range []string{"35.68,139.76", "35.68,139.76 | ", "", " | photo"} {
+		if _, err := parsePoolLines(text, ""); err == nil {
+			t.Errorf("parsePoolLines(%q) succeeded, want an error", text)
+		}
+	}
+}
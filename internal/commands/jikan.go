@@ -3,14 +3,14 @@ package commands
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/metrics"
 	"github.com/susu3304/nkmzbot/internal/nomikai"
 )
 
@@ -23,17 +23,22 @@ type activeTask struct {
 	ChannelID string
 	GuildID   int64
 	UserID    string
+
+	timer *time.Timer
 }
 
 var (
 	activeTasks = make(map[int]*activeTask)
 	tasksMu     sync.Mutex
+	// runningTasks tracks executions in progress so shutdown can wait for them.
+	runningTasks     sync.WaitGroup
+	schedulerStopped bool
 	// JST timezone (UTC+9)
 	jst = time.FixedZone("JST", 9*60*60)
 )
 
 // RestoreScheduledTasks loads all scheduled tasks from the database and schedules them
-func RestoreScheduledTasks(ctx context.Context, s *discordgo.Session, svc *nomikai.Service, database *db.DB) error {
+func RestoreScheduledTasks(ctx context.Context, s Session, svc *nomikai.Service, database TaskStore) error {
 	dbTasks, err := database.ListAllScheduledTasks(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to load scheduled tasks: %w", err)
@@ -48,7 +53,7 @@ func RestoreScheduledTasks(ctx context.Context, s *discordgo.Session, svc *nomik
 		if dbTask.Time.Before(now) && !dbTask.Repeat {
 			// Delete expired non-repeating tasks
 			if err := database.DeleteScheduledTask(ctx, dbTask.ID); err != nil {
-				log.Printf("Failed to delete expired task %d: %v", dbTask.ID, err)
+				slog.Error("failed to delete expired scheduled task", "task_id", dbTask.ID, "err", err)
 			}
 			continue
 		}
@@ -61,7 +66,7 @@ func RestoreScheduledTasks(ctx context.Context, s *discordgo.Session, svc *nomik
 			nextTime := dbTask.Time.Add(time.Duration(daysToAdd) * 24 * time.Hour)
 			dbTask.Time = nextTime
 			if err := database.UpdateScheduledTaskTime(ctx, dbTask.ID, nextTime); err != nil {
-				log.Printf("Failed to update task %d time: %v", dbTask.ID, err)
+				slog.Error("failed to update scheduled task time", "task_id", dbTask.ID, "err", err)
 				continue
 			}
 		}
@@ -80,14 +85,14 @@ func RestoreScheduledTasks(ctx context.Context, s *discordgo.Session, svc *nomik
 		go scheduleTask(s, svc, database, task)
 	}
 
-	log.Printf("Restored %d scheduled tasks", len(activeTasks))
+	slog.Info("restored scheduled tasks", "count", len(activeTasks))
 	return nil
 }
 
-func HandleJikan(s *discordgo.Session, i *discordgo.InteractionCreate, svc *nomikai.Service, database *db.DB) {
+func HandleJikan(s Session, i *discordgo.InteractionCreate, svc *nomikai.Service, database TaskStore) {
 	options := i.ApplicationCommandData().Options
 	if len(options) == 0 {
-		respondText(s, i, "サブコマンドを指定してください")
+		respondError(s, i, "サブコマンドを指定してください")
 		return
 	}
 
@@ -103,13 +108,13 @@ func HandleJikan(s *discordgo.Session, i *discordgo.InteractionCreate, svc *nomi
 	}
 }
 
-func handleJikanAdd(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, svc *nomikai.Service, database *db.DB) {
+func handleJikanAdd(s Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, svc *nomikai.Service, database TaskStore) {
 	cmdStr := getStringOption(options, "command")
 	timeStr := getStringOption(options, "time")
 	repeatOpt := getBoolOption(options, "repeat")
 
 	if cmdStr == nil || timeStr == nil {
-		respondText(s, i, "コマンドと時間を指定してください")
+		respondError(s, i, "コマンドと時間を指定してください")
 		return
 	}
 
@@ -120,13 +125,13 @@ func handleJikanAdd(s *discordgo.Session, i *discordgo.InteractionCreate, option
 
 	targetTime, err := parseTime(*timeStr)
 	if err != nil {
-		respondText(s, i, fmt.Sprintf("時間の形式が正しくありません: %v (例: 18:00, 2025-12-26 18:00)", err))
+		respondError(s, i, fmt.Sprintf("時間の形式が正しくありません: %v (例: 18:00, 2025-12-26 18:00)", err))
 		return
 	}
 
 	now := time.Now()
 	if targetTime.Before(now) {
-		respondText(s, i, "指定された時間は既に過ぎています")
+		respondError(s, i, "指定された時間は既に過ぎています")
 		return
 	}
 
@@ -137,7 +142,7 @@ func handleJikanAdd(s *discordgo.Session, i *discordgo.InteractionCreate, option
 	// Parse guildID to int64
 	gid, err := strconv.ParseInt(guildID, 10, 64)
 	if err != nil {
-		respondText(s, i, "ギルドIDの解析に失敗しました")
+		respondError(s, i, "ギルドIDの解析に失敗しました")
 		return
 	}
 
@@ -145,7 +150,7 @@ func handleJikanAdd(s *discordgo.Session, i *discordgo.InteractionCreate, option
 	ctx := context.Background()
 	dbTask, err := database.AddScheduledTask(ctx, *cmdStr, targetTime, isRepeat, channelID, gid, userID)
 	if err != nil {
-		respondText(s, i, fmt.Sprintf("タスクの保存に失敗しました: %v", err))
+		respondError(s, i, fmt.Sprintf("タスクの保存に失敗しました: %v", err))
 		return
 	}
 
@@ -174,11 +179,11 @@ func handleJikanAdd(s *discordgo.Session, i *discordgo.InteractionCreate, option
 	respondText(s, i, msg)
 }
 
-func handleJikanList(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func handleJikanList(s Session, i *discordgo.InteractionCreate) {
 	// Parse guildID to int64
 	gid, err := strconv.ParseInt(i.GuildID, 10, 64)
 	if err != nil {
-		respondText(s, i, "ギルドIDの解析に失敗しました")
+		respondError(s, i, "ギルドIDの解析に失敗しました")
 		return
 	}
 
@@ -215,11 +220,11 @@ func handleJikanList(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	respondText(s, i, b.String())
 }
 
-func handleJikanDelete(s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, database *db.DB) {
+func handleJikanDelete(s Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption, database TaskStore) {
 	taskIDOpt := getIntegerOption(options, "id")
 
 	if taskIDOpt == nil {
-		respondText(s, i, "タスクIDを指定してください")
+		respondError(s, i, "タスクIDを指定してください")
 		return
 	}
 
@@ -228,7 +233,7 @@ func handleJikanDelete(s *discordgo.Session, i *discordgo.InteractionCreate, opt
 	// Parse guildID to int64
 	gid, err := strconv.ParseInt(i.GuildID, 10, 64)
 	if err != nil {
-		respondText(s, i, "ギルドIDの解析に失敗しました")
+		respondError(s, i, "ギルドIDの解析に失敗しました")
 		return
 	}
 
@@ -238,24 +243,27 @@ func handleJikanDelete(s *discordgo.Session, i *discordgo.InteractionCreate, opt
 	tasksMu.Unlock()
 
 	if !exists {
-		respondText(s, i, fmt.Sprintf("ID %d のタスクが見つかりません", taskID))
+		respondError(s, i, fmt.Sprintf("ID %d のタスクが見つかりません", taskID))
 		return
 	}
 
 	if task.GuildID != gid {
-		respondText(s, i, "このサーバーのタスクではありません")
+		respondError(s, i, "このサーバーのタスクではありません")
 		return
 	}
 
 	// Delete from database (without holding the mutex)
 	ctx := context.Background()
 	if err := database.DeleteScheduledTask(ctx, taskID); err != nil {
-		respondText(s, i, fmt.Sprintf("タスクの削除に失敗しました: %v", err))
+		respondError(s, i, fmt.Sprintf("タスクの削除に失敗しました: %v", err))
 		return
 	}
 
-	// Remove from memory
+	// Remove from memory and cancel the pending run
 	tasksMu.Lock()
+	if task.timer != nil {
+		task.timer.Stop()
+	}
 	delete(activeTasks, taskID)
 	tasksMu.Unlock()
 
@@ -272,7 +280,7 @@ func getIntegerOption(opts []*discordgo.ApplicationCommandInteractionDataOption,
 	return nil
 }
 
-func scheduleTask(s *discordgo.Session, svc *nomikai.Service, database *db.DB, task *activeTask) {
+func scheduleTask(s Session, svc *nomikai.Service, database TaskStore, task *activeTask) {
 	now := time.Now()
 	duration := task.Time.Sub(now)
 
@@ -281,7 +289,21 @@ func scheduleTask(s *discordgo.Session, svc *nomikai.Service, database *db.DB, t
 		duration = 0
 	}
 
-	time.AfterFunc(duration, func() {
+	tasksMu.Lock()
+	defer tasksMu.Unlock()
+	if schedulerStopped {
+		return
+	}
+
+	task.timer = time.AfterFunc(duration, func() {
+		if !beginTaskRun() {
+			return
+		}
+		defer runningTasks.Done()
+
+		metrics.ScheduledTaskRuns.Inc()
+		metrics.ScheduledTaskLag.Observe(time.Since(task.Time).Seconds())
+
 		// Execute
 		guildIDStr := strconv.FormatInt(task.GuildID, 10)
 		executeScheduledCommand(s, svc, database, task.ChannelID, guildIDStr, task.UserID, task.Command)
@@ -303,14 +325,14 @@ func scheduleTask(s *discordgo.Session, svc *nomikai.Service, database *db.DB, t
 			task.Time = task.Time.Add(24 * time.Hour)
 			// Update database
 			if err := database.UpdateScheduledTaskTime(ctx, task.ID, task.Time); err != nil {
-				log.Printf("Failed to update scheduled task time: %v", err)
+				slog.Error("failed to update scheduled task time", "task_id", task.ID, "err", err)
 				return
 			}
 			go scheduleTask(s, svc, database, task)
 		} else {
 			// Remove from database
 			if err := database.DeleteScheduledTask(ctx, task.ID); err != nil {
-				log.Printf("Failed to delete scheduled task: %v", err)
+				slog.Error("failed to delete scheduled task", "task_id", task.ID, "err", err)
 			}
 			// Remove from memory
 			delete(activeTasks, task.ID)
@@ -318,6 +340,42 @@ func scheduleTask(s *discordgo.Session, svc *nomikai.Service, database *db.DB, t
 	})
 }
 
+// beginTaskRun registers a task execution unless the scheduler is shutting down.
+func beginTaskRun() bool {
+	tasksMu.Lock()
+	defer tasksMu.Unlock()
+	if schedulerStopped {
+		return false
+	}
+	runningTasks.Add(1)
+	return true
+}
+
+// StopScheduledTasks cancels pending timers and waits for running tasks to finish, or until ctx expires.
+// Tasks remain in the database and are restored by RestoreScheduledTasks on the next start.
+func StopScheduledTasks(ctx context.Context) error {
+	tasksMu.Lock()
+	schedulerStopped = true
+	for _, t := range activeTasks {
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+	}
+	tasksMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		runningTasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func parseTime(input string) (time.Time, error) {
 	now := time.Now().In(jst)
 	
@@ -340,7 +398,7 @@ func parseTime(input string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unsupported format")
 }
 
-func executeScheduledCommand(s *discordgo.Session, svc *nomikai.Service, database *db.DB, channelID, guildIDStr, userID, cmdStr string) {
+func executeScheduledCommand(s Session, svc *nomikai.Service, database TaskStore, channelID, guildIDStr, userID, cmdStr string) {
 	parts := strings.Fields(cmdStr)
 	if len(parts) == 0 {
 		return
@@ -366,19 +424,23 @@ func executeScheduledCommand(s *discordgo.Session, svc *nomikai.Service, databas
 			return
 		}
 		subCmd := parts[1]
+		// Anything after the subcommand names the event, as in "nomikai start 二次会".
+		name := strings.Join(parts[2:], " ")
 		ctx := context.Background()
 
 		switch subCmd {
 		case "start":
 			gid, _ := strconv.ParseInt(guildIDStr, 10, 64)
-			err := svc.StartSession(ctx, channelID, gid, userID, 1, "organizer")
+			err := svc.StartSession(ctx, channelID, gid, userID, name, 1, "organizer")
 			if err != nil {
 				s.ChannelMessageSend(channelID, fmt.Sprintf("予約実行エラー (nomikai start): %v", err))
+			} else if name != "" {
+				s.ChannelMessageSend(channelID, fmt.Sprintf("予約実行: 飲み会「%s」を開始しました", name))
 			} else {
 				s.ChannelMessageSend(channelID, "予約実行: 飲み会セッションを開始しました")
 			}
 		case "stop":
-			err := svc.StopSession(ctx, channelID)
+			_, err := svc.StopSession(ctx, nomikai.Target{ChannelID: channelID, UserID: userID, Event: name})
 			if err != nil {
 				s.ChannelMessageSend(channelID, fmt.Sprintf("予約実行エラー (nomikai stop): %v", err))
 			} else {
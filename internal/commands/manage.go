@@ -3,11 +3,12 @@ package commands
 import (
 	"context"
 	"fmt"
+	"strings"
+
 	"github.com/bwmarrin/discordgo"
-	"github.com/susu3304/nkmzbot/internal/db"
 )
 
-func HandleAdd(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.DB) {
+func HandleAdd(s Session, i *discordgo.InteractionCreate, db CommandStore) {
 	data := i.ApplicationCommandData()
 	guildID := ParseGuildID(i.GuildID)
 
@@ -22,22 +23,14 @@ func HandleAdd(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.DB)
 	}
 
 	err := db.AddCommand(context.Background(), guildID, name, response)
-	var content string
 	if err != nil {
-		content = "追加に失敗しました。"
-	} else {
-		content = fmt.Sprintf("コマンド '%s' を追加しました。", name)
+		respondError(s, i, "追加に失敗しました。")
+		return
 	}
-
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-		},
-	})
+	respondText(s, i, fmt.Sprintf("コマンド '%s' を追加しました。", name))
 }
 
-func HandleRemove(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.DB) {
+func HandleRemove(s Session, i *discordgo.InteractionCreate, db CommandStore) {
 	data := i.ApplicationCommandData()
 	guildID := ParseGuildID(i.GuildID)
 
@@ -50,22 +43,14 @@ func HandleRemove(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.D
 	}
 
 	err := db.RemoveCommand(context.Background(), guildID, name)
-	var content string
 	if err != nil {
-		content = "そのコマンドは存在しません。"
-	} else {
-		content = fmt.Sprintf("コマンド '%s' を削除しました。", name)
+		respondError(s, i, "そのコマンドは存在しません。")
+		return
 	}
-
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-		},
-	})
+	respondText(s, i, fmt.Sprintf("コマンド '%s' を削除しました。", name))
 }
 
-func HandleUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.DB) {
+func HandleUpdate(s Session, i *discordgo.InteractionCreate, db CommandStore) {
 	data := i.ApplicationCommandData()
 	guildID := ParseGuildID(i.GuildID)
 
@@ -80,17 +65,28 @@ func HandleUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.D
 	}
 
 	err := db.UpdateCommand(context.Background(), guildID, name, response)
-	var content string
 	if err != nil {
-		content = "そのコマンドは存在しません。"
-	} else {
-		content = fmt.Sprintf("コマンド '%s' を更新しました。", name)
+		respondError(s, i, "そのコマンドは存在しません。")
+		return
+	}
+	respondText(s, i, fmt.Sprintf("コマンド '%s' を更新しました。", name))
+}
+
+// HandleCustomCommand replies to "!name" messages with the registered response.
+func HandleCustomCommand(s Session, m *discordgo.MessageCreate, db CommandStore) {
+	// Ignore bot messages
+	if m.Author == nil || m.Author.Bot {
+		return
 	}
 
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{
-			Content: content,
-		},
-	})
+	content := strings.TrimSpace(m.Content)
+	if !strings.HasPrefix(content, "!") || len(content) <= 1 || m.GuildID == "" {
+		return
+	}
+	cmdName := content[1:]
+	guildID := ParseGuildID(m.GuildID)
+	cmd, err := db.GetCommand(context.Background(), guildID, cmdName)
+	if err == nil && cmd != nil {
+		s.ChannelMessageSend(m.ChannelID, cmd.Response)
+	}
 }
@@ -8,54 +8,95 @@ import (
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/db"
 	"github.com/susu3304/nkmzbot/internal/nomikai"
 )
 
-func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *nomikai.Service) {
+func HandleNomikai(s Session, i *discordgo.InteractionCreate, svc *nomikai.Service) {
 	data := i.ApplicationCommandData()
 	if len(data.Options) == 0 {
-		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{Content: "サブコマンドが指定されていません"},
-		})
+		respondError(s, i, "サブコマンドが指定されていません")
 		return
 	}
 
 	sub := data.Options[0]
 	channelID := i.ChannelID
 	userID := i.Member.User.ID
+	target := nomikai.Target{ChannelID: channelID, UserID: userID}
+	if opt := getStringOption(sub.Options, "event"); opt != nil {
+		target.Event = strings.TrimSpace(*opt)
+	}
 
 	switch sub.Name {
 	case "start":
 		// Parse guild ID to int64
 		gid, errParse := strconv.ParseInt(i.GuildID, 10, 64)
 		if errParse != nil || gid == 0 {
-			respondText(s, i, "ギルドIDの取得に失敗しました")
+			respondError(s, i, "ギルドIDの取得に失敗しました")
+			return
+		}
+		name := ""
+		if opt := getStringOption(sub.Options, "name"); opt != nil {
+			name = strings.TrimSpace(*opt)
+		}
+		if err := nomikai.ValidateEventName(name); err != nil {
+			respondError(s, i, err.Error())
 			return
 		}
 		// Defaults: rounding=1, remainder strategy="organizer"
-		err := svc.StartSession(context.Background(), channelID, gid, userID, 1, "organizer")
-		respondSimple(s, i, err, "このチャンネルでセッションを開始しました", "既に開始されています")
+		err := svc.StartSession(context.Background(), channelID, gid, userID, name, 1, "organizer")
+		ok := "このチャンネルでセッションを開始しました"
+		if name != "" {
+			ok = fmt.Sprintf("このチャンネルで飲み会「%s」を開始しました", name)
+		}
+		respondSimple(s, i, err, ok, "既に開始されています")
 	case "stop":
-		err := svc.StopSession(context.Background(), channelID)
-		respondSimple(s, i, err, "セッションを終了しました", "セッションが存在しません")
+		ev, err := svc.StopSession(context.Background(), target)
+		if err != nil {
+			respondError(s, i, noEventMessage(err, target, "セッションが存在しません"))
+			return
+		}
+		if ev.Name != "" {
+			respondText(s, i, fmt.Sprintf("飲み会「%s」を終了しました", ev.Name))
+			return
+		}
+		respondText(s, i, "セッションを終了しました")
 	case "join":
-		err := svc.Join(context.Background(), channelID, userID)
-		respondSimple(s, i, err, "参加者として登録しました", "セッションが開始されていません")
+		if err := svc.Join(context.Background(), target, userID); err != nil {
+			respondError(s, i, noEventMessage(err, target, "セッションが開始されていません"))
+			return
+		}
+		respondText(s, i, "参加者として登録しました")
+	case "use":
+		if target.Event == "" {
+			events, current, err := svc.ActiveEvents(context.Background(), channelID, userID)
+			if err != nil {
+				respondError(s, i, err.Error())
+				return
+			}
+			respondText(s, i, formatActiveEvents(events, current))
+			return
+		}
+		ev, err := svc.Use(context.Background(), target)
+		if err != nil {
+			respondError(s, i, noEventMessage(err, target, "セッションが開始されていません"))
+			return
+		}
+		respondText(s, i, fmt.Sprintf("このチャンネルでは飲み会%sを対象にします", nomikai.EventLabel(ev)))
 	case "member":
 		usersOpt := getStringOption(sub.Options, "users")
 		if usersOpt == nil {
-			respondText(s, i, "users の指定が必要です")
+			respondError(s, i, "users の指定が必要です")
 			return
 		}
 		ids := parseMentionIDs(*usersOpt)
 		if len(ids) == 0 {
-			respondText(s, i, "ユーザーのメンション/IDを認識できませんでした")
+			respondError(s, i, "ユーザーのメンション/IDを認識できませんでした")
 			return
 		}
 		for _, id := range ids {
-			if err := svc.Join(context.Background(), channelID, id); err != nil {
-				respondText(s, i, "セッションが開始されていません")
+			if err := svc.Join(context.Background(), target, id); err != nil {
+				respondError(s, i, noEventMessage(err, target, "セッションが開始されていません"))
 				return
 			}
 		}
@@ -76,17 +117,17 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 		usersOpt := getStringOption(sub.Options, "users")
 		val := getNumberOption(sub.Options, "value")
 		if usersOpt == nil || val == nil {
-			respondText(s, i, "users と value の指定が必要です")
+			respondError(s, i, "users と value の指定が必要です")
 			return
 		}
 		ids := parseMentionIDs(*usersOpt)
 		if len(ids) == 0 {
-			respondText(s, i, "ユーザーのメンション/IDを認識できませんでした")
+			respondError(s, i, "ユーザーのメンション/IDを認識できませんでした")
 			return
 		}
 		var joinedIDs []string
 		for _, id := range ids {
-			joined, _ := svc.SetWeight(context.Background(), channelID, id, *val)
+			joined, _ := svc.SetWeight(context.Background(), target, id, *val)
 			if joined {
 				joinedIDs = append(joinedIDs, id)
 			}
@@ -115,7 +156,7 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 		memoOpt := getStringOption(sub.Options, "memo")
 		forOpt := getStringOption(sub.Options, "for")
 		if amtOpt == nil {
-			respondText(s, i, "金額の指定が必要です")
+			respondError(s, i, "金額の指定が必要です")
 			return
 		}
 		memo := ""
@@ -135,12 +176,12 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 		var benJoined []string
 		var err error
 		if len(beneficiaries) > 0 {
-			joined, benJoined, err = svc.AddPaymentFor(context.Background(), channelID, payer, *amtOpt, memo, beneficiaries)
+			joined, benJoined, err = svc.AddPaymentFor(context.Background(), target, payer, *amtOpt, memo, beneficiaries)
 		} else {
-			joined, err = svc.AddPayment(context.Background(), channelID, payer, *amtOpt, memo)
+			joined, err = svc.AddPayment(context.Background(), target, payer, *amtOpt, memo)
 		}
 		if err != nil {
-			respondText(s, i, err.Error())
+			respondError(s, i, err.Error())
 			return
 		}
 		msg := fmt.Sprintf("<@%s> の支払として %d 円を記録しました", payer, *amtOpt)
@@ -172,27 +213,24 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 		}
 		respondText(s, i, msg)
 	case "settle":
-		res, err := svc.Settle(context.Background(), channelID)
-		if err != nil {
-			respondText(s, i, err.Error())
-			return
-		}
-		if len(res.Tasks) == 0 {
-			respondText(s, i, "精算は不要です")
-			return
-		}
-		respondText(s, i, res.Summary)
+		respondSlow(s, i, func() (string, error) {
+			res, err := svc.Settle(context.Background(), target)
+			if err != nil {
+				return "", err
+			}
+			if len(res.Tasks) == 0 {
+				return "精算は不要です", nil
+			}
+			return res.Summary, nil
+		})
 	case "status":
-		txt, err := svc.Status(context.Background(), channelID)
-		if err != nil {
-			respondText(s, i, err.Error())
-			return
-		}
-		respondText(s, i, txt)
+		respondSlow(s, i, func() (string, error) {
+			return svc.Status(context.Background(), target)
+		})
 	case "memberlist":
-		ids, err := svc.Members(context.Background(), channelID)
+		ids, err := svc.Members(context.Background(), target)
 		if err != nil {
-			respondText(s, i, err.Error())
+			respondError(s, i, err.Error())
 			return
 		}
 		var b strings.Builder
@@ -206,7 +244,7 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 		if opt := getStringOption(sub.Options, "interval"); opt != nil {
 			mins, err := parseDHMToMinutes(*opt)
 			if err != nil {
-				respondText(s, i, err.Error())
+				respondError(s, i, err.Error())
 				return
 			}
 			intervalMinutes = mins
@@ -224,25 +262,25 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 			case "オフ":
 				disable = true
 			default:
-				respondText(s, i, "state は on/off で指定してください")
+				respondError(s, i, "state は on/off で指定してください")
 				return
 			}
 		}
-		msg, err := svc.ConfigureReminder(context.Background(), channelID, intervalMinutes, disable, true)
+		msg, err := svc.ConfigureReminder(context.Background(), target, intervalMinutes, disable, true)
 		if err != nil {
-			respondText(s, i, err.Error())
+			respondError(s, i, err.Error())
 			return
 		}
 		respondText(s, i, msg)
 	case "seisan":
 		amtStrOpt := getStringOption(sub.Options, "amount")
 		if amtStrOpt == nil {
-			respondText(s, i, "amount の指定が必要です")
+			respondError(s, i, "amount の指定が必要です")
 			return
 		}
 		payee := getUserID(data, sub, "to")
 		if payee == "" {
-			respondText(s, i, "to の指定が必要です")
+			respondError(s, i, "to の指定が必要です")
 			return
 		}
 		payer := getUserID(data, sub, "payer")
@@ -263,36 +301,157 @@ func HandleNomikai(s *discordgo.Session, i *discordgo.InteractionCreate, svc *no
 		default:
 			v, err := strconv.ParseInt(amountStr, 10, 64)
 			if err != nil || v <= 0 {
-				respondText(s, i, "amount は正の数、または all を指定してください")
+				respondError(s, i, "amount は正の数、または all を指定してください")
 				return
 			}
 			amount = v
 		}
 
-		msg, err := svc.RegisterPayment(context.Background(), channelID, payer, payee, amount, memo, userID, payAll)
+		msg, err := svc.RegisterPayment(context.Background(), target, payer, payee, amount, memo, userID, payAll)
 		if err != nil {
-			respondText(s, i, err.Error())
+			respondError(s, i, err.Error())
 			return
 		}
 		respondText(s, i, msg)
+	case "payments":
+		respondSlow(s, i, func() (string, error) {
+			return svc.Payments(context.Background(), target)
+		})
+	case "payment":
+		handleNomikaiPayment(s, i, svc, data, sub)
+	case "history":
+		gid := ParseGuildID(i.GuildID)
+		if gid == 0 {
+			respondError(s, i, "ギルドIDの取得に失敗しました")
+			return
+		}
+		scope := channelID
+		if opt := getStringOption(sub.Options, "scope"); opt != nil && *opt == "guild" {
+			scope = ""
+		}
+		respondSlow(s, i, func() (string, error) {
+			return svc.History(context.Background(), gid, scope)
+		})
+	case "show":
+		gid := ParseGuildID(i.GuildID)
+		idOpt := getIntOption(sub.Options, "id")
+		if gid == 0 || idOpt == nil {
+			respondError(s, i, "id の指定が必要です")
+			return
+		}
+		respondSlow(s, i, func() (string, error) {
+			return svc.Show(context.Background(), gid, *idOpt)
+		})
+	case "reopen":
+		gid := ParseGuildID(i.GuildID)
+		idOpt := getIntOption(sub.Options, "id")
+		if gid == 0 || idOpt == nil {
+			respondError(s, i, "id の指定が必要です")
+			return
+		}
+		ev, err := svc.Reopen(context.Background(), gid, *idOpt, userID, canManageServer(i))
+		if err != nil {
+			respondError(s, i, err.Error())
+			return
+		}
+		name := ""
+		if ev.Name != "" {
+			name = "「" + ev.Name + "」"
+		}
+		respondText(s, i, fmt.Sprintf("飲み会 #%d%s を <#%s> で再開しました。このチャンネルでのあなたのコマンドはこの飲み会が対象になります\n"+
+			"/nomikai seisan event:#%d で支払いを記録できます。記録が終わったら /nomikai stop event:#%d で終了してください",
+			ev.ID, name, ev.ChannelID, ev.ID, ev.ID))
 	default:
-		respondText(s, i, "未知のサブコマンドです")
+		respondError(s, i, "未知のサブコマンドです")
 	}
 }
 
-func respondSimple(s *discordgo.Session, i *discordgo.InteractionCreate, err error, ok, ng string) {
+func handleNomikaiPayment(s Session, i *discordgo.InteractionCreate, svc *nomikai.Service, data discordgo.ApplicationCommandInteractionData, group *discordgo.ApplicationCommandInteractionDataOption) {
+	if len(group.Options) == 0 {
+		respondError(s, i, "サブコマンドが指定されていません")
+		return
+	}
+	sub := group.Options[0]
+	gid := ParseGuildID(i.GuildID)
+	idOpt := getIntOption(sub.Options, "id")
+	if gid == 0 || idOpt == nil {
+		respondError(s, i, "id の指定が必要です")
+		return
+	}
+	userID := InteractionUserID(i)
+
+	var msg string
+	var err error
+	switch sub.Name {
+	case "edit":
+		edit := nomikai.PaymentEdit{
+			PayerID: getUserID(data, sub, "payer"),
+			Amount:  getIntOption(sub.Options, "amount"),
+			Memo:    getStringOption(sub.Options, "memo"),
+		}
+		if opt := getStringOption(sub.Options, "for"); opt != nil {
+			switch strings.ToLower(strings.TrimSpace(*opt)) {
+			case "all", "全員":
+				edit.AllMembers = true
+			default:
+				if edit.Beneficiaries = parseMentionIDs(*opt); len(edit.Beneficiaries) == 0 {
+					respondError(s, i, "ユーザーのメンション/IDを認識できませんでした")
+					return
+				}
+			}
+		}
+		msg, err = svc.EditPayment(context.Background(), gid, *idOpt, userID, edit)
+	case "delete":
+		msg, err = svc.DeletePayment(context.Background(), gid, *idOpt, userID)
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+		return
+	}
 	if err != nil {
-		respondText(s, i, ng)
+		respondError(s, i, err.Error())
 		return
 	}
-	respondText(s, i, ok)
+	respondText(s, i, msg)
+}
+
+// noEventMessage is what to tell the user when err means the event they named, if any, is not
+// active; ng stands in for other errors.
+func noEventMessage(err error, target nomikai.Target, ng string) string {
+	if target.Event != "" && nomikai.IsNoEvent(err) {
+		return err.Error()
+	}
+	return ng
+}
+
+// formatActiveEvents lists the channel's active events, marking the user's current one.
+func formatActiveEvents(events []db.NomikaiEvent, current int64) string {
+	if len(events) == 0 {
+		return "このチャンネルで開催中の飲み会はありません"
+	}
+	var b strings.Builder
+	b.WriteString("このチャンネルで開催中の飲み会:\n")
+	for idx := range events {
+		ev := &events[idx]
+		mark := "・"
+		if ev.ID == current {
+			mark = "▶ "
+		}
+		fmt.Fprintf(&b, "%s#%d", mark, ev.ID)
+		if ev.Name != "" {
+			fmt.Fprintf(&b, "「%s」", ev.Name)
+		}
+		fmt.Fprintf(&b, " 主催 <@%s>\n", ev.OrganizerID)
+	}
+	b.WriteString("▶ が対象の飲み会です。/nomikai use event で切り替えられます")
+	return b.String()
 }
 
-func respondText(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{Content: content},
-	})
+func respondSimple(s Session, i *discordgo.InteractionCreate, err error, ok, ng string) {
+	if err != nil {
+		respondError(s, i, ng)
+		return
+	}
+	respondText(s, i, ok)
 }
 
 func getUserID(data discordgo.ApplicationCommandInteractionData, sub *discordgo.ApplicationCommandInteractionDataOption, name string) string {
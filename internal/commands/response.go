@@ -3,16 +3,14 @@ package commands
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/susu3304/nkmzbot/internal/db"
 )
 
-func HandleRegisterAsResponse(s *discordgo.Session, i *discordgo.InteractionCreate) {
+func HandleRegisterAsResponse(s Session, i *discordgo.InteractionCreate) {
 	data := i.ApplicationCommandData()
-	
+
 	// Get the message from the interaction
 	if data.Resolved == nil || len(data.Resolved.Messages) == 0 {
 		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
@@ -55,11 +53,11 @@ func HandleRegisterAsResponse(s *discordgo.Session, i *discordgo.InteractionCrea
 	})
 
 	if err != nil {
-		log.Printf("Failed to create modal: %v", err)
+		InteractionLogger(i).Error("failed to create modal", "err", err)
 	}
 }
 
-func HandleModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate, db *db.DB) {
+func HandleModalSubmit(s Session, i *discordgo.InteractionCreate, db CommandStore) {
 	data := i.ModalSubmitData()
 	if !strings.HasPrefix(data.CustomID, "reg_resp:") {
 		return
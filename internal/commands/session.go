@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/db"
+)
+
+// Session is the subset of *discordgo.Session that command handlers use.
+// Handlers take this instead of the concrete session so they can be driven by a fake in tests.
+type Session interface {
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+}
+
+var _ Session = (*discordgo.Session)(nil)
+
+// CommandStore is the persistence used by the custom command handlers.
+type CommandStore interface {
+	GetCommand(ctx context.Context, guildID int64, name string) (*db.Command, error)
+	AddCommand(ctx context.Context, guildID int64, name, response string) error
+	UpdateCommand(ctx context.Context, guildID int64, name, response string) error
+	RemoveCommand(ctx context.Context, guildID int64, name string) error
+	ListCommands(ctx context.Context, guildID int64, pattern string) ([]db.Command, error)
+}
+
+// TaskStore is the persistence used by /jikan and the scheduled task runner.
+type TaskStore interface {
+	GetCommand(ctx context.Context, guildID int64, name string) (*db.Command, error)
+	AddScheduledTask(ctx context.Context, command string, execTime time.Time, repeat bool, channelID string, guildID int64, userID string) (*db.ScheduledTask, error)
+	ListAllScheduledTasks(ctx context.Context) ([]*db.ScheduledTask, error)
+	UpdateScheduledTaskTime(ctx context.Context, id int, newTime time.Time) error
+	DeleteScheduledTask(ctx context.Context, id int) error
+}
+
+var (
+	_ CommandStore       = (*db.DB)(nil)
+	_ TaskStore          = (*db.DB)(nil)
+	_ ReplySettingsStore = (*db.DB)(nil)
+)
+
+// ReplySettingsStore is the persistence used by /settings and reply visibility resolution.
+type ReplySettingsStore interface {
+	ListReplySettings(ctx context.Context, guildID int64) ([]db.ReplySetting, error)
+	SetReplySetting(ctx context.Context, guildID int64, command, visibility string) error
+	DeleteReplySetting(ctx context.Context, guildID int64, command string) error
+}
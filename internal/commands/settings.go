@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// HandleSettings handles /settings, which configures per-guild reply visibility.
+func HandleSettings(s Session, i *discordgo.InteractionCreate, store ReplySettingsStore) {
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		respondError(s, i, "サブコマンドが指定されていません")
+		return
+	}
+	guildID := ParseGuildID(i.GuildID)
+	if guildID == 0 {
+		respondError(s, i, "ギルドIDの取得に失敗しました")
+		return
+	}
+
+	sub := data.Options[0]
+	switch sub.Name {
+	case "visibility":
+		target := ""
+		if opt := getStringOption(sub.Options, "command"); opt != nil {
+			target = strings.Join(strings.Fields(strings.ToLower(*opt)), " ")
+		}
+		if !IsReplyTarget(target) {
+			respondError(s, i, fmt.Sprintf("コマンド '%s' は設定できません", target))
+			return
+		}
+		mode := ""
+		if opt := getStringOption(sub.Options, "mode"); opt != nil {
+			mode = *opt
+		}
+
+		if mode == "default" {
+			if err := store.DeleteReplySetting(context.Background(), guildID, target); err != nil {
+				respondError(s, i, fmt.Sprintf("'%s' は既定の表示方法です", target))
+				return
+			}
+			InvalidateReplySettings(guildID)
+			respondText(s, i, fmt.Sprintf("'%s' の表示方法を既定に戻しました", target))
+			return
+		}
+		vis, err := ParseVisibility(mode)
+		if err != nil {
+			respondError(s, i, err.Error())
+			return
+		}
+		if err := store.SetReplySetting(context.Background(), guildID, target, string(vis)); err != nil {
+			respondError(s, i, "設定の保存に失敗しました: "+err.Error())
+			return
+		}
+		InvalidateReplySettings(guildID)
+		respondText(s, i, fmt.Sprintf("'%s' の返信を「%s」に設定しました", target, vis.Label()))
+
+	case "list":
+		settings, err := store.ListReplySettings(context.Background(), guildID)
+		if err != nil {
+			respondError(s, i, "設定の取得に失敗しました: "+err.Error())
+			return
+		}
+		var b strings.Builder
+		b.WriteString("**返信の表示方法**\n")
+		if len(settings) == 0 {
+			b.WriteString("このサーバーでの設定はありません\n")
+		}
+		for _, st := range settings {
+			fmt.Fprintf(&b, "・%s: %s\n", st.Command, Visibility(st.Visibility).Label())
+		}
+		b.WriteString("\n**既定値**\n")
+		for _, target := range ReplyTargets() {
+			if vis, ok := defaultVisibilities[target]; ok {
+				fmt.Fprintf(&b, "・%s: %s\n", target, vis.Label())
+			}
+		}
+		b.WriteString("・その他: 公開")
+		respondText(s, i, b.String())
+
+	default:
+		respondError(s, i, "未知のサブコマンドです")
+	}
+}
+
+// HandleSettingsAutocomplete suggests command keys for /settings visibility.
+func HandleSettingsAutocomplete(s Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 || data.Options[0].Name != "visibility" {
+		return
+	}
+	input := ""
+	for _, opt := range data.Options[0].Options {
+		if opt.Focused {
+			input = strings.ToLower(strings.TrimSpace(opt.StringValue()))
+		}
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, target := range ReplyTargets() {
+		if input != "" && !strings.Contains(target, input) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: target, Value: target})
+		if len(choices) == 25 {
+			break
+		}
+	}
+	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+}
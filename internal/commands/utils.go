@@ -1,15 +1,350 @@
 package commands
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
 )
 
+// maxMessageLength is Discord's per-message content limit in characters.
+const maxMessageLength = 2000
+
+// deferAfter is how long respondSlow waits for work before deferring the interaction.
+// Discord invalidates interactions that are not answered within 3 seconds, and the defer itself
+// is a round trip to Discord, so this stays well below that.
+var deferAfter = 500 * time.Millisecond
+
 func ParseGuildID(guildID string) int64 {
 	id, err := strconv.ParseInt(guildID, 10, 64)
 	if err != nil {
-		log.Printf("Failed to parse guild ID '%s': %v", guildID, err)
+		slog.Warn("failed to parse guild ID", "guild_id", guildID, "err", err)
 		return 0
 	}
 	return id
 }
+
+// InteractionLogger returns a logger carrying the guild, channel, user and command of i.
+func InteractionLogger(i *discordgo.InteractionCreate) *slog.Logger {
+	attrs := []any{"guild_id", i.GuildID, "channel_id", i.ChannelID}
+	if userID := InteractionUserID(i); userID != "" {
+		attrs = append(attrs, "user_id", userID)
+	}
+	if name := InteractionName(i); name != "" {
+		attrs = append(attrs, "command", name)
+	}
+	return slog.With(attrs...)
+}
+
+// InteractionName returns the command (and subcommand) name of i, or the custom ID for modals.
+func InteractionName(i *discordgo.InteractionCreate) string {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		data := i.ApplicationCommandData()
+		name := data.Name
+		opts := data.Options
+		if len(opts) > 0 && opts[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup {
+			name += " " + opts[0].Name
+			opts = opts[0].Options
+		}
+		if len(opts) > 0 && opts[0].Type == discordgo.ApplicationCommandOptionSubCommand {
+			name += " " + opts[0].Name
+		}
+		return name
+	case discordgo.InteractionModalSubmit:
+		return i.ModalSubmitData().CustomID
+	}
+	return ""
+}
+
+// InteractionUserID returns the ID of the user who triggered i, in a guild or a DM.
+func InteractionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// canManageServer reports whether the invoker of i may manage the guild. Discord computes the
+// member's permissions in the channel, so Administrator implies ManageServer.
+func canManageServer(i *discordgo.InteractionCreate) bool {
+	if i.Member == nil {
+		return false
+	}
+	const perms = discordgo.PermissionManageServer | discordgo.PermissionAdministrator
+	return i.Member.Permissions&perms != 0
+}
+
+// reply sends the content of one interaction reply, deferring and splitting as needed.
+type reply struct {
+	s        Session
+	i        *discordgo.InteractionCreate
+	deferred bool
+	// deferredEphemeral is whether the deferred "thinking" response is only visible to the invoker.
+	deferredEphemeral bool
+}
+
+func ephemeralFlags(ephemeral bool) discordgo.MessageFlags {
+	if ephemeral {
+		return discordgo.MessageFlagsEphemeral
+	}
+	return 0
+}
+
+// deferResponse acknowledges the interaction so the final content can be sent later.
+func (r *reply) deferResponse(vis Visibility) {
+	ephemeral := vis != VisibilityPublic
+	err := r.s.InteractionRespond(r.i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: ephemeralFlags(ephemeral)},
+	})
+	if err != nil {
+		InteractionLogger(r.i).Error("failed to defer interaction", "err", err)
+		return
+	}
+	r.deferred = true
+	r.deferredEphemeral = ephemeral
+}
+
+// send delivers content with the given visibility: the first chunk answers (or edits) the
+// interaction response, the rest are posted as followups. Files are attached to the last chunk.
+func (r *reply) send(vis Visibility, content string, files ...*discordgo.File) {
+	logger := InteractionLogger(r.i)
+	if vis == VisibilityDM {
+		if err := r.sendDM(content, files); err != nil {
+			// Fall back to showing the reply to the invoker only.
+			logger.Warn("failed to send reply by DM", "err", err)
+		} else {
+			content = "📩 DMに送信しました"
+			files = nil
+		}
+		vis = VisibilityEphemeral
+	}
+	ephemeral := vis == VisibilityEphemeral
+
+	// A deferred public response cannot become ephemeral; remove it and use followups instead.
+	followupOnly := false
+	if r.deferred && !r.deferredEphemeral && ephemeral {
+		if err := r.s.InteractionResponseDelete(r.i.Interaction); err != nil {
+			logger.Warn("failed to delete deferred response", "err", err)
+		}
+		followupOnly = true
+	}
+
+	chunks := splitMessage(content, maxMessageLength)
+	for idx, chunk := range chunks {
+		var attach []*discordgo.File
+		if idx == len(chunks)-1 {
+			attach = files
+		}
+		var err error
+		switch {
+		case idx > 0 || followupOnly:
+			_, err = r.s.FollowupMessageCreate(r.i.Interaction, true, &discordgo.WebhookParams{
+				Content: chunk,
+				Flags:   ephemeralFlags(ephemeral),
+				Files:   attach,
+			})
+		case r.deferred:
+			_, err = r.s.InteractionResponseEdit(r.i.Interaction, &discordgo.WebhookEdit{Content: &chunk, Files: attach})
+		default:
+			err = r.s.InteractionRespond(r.i.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{Content: chunk, Flags: ephemeralFlags(ephemeral), Files: attach},
+			})
+		}
+		if err != nil {
+			logger.Error("failed to send interaction reply", "chunk", idx, "err", err)
+			return
+		}
+	}
+}
+
+func (r *reply) sendDM(content string, files []*discordgo.File) error {
+	userID := InteractionUserID(r.i)
+	if userID == "" {
+		return fmt.Errorf("interaction has no user")
+	}
+	ch, err := r.s.UserChannelCreate(userID)
+	if err != nil {
+		return err
+	}
+	chunks := splitMessage(content, maxMessageLength)
+	for idx, chunk := range chunks {
+		if idx == len(chunks)-1 && len(files) > 0 {
+			_, err = r.s.ChannelMessageSendComplex(ch.ID, &discordgo.MessageSend{Content: chunk, Files: files})
+		} else {
+			_, err = r.s.ChannelMessageSend(ch.ID, chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondText replies with content using the visibility configured for the command.
+func respondText(s Session, i *discordgo.InteractionCreate, content string) {
+	r := &reply{s: s, i: i}
+	r.send(resolveVisibility(i, false), content)
+}
+
+// respondError replies with an error message using the guild's error visibility
+// (only the invoker by default).
+func respondError(s Session, i *discordgo.InteractionCreate, content string) {
+	r := &reply{s: s, i: i}
+	r.send(resolveVisibility(i, true), content)
+}
+
+// respondPrivate replies to the invoker only, whatever the guild's visibility settings are.
+// Use it for content others must not see, such as sealed answers.
+func respondPrivate(s Session, i *discordgo.InteractionCreate, content string) {
+	r := &reply{s: s, i: i}
+	r.send(VisibilityEphemeral, content)
+}
+
+// respondSlow runs work and replies with its result. If work has not finished within deferAfter,
+// the interaction is deferred first and the deferred response is edited once work completes.
+// An error from work is replied like respondError; a panic is reported as an internal error.
+func respondSlow(s Session, i *discordgo.InteractionCreate, work func() (string, error)) {
+	respondSlowAs(s, i, resolveVisibility(i, false), work)
+}
+
+// respondSlowPrivate is respondSlow with a result only the invoker can see.
+func respondSlowPrivate(s Session, i *discordgo.InteractionCreate, work func() (string, error)) {
+	respondSlowAs(s, i, VisibilityEphemeral, work)
+}
+
+func respondSlowAs(s Session, i *discordgo.InteractionCreate, vis Visibility, work func() (string, error)) {
+	respondSlowWithFiles(s, i, vis, func() (string, []*discordgo.File, error) {
+		content, err := work()
+		return content, nil, err
+	})
+}
+
+// respondSlowWithFiles is respondSlowAs for work that also produces attachments, such as images.
+func respondSlowWithFiles(s Session, i *discordgo.InteractionCreate, vis Visibility, work func() (string, []*discordgo.File, error)) {
+	type result struct {
+		content string
+		files   []*discordgo.File
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				InteractionLogger(i).Error("handler panicked", "panic", p)
+				done <- result{err: fmt.Errorf("内部エラーが発生しました")}
+			}
+		}()
+		content, files, err := work()
+		done <- result{content: content, files: files, err: err}
+	}()
+
+	r := &reply{s: s, i: i}
+	var res result
+	select {
+	case res = <-done:
+	case <-time.After(deferAfter):
+		r.deferResponse(vis)
+		res = <-done
+	}
+	if res.err != nil {
+		r.send(resolveVisibility(i, true), res.err.Error())
+		return
+	}
+	r.send(vis, res.content, res.files...)
+}
+
+// SplitMessage breaks content into chunks that fit in one Discord message, for posts made
+// outside an interaction.
+func SplitMessage(content string) []string {
+	return splitMessage(content, maxMessageLength)
+}
+
+// splitMessage breaks content into chunks of at most limit characters, preferring line breaks.
+// A code block that spans a break is closed at the end of one chunk and reopened in the next.
+func splitMessage(content string, limit int) []string {
+	if utf8.RuneCountInString(content) <= limit {
+		return []string{content}
+	}
+
+	const fence = "```"
+	var chunks []string
+	var b strings.Builder
+	size := 0
+	inFence := false
+	fenceOpen := "" // the opening fence line, including any language tag
+	// Room kept free in every chunk for a closing fence.
+	reserve := len(fence) + 1
+
+	flush := func() {
+		if size == 0 {
+			return
+		}
+		text := strings.TrimRight(b.String(), "\n")
+		if inFence {
+			text += "\n" + fence
+		}
+		chunks = append(chunks, text)
+		b.Reset()
+		size = 0
+		if inFence {
+			b.WriteString(fenceOpen + "\n")
+			size = utf8.RuneCountInString(fenceOpen) + 1
+		}
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		isFence := strings.HasPrefix(strings.TrimSpace(line), fence)
+		// A fence line is never cut. One whose info string would crowd out the code is
+		// written without it, so every chunk keeps room for content.
+		if isFence && utf8.RuneCountInString(strings.TrimSpace(line))+1+reserve > limit/2 {
+			line = strings.Replace(line, strings.TrimSpace(line), fence, 1)
+		}
+		n := utf8.RuneCountInString(line)
+		if size+n+reserve > limit {
+			flush()
+		}
+		// A single line longer than a whole chunk is cut by characters.
+		for n+size+reserve > limit {
+			room := limit - size - reserve
+			head, tail := splitRunes(line, room)
+			b.WriteString(head)
+			size += room
+			flush()
+			line = tail
+			n = utf8.RuneCountInString(line)
+		}
+		b.WriteString(line)
+		size += n
+		if isFence {
+			inFence = !inFence
+			if inFence {
+				fenceOpen = strings.TrimSpace(line)
+			}
+		}
+	}
+	if inFence {
+		// An unterminated fence in the input is left as-is.
+		inFence = false
+	}
+	flush()
+	return chunks
+}
+
+func splitRunes(s string, n int) (string, string) {
+	idx := 0
+	for i := 0; i < n && idx < len(s); i++ {
+		_, size := utf8.DecodeRuneInString(s[idx:])
+		idx += size
+	}
+	return s[:idx], s[idx:]
+}
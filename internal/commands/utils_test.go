@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/discordtest"
+)
+
+func TestSplitMessage(t *testing.T) {
+	if got := splitMessage("短い", 10); len(got) != 1 || got[0] != "短い" {
+		t.Fatalf("short = %q", got)
+	}
+
+	lines := make([]string, 30)
+	for idx := range lines {
+		lines[idx] = "あいうえおかきくけこ"
+	}
+	content := "```\n" + strings.Join(lines, "\n") + "\n```\n結果"
+	chunks := splitMessage(content, 100)
+	if len(chunks) < 2 {
+		t.Fatalf("chunks = %d", len(chunks))
+	}
+	for idx, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 100 {
+			t.Fatalf("chunk %d has %d chars", idx, n)
+		}
+		if strings.Count(c, "```")%2 != 0 {
+			t.Fatalf("chunk %d has an unbalanced code fence: %q", idx, c)
+		}
+	}
+	if !strings.HasSuffix(chunks[len(chunks)-1], "結果") {
+		t.Fatalf("last chunk = %q", chunks[len(chunks)-1])
+	}
+
+	long := strings.Repeat("あ", 250)
+	chunks = splitMessage(long, 100)
+	if strings.Join(chunks, "") != long {
+		t.Fatalf("long line not preserved: %q", chunks)
+	}
+
+	// A fence with a long info string is reopened without it.
+	content = "```" + strings.Repeat("x", 92) + "\n" + strings.Repeat("い", 250) + "\n```"
+	chunks = splitMessage(content, 100)
+	for idx, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 100 {
+			t.Fatalf("chunk %d has %d chars: %q", idx, n, c)
+		}
+		if strings.Count(c, "```")%2 != 0 {
+			t.Fatalf("chunk %d has an unbalanced code fence: %q", idx, c)
+		}
+	}
+	if got := strings.Count(strings.Join(chunks, ""), "い"); got != 250 {
+		t.Fatalf("long fenced line kept %d of 250 chars", got)
+	}
+
+	// An opening fence line longer than a whole chunk is not cut.
+	content = "```" + strings.Repeat("x", 150) + "\n" + strings.Repeat("う", 150) + "\n```\n結果"
+	chunks = splitMessage(content, 100)
+	for idx, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 100 {
+			t.Fatalf("chunk %d has %d chars: %q", idx, n, c)
+		}
+		if strings.Count(c, "```")%2 != 0 || strings.Contains(c, "x") {
+			t.Fatalf("chunk %d has a broken code fence: %q", idx, c)
+		}
+	}
+	if got := strings.Count(strings.Join(chunks, ""), "う"); got != 150 {
+		t.Fatalf("code after a long fence line kept %d of 150 chars", got)
+	}
+}
+
+func TestRespondSlowDefers(t *testing.T) {
+	defer func(d time.Duration) { deferAfter = d }(deferAfter)
+	deferAfter = 10 * time.Millisecond
+
+	s := discordtest.NewSession()
+	// "/nomikai status" replies are ephemeral by default.
+	c := discordtest.DefaultContext.Command("nomikai", discordtest.SubCommand("status"))
+	respondSlow(s, c, func() (string, error) {
+		time.Sleep(50 * time.Millisecond)
+		return strings.Repeat("a\n", 1500), nil
+	})
+	replies := s.Replies()
+	if len(replies) != 3 {
+		t.Fatalf("replies = %d", len(replies))
+	}
+	if replies[0].Type != discordgo.InteractionResponseDeferredChannelMessageWithSource || !replies[0].Ephemeral {
+		t.Fatalf("first reply = %+v", replies[0])
+	}
+	if replies[1].Kind != discordtest.KindEdit || replies[2].Kind != discordtest.KindFollowup || !replies[2].Ephemeral {
+		t.Fatalf("replies = %+v", replies)
+	}
+}
+
+func TestRespondSlowImmediateError(t *testing.T) {
+	s := discordtest.NewSession()
+	respondSlow(s, discordtest.DefaultContext.Command("guess"), func() (string, error) {
+		return "", errors.New("失敗しました")
+	})
+	r := s.LastReply()
+	if r.Kind != discordtest.KindRespond || r.Content != "失敗しました" || !r.Ephemeral {
+		t.Fatalf("reply = %+v", r)
+	}
+
+	s.Reset()
+	respondSlow(s, discordtest.DefaultContext.Command("guess"), func() (string, error) {
+		panic("boom")
+	})
+	if got := s.LastReply().Content; got != "内部エラーが発生しました" {
+		t.Fatalf("panic reply = %q", got)
+	}
+}
+
+func TestRespondSlowWithFiles(t *testing.T) {
+	s := discordtest.NewSession()
+	c := discordtest.DefaultContext.Command("guess", discordtest.SubCommand("answer"))
+	respondSlowWithFiles(s, c, VisibilityPublic, func() (string, []*discordgo.File, error) {
+		file := &discordgo.File{Name: "result.png", ContentType: "image/png", Reader: strings.NewReader("png")}
+		return strings.Repeat("a\n", 1500), []*discordgo.File{file}, nil
+	})
+	replies := s.Replies()
+	if len(replies) != 2 {
+		t.Fatalf("replies = %d", len(replies))
+	}
+	if len(replies[0].Files) != 0 {
+		t.Fatalf("first chunk has files: %v", replies[0].Files)
+	}
+	if got := replies[1].Files; len(got) != 1 || got[0] != "result.png" {
+		t.Fatalf("last chunk files = %v", got)
+	}
+}
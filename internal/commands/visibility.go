@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Visibility is how a reply is delivered to the user who invoked a command.
+type Visibility string
+
+const (
+	// VisibilityPublic posts the reply in the channel for everyone.
+	VisibilityPublic Visibility = "public"
+	// VisibilityEphemeral shows the reply only to the invoker.
+	VisibilityEphemeral Visibility = "ephemeral"
+	// VisibilityDM sends the reply as a direct message to the invoker.
+	VisibilityDM Visibility = "dm"
+)
+
+// Special reply setting keys besides command keys such as "nomikai status".
+const (
+	// ReplyTargetAll applies to every command without a more specific setting.
+	ReplyTargetAll = "*"
+	// ReplyTargetError applies to error replies of every command.
+	ReplyTargetError = "error"
+)
+
+// ParseVisibility validates a visibility name.
+func ParseVisibility(v string) (Visibility, error) {
+	switch vis := Visibility(strings.ToLower(strings.TrimSpace(v))); vis {
+	case VisibilityPublic, VisibilityEphemeral, VisibilityDM:
+		return vis, nil
+	}
+	return "", fmt.Errorf("visibility は public, ephemeral, dm のいずれかで指定してください")
+}
+
+// Label is the Japanese name of v shown in replies.
+func (v Visibility) Label() string {
+	switch v {
+	case VisibilityEphemeral:
+		return "本人のみ"
+	case VisibilityDM:
+		return "DM"
+	}
+	return "公開"
+}
+
+// defaultVisibilities applies when a guild has not configured a command.
+// Anything not listed here is public.
+var defaultVisibilities = map[string]Visibility{
+	ReplyTargetError:   VisibilityEphemeral,
+	"jikan list":       VisibilityEphemeral,
+	"nomikai status":   VisibilityEphemeral,
+	"nomikai history":  VisibilityEphemeral,
+	"nomikai show":     VisibilityEphemeral,
+	"nomikai use":      VisibilityEphemeral,
+	"nomikai payments": VisibilityEphemeral,
+	"nomikai settle":   VisibilityPublic,
+	"settings":         VisibilityEphemeral,
+}
+
+// DefaultVisibilities returns a copy of the built-in visibility defaults.
+func DefaultVisibilities() map[string]Visibility {
+	out := make(map[string]Visibility, len(defaultVisibilities))
+	for k, v := range defaultVisibilities {
+		out[k] = v
+	}
+	return out
+}
+
+// ReplyTargets lists every key a visibility can be configured for.
+func ReplyTargets() []string {
+	targets := []string{ReplyTargetAll, ReplyTargetError}
+	for _, cmd := range GetCommands() {
+		if cmd.Type != 0 && cmd.Type != discordgo.ChatApplicationCommand {
+			continue
+		}
+		targets = append(targets, cmd.Name)
+		for _, opt := range cmd.Options {
+			switch opt.Type {
+			case discordgo.ApplicationCommandOptionSubCommand:
+				targets = append(targets, cmd.Name+" "+opt.Name)
+			case discordgo.ApplicationCommandOptionSubCommandGroup:
+				for _, sub := range opt.Options {
+					targets = append(targets, cmd.Name+" "+opt.Name+" "+sub.Name)
+				}
+			}
+		}
+	}
+	sort.Strings(targets[2:])
+	return targets
+}
+
+// IsReplyTarget reports whether target is a key ReplyTargets knows about.
+func IsReplyTarget(target string) bool {
+	for _, t := range ReplyTargets() {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// replySettings is consulted for per-guild overrides; nil means only defaults apply.
+var replySettings ReplySettingsStore
+
+// SetReplySettingsStore makes reply helpers honor per-guild visibility settings from store.
+func SetReplySettingsStore(store ReplySettingsStore) {
+	replySettings = store
+	replySettingsCache.Lock()
+	replySettingsCache.guilds = make(map[int64]cachedReplySettings)
+	replySettingsCache.Unlock()
+}
+
+const replySettingsTimeout = 2 * time.Second
+
+// replySettingsTTL is how long a guild's settings are reused, so that most replies do not wait
+// for the database within Discord's 3 second deadline. Changes made through /settings and the
+// API take effect at once; see InvalidateReplySettings.
+const replySettingsTTL = time.Minute
+
+type cachedReplySettings struct {
+	settings map[string]Visibility
+	loaded   time.Time
+}
+
+var replySettingsCache = struct {
+	sync.Mutex
+	guilds map[int64]cachedReplySettings
+}{guilds: make(map[int64]cachedReplySettings)}
+
+// InvalidateReplySettings makes the next reply in the guild load its visibility settings again.
+// Call it after changing them.
+func InvalidateReplySettings(guildID int64) {
+	replySettingsCache.Lock()
+	delete(replySettingsCache.guilds, guildID)
+	replySettingsCache.Unlock()
+}
+
+// resolveVisibility decides how a reply to i is delivered. Normal replies use, in order, the
+// guild's setting for the subcommand, the top-level command, "*", then the built-in defaults.
+// Error replies use the guild's "error" setting, then the built-in default.
+func resolveVisibility(i *discordgo.InteractionCreate, isError bool) Visibility {
+	name := InteractionName(i)
+	keys := []string{ReplyTargetError}
+	if !isError {
+		keys = []string{name}
+		if top, _, ok := strings.Cut(name, " "); ok {
+			keys = append(keys, top)
+		}
+		keys = append(keys, ReplyTargetAll)
+	}
+
+	if overrides := guildReplySettings(i); overrides != nil {
+		for _, k := range keys {
+			if v, ok := overrides[k]; ok {
+				return v
+			}
+		}
+	}
+	for _, k := range keys {
+		if v, ok := defaultVisibilities[k]; ok {
+			return v
+		}
+	}
+	return VisibilityPublic
+}
+
+func guildReplySettings(i *discordgo.InteractionCreate) map[string]Visibility {
+	if replySettings == nil || i.GuildID == "" {
+		return nil
+	}
+	guildID := ParseGuildID(i.GuildID)
+	replySettingsCache.Lock()
+	cached, ok := replySettingsCache.guilds[guildID]
+	replySettingsCache.Unlock()
+	if ok && time.Since(cached.loaded) < replySettingsTTL {
+		return cached.settings
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), replySettingsTimeout)
+	defer cancel()
+	settings, err := replySettings.ListReplySettings(ctx, guildID)
+	if err != nil {
+		InteractionLogger(i).Warn("failed to load reply settings, using defaults", "err", err)
+		return nil
+	}
+	out := make(map[string]Visibility, len(settings))
+	for _, st := range settings {
+		out[st.Command] = Visibility(st.Visibility)
+	}
+	replySettingsCache.Lock()
+	replySettingsCache.guilds[guildID] = cachedReplySettings{settings: out, loaded: time.Now()}
+	replySettingsCache.Unlock()
+	return out
+}
@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/discordtest"
+)
+
+// memReplySettings is an in-memory ReplySettingsStore for a single guild.
+type memReplySettings map[string]string
+
+func (m memReplySettings) ListReplySettings(ctx context.Context, guildID int64) ([]db.ReplySetting, error) {
+	var out []db.ReplySetting
+	for cmd, vis := range m {
+		out = append(out, db.ReplySetting{GuildID: guildID, Command: cmd, Visibility: vis})
+	}
+	return out, nil
+}
+
+func (m memReplySettings) SetReplySetting(ctx context.Context, guildID int64, command, visibility string) error {
+	m[command] = visibility
+	return nil
+}
+
+func (m memReplySettings) DeleteReplySetting(ctx context.Context, guildID int64, command string) error {
+	if _, ok := m[command]; !ok {
+		return errors.New("reply setting not found")
+	}
+	delete(m, command)
+	return nil
+}
+
+func useReplySettings(t *testing.T, store ReplySettingsStore) {
+	t.Helper()
+	prev := replySettings
+	SetReplySettingsStore(store)
+	t.Cleanup(func() { SetReplySettingsStore(prev) })
+}
+
+// countingReplySettings counts how often the settings are loaded.
+type countingReplySettings struct {
+	memReplySettings
+	loads int
+}
+
+func (c *countingReplySettings) ListReplySettings(ctx context.Context, guildID int64) ([]db.ReplySetting, error) {
+	c.loads++
+	return c.memReplySettings.ListReplySettings(ctx, guildID)
+}
+
+func TestReplySettingsCached(t *testing.T) {
+	store := &countingReplySettings{memReplySettings: memReplySettings{"list": "dm"}}
+	useReplySettings(t, store)
+	c := discordtest.DefaultContext
+
+	for n := 0; n < 3; n++ {
+		if got := resolveVisibility(c.Command("list"), false); got != VisibilityDM {
+			t.Fatalf("visibility = %s", got)
+		}
+	}
+	if store.loads != 1 {
+		t.Fatalf("loaded %d times", store.loads)
+	}
+
+	// Changing a setting through /settings applies to the next reply.
+	HandleSettings(discordtest.NewSession(), c.Command("settings", discordtest.SubCommand("visibility",
+		discordtest.String("command", "list"), discordtest.String("mode", "public"))), store)
+	if got := resolveVisibility(c.Command("list"), false); got != VisibilityPublic {
+		t.Fatalf("after change: visibility = %s", got)
+	}
+}
+
+func TestResolveVisibility(t *testing.T) {
+	c := discordtest.DefaultContext
+	status := c.Command("nomikai", discordtest.SubCommand("status"))
+	settle := c.Command("nomikai", discordtest.SubCommand("settle"))
+	join := c.Command("nomikai", discordtest.SubCommand("join"))
+
+	useReplySettings(t, nil)
+	for _, tc := range []struct {
+		i       *discordgo.InteractionCreate
+		isError bool
+		want    Visibility
+	}{
+		{status, false, VisibilityEphemeral},
+		{settle, false, VisibilityPublic},
+		{join, false, VisibilityPublic},
+		{join, true, VisibilityEphemeral},
+	} {
+		if got := resolveVisibility(tc.i, tc.isError); got != tc.want {
+			t.Errorf("default %s (error=%v) = %s, want %s", InteractionName(tc.i), tc.isError, got, tc.want)
+		}
+	}
+
+	useReplySettings(t, memReplySettings{"nomikai": "dm", "*": "ephemeral", "nomikai settle": "public", "error": "public"})
+	for _, tc := range []struct {
+		i       *discordgo.InteractionCreate
+		isError bool
+		want    Visibility
+	}{
+		{status, false, VisibilityDM},
+		{settle, false, VisibilityPublic},
+		{c.Command("list"), false, VisibilityEphemeral},
+		{join, true, VisibilityPublic},
+	} {
+		if got := resolveVisibility(tc.i, tc.isError); got != tc.want {
+			t.Errorf("configured %s (error=%v) = %s, want %s", InteractionName(tc.i), tc.isError, got, tc.want)
+		}
+	}
+}
+
+func TestRespondDM(t *testing.T) {
+	useReplySettings(t, memReplySettings{"list": "dm"})
+	c := discordtest.DefaultContext
+	s := discordtest.NewSession()
+
+	respondText(s, c.Command("list"), "一覧")
+	replies := s.Replies()
+	if len(replies) != 2 || replies[0].ChannelID != discordtest.DMChannelPrefix+c.UserID || replies[0].Content != "一覧" {
+		t.Fatalf("replies = %+v", replies)
+	}
+	if !replies[1].Ephemeral || replies[1].Content != "📩 DMに送信しました" {
+		t.Fatalf("ack = %+v", replies[1])
+	}
+
+	// Users who refuse DMs get the reply ephemerally instead.
+	s.Reset()
+	s.DMErr = errors.New("cannot send messages to this user")
+	respondText(s, c.Command("list"), "一覧")
+	if r := s.LastReply(); len(s.Replies()) != 1 || !r.Ephemeral || r.Content != "一覧" {
+		t.Fatalf("fallback = %+v", s.Replies())
+	}
+}
+
+func TestRespondSlowPublicDeferredError(t *testing.T) {
+	defer func(d time.Duration) { deferAfter = d }(deferAfter)
+	deferAfter = 10 * time.Millisecond
+	useReplySettings(t, nil)
+
+	s := discordtest.NewSession()
+	respondSlow(s, discordtest.DefaultContext.Command("nomikai", discordtest.SubCommand("settle")), func() (string, error) {
+		time.Sleep(50 * time.Millisecond)
+		return "", errors.New("セッションが存在しません")
+	})
+	replies := s.Replies()
+	if len(replies) != 3 || replies[0].Ephemeral || replies[1].Kind != discordtest.KindDelete {
+		t.Fatalf("replies = %+v", replies)
+	}
+	if r := replies[2]; r.Kind != discordtest.KindFollowup || !r.Ephemeral || r.Content != "セッションが存在しません" {
+		t.Fatalf("error reply = %+v", r)
+	}
+}
+
+func TestHandleSettings(t *testing.T) {
+	store := memReplySettings{}
+	c := discordtest.DefaultContext
+	s := discordtest.NewSession()
+
+	HandleSettings(s, c.Command("settings", discordtest.SubCommand("visibility",
+		discordtest.String("command", "Nomikai  Status"), discordtest.String("mode", "dm"))), store)
+	if store["nomikai status"] != "dm" {
+		t.Fatalf("store = %v, reply = %q", store, s.LastReply().Content)
+	}
+	if r := s.LastReply(); !r.Ephemeral {
+		t.Fatalf("settings reply should be ephemeral: %+v", r)
+	}
+
+	HandleSettings(s, c.Command("settings", discordtest.SubCommand("visibility",
+		discordtest.String("command", "nomikai nope"), discordtest.String("mode", "dm"))), store)
+	if got := s.LastReply().Content; got != "コマンド 'nomikai nope' は設定できません" {
+		t.Fatalf("unknown target reply = %q", got)
+	}
+
+	HandleSettings(s, c.Command("settings", discordtest.SubCommand("visibility",
+		discordtest.String("command", "nomikai status"), discordtest.String("mode", "default"))), store)
+	if len(store) != 0 {
+		t.Fatalf("store after reset = %v", store)
+	}
+}
@@ -24,6 +24,13 @@ type Config struct {
 
 	// Session
 	JWTSecret string
+
+	// Metrics
+	MetricsToken string // bearer token for /metrics; the endpoint is disabled when empty
+
+	// Logging
+	LogLevel  string // debug, info, warn, error
+	LogFormat string // text or json
 }
 
 func Load() (*Config, error) {
@@ -38,6 +45,9 @@ func Load() (*Config, error) {
 		DiscordClientSecret: os.Getenv("DISCORD_CLIENT_SECRET"),
 		DiscordRedirectURI:  getEnvDefault("DISCORD_REDIRECT_URI", "http://localhost:3000/api/auth/callback"),
 		JWTSecret:           getEnvDefault("JWT_SECRET", "dev-only-change-me"),
+		MetricsToken:        os.Getenv("METRICS_TOKEN"),
+		LogLevel:            getEnvDefault("LOG_LEVEL", "info"),
+		LogFormat:           getEnvDefault("LOG_FORMAT", "text"),
 	}
 
 	if cfg.DiscordToken == "" {
@@ -35,6 +35,16 @@ func (db *DB) Close() {
 	db.pool.Close()
 }
 
+// Ping checks that the database is reachable.
+func (db *DB) Ping(ctx context.Context) error {
+	return db.pool.Ping(ctx)
+}
+
+// Stat returns connection pool statistics.
+func (db *DB) Stat() *pgxpool.Stat {
+	return db.pool.Stat()
+}
+
 // Exec executes a query without returning any rows.
 func (db *DB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
 	return db.pool.Exec(ctx, sql, args...)
@@ -50,11 +60,18 @@ func (db *DB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx
 	return db.pool.QueryRow(ctx, sql, args...)
 }
 
+// Begin starts a transaction.
+func (db *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return db.pool.Begin(ctx)
+}
+
 // RunMigrations runs database migrations
 func (db *DB) RunMigrations(ctx context.Context) error {
-	// Load and execute all .sql files under ./migrations in lexical order
-	migrationsDir := "./migrations"
+	return db.RunMigrationsDir(ctx, "./migrations")
+}
 
+// RunMigrationsDir loads and executes all .sql files under migrationsDir in lexical order.
+func (db *DB) RunMigrationsDir(ctx context.Context, migrationsDir string) error {
 	entries, err := os.ReadDir(migrationsDir)
 	if err != nil {
 		return fmt.Errorf("failed to read migrations dir: %w", err)
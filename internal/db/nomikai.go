@@ -16,13 +16,44 @@ type ReminderConfig struct {
 }
 
 type NomikaiEvent struct {
-	ID                int64
-	GuildID           int64
-	ChannelID         string
+	ID        int64
+	GuildID   int64
+	ChannelID string
+	// Name tells apart the active events of a channel; events may be unnamed ("").
+	Name              string
 	OrganizerID       string
 	Status            string
 	RoundingUnit      int
 	RemainderStrategy string
+	CreatedAt         time.Time
+	ClosedAt          *time.Time
+}
+
+// NomikaiEventSummary is an event with its totals, for listing past events.
+type NomikaiEventSummary struct {
+	NomikaiEvent
+	Members int
+	Total   int64
+	// Unpaid is what the pending settlement tasks still add up to.
+	Unpaid int64
+}
+
+// NomikaiSettlementPayment is a logged settlement payment (/nomikai seisan).
+type NomikaiSettlementPayment struct {
+	ID         int64
+	PayerID    string
+	PayeeID    string
+	Amount     int64
+	Memo       string
+	RecordedBy string
+	CreatedAt  time.Time
+}
+
+const nomikaiEventColumns = `id, guild_id, channel_id, name, organizer_id, status, rounding_unit, remainder_strategy, COALESCE(created_at, CURRENT_TIMESTAMP), closed_at`
+
+func scanNomikaiEvent(row pgx.Row, ev *NomikaiEvent, extra ...interface{}) error {
+	dest := append([]interface{}{&ev.ID, &ev.GuildID, &ev.ChannelID, &ev.Name, &ev.OrganizerID, &ev.Status, &ev.RoundingUnit, &ev.RemainderStrategy, &ev.CreatedAt, &ev.ClosedAt}, extra...)
+	return row.Scan(dest...)
 }
 
 type NomikaiMember struct {
@@ -37,17 +68,45 @@ type NomikaiPayment struct {
 	PayerID string
 	Amount  int64
 	Memo    string
+	// RecordedBy is who ran /nomikai tatekae; empty for payments recorded before it was kept.
+	RecordedBy string
 }
 
-// CreateEvent creates a new active event. If there is already an active event for the channel,
-// it returns an error unless allowDuplicate is true.
-func (db *DB) CreateEvent(ctx context.Context, guildID int64, channelID, organizerID string, roundingUnit int, remainderStrategy string) (int64, error) {
+// Actions in the payment audit trail.
+const (
+	NomikaiPaymentEdited  = "edit"
+	NomikaiPaymentDeleted = "delete"
+)
+
+// NomikaiPaymentValues are the editable fields of a payment.
+type NomikaiPaymentValues struct {
+	PayerID       string
+	Amount        int64
+	Memo          string
+	Beneficiaries []string
+}
+
+// NomikaiPaymentAudit is an edit or deletion of a recorded payment. New is nil for deletions.
+type NomikaiPaymentAudit struct {
+	ID        int64
+	EventID   int64
+	PaymentID int64
+	Action    string
+	ActorID   string
+	Old       NomikaiPaymentValues
+	New       *NomikaiPaymentValues
+	CreatedAt time.Time
+}
+
+// CreateEvent creates a new active event. It fails if the channel already has an active event
+// with the same name.
+func (db *DB) CreateEvent(ctx context.Context, guildID int64, channelID, name, organizerID string, roundingUnit int, remainderStrategy string) (int64, error) {
 	var id int64
 	err := db.pool.QueryRow(ctx,
-		`INSERT INTO nomikai_events (guild_id, channel_id, organizer_id, status, rounding_unit, remainder_strategy)
-         VALUES ($1, $2, $3, 'active', $4, $5)
+		`INSERT INTO nomikai_events (guild_id, channel_id, name, organizer_id, status, rounding_unit, remainder_strategy)
+         VALUES ($1, $2, $3, $4, 'active', $5, $6)
          RETURNING id`,
-		guildID, channelID, organizerID, roundingUnit, remainderStrategy,
+		guildID, channelID, name, organizerID, roundingUnit, remainderStrategy,
 	).Scan(&id)
 	if err != nil {
 		return 0, err
@@ -78,8 +137,8 @@ func (db *DB) UpsertMember(ctx context.Context, eventID int64, userID string, we
 	return err
 }
 
-// AddPayment inserts a payment and optional beneficiaries.
-func (db *DB) AddPayment(ctx context.Context, eventID int64, payerID string, amount int64, memo string, beneficiaries []string) (int64, error) {
+// AddPayment inserts a payment recorded by recordedBy and optional beneficiaries.
+func (db *DB) AddPayment(ctx context.Context, eventID int64, payerID, recordedBy string, amount int64, memo string, beneficiaries []string) (int64, error) {
 	tx, err := db.pool.Begin(ctx)
 	if err != nil {
 		return 0, err
@@ -88,10 +147,10 @@ func (db *DB) AddPayment(ctx context.Context, eventID int64, payerID string, amo
 
 	var payID int64
 	if err := tx.QueryRow(ctx,
-		`INSERT INTO nomikai_payments (event_id, payer_id, amount, memo)
-         VALUES ($1, $2, $3, $4)
+		`INSERT INTO nomikai_payments (event_id, payer_id, amount, memo, recorded_by)
+         VALUES ($1, $2, $3, $4, $5)
          RETURNING id`,
-		eventID, payerID, amount, memo,
+		eventID, payerID, amount, memo, recordedBy,
 	).Scan(&payID); err != nil {
 		return 0, err
 	}
@@ -134,9 +193,11 @@ func (db *DB) Members(ctx context.Context, eventID int64) ([]NomikaiMember, erro
 	return out, rows.Err()
 }
 
+const nomikaiPaymentColumns = `id, event_id, payer_id, amount, COALESCE(memo, ''), COALESCE(recorded_by, '')`
+
 // Payments returns payments and does not expand beneficiaries.
 func (db *DB) Payments(ctx context.Context, eventID int64) ([]NomikaiPayment, error) {
-	rows, err := db.pool.Query(ctx, `SELECT id, event_id, payer_id, amount, COALESCE(memo, '') FROM nomikai_payments WHERE event_id = $1 ORDER BY id`, eventID)
+	rows, err := db.pool.Query(ctx, `SELECT `+nomikaiPaymentColumns+` FROM nomikai_payments WHERE event_id = $1 ORDER BY id`, eventID)
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +205,7 @@ func (db *DB) Payments(ctx context.Context, eventID int64) ([]NomikaiPayment, er
 	var out []NomikaiPayment
 	for rows.Next() {
 		var p NomikaiPayment
-		if err := rows.Scan(&p.ID, &p.EventID, &p.PayerID, &p.Amount, &p.Memo); err != nil {
+		if err := rows.Scan(&p.ID, &p.EventID, &p.PayerID, &p.Amount, &p.Memo, &p.RecordedBy); err != nil {
 			return nil, err
 		}
 		out = append(out, p)
@@ -218,16 +279,129 @@ func (db *DB) InsertDebt(ctx context.Context, guildID int64, lenderID, borrowerI
 	return id, nil
 }
 
-// ActiveEventByChannel returns the active event for the given channel, if any.
-func (db *DB) ActiveEventByChannel(ctx context.Context, channelID string) (*NomikaiEvent, error) {
-	row := db.pool.QueryRow(ctx, `SELECT id, guild_id, channel_id, organizer_id, status, rounding_unit, remainder_strategy FROM nomikai_events WHERE channel_id = $1 AND status = 'active' LIMIT 1`, channelID)
+// ActiveEvents returns the active events of the channel, most recent first.
+func (db *DB) ActiveEvents(ctx context.Context, channelID string) ([]NomikaiEvent, error) {
+	rows, err := db.pool.Query(ctx, `SELECT `+nomikaiEventColumns+` FROM nomikai_events WHERE channel_id = $1 AND status = 'active' ORDER BY id DESC`, channelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []NomikaiEvent
+	for rows.Next() {
+		var ev NomikaiEvent
+		if err := scanNomikaiEvent(rows, &ev); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// CurrentEventID returns the event the user last chose in the channel, or 0 if none.
+func (db *DB) CurrentEventID(ctx context.Context, channelID, userID string) (int64, error) {
+	var id int64
+	err := db.pool.QueryRow(ctx,
+		`SELECT event_id FROM nomikai_current_events WHERE channel_id = $1 AND user_id = $2`,
+		channelID, userID,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SetCurrentEvent makes eventID the user's current event in the channel.
+func (db *DB) SetCurrentEvent(ctx context.Context, channelID, userID string, eventID int64) error {
+	_, err := db.pool.Exec(ctx,
+		`INSERT INTO nomikai_current_events (channel_id, user_id, event_id)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (channel_id, user_id) DO UPDATE
+		 SET event_id = EXCLUDED.event_id, updated_at = CURRENT_TIMESTAMP`,
+		channelID, userID, eventID,
+	)
+	return err
+}
+
+// EventByID returns an event of the guild, active or closed. It returns pgx.ErrNoRows if the
+// guild has no such event.
+func (db *DB) EventByID(ctx context.Context, guildID, eventID int64) (*NomikaiEvent, error) {
+	row := db.pool.QueryRow(ctx, `SELECT `+nomikaiEventColumns+` FROM nomikai_events WHERE guild_id = $1 AND id = $2`, guildID, eventID)
 	var ev NomikaiEvent
-	if err := row.Scan(&ev.ID, &ev.GuildID, &ev.ChannelID, &ev.OrganizerID, &ev.Status, &ev.RoundingUnit, &ev.RemainderStrategy); err != nil {
+	if err := scanNomikaiEvent(row, &ev); err != nil {
 		return nil, err
 	}
 	return &ev, nil
 }
 
+// ListEvents returns the guild's events with their totals, newest first. A non-empty channelID
+// lists only the events of that channel.
+func (db *DB) ListEvents(ctx context.Context, guildID int64, channelID string, limit int) ([]NomikaiEventSummary, error) {
+	rows, err := db.pool.Query(ctx,
+		`SELECT `+nomikaiEventColumns+`,
+		        (SELECT COUNT(*) FROM nomikai_event_members m WHERE m.event_id = e.id),
+		        (SELECT COALESCE(SUM(amount), 0) FROM nomikai_payments p WHERE p.event_id = e.id),
+		        (SELECT COALESCE(SUM(amount), 0) FROM nomikai_settlement_tasks t WHERE t.event_id = e.id AND t.completed = FALSE)
+		 FROM nomikai_events e
+		 WHERE guild_id = $1 AND ($2 = '' OR channel_id = $2)
+		 ORDER BY id DESC
+		 LIMIT $3`,
+		guildID, channelID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []NomikaiEventSummary
+	for rows.Next() {
+		var e NomikaiEventSummary
+		if err := scanNomikaiEvent(rows, &e.NomikaiEvent, &e.Members, &e.Total, &e.Unpaid); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ReopenEvent makes a closed event active again. It fails if its channel already has an
+// active event with the same name.
+func (db *DB) ReopenEvent(ctx context.Context, eventID int64) error {
+	ct, err := db.pool.Exec(ctx, `UPDATE nomikai_events SET status = 'active', closed_at = NULL WHERE id = $1 AND status = 'closed'`, eventID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return fmt.Errorf("event not found")
+	}
+	return nil
+}
+
+// SettlementPayments returns the settlement payments logged for an event, oldest first.
+func (db *DB) SettlementPayments(ctx context.Context, eventID int64) ([]NomikaiSettlementPayment, error) {
+	rows, err := db.pool.Query(ctx,
+		`SELECT id, payer_id, payee_id, amount, COALESCE(memo, ''), COALESCE(recorded_by, ''), COALESCE(created_at, CURRENT_TIMESTAMP)
+		 FROM nomikai_task_payments
+		 WHERE event_id = $1
+		 ORDER BY id`,
+		eventID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []NomikaiSettlementPayment
+	for rows.Next() {
+		var p NomikaiSettlementPayment
+		if err := rows.Scan(&p.ID, &p.PayerID, &p.PayeeID, &p.Amount, &p.Memo, &p.RecordedBy, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
 type SettlementTaskRow struct {
 	PayerID string
 	PayeeID string
@@ -236,6 +410,7 @@ type SettlementTaskRow struct {
 
 type ReminderDue struct {
 	EventID         int64
+	EventName       string
 	ChannelID       string
 	IntervalMinutes int
 }
@@ -335,7 +510,7 @@ func (db *DB) OutstandingSettlementAmount(ctx context.Context, eventID int64, pa
 // DueReminders returns reminder targets that are due and still have pending tasks.
 func (db *DB) DueReminders(ctx context.Context, now time.Time) ([]ReminderDue, error) {
 	rows, err := db.pool.Query(ctx,
-		`SELECT r.event_id, e.channel_id, r.interval_minutes
+		`SELECT r.event_id, e.name, e.channel_id, r.interval_minutes
 		 FROM nomikai_reminders r
 		 JOIN nomikai_events e ON e.id = r.event_id
 		 WHERE r.enabled = TRUE
@@ -354,7 +529,7 @@ func (db *DB) DueReminders(ctx context.Context, now time.Time) ([]ReminderDue, e
 	var targets []ReminderDue
 	for rows.Next() {
 		var r ReminderDue
-		if err := rows.Scan(&r.EventID, &r.ChannelID, &r.IntervalMinutes); err != nil {
+		if err := rows.Scan(&r.EventID, &r.EventName, &r.ChannelID, &r.IntervalMinutes); err != nil {
 			return nil, err
 		}
 		targets = append(targets, r)
@@ -541,3 +716,148 @@ func (db *DB) RecordSettlementPaymentAll(ctx context.Context, eventID int64, pay
 	}
 	return total, nil
 }
+
+// PaymentByID returns a payment of an event in the guild.
+func (db *DB) PaymentByID(ctx context.Context, guildID, paymentID int64) (*NomikaiPayment, error) {
+	var p NomikaiPayment
+	err := db.pool.QueryRow(ctx,
+		`SELECT `+nomikaiPaymentColumns+`
+		 FROM nomikai_payments
+		 WHERE id = $1 AND event_id IN (SELECT id FROM nomikai_events WHERE guild_id = $2)`,
+		paymentID, guildID,
+	).Scan(&p.ID, &p.EventID, &p.PayerID, &p.Amount, &p.Memo, &p.RecordedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpdatePayment applies a.New to the payment and logs a in the audit trail. The event's
+// settlement tasks no longer add up, so they are cleared; it reports whether there were any.
+func (db *DB) UpdatePayment(ctx context.Context, a *NomikaiPaymentAudit) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ct, err := tx.Exec(ctx,
+		`UPDATE nomikai_payments SET payer_id = $3, amount = $4, memo = $5 WHERE id = $1 AND event_id = $2`,
+		a.PaymentID, a.EventID, a.New.PayerID, a.New.Amount, a.New.Memo,
+	)
+	if err != nil {
+		return false, err
+	}
+	if ct.RowsAffected() == 0 {
+		return false, fmt.Errorf("payment not found")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM nomikai_payment_beneficiaries WHERE payment_id = $1`, a.PaymentID); err != nil {
+		return false, err
+	}
+	for _, uid := range a.New.Beneficiaries {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO nomikai_payment_beneficiaries (payment_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			a.PaymentID, uid,
+		); err != nil {
+			return false, err
+		}
+	}
+	cleared, err := logPaymentChange(ctx, tx, a)
+	if err != nil {
+		return false, err
+	}
+	return cleared, tx.Commit(ctx)
+}
+
+// DeletePayment deletes the payment and logs a in the audit trail. Like UpdatePayment, it
+// clears the event's settlement tasks and reports whether there were any.
+func (db *DB) DeletePayment(ctx context.Context, a *NomikaiPaymentAudit) (bool, error) {
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	ct, err := tx.Exec(ctx, `DELETE FROM nomikai_payments WHERE id = $1 AND event_id = $2`, a.PaymentID, a.EventID)
+	if err != nil {
+		return false, err
+	}
+	if ct.RowsAffected() == 0 {
+		return false, fmt.Errorf("payment not found")
+	}
+	cleared, err := logPaymentChange(ctx, tx, a)
+	if err != nil {
+		return false, err
+	}
+	return cleared, tx.Commit(ctx)
+}
+
+func logPaymentChange(ctx context.Context, tx pgx.Tx, a *NomikaiPaymentAudit) (bool, error) {
+	var newPayer, newMemo *string
+	var newAmount *int64
+	var newBen []string
+	if a.New != nil {
+		newPayer, newAmount, newMemo = &a.New.PayerID, &a.New.Amount, &a.New.Memo
+		newBen = nonNilStrings(a.New.Beneficiaries)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO nomikai_payment_audit
+		   (event_id, payment_id, action, actor_id, old_payer_id, old_amount, old_memo, old_beneficiaries,
+		    new_payer_id, new_amount, new_memo, new_beneficiaries)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		a.EventID, a.PaymentID, a.Action, a.ActorID, a.Old.PayerID, a.Old.Amount, a.Old.Memo, nonNilStrings(a.Old.Beneficiaries),
+		newPayer, newAmount, newMemo, newBen,
+	); err != nil {
+		return false, err
+	}
+	ct, err := tx.Exec(ctx, `DELETE FROM nomikai_settlement_tasks WHERE event_id = $1`, a.EventID)
+	if err != nil {
+		return false, err
+	}
+	return ct.RowsAffected() > 0, nil
+}
+
+// nonNilStrings keeps an empty list from being stored as NULL.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// PaymentAudits returns the audit trail of an event's payments, oldest first.
+func (db *DB) PaymentAudits(ctx context.Context, eventID int64) ([]NomikaiPaymentAudit, error) {
+	rows, err := db.pool.Query(ctx,
+		`SELECT id, event_id, payment_id, action, actor_id, old_payer_id, old_amount, old_memo, old_beneficiaries,
+		        new_payer_id, new_amount, new_memo, new_beneficiaries, COALESCE(created_at, CURRENT_TIMESTAMP)
+		 FROM nomikai_payment_audit
+		 WHERE event_id = $1
+		 ORDER BY id`,
+		eventID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []NomikaiPaymentAudit
+	for rows.Next() {
+		var a NomikaiPaymentAudit
+		var newPayer, newMemo *string
+		var newAmount *int64
+		var newBen []string
+		if err := rows.Scan(&a.ID, &a.EventID, &a.PaymentID, &a.Action, &a.ActorID,
+			&a.Old.PayerID, &a.Old.Amount, &a.Old.Memo, &a.Old.Beneficiaries,
+			&newPayer, &newAmount, &newMemo, &newBen, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if newPayer != nil && newAmount != nil {
+			a.New = &NomikaiPaymentValues{PayerID: *newPayer, Amount: *newAmount, Beneficiaries: newBen}
+			if newMemo != nil {
+				a.New.Memo = *newMemo
+			}
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
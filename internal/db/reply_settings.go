@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReplySetting overrides how replies to a command are delivered in one guild.
+type ReplySetting struct {
+	GuildID    int64  `json:"guild_id"`
+	Command    string `json:"command"`
+	Visibility string `json:"visibility"`
+}
+
+func (db *DB) ListReplySettings(ctx context.Context, guildID int64) ([]ReplySetting, error) {
+	rows, err := db.pool.Query(ctx,
+		"SELECT guild_id, command, visibility FROM reply_settings WHERE guild_id = $1 ORDER BY command",
+		guildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []ReplySetting
+	for rows.Next() {
+		var st ReplySetting
+		if err := rows.Scan(&st.GuildID, &st.Command, &st.Visibility); err != nil {
+			return nil, err
+		}
+		settings = append(settings, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (db *DB) SetReplySetting(ctx context.Context, guildID int64, command, visibility string) error {
+	_, err := db.pool.Exec(ctx,
+		`INSERT INTO reply_settings (guild_id, command, visibility) VALUES ($1, $2, $3)
+		 ON CONFLICT (guild_id, command) DO UPDATE SET visibility = EXCLUDED.visibility, updated_at = now()`,
+		guildID, command, visibility,
+	)
+	return err
+}
+
+func (db *DB) DeleteReplySetting(ctx context.Context, guildID int64, command string) error {
+	result, err := db.pool.Exec(ctx,
+		"DELETE FROM reply_settings WHERE guild_id = $1 AND command = $2",
+		guildID, command,
+	)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("reply setting not found")
+	}
+	return nil
+}
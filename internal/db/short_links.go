@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/geourl"
+)
+
+// shortLinkTTL is how long a resolved short link is trusted. Links can be edited to point
+// elsewhere, so older entries are resolved again.
+const shortLinkTTL = "30 days"
+
+var _ geourl.Cache = (*DB)(nil)
+
+// GetShortLink returns the cached resolution of shortURL, or nil if it has not been resolved
+// within shortLinkTTL.
+func (db *DB) GetShortLink(ctx context.Context, shortURL string) (*geourl.ShortLink, error) {
+	l := geourl.ShortLink{ShortURL: shortURL}
+	err := db.pool.QueryRow(ctx,
+		"SELECT lat, lng, final_url FROM short_link_cache WHERE short_url = $1 AND created_at > CURRENT_TIMESTAMP - $2::interval",
+		shortURL, shortLinkTTL,
+	).Scan(&l.Lat, &l.Lng, &l.FinalURL)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// SaveShortLink caches the resolution of a short link.
+func (db *DB) SaveShortLink(ctx context.Context, l geourl.ShortLink) error {
+	_, err := db.pool.Exec(ctx, `
+		INSERT INTO short_link_cache (short_url, lat, lng, final_url)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (short_url) DO UPDATE SET lat = EXCLUDED.lat, lng = EXCLUDED.lng, final_url = EXCLUDED.final_url, created_at = CURRENT_TIMESTAMP
+	`, l.ShortURL, l.Lat, l.Lng, l.FinalURL)
+	return err
+}
@@ -0,0 +1,153 @@
+package discordtest
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Option is shorthand for a slash command option as delivered by Discord.
+type Option = discordgo.ApplicationCommandInteractionDataOption
+
+// Context identifies where and by whom an interaction was sent.
+type Context struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+}
+
+// DefaultContext is used by tests that do not care about the IDs.
+var DefaultContext = Context{
+	GuildID:   "100000000000000001",
+	ChannelID: "200000000000000001",
+	UserID:    "300000000000000001",
+}
+
+func (c Context) interaction(typ discordgo.InteractionType, data discordgo.InteractionData) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			ID:        "interaction",
+			Type:      typ,
+			Data:      data,
+			GuildID:   c.GuildID,
+			ChannelID: c.ChannelID,
+			Member: &discordgo.Member{
+				GuildID: c.GuildID,
+				User:    &discordgo.User{ID: c.UserID},
+			},
+		},
+	}
+}
+
+// Command builds a slash command interaction. Resolved users are filled in from user options.
+func (c Context) Command(name string, options ...*Option) *discordgo.InteractionCreate {
+	data := discordgo.ApplicationCommandInteractionData{
+		ID:      "command",
+		Name:    name,
+		Options: options,
+	}
+	users := make(map[string]*discordgo.User)
+	collectUsers(options, users)
+	if len(users) > 0 {
+		data.Resolved = &discordgo.ApplicationCommandInteractionDataResolved{Users: users}
+	}
+	return c.interaction(discordgo.InteractionApplicationCommand, data)
+}
+
+// Autocomplete builds an autocomplete interaction; mark the option being typed with Focused.
+func (c Context) Autocomplete(name string, options ...*Option) *discordgo.InteractionCreate {
+	ic := c.Command(name, options...)
+	ic.Type = discordgo.InteractionApplicationCommandAutocomplete
+	return ic
+}
+
+// MessageCommand builds a message context menu interaction targeting msg.
+func (c Context) MessageCommand(name string, msg *discordgo.Message) *discordgo.InteractionCreate {
+	return c.interaction(discordgo.InteractionApplicationCommand, discordgo.ApplicationCommandInteractionData{
+		ID:       "command",
+		Name:     name,
+		TargetID: msg.ID,
+		Resolved: &discordgo.ApplicationCommandInteractionDataResolved{
+			Messages: map[string]*discordgo.Message{msg.ID: msg},
+		},
+	})
+}
+
+// ModalSubmit builds a modal submission with one text input per field (custom ID -> value).
+func (c Context) ModalSubmit(customID string, fields map[string]string) *discordgo.InteractionCreate {
+	var rows []discordgo.MessageComponent
+	for id, value := range fields {
+		rows = append(rows, &discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				&discordgo.TextInput{CustomID: id, Value: value},
+			},
+		})
+	}
+	return c.interaction(discordgo.InteractionModalSubmit, discordgo.ModalSubmitInteractionData{
+		CustomID:   customID,
+		Components: rows,
+	})
+}
+
+// MessageCreate builds a plain channel message event.
+func (c Context) MessageCreate(content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        "message",
+			GuildID:   c.GuildID,
+			ChannelID: c.ChannelID,
+			Content:   content,
+			Author:    &discordgo.User{ID: c.UserID},
+		},
+	}
+}
+
+// SubCommand builds a subcommand option.
+func SubCommand(name string, options ...*Option) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionSubCommand, Options: options}
+}
+
+// SubCommandGroup builds a subcommand group option holding subcommands.
+func SubCommandGroup(name string, subcommands ...*Option) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionSubCommandGroup, Options: subcommands}
+}
+
+// String builds a string option.
+func String(name, value string) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+}
+
+// Int builds an integer option. Discord delivers all numbers as JSON floats.
+func Int(name string, value int64) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionInteger, Value: float64(value)}
+}
+
+// Number builds a number option.
+func Number(name string, value float64) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionNumber, Value: value}
+}
+
+// Bool builds a boolean option.
+func Bool(name string, value bool) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionBoolean, Value: value}
+}
+
+// User builds a user option holding a snowflake.
+func User(name, userID string) *Option {
+	return &Option{Name: name, Type: discordgo.ApplicationCommandOptionUser, Value: userID}
+}
+
+// Focused marks opt as the option currently being typed in an autocomplete request.
+func Focused(opt *Option) *Option {
+	opt.Focused = true
+	return opt
+}
+
+func collectUsers(options []*Option, users map[string]*discordgo.User) {
+	for _, o := range options {
+		if o.Type == discordgo.ApplicationCommandOptionUser {
+			if id, ok := o.Value.(string); ok {
+				users[id] = &discordgo.User{ID: id}
+			}
+		}
+		collectUsers(o.Options, users)
+	}
+}
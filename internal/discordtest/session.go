@@ -0,0 +1,240 @@
+// Package discordtest provides an in-memory Discord session and interaction builders
+// for exercising command handlers without a gateway connection.
+package discordtest
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Reply kinds recorded by Session.
+const (
+	KindRespond  = "respond"
+	KindEdit     = "edit"
+	KindFollowup = "followup"
+	KindMessage  = "message"
+	KindDelete   = "delete"
+	// KindMessageEdit is an edit of a channel message; Reply.MessageID names the message.
+	KindMessageEdit = "message_edit"
+)
+
+// DMChannelPrefix prefixes the channel ID returned by UserChannelCreate, so DMs can be told
+// apart from channel messages: a DM to user 42 is sent to channel "dm-42".
+const DMChannelPrefix = "dm-"
+
+// Reply is a single message the bot tried to send.
+type Reply struct {
+	Kind      string
+	ChannelID string
+	Content   string
+	Ephemeral bool
+	// Type is the interaction response type (KindRespond only).
+	Type discordgo.InteractionResponseType
+	// Response is the raw interaction response (KindRespond only).
+	Response *discordgo.InteractionResponse
+	// Files are the names of attached files.
+	Files []string
+	// MessageID is the edited message (KindMessageEdit only).
+	MessageID string
+}
+
+func fileNames(files []*discordgo.File) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Session records interaction responses and channel messages in the order they were sent.
+// It satisfies commands.Session.
+type Session struct {
+	mu       sync.Mutex
+	replies  []Reply
+	messages map[string]*discordgo.Message
+	nextID   int
+
+	// Err, when set, is returned from every call instead of recording the reply.
+	Err error
+	// DMErr, when set, is returned from UserChannelCreate.
+	DMErr error
+}
+
+// NewSession returns an empty fake session.
+func NewSession() *Session {
+	return &Session{messages: make(map[string]*discordgo.Message)}
+}
+
+// AddMessage makes msg available to ChannelMessage.
+func (s *Session) AddMessage(msg *discordgo.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[msg.ChannelID+"/"+msg.ID] = msg
+}
+
+// Replies returns a copy of everything sent so far.
+func (s *Session) Replies() []Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Reply, len(s.replies))
+	copy(out, s.replies)
+	return out
+}
+
+// LastReply returns the most recent reply, or a zero Reply when nothing was sent.
+func (s *Session) LastReply() Reply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.replies) == 0 {
+		return Reply{}
+	}
+	return s.replies[len(s.replies)-1]
+}
+
+// Contents returns the text of every reply in order.
+func (s *Session) Contents() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.replies))
+	for _, r := range s.replies {
+		out = append(out, r.Content)
+	}
+	return out
+}
+
+// Reset forgets all recorded replies.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = nil
+}
+
+func (s *Session) record(r Reply) *discordgo.Message {
+	s.nextID++
+	s.replies = append(s.replies, r)
+	msg := &discordgo.Message{
+		ID:        strconv.Itoa(s.nextID),
+		ChannelID: r.ChannelID,
+		Content:   r.Content,
+	}
+	s.messages[msg.ChannelID+"/"+msg.ID] = msg
+	return msg
+}
+
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	r := Reply{Kind: KindRespond, ChannelID: interaction.ChannelID, Type: resp.Type, Response: resp}
+	if resp.Data != nil {
+		r.Content = resp.Data.Content
+		r.Ephemeral = resp.Data.Flags&discordgo.MessageFlagsEphemeral != 0
+		r.Files = fileNames(resp.Data.Files)
+	}
+	s.record(r)
+	return nil
+}
+
+func (s *Session) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	r := Reply{Kind: KindEdit, ChannelID: interaction.ChannelID, Files: fileNames(newresp.Files)}
+	if newresp.Content != nil {
+		r.Content = *newresp.Content
+	}
+	return s.record(r), nil
+}
+
+func (s *Session) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return s.Err
+	}
+	s.record(Reply{Kind: KindDelete, ChannelID: interaction.ChannelID})
+	return nil
+}
+
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return s.record(Reply{
+		Kind:      KindFollowup,
+		ChannelID: interaction.ChannelID,
+		Content:   data.Content,
+		Ephemeral: data.Flags&discordgo.MessageFlagsEphemeral != 0,
+		Files:     fileNames(data.Files),
+	}), nil
+}
+
+func (s *Session) ChannelMessage(channelID, messageID string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	msg, ok := s.messages[channelID+"/"+messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %s not found in channel %s", messageID, channelID)
+	}
+	return msg, nil
+}
+
+func (s *Session) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return s.record(Reply{Kind: KindMessage, ChannelID: channelID, Content: content}), nil
+}
+
+func (s *Session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return s.record(Reply{Kind: KindMessage, ChannelID: channelID, Content: data.Content, Files: fileNames(data.Files)}), nil
+}
+
+// ChannelMessageEdit replaces the content of a message sent or added earlier.
+func (s *Session) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	msg, ok := s.messages[channelID+"/"+messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %s not found in channel %s", messageID, channelID)
+	}
+	s.replies = append(s.replies, Reply{Kind: KindMessageEdit, ChannelID: channelID, Content: content, MessageID: messageID})
+	msg.Content = content
+	return msg, nil
+}
+
+// UserChannelCreate returns a DM channel whose ID is DMChannelPrefix followed by recipientID.
+// Set DMErr to simulate a user who does not accept DMs.
+func (s *Session) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	if s.DMErr != nil {
+		return nil, s.DMErr
+	}
+	return &discordgo.Channel{ID: DMChannelPrefix + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
@@ -0,0 +1,167 @@
+// Package georegion finds the country, and in Japan the prefecture, that a point lies in.
+// The boundaries are simplified polygons bundled with the binary: good enough to tell
+// countries and prefectures apart in a game, not for anything that needs exact borders.
+package georegion
+
+import (
+	_ "embed"
+	"encoding/json"
+	"math"
+)
+
+// Japan is the country code of Japan, the only country with prefecture data.
+const Japan = "JP"
+
+// CoastTolerance is how far outside the simplified outlines a point may be and still count as
+// the nearest region. The coarse coastlines cut off some real land, so a point just off the
+// coast most likely belongs to the region next to it.
+const CoastTolerance = 30000.0
+
+// Region is a country (ISO 3166-1 alpha-2 code) or prefecture (ISO 3166-2 code, e.g. JP-13).
+type Region struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// Place is where a point lies. Prefecture is set only in Japan.
+type Place struct {
+	Country    Region
+	Prefecture *Region
+}
+
+//go:embed countries.json
+var countriesJSON []byte
+
+//go:embed prefectures.json
+var prefecturesJSON []byte
+
+var (
+	countries   = loadRegions("countries.json", countriesJSON)
+	prefectures = loadRegions("prefectures.json", prefecturesJSON)
+)
+
+// Locate returns the country and prefecture of a point. ok is false in the open sea, or
+// anywhere farther than CoastTolerance from every country.
+func Locate(lat, lng float64) (p Place, ok bool) {
+	c := find(countries, lat, lng)
+	if c == nil {
+		return Place{}, false
+	}
+	p.Country = c.Region
+	if c.Code == Japan {
+		if pref := find(prefectures, lat, lng); pref != nil {
+			r := pref.Region
+			p.Prefecture = &r
+		}
+	}
+	return p, true
+}
+
+// Lookup returns a country or prefecture by its code.
+func Lookup(code string) (Region, bool) {
+	for _, set := range [][]*boundary{countries, prefectures} {
+		for _, b := range set {
+			if b.Code == code {
+				return b.Region, true
+			}
+		}
+	}
+	return Region{}, false
+}
+
+// boundary is a region and its outline rings of {lng, lat} points. Holes are rings too;
+// a point is inside when it is inside an odd number of rings.
+type boundary struct {
+	Region
+	Rings [][][2]float64 `json:"rings"`
+	// Bounding box, widened by CoastTolerance for the nearest-region fallback.
+	minLat, minLng, maxLat, maxLng float64
+}
+
+func loadRegions(name string, data []byte) []*boundary {
+	var file struct {
+		Regions []*boundary `json:"regions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		panic("georegion: invalid " + name + ": " + err.Error())
+	}
+	margin := CoastTolerance / metersPerDegree
+	for _, b := range file.Regions {
+		b.minLat, b.minLng, b.maxLat, b.maxLng = 90, 180, -90, -180
+		for _, ring := range b.Rings {
+			for _, pt := range ring {
+				b.minLng, b.maxLng = math.Min(b.minLng, pt[0]), math.Max(b.maxLng, pt[0])
+				b.minLat, b.maxLat = math.Min(b.minLat, pt[1]), math.Max(b.maxLat, pt[1])
+			}
+		}
+		b.minLat -= margin
+		b.maxLat += margin
+		// Longitude degrees shrink toward the poles; widen by the worst case in the box.
+		lngMargin := margin / math.Max(math.Cos(math.Max(math.Abs(b.minLat), math.Abs(b.maxLat))*math.Pi/180), 0.05)
+		b.minLng -= lngMargin
+		b.maxLng += lngMargin
+	}
+	return file.Regions
+}
+
+// metersPerDegree is the length of a degree of latitude.
+const metersPerDegree = 111195.0
+
+// find returns the region containing the point, or failing that the nearest one within
+// CoastTolerance.
+func find(set []*boundary, lat, lng float64) *boundary {
+	var nearest *boundary
+	nearestDist := CoastTolerance
+	for _, b := range set {
+		if lat < b.minLat || lat > b.maxLat || lng < b.minLng || lng > b.maxLng {
+			continue
+		}
+		if b.contains(lat, lng) {
+			return b
+		}
+		if d := b.distance(lat, lng); d <= nearestDist {
+			nearest, nearestDist = b, d
+		}
+	}
+	return nearest
+}
+
+// contains is an even-odd ray casting test over all of the region's rings.
+func (b *boundary) contains(lat, lng float64) bool {
+	inside := false
+	for _, ring := range b.Rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			xi, yi := ring[i][0], ring[i][1]
+			xj, yj := ring[j][0], ring[j][1]
+			if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// distance is roughly how far the point is from the region's outline in meters, on a plane
+// tangent at the point. That is accurate enough within CoastTolerance.
+func (b *boundary) distance(lat, lng float64) float64 {
+	kx := metersPerDegree * math.Cos(lat*math.Pi/180)
+	best := math.Inf(1)
+	for _, ring := range b.Rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			ax, ay := (ring[j][0]-lng)*kx, (ring[j][1]-lat)*metersPerDegree
+			bx, by := (ring[i][0]-lng)*kx, (ring[i][1]-lat)*metersPerDegree
+			best = math.Min(best, segmentDistance(ax, ay, bx, by))
+		}
+	}
+	return best
+}
+
+// segmentDistance is the distance from the origin to the segment a-b.
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
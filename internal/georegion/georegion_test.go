@@ -0,0 +1,58 @@
+package georegion
+
+import "testing"
+
+func TestLocate(t *testing.T) {
+	tests := []struct {
+		name          string
+		lat, lng      float64
+		country, pref string
+	}{
+		{"Tokyo Station", 35.681236, 139.767125, "JP", "JP-13"},
+		{"Yokohama", 35.4437, 139.638, "JP", "JP-14"},
+		{"Osaka", 34.702485, 135.495951, "JP", "JP-27"},
+		{"Kyoto", 35.0116, 135.7681, "JP", "JP-26"},
+		{"Sapporo", 43.068661, 141.350755, "JP", "JP-01"},
+		{"Naha", 26.2124, 127.6809, "JP", "JP-47"},
+		{"Fukuoka", 33.5902, 130.4017, "JP", "JP-40"},
+		{"Matsumoto", 36.238, 137.972, "JP", "JP-20"},
+		{"Kochi", 33.5597, 133.5311, "JP", "JP-39"},
+		{"Seoul", 37.5665, 126.978, "KR", ""},
+		{"Paris", 48.8566, 2.3522, "FR", ""},
+		{"Berlin", 52.52, 13.405, "DE", ""},
+		{"New York", 40.7128, -74.006, "US", ""},
+		{"Sydney", -33.8688, 151.2093, "AU", ""},
+		{"Sao Paulo", -23.5505, -46.6333, "BR", ""},
+		{"Nairobi", -1.2921, 36.8219, "KE", ""},
+	}
+	for _, tt := range tests {
+		p, ok := Locate(tt.lat, tt.lng)
+		if !ok || p.Country.Code != tt.country {
+			t.Errorf("%s: country = %+v (ok=%v), want %s", tt.name, p.Country, ok, tt.country)
+			continue
+		}
+		got := ""
+		if p.Prefecture != nil {
+			got = p.Prefecture.Code
+		}
+		if got != tt.pref {
+			t.Errorf("%s: prefecture = %q, want %q", tt.name, got, tt.pref)
+		}
+	}
+
+	if p, ok := Locate(30, -40); ok {
+		t.Errorf("mid-Atlantic located in %+v", p)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if r, ok := Lookup("JP-13"); !ok || r.Name != "東京都" {
+		t.Errorf("Lookup(JP-13) = %+v, %v", r, ok)
+	}
+	if r, ok := Lookup("FR"); !ok || r.Name != "フランス" {
+		t.Errorf("Lookup(FR) = %+v, %v", r, ok)
+	}
+	if _, ok := Lookup("ZZ"); ok {
+		t.Error("Lookup(ZZ) found a region")
+	}
+}
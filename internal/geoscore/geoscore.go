@@ -33,43 +33,16 @@ func FiveKRadiusMeters(maxErrorDistanceMeters float64) float64 {
 	if maxErrorDistanceMeters <= 0 {
 		return 0
 	}
-	// r = ln(5000/4999.5) * maxErrorDistance / 10
-	r := math.Log(5000.0/4999.5) * maxErrorDistanceMeters / 10.0
-	if r < MinFiveKRadius {
-		r = MinFiveKRadius
-	}
-	return r
+	return fiveKRadius(DefaultDecay, maxErrorDistanceMeters)
 }
 
-// GeoGuessrScore returns an integer score in [0, 5000].
+// GeoGuessrScore returns an integer score in [0, 5000] with GeoGuessr's curve, the default scorer.
 // - trueLat/trueLng: correct location
 // - guessLat/guessLng: player's guess
 // - maxErrorDistanceMeters: map scale parameter (per-map)
 func GeoGuessrScore(trueLat, trueLng, guessLat, guessLng, maxErrorDistanceMeters float64) int {
-	if maxErrorDistanceMeters <= 0 {
-		return 0
-	}
-
 	d := DistanceMeters(trueLat, trueLng, guessLat, guessLng)
-
-	// If within 5k radius, return 5000.
-	if d <= FiveKRadiusMeters(maxErrorDistanceMeters) {
-		return 5000
-	}
-
-	// Base scoring (community-reverse-engineered).
-	raw := 5000.0 * math.Exp(-10.0*d/maxErrorDistanceMeters)
-
-	// GeoGuessr is commonly treated as rounding to nearest integer.
-	score := int(math.Round(raw))
-
-	if score < 0 {
-		return 0
-	}
-	if score > 5000 {
-		return 5000
-	}
-	return score
+	return Exponential{Decay: DefaultDecay}.Score(d, maxErrorDistanceMeters)
 }
 
 // MaxErrorDistanceFromBounds computes a map-scale parameter from a bounding box diagonal (meters).
@@ -0,0 +1,42 @@
+package geoscore
+
+// RegionMatch grades a guess by the region it landed in rather than by distance.
+type RegionMatch string
+
+const (
+	// RegionExact is the answer's region: its prefecture when judged by prefecture, else its country.
+	RegionExact RegionMatch = "exact"
+	// RegionCountry is the right country but the wrong prefecture.
+	RegionCountry RegionMatch = "country"
+	// RegionMiss is another country, or the sea.
+	RegionMiss RegionMatch = "miss"
+)
+
+// Region mode scores.
+const (
+	RegionExactScore   = 5000
+	RegionCountryScore = 1000
+)
+
+// RegionScore returns the score of a region mode guess.
+func RegionScore(m RegionMatch) int {
+	switch m {
+	case RegionExact:
+		return RegionExactScore
+	case RegionCountry:
+		return RegionCountryScore
+	}
+	return 0
+}
+
+// MatchRegion grades a guess: answer and guess are the country codes and, when judging by
+// prefecture, the prefecture codes of each point ("" where unknown).
+func MatchRegion(answerCountry, answerPrefecture, guessCountry, guessPrefecture string, byPrefecture bool) RegionMatch {
+	if answerCountry == "" || guessCountry != answerCountry {
+		return RegionMiss
+	}
+	if !byPrefecture || answerPrefecture == "" || guessPrefecture == answerPrefecture {
+		return RegionExact
+	}
+	return RegionCountry
+}
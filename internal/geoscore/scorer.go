@@ -0,0 +1,360 @@
+package geoscore
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxScore is the score of a perfect guess.
+const MaxScore = 5000
+
+// Scorer turns the distance of a guess from the answer into a score in [0, MaxScore]. A guess
+// farther away never scores more than a closer one.
+type Scorer interface {
+	// Score returns the score of a guess distanceMeters away on a map whose scale is
+	// maxErrorDistanceMeters (see MaxErrorDistanceFromBounds).
+	Score(distanceMeters, maxErrorDistanceMeters float64) int
+	// Kind names the scoring model.
+	Kind() ScorerKind
+	// Params encodes the parameters as ParseScorer reads them: comma separated key=value
+	// pairs, or "" for the defaults.
+	Params() string
+}
+
+// ScorerKind names a scoring model.
+type ScorerKind string
+
+const (
+	// ScorerExponential is GeoGuessr's curve: the score decays exponentially with the
+	// distance relative to the map scale.
+	ScorerExponential ScorerKind = "exponential"
+	// ScorerLinear falls off linearly to 0 at a fraction of the map scale.
+	ScorerLinear ScorerKind = "linear"
+	// ScorerStepped scores by fixed distance bands.
+	ScorerStepped ScorerKind = "stepped"
+	// ScorerLog falls off with the logarithm of the distance, which separates close guesses
+	// less harshly than far ones.
+	ScorerLog ScorerKind = "log"
+)
+
+// ScorerKinds lists the scoring models.
+var ScorerKinds = []ScorerKind{ScorerExponential, ScorerLinear, ScorerStepped, ScorerLog}
+
+// DefaultScorer is the scorer of games that do not choose one.
+var DefaultScorer Scorer = Exponential{Decay: DefaultDecay}
+
+// ParseScorer returns the scorer of kind with params, as encoded by Scorer.Params. An empty
+// kind is the default scorer and empty params are the kind's defaults.
+func ParseScorer(kind ScorerKind, params string) (Scorer, error) {
+	values, err := parseParams(params)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "", ScorerExponential:
+		s := Exponential{Decay: DefaultDecay}
+		if err := values.float("decay", &s.Decay); err != nil {
+			return nil, err
+		}
+		if s.Decay <= 0 {
+			return nil, fmt.Errorf("decay must be positive")
+		}
+		return s, values.done()
+	case ScorerLinear:
+		s := Linear{Zero: DefaultLinearZero}
+		if err := values.float("zero", &s.Zero); err != nil {
+			return nil, err
+		}
+		if s.Zero <= 0 {
+			return nil, fmt.Errorf("zero must be positive")
+		}
+		return s, values.done()
+	case ScorerLog:
+		s := Log{Scale: DefaultLogScale}
+		if err := values.meters("scale", &s.Scale); err != nil {
+			return nil, err
+		}
+		if s.Scale <= 0 {
+			return nil, fmt.Errorf("scale must be positive")
+		}
+		return s, values.done()
+	case ScorerStepped:
+		return parseBands(values)
+	}
+	return nil, fmt.Errorf("unknown scorer %q", kind)
+}
+
+// DefaultDecay is the decay of GeoGuessr's curve.
+const DefaultDecay = 10.0
+
+// Exponential scores MaxScore * exp(-Decay * distance / map scale), with a perfect score within
+// FiveKRadiusMeters of the answer.
+type Exponential struct {
+	Decay float64
+}
+
+func (s Exponential) Score(d, maxErrorDistanceMeters float64) int {
+	if maxErrorDistanceMeters <= 0 {
+		return 0
+	}
+	if d <= fiveKRadius(s.Decay, maxErrorDistanceMeters) {
+		return MaxScore
+	}
+	return clampScore(MaxScore * math.Exp(-s.Decay*d/maxErrorDistanceMeters))
+}
+
+func (Exponential) Kind() ScorerKind { return ScorerExponential }
+
+func (s Exponential) Params() string {
+	if s.Decay == DefaultDecay {
+		return ""
+	}
+	return "decay=" + formatFloat(s.Decay)
+}
+
+// DefaultLinearZero puts the zero of the linear scorer at the map scale.
+const DefaultLinearZero = 1.0
+
+// Linear scores MaxScore at the answer, falling off linearly to 0 at Zero times the map scale.
+type Linear struct {
+	Zero float64
+}
+
+func (s Linear) Score(d, maxErrorDistanceMeters float64) int {
+	zero := s.Zero * maxErrorDistanceMeters
+	if zero <= 0 {
+		return 0
+	}
+	if d <= MinFiveKRadius {
+		return MaxScore
+	}
+	return clampScore(MaxScore * (1 - d/zero))
+}
+
+func (Linear) Kind() ScorerKind { return ScorerLinear }
+
+func (s Linear) Params() string {
+	if s.Zero == DefaultLinearZero {
+		return ""
+	}
+	return "zero=" + formatFloat(s.Zero)
+}
+
+// DefaultLogScale is the distance in meters below which the log scorer is nearly flat.
+const DefaultLogScale = 1000.0
+
+// Log scores MaxScore * (1 - ln(1 + d/Scale) / ln(1 + map scale/Scale)): every doubling of the
+// distance costs about the same, down to 0 at the map scale.
+type Log struct {
+	Scale float64
+}
+
+func (s Log) Score(d, maxErrorDistanceMeters float64) int {
+	if maxErrorDistanceMeters <= 0 || s.Scale <= 0 {
+		return 0
+	}
+	if d <= MinFiveKRadius {
+		return MaxScore
+	}
+	return clampScore(MaxScore * (1 - math.Log1p(d/s.Scale)/math.Log1p(maxErrorDistanceMeters/s.Scale)))
+}
+
+func (Log) Kind() ScorerKind { return ScorerLog }
+
+func (s Log) Params() string {
+	if s.Scale == DefaultLogScale {
+		return ""
+	}
+	return "scale=" + formatMeters(s.Scale)
+}
+
+// Band is a step of the stepped scorer: guesses within MaxMeters score Score.
+type Band struct {
+	MaxMeters float64
+	Score     int
+}
+
+// DefaultBands are the bands of the stepped scorer.
+var DefaultBands = []Band{
+	{1000, 5000},
+	{10000, 4000},
+	{50000, 3000},
+	{200000, 2000},
+	{1000000, 1000},
+	{3000000, 500},
+}
+
+// Stepped scores guesses by the first band they fall within, and 0 beyond the last one. Bands
+// are ordered by distance with scores that never go up, and do not depend on the map scale.
+type Stepped struct {
+	Bands []Band
+}
+
+func (s Stepped) Score(d, _ float64) int {
+	for _, b := range s.Bands {
+		if d <= b.MaxMeters {
+			return b.Score
+		}
+	}
+	return 0
+}
+
+func (Stepped) Kind() ScorerKind { return ScorerStepped }
+
+func (s Stepped) Params() string {
+	if bandsEqual(s.Bands, DefaultBands) {
+		return ""
+	}
+	parts := make([]string, len(s.Bands))
+	for idx, b := range s.Bands {
+		parts[idx] = formatMeters(b.MaxMeters) + "=" + strconv.Itoa(b.Score)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseBands reads stepped bands given as distance=score pairs, such as "1km=5000,500m=4000".
+func parseBands(values params) (Scorer, error) {
+	if len(values) == 0 {
+		return Stepped{Bands: append([]Band(nil), DefaultBands...)}, nil
+	}
+	bands := make([]Band, 0, len(values))
+	for _, kv := range values {
+		meters, err := parseMeters(kv.key)
+		if err != nil {
+			return nil, err
+		}
+		score, err := strconv.Atoi(kv.value)
+		if err != nil || score < 0 || score > MaxScore {
+			return nil, fmt.Errorf("band score must be an integer from 0 to %d: %q", MaxScore, kv.value)
+		}
+		bands = append(bands, Band{MaxMeters: meters, Score: score})
+	}
+	sort.SliceStable(bands, func(i, j int) bool { return bands[i].MaxMeters < bands[j].MaxMeters })
+	for idx := 1; idx < len(bands); idx++ {
+		if bands[idx].MaxMeters == bands[idx-1].MaxMeters {
+			return nil, fmt.Errorf("duplicate band %s", formatMeters(bands[idx].MaxMeters))
+		}
+		if bands[idx].Score > bands[idx-1].Score {
+			return nil, fmt.Errorf("band %s scores more than the closer band %s", formatMeters(bands[idx].MaxMeters), formatMeters(bands[idx-1].MaxMeters))
+		}
+	}
+	return Stepped{Bands: bands}, nil
+}
+
+func bandsEqual(a, b []Band) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+// fiveKRadius is the distance within which the exponential curve rounds to a perfect score,
+// at least MinFiveKRadius.
+func fiveKRadius(decay, maxErrorDistanceMeters float64) float64 {
+	return math.Max(math.Log(MaxScore/(MaxScore-0.5))*maxErrorDistanceMeters/decay, MinFiveKRadius)
+}
+
+func clampScore(raw float64) int {
+	return min(max(int(math.Round(raw)), 0), MaxScore)
+}
+
+// param is a key=value pair of scorer parameters.
+type param struct {
+	key, value string
+}
+
+// params are scorer parameters in the order given; keys are consumed as they are read.
+type params []param
+
+func parseParams(s string) (params, error) {
+	var out params
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("parameter %q is not key=value", part)
+		}
+		out = append(out, param{strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)})
+	}
+	return out, nil
+}
+
+// float reads the parameter key into v if it is present, removing it from ps.
+func (ps *params) float(key string, v *float64) error {
+	return ps.read(key, v, func(s string) (float64, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0, fmt.Errorf("%s must be a number: %q", key, s)
+		}
+		return f, nil
+	})
+}
+
+// meters reads the distance parameter key into v if it is present, removing it from ps.
+func (ps *params) meters(key string, v *float64) error {
+	return ps.read(key, v, parseMeters)
+}
+
+func (ps *params) read(key string, v *float64, parse func(string) (float64, error)) error {
+	for idx, kv := range *ps {
+		if kv.key != key {
+			continue
+		}
+		f, err := parse(kv.value)
+		if err != nil {
+			return err
+		}
+		*v = f
+		*ps = append((*ps)[:idx], (*ps)[idx+1:]...)
+		return nil
+	}
+	return nil
+}
+
+// done reports parameters that were not read.
+func (ps params) done() error {
+	if len(ps) > 0 {
+		return fmt.Errorf("unknown parameter %q", ps[0].key)
+	}
+	return nil
+}
+
+// parseMeters reads a distance such as "500", "500m" or "1.5km".
+func parseMeters(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(s, "km"):
+		s, unit = strings.TrimSuffix(s, "km"), 1000
+	case strings.HasSuffix(s, "m"):
+		s = strings.TrimSuffix(s, "m")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid distance %q", s)
+	}
+	return f * unit, nil
+}
+
+// formatMeters writes a distance the way parseMeters reads it, in km when it is whole.
+func formatMeters(m float64) string {
+	if m >= 1000 && math.Mod(m, 1000) == 0 {
+		return formatFloat(m/1000) + "km"
+	}
+	return formatFloat(m) + "m"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,122 @@
+package geoscore
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// testScorers are the scorers the properties are checked for: each kind with its defaults and
+// with unusual parameters.
+func testScorers(t *testing.T) []Scorer {
+	t.Helper()
+	specs := []struct {
+		kind   ScorerKind
+		params string
+	}{
+		{ScorerExponential, ""},
+		{ScorerExponential, "decay=3"},
+		{ScorerLinear, ""},
+		{ScorerLinear, "zero=0.2"},
+		{ScorerStepped, ""},
+		{ScorerStepped, "500m=5000,2km=5000,30km=1200,100km=0"},
+		{ScorerLog, ""},
+		{ScorerLog, "scale=25km"},
+	}
+	scorers := make([]Scorer, len(specs))
+	for idx, spec := range specs {
+		s, err := ParseScorer(spec.kind, spec.params)
+		if err != nil {
+			t.Fatalf("ParseScorer(%s, %q): %v", spec.kind, spec.params, err)
+		}
+		scorers[idx] = s
+	}
+	return scorers
+}
+
+// distances generates pairs of distances from 0 to beyond the earth's circumference, spread
+// over magnitudes so that both short and long distances are covered, and a map scale.
+type distances struct {
+	Near, Far, MaxError float64
+}
+
+func (distances) Generate(r *rand.Rand, _ int) reflect.Value {
+	d := func() float64 { return math.Pow(10, r.Float64()*8) - 1 }
+	a, b := d(), d()
+	return reflect.ValueOf(distances{Near: math.Min(a, b), Far: math.Max(a, b), MaxError: math.Pow(10, 3+r.Float64()*5)})
+}
+
+func TestScorersMonotonic(t *testing.T) {
+	for _, s := range testScorers(t) {
+		prop := func(ds distances) bool {
+			near, far := s.Score(ds.Near, ds.MaxError), s.Score(ds.Far, ds.MaxError)
+			return near >= far && near <= MaxScore && far >= 0
+		}
+		if err := quick.Check(prop, &quick.Config{MaxCount: 2000}); err != nil {
+			t.Errorf("%s(%s): %v", s.Kind(), s.Params(), err)
+		}
+	}
+}
+
+func TestScorersPerfectAtAnswer(t *testing.T) {
+	for _, s := range testScorers(t) {
+		prop := func(ds distances) bool {
+			return s.Score(0, ds.MaxError) == MaxScore
+		}
+		if err := quick.Check(prop, nil); err != nil {
+			t.Errorf("%s(%s): %v", s.Kind(), s.Params(), err)
+		}
+	}
+}
+
+// TestScorerParamsRoundTrip checks that Params encodes a scorer ParseScorer reads back.
+func TestScorerParamsRoundTrip(t *testing.T) {
+	for _, s := range testScorers(t) {
+		got, err := ParseScorer(s.Kind(), s.Params())
+		if err != nil || !reflect.DeepEqual(got, s) {
+			t.Errorf("ParseScorer(%s, %q) = %+v, %v; want %+v", s.Kind(), s.Params(), got, err, s)
+		}
+	}
+}
+
+// TestDefaultScorerIsGeoGuessr checks the default scorer against GeoGuessr's curve.
+func TestDefaultScorerIsGeoGuessr(t *testing.T) {
+	radius := math.Log(5000/4999.5) * worldScale / 10
+	for _, d := range []float64{0, 20, radius, radius + 1, 1000, 150000, 1e6, 2e6, 2e7} {
+		want := 5000
+		if d > radius {
+			want = int(math.Round(5000 * math.Exp(-10*d/worldScale)))
+		}
+		if got := DefaultScorer.Score(d, worldScale); got != want {
+			t.Errorf("distance %v: score = %d, want %d", d, got, want)
+		}
+	}
+	if got := GeoGuessrScore(0, 0, 9, 0, worldScale); got != DefaultScorer.Score(DistanceMeters(0, 0, 9, 0), worldScale) {
+		t.Errorf("GeoGuessrScore = %d, differs from the default scorer", got)
+	}
+}
+
+func TestParseScorerErrors(t *testing.T) {
+	for _, tt := range []struct {
+		kind   ScorerKind
+		params string
+	}{
+		{"cubic", ""},
+		{ScorerExponential, "decay=0"},
+		{ScorerExponential, "decay"},
+		{ScorerLinear, "zero=abc"},
+		{ScorerLog, "decay=3"},
+		{ScorerStepped, "1km=5000,10km=5001"},
+		{ScorerStepped, "1km=3000,10km=4000"},
+		{ScorerStepped, "1km=5000,1000m=4000"},
+	} {
+		if s, err := ParseScorer(tt.kind, tt.params); err == nil {
+			t.Errorf("ParseScorer(%s, %q) = %+v, want an error", tt.kind, tt.params, s)
+		}
+	}
+}
+
+// worldScale is the world map's scale in meters.
+const worldScale = 20015086.796
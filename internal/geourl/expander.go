@@ -0,0 +1,273 @@
+package geourl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrHostNotAllowed means a link or one of its redirects points outside the map hosts we resolve.
+	ErrHostNotAllowed = errors.New("host is not an allowed map service")
+	// ErrBlockedAddress means a map host resolved to a private, loopback or otherwise internal address.
+	ErrBlockedAddress = errors.New("refusing to connect to a non-public address")
+)
+
+// reGoogleHost matches google.com, www.google.co.jp, maps.google.de and other Google domains.
+var reGoogleHost = regexp.MustCompile(`^(?:[a-z0-9-]+\.)*google\.(?:com|[a-z]{2}|co\.[a-z]{2}|com\.[a-z]{2})$`)
+
+// reCenter finds the center of the static map Google embeds in place pages.
+var reCenter = regexp.MustCompile(`center=(-?\d+(?:\.\d+)?)(?:%2C|,)(-?\d+(?:\.\d+)?)`)
+
+// ShortLink is the location a map short link resolved to.
+type ShortLink struct {
+	ShortURL string
+	Lat      float64
+	Lng      float64
+	FinalURL string
+}
+
+// Cache stores what short links resolved to, so each one is fetched only once.
+// GetShortLink returns nil for links it has not seen or has forgotten.
+type Cache interface {
+	GetShortLink(ctx context.Context, shortURL string) (*ShortLink, error)
+	SaveShortLink(ctx context.Context, l ShortLink) error
+}
+
+// Options configure an Expander.
+type Options struct {
+	// Transport makes the requests; nil uses one that only dials public addresses.
+	// Tests can route requests to a local server with it.
+	Transport http.RoundTripper
+	// Cache is optional.
+	Cache Cache
+}
+
+// Expander resolves locations to coordinates. Short links are followed only through allowed
+// map hosts, only to public addresses, and their results are cached.
+type Expander struct {
+	client *http.Client
+	cache  Cache
+}
+
+// NewExpander creates an Expander.
+func NewExpander(opts Options) *Expander {
+	transport := opts.Transport
+	if transport == nil {
+		transport = newSafeTransport()
+	}
+	return &Expander{
+		client: &http.Client{
+			Timeout:   RequestTimeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= MaxRedirects {
+					return errors.New("too many redirects")
+				}
+				return checkAllowed(req.URL)
+			},
+		},
+		cache: opts.Cache,
+	}
+}
+
+var defaultExpander atomic.Pointer[Expander]
+
+func init() {
+	defaultExpander.Store(NewExpander(Options{}))
+}
+
+// DefaultExpander returns the Expander used by ExpandAndExtractCoords.
+func DefaultExpander() *Expander {
+	return defaultExpander.Load()
+}
+
+// SetDefaultExpander replaces the Expander used by ExpandAndExtractCoords.
+func SetDefaultExpander(e *Expander) {
+	defaultExpander.Store(e)
+}
+
+// ExpandAndExtractCoords extracts coordinates from a location in any format ParseCoords understands.
+// Only short links are fetched over the network. finalURL is the expanded URL, the input URL,
+// or a Google Maps link to the coordinates when the input was not a URL.
+func (e *Expander) ExpandAndExtractCoords(ctx context.Context, input string) (lat float64, lng float64, finalURL string, err error) {
+	input = strings.TrimSpace(input)
+	if lat, lng, ok := ParseCoords(input); ok {
+		if strings.Contains(input, "://") {
+			return lat, lng, input, nil
+		}
+		return lat, lng, CoordsURL(lat, lng), nil
+	}
+	if looksLikePlusCode(normalizeWidth(input)) {
+		if _, _, err := decodePlusCode(normalizeWidth(input)); err != nil {
+			return 0, 0, "", err
+		}
+	}
+	u, err := url.Parse(input)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return 0, 0, "", fmt.Errorf("unrecognized location format: %s", input)
+	}
+	if !isShortLink(u) {
+		return 0, 0, input, fmt.Errorf("coordinates not found in URL: %s", input)
+	}
+	if err := checkAllowed(u); err != nil {
+		return 0, 0, input, err
+	}
+
+	if e.cache != nil {
+		l, err := e.cache.GetShortLink(ctx, input)
+		if err != nil {
+			slog.Warn("geourl: failed to read short link cache", "url", input, "err", err)
+		} else if l != nil {
+			return l.Lat, l.Lng, l.FinalURL, nil
+		}
+	}
+	lat, lng, finalURL, err = e.expand(ctx, input)
+	if err != nil {
+		return 0, 0, finalURL, err
+	}
+	if e.cache != nil {
+		l := ShortLink{ShortURL: input, Lat: lat, Lng: lng, FinalURL: finalURL}
+		if err := e.cache.SaveShortLink(ctx, l); err != nil {
+			slog.Warn("geourl: failed to cache short link", "url", input, "err", err)
+		}
+	}
+	return lat, lng, finalURL, nil
+}
+
+// expand follows the redirects of a short link and extracts coordinates from the final URL,
+// or failing that from the start of the final page.
+func (e *Expander) expand(ctx context.Context, input string) (lat float64, lng float64, finalURL string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", input, nil)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	// Some endpoints behave better with a UA.
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GeoTools/1.0)")
+	req.Header.Set("Accept-Language", "ja,en;q=0.8")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	defer resp.Body.Close()
+
+	// After redirects, this is the final URL.
+	if resp.Request == nil || resp.Request.URL == nil {
+		return 0, 0, "", errors.New("failed to determine final URL")
+	}
+	finalURL = resp.Request.URL.String()
+
+	if lat, lng, ok := parseMapURL(finalURL); ok && validCoords(lat, lng) {
+		return lat, lng, finalURL, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodyBytes))
+	if err != nil {
+		return 0, 0, finalURL, err
+	}
+	if lat, lng, ok := extractFromBody(string(body)); ok {
+		return lat, lng, finalURL, nil
+	}
+	return 0, 0, finalURL, fmt.Errorf("coordinates not found in final URL: %s", finalURL)
+}
+
+// extractFromBody finds coordinates a map page mentions, such as links to @lat,lng or its static map.
+func extractFromBody(body string) (lat, lng float64, ok bool) {
+	for _, re := range []*regexp.Regexp{re3d4d, reAt, reCenter} {
+		if m := re.FindStringSubmatch(body); m != nil {
+			if lat, lng, ok := parse2(m[1], m[2]); ok && validCoords(lat, lng) {
+				return lat, lng, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// checkAllowed rejects redirects away from the map hosts we resolve, or to non-default ports.
+func checkAllowed(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Port() != "" {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Redacted())
+	}
+	if isShortLink(u) || reGoogleHost.MatchString(strings.ToLower(u.Hostname())) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Hostname())
+}
+
+// newSafeTransport dials only public addresses, checked after DNS resolution so a hostname
+// cannot point the bot at internal services. It ignores proxy settings, which would bypass the check.
+func newSafeTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   denyInternalAddress,
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// denyInternalAddress is a net.Dialer Control hook that refuses non-public addresses.
+func denyInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// nonPublicNets are reserved ranges that net.IP's predicates don't cover.
+var nonPublicNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",       // "this" network
+		"100.64.0.0/10",   // carrier-grade NAT
+		"192.0.0.0/24",    // IETF protocol assignments
+		"192.0.2.0/24",    // documentation
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"240.0.0.0/4",     // reserved, and broadcast
+		"64:ff9b::/96",    // NAT64, which can reach IPv4 internals
+		"2001:db8::/32",   // documentation
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package geourl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// fakeSite answers requests by URL like the map services would.
+func fakeSite(t *testing.T, pages map[string]func() *http.Response) (http.RoundTripper, *int) {
+	hits := 0
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		hits++
+		page, ok := pages[req.URL.String()]
+		if !ok {
+			t.Errorf("unexpected request to %s", req.URL)
+			return nil, errors.New("not found")
+		}
+		resp := page()
+		resp.Request = req
+		return resp, nil
+	}), &hits
+}
+
+func redirect(to string) func() *http.Response {
+	return func() *http.Response {
+		return &http.Response{StatusCode: http.StatusFound, Header: http.Header{"Location": {to}}, Body: http.NoBody}
+	}
+}
+
+func page(body string) func() *http.Response {
+	return func() *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	}
+}
+
+type memCache map[string]ShortLink
+
+func (c memCache) GetShortLink(_ context.Context, shortURL string) (*ShortLink, error) {
+	if l, ok := c[shortURL]; ok {
+		return &l, nil
+	}
+	return nil, nil
+}
+
+func (c memCache) SaveShortLink(_ context.Context, l ShortLink) error {
+	c[l.ShortURL] = l
+	return nil
+}
+
+func TestExpanderCachesShortLinks(t *testing.T) {
+	transport, hits := fakeSite(t, map[string]func() *http.Response{
+		"https://maps.app.goo.gl/abc":                             redirect("https://www.google.co.jp/maps/@35.681236,139.767125,15z"),
+		"https://www.google.co.jp/maps/@35.681236,139.767125,15z": page(""),
+	})
+	cache := memCache{}
+	e := NewExpander(Options{Transport: transport, Cache: cache})
+
+	for n := 0; n < 2; n++ {
+		lat, lng, finalURL, err := e.ExpandAndExtractCoords(context.Background(), "https://maps.app.goo.gl/abc")
+		if err != nil || lat != 35.681236 || lng != 139.767125 || finalURL != "https://www.google.co.jp/maps/@35.681236,139.767125,15z" {
+			t.Fatalf("call %d = %v, %v, %q, %v", n, lat, lng, finalURL, err)
+		}
+	}
+	if *hits != 2 {
+		t.Fatalf("hits = %d, want 2 (the second call should be cached)", *hits)
+	}
+	if _, ok := cache["https://maps.app.goo.gl/abc"]; !ok {
+		t.Fatalf("cache = %v", cache)
+	}
+}
+
+func TestExpanderReadsFinalPage(t *testing.T) {
+	transport, _ := fakeSite(t, map[string]func() *http.Response{
+		"https://maps.app.goo.gl/place":                            redirect("https://www.google.com/maps/place/Tokyo+Station/data=xyz"),
+		"https://www.google.com/maps/place/Tokyo+Station/data=xyz": page(`<meta content="https://maps.google.com/maps/api/staticmap?center=35.681236%2C139.767125&amp;zoom=15">`),
+		"https://maps.app.goo.gl/huge":                             redirect("https://www.google.com/maps/place/Far"),
+		"https://www.google.com/maps/place/Far":                    page(strings.Repeat(" ", MaxBodyBytes) + "@35.0,139.0"),
+	})
+	e := NewExpander(Options{Transport: transport})
+
+	lat, lng, _, err := e.ExpandAndExtractCoords(context.Background(), "https://maps.app.goo.gl/place")
+	if err != nil || lat != 35.681236 || lng != 139.767125 {
+		t.Fatalf("place = %v, %v, %v", lat, lng, err)
+	}
+	// Coordinates past the read limit are not found.
+	if _, _, _, err := e.ExpandAndExtractCoords(context.Background(), "https://maps.app.goo.gl/huge"); err == nil {
+		t.Fatal("read past MaxBodyBytes")
+	}
+}
+
+func TestExpanderRejectsRedirectsOffAllowlist(t *testing.T) {
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost/admin",
+		"https://evil.example.com/maps/@35.0,139.0,15z",
+		"https://www.google.com:8443/maps/@35.0,139.0,15z",
+		"https://google.com.evil.example/maps/@35.0,139.0,15z",
+	} {
+		transport, _ := fakeSite(t, map[string]func() *http.Response{
+			"https://maps.app.goo.gl/x": redirect(target),
+		})
+		e := NewExpander(Options{Transport: transport})
+		_, _, _, err := e.ExpandAndExtractCoords(context.Background(), "https://maps.app.goo.gl/x")
+		if !errors.Is(err, ErrHostNotAllowed) {
+			t.Errorf("redirect to %s: err = %v", target, err)
+		}
+	}
+}
+
+func TestSafeTransportBlocksInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newSafeTransport()}
+	_, err := client.Get(srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("err = %v", err)
+	}
+
+	for ip, want := range map[string]bool{
+		"8.8.8.8": true, "2404:6800:4004:80f::200e": true,
+		"127.0.0.1": false, "10.1.2.3": false, "172.16.0.1": false, "192.168.1.1": false,
+		"169.254.169.254": false, "100.64.0.1": false, "0.0.0.0": false, "::1": false,
+		"fe80::1": false, "fd00::1": false, "::ffff:127.0.0.1": false, "64:ff9b::a00:1": false,
+	} {
+		if got := isPublicIP(net.ParseIP(ip)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package geourl
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +15,8 @@ const (
 	RequestTimeout = 15 * time.Second
 	// MaxRedirects is the maximum number of redirects to follow
 	MaxRedirects = 10
+	// MaxBodyBytes is how much of the final page is searched for coordinates
+	MaxBodyBytes = 512 << 10
 )
 
 var (
@@ -24,44 +26,39 @@ var (
 	reSearch = regexp.MustCompile(`/maps/search/(-?\d+(?:\.\d+)?),[ +]?(-?\d+(?:\.\d+)?)`)
 )
 
-// ExpandAndExtractCoords expands a Google Maps short URL and extracts coordinates from the final URL.
-func ExpandAndExtractCoords(input string) (lat float64, lng float64, finalURL string, err error) {
-	client := &http.Client{
-		Timeout: RequestTimeout,
-		// Follow redirects (default is fine); keep a safety cap.
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(via) >= MaxRedirects {
-				return errors.New("too many redirects")
-			}
-			return nil
-		},
-	}
+// shortLinkHosts are the hosts whose links carry no coordinates until they are expanded.
+// Only links to these hosts are fetched over the network.
+var shortLinkHosts = []string{"maps.app.goo.gl", "goo.gl", "g.co"}
 
-	req, err := http.NewRequest("GET", input, nil)
-	if err != nil {
-		return 0, 0, "", err
-	}
-	// Some endpoints behave better with a UA.
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GeoTools/1.0)")
-	req.Header.Set("Accept-Language", "ja,en;q=0.8")
+// ExpandAndExtractCoords extracts coordinates from input with the default Expander.
+// See Expander.ExpandAndExtractCoords.
+func ExpandAndExtractCoords(input string) (lat float64, lng float64, finalURL string, err error) {
+	return DefaultExpander().ExpandAndExtractCoords(context.Background(), input)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, 0, "", err
-	}
-	defer resp.Body.Close()
+// CoordsURL is a Google Maps link that shows lat, lng.
+func CoordsURL(lat, lng float64) string {
+	return fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%.6f,%.6f", lat, lng)
+}
 
-	// After redirects, this is the final URL.
-	if resp.Request == nil || resp.Request.URL == nil {
-		return 0, 0, "", errors.New("failed to determine final URL")
+// NeedsFetch reports whether resolving input means fetching a short link over the network.
+func NeedsFetch(input string) bool {
+	input = strings.TrimSpace(input)
+	if _, _, ok := ParseCoords(input); ok {
+		return false
 	}
-	finalURL = resp.Request.URL.String()
+	u, err := url.Parse(input)
+	return err == nil && isShortLink(u)
+}
 
-	lat, lng, ok := extractFromURL(finalURL)
-	if !ok {
-		return 0, 0, finalURL, fmt.Errorf("coordinates not found in final URL: %s", finalURL)
+func isShortLink(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, h := range shortLinkHosts {
+		if host == h {
+			return true
+		}
 	}
-	return lat, lng, finalURL, nil
+	return false
 }
 
 func extractFromURL(s string) (lat, lng float64, ok bool) {
@@ -1,6 +1,10 @@
 package geourl
 
 import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
 	"testing"
 )
 
@@ -103,3 +107,96 @@ func TestExtractFromURL(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCoords(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantLat float64
+		wantLng float64
+		wantOk  bool
+	}{
+		{name: "plain comma", input: "35.681236, 139.767125", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "plain space", input: "-33.8688 151.2093", wantLat: -33.8688, wantLng: 151.2093, wantOk: true},
+		{name: "full-width", input: "３５．６８１２３６，１３９．７６７１２５", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "DMS", input: `35°41'22"N 139°41'30"E`, wantLat: 35 + 41.0/60 + 22.0/3600, wantLng: 139 + 41.0/60 + 30.0/3600, wantOk: true},
+		{name: "DMS with decimals and prime marks", input: `33°52′07.7″S, 151°12′33.5″E`, wantLat: -(33 + 52.0/60 + 7.7/3600), wantLng: 151 + 12.0/60 + 33.5/3600, wantOk: true},
+		{name: "DMS hemisphere first", input: `N35°41.5' W139°30'`, wantLat: 35 + 41.5/60, wantLng: -(139 + 30.0/60), wantOk: true},
+		{name: "DMS longitude first", input: `139°41'30"E 35°41'22"N`, wantLat: 35 + 41.0/60 + 22.0/3600, wantLng: 139 + 41.0/60 + 30.0/3600, wantOk: true},
+		{name: "decimal degrees with hemisphere", input: "35.6895N 139.6917E", wantLat: 35.6895, wantLng: 139.6917, wantOk: true},
+		{name: "北緯東経 DMS", input: "北緯35度41分22秒 東経139度41分30秒", wantLat: 35 + 41.0/60 + 22.0/3600, wantLng: 139 + 41.0/60 + 30.0/3600, wantOk: true},
+		{name: "北緯東経 decimal", input: "北緯35.6895度、東経139.6917度", wantLat: 35.6895, wantLng: 139.6917, wantOk: true},
+		{name: "南緯西経", input: "南緯22度54分 西経43度12分", wantLat: -(22 + 54.0/60), wantLng: -(43 + 12.0/60), wantOk: true},
+		{name: "plus code", input: "8Q7XMM8R+2X", wantLat: 35.6650625, wantLng: 139.6924375, wantOk: true},
+		{name: "plus code lower case", input: "8fvc9g8f+6x", wantLat: 47.3655625, wantLng: 8.5249375, wantOk: true},
+		{name: "plus code grid digit", input: "8FVC9G8F+6XQ", wantLat: 47.3655875, wantLng: 8.524984375, wantOk: true},
+		{name: "geo URI", input: "geo:35.681236,139.767125;u=35", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "geo URI with query", input: "geo:0,0?q=35.681236,139.767125(Tokyo Station)", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "OpenStreetMap fragment", input: "https://www.openstreetmap.org/#map=17/35.68124/139.76713", wantLat: 35.68124, wantLng: 139.76713, wantOk: true},
+		{name: "OpenStreetMap marker", input: "https://www.openstreetmap.org/?mlat=35.6812&mlon=139.7671#map=15/35.6800/139.7600", wantLat: 35.6812, wantLng: 139.7671, wantOk: true},
+		{name: "OpenStreetMap marker only", input: "https://www.openstreetmap.org/?mlat=35.6812&mlon=139.7671", wantLat: 35.6812, wantLng: 139.7671, wantOk: true},
+		{name: "Apple Maps ll", input: "https://maps.apple.com/?ll=35.681236,139.767125&q=Tokyo", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "Apple Maps coordinate", input: "https://maps.apple.com/place?coordinate=35.681236,139.767125&name=Tokyo", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "Bing cp", input: "https://www.bing.com/maps?cp=35.681236~139.767125&lvl=16", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "Bing sp", input: "https://www.bing.com/maps?sp=point.35.681236_139.767125_Tokyo&cp=1~2", wantLat: 35.681236, wantLng: 139.767125, wantOk: true},
+		{name: "Google URL", input: "https://www.google.com/maps/@35.696677,138.430228,15z", wantLat: 35.696677, wantLng: 138.430228, wantOk: true},
+		{name: "latitude out of range", input: "95.0, 139.0"},
+		{name: "minutes out of range", input: `35°61'00"N 139°00'00"E`},
+		{name: "two latitudes", input: `35°N 36°S`},
+		{name: "missing hemisphere", input: `35°41'22" 139°41'30"E`},
+		{name: "short plus code", input: "MM8R+2X"},
+		{name: "place name", input: "東京駅"},
+		{name: "short link", input: "https://maps.app.goo.gl/abc123"},
+		{name: "OpenStreetMap without coordinates", input: "https://www.openstreetmap.org/way/123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotLat, gotLng, gotOk := ParseCoords(tt.input)
+			if gotOk != tt.wantOk {
+				t.Fatalf("ParseCoords(%q) ok = %v, want %v (%v, %v)", tt.input, gotOk, tt.wantOk, gotLat, gotLng)
+			}
+			if math.Abs(gotLat-tt.wantLat) > 1e-9 || math.Abs(gotLng-tt.wantLng) > 1e-9 {
+				t.Errorf("ParseCoords(%q) = %v, %v, want %v, %v", tt.input, gotLat, gotLng, tt.wantLat, tt.wantLng)
+			}
+		})
+	}
+}
+
+func TestExpandAndExtractCoordsOffline(t *testing.T) {
+	// Only the short link may be fetched.
+	var requests []string
+	e := NewExpander(Options{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.String())
+		return nil, errors.New("offline")
+	})})
+	ctx := context.Background()
+
+	lat, lng, finalURL, err := e.ExpandAndExtractCoords(ctx, "  35.681236,139.767125 ")
+	if err != nil || lat != 35.681236 || lng != 139.767125 {
+		t.Fatalf("plain = %v, %v, %v", lat, lng, err)
+	}
+	if got, want := finalURL, "https://www.google.com/maps/search/?api=1&query=35.681236,139.767125"; got != want {
+		t.Fatalf("finalURL = %q, want %q", got, want)
+	}
+	if gotLat, gotLng, ok := extractFromURL(finalURL); !ok || gotLat != lat || gotLng != lng {
+		t.Fatalf("finalURL does not round-trip: %v, %v, %v", gotLat, gotLng, ok)
+	}
+
+	osm := "https://www.openstreetmap.org/#map=17/35.68124/139.76713"
+	if _, _, finalURL, err := e.ExpandAndExtractCoords(ctx, osm); err != nil || finalURL != osm {
+		t.Fatalf("url = %q, %v", finalURL, err)
+	}
+
+	for _, input := range []string{"https://example.com/maps/place/Tokyo", "https://maps.app.goo.gl/abc123", "東京駅", "MM8R+2X"} {
+		if _, _, _, err := e.ExpandAndExtractCoords(ctx, input); err == nil {
+			t.Errorf("ExpandAndExtractCoords(%q) succeeded", input)
+		}
+	}
+	if _, _, _, err := e.ExpandAndExtractCoords(ctx, "MM8R+2X"); err != ErrShortPlusCode {
+		t.Errorf("short plus code err = %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "https://maps.app.goo.gl/abc123" {
+		t.Errorf("requests = %q", requests)
+	}
+}
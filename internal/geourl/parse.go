@@ -0,0 +1,208 @@
+package geourl
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Plain "lat,lng" or "lat lng".
+	rePlain = regexp.MustCompile(`^\s*([-+]?\d+(?:\.\d+)?)\s*(?:[,、]\s*|\s+)([-+]?\d+(?:\.\d+)?)\s*$`)
+
+	// Degrees, minutes and seconds of one DMS component such as 35°41'22.2" or 35.6895°.
+	dmsNumber = `(\d+(?:\.\d+)?)\s*°?\s*(?:(\d+(?:\.\d+)?)\s*['′’]\s*)?(?:(\d+(?:\.\d+)?)\s*(?:"|″|”|'')\s*)?`
+	// A pair of components with the hemisphere after (35°41'N 139°41'E) or before (N35°41' E139°41') each.
+	reDMSSuffix = regexp.MustCompile(`^\s*` + dmsNumber + `([NSEW])\s*[,、]?\s*` + dmsNumber + `([NSEW])\s*$`)
+	reDMSPrefix = regexp.MustCompile(`^\s*([NSEW])\s*` + dmsNumber + `[,、]?\s*([NSEW])\s*` + dmsNumber + `$`)
+
+	// OpenStreetMap: #map=zoom/lat/lng
+	reOSMFragment = regexp.MustCompile(`(?:^|&)map=\d+(?:\.\d+)?/(-?\d+(?:\.\d+)?)/(-?\d+(?:\.\d+)?)`)
+	// Bing: cp=lat~lng
+	reBingCP = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)~(-?\d+(?:\.\d+)?)$`)
+	// Bing: sp=point.lat_lng_name
+	reBingSP = regexp.MustCompile(`point\.(-?\d+(?:\.\d+)?)_(-?\d+(?:\.\d+)?)`)
+)
+
+// japaneseMarkers turn 北緯35度41分22秒 into N35°41'22" for the DMS parser.
+var japaneseMarkers = strings.NewReplacer(
+	"北緯", "N", "南緯", "S", "東経", "E", "西経", "W",
+	"度", "°", "分", "'", "秒", `"`,
+)
+
+// ParseCoords reads coordinates from input without touching the network. It understands
+// "lat,lng", degrees/minutes/seconds (35°41'22"N 139°41'30"E), 北緯/東経 notation, full
+// Plus Codes, geo: URIs, and Google Maps, OpenStreetMap, Apple Maps and Bing Maps URLs.
+func ParseCoords(input string) (lat, lng float64, ok bool) {
+	s := normalizeWidth(strings.TrimSpace(input))
+	if s == "" {
+		return 0, 0, false
+	}
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(s), "geo:"):
+		lat, lng, ok = parseGeoURI(s)
+	case strings.Contains(s, "://"):
+		lat, lng, ok = parseMapURL(s)
+	case looksLikePlusCode(s):
+		var err error
+		lat, lng, err = decodePlusCode(s)
+		ok = err == nil
+	default:
+		if m := rePlain.FindStringSubmatch(s); m != nil {
+			lat, lng, ok = parse2(m[1], m[2])
+		} else {
+			lat, lng, ok = parseDMS(japaneseMarkers.Replace(s))
+		}
+	}
+	if !ok || !validCoords(lat, lng) {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+func validCoords(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// normalizeWidth folds full-width ASCII (３５．６、Ｎ) and ideographic spaces to their ASCII forms.
+func normalizeWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '！' && r <= '～':
+			return r - '！' + '!'
+		case r == '　':
+			return ' '
+		case r == '，':
+			return ','
+		}
+		return r
+	}, s)
+}
+
+// parseDMS reads a latitude and longitude in degrees, minutes and seconds, each with a hemisphere.
+// The longitude may come first.
+func parseDMS(s string) (lat, lng float64, ok bool) {
+	s = strings.ToUpper(s)
+	var c1, c2 []string
+	if m := reDMSSuffix.FindStringSubmatch(s); m != nil {
+		c1, c2 = m[1:5], m[5:9]
+	} else if m := reDMSPrefix.FindStringSubmatch(s); m != nil {
+		c1 = append(m[2:5:5], m[1])
+		c2 = append(m[6:9:9], m[5])
+	} else {
+		return 0, 0, false
+	}
+	v1, h1, ok1 := dmsValue(c1)
+	v2, h2, ok2 := dmsValue(c2)
+	if !ok1 || !ok2 {
+		return 0, 0, false
+	}
+	isLat := func(h string) bool { return h == "N" || h == "S" }
+	switch {
+	case isLat(h1) && !isLat(h2):
+		return v1, v2, true
+	case !isLat(h1) && isLat(h2):
+		return v2, v1, true
+	}
+	return 0, 0, false
+}
+
+// dmsValue converts one DMS component's submatches (degrees, minutes, seconds, hemisphere)
+// to signed decimal degrees.
+func dmsValue(m []string) (v float64, hemisphere string, ok bool) {
+	hemisphere = m[3]
+	deg, err := strconv.ParseFloat(m[0], 64)
+	if err != nil {
+		return 0, "", false
+	}
+	parts := []float64{deg, 0, 0}
+	for idx, p := range m[1:3] {
+		if p == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(p, 64)
+		if err != nil || n >= 60 {
+			return 0, "", false
+		}
+		parts[idx+1] = n
+	}
+	v = parts[0] + parts[1]/60 + parts[2]/3600
+	if hemisphere == "S" || hemisphere == "W" {
+		v = -v
+	}
+	return v, hemisphere, true
+}
+
+// parseGeoURI reads RFC 5870 geo: URIs (geo:lat,lng;u=10) and the Android form geo:0,0?q=lat,lng(label).
+func parseGeoURI(s string) (lat, lng float64, ok bool) {
+	rest := s[len("geo:"):]
+	query := ""
+	if idx := strings.IndexByte(rest, '?'); idx >= 0 {
+		rest, query = rest[:idx], rest[idx+1:]
+	}
+	if idx := strings.IndexByte(rest, ';'); idx >= 0 {
+		rest = rest[:idx]
+	}
+	fields := strings.Split(rest, ",")
+	if len(fields) >= 2 {
+		lat, lng, ok = parse2(strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]))
+	}
+	if (!ok || (lat == 0 && lng == 0)) && query != "" {
+		if values, err := url.ParseQuery(query); err == nil {
+			q := values.Get("q")
+			if idx := strings.IndexByte(q, '('); idx >= 0 {
+				q = q[:idx]
+			}
+			if m := rePlain.FindStringSubmatch(q); m != nil {
+				return parse2(m[1], m[2])
+			}
+		}
+	}
+	return lat, lng, ok
+}
+
+// parseMapURL reads coordinates from a map service URL, by the conventions of its host.
+func parseMapURL(s string) (lat, lng float64, ok bool) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return 0, 0, false
+	}
+	host := strings.ToLower(u.Hostname())
+	q := u.Query()
+	switch {
+	case host == "openstreetmap.org" || strings.HasSuffix(host, ".openstreetmap.org") || host == "osm.org":
+		// The marker is the shared place; the fragment is only the view.
+		if q.Get("mlat") != "" {
+			return parse2(q.Get("mlat"), q.Get("mlon"))
+		}
+		if m := reOSMFragment.FindStringSubmatch(u.Fragment); m != nil {
+			return parse2(m[1], m[2])
+		}
+		return 0, 0, false
+
+	case host == "maps.apple.com":
+		for _, key := range []string{"ll", "coordinate", "q", "sll", "center"} {
+			if m := rePlain.FindStringSubmatch(q.Get(key)); m != nil {
+				return parse2(m[1], m[2])
+			}
+		}
+		return 0, 0, false
+
+	case host == "bing.com" || strings.HasSuffix(host, ".bing.com"):
+		if m := reBingSP.FindStringSubmatch(q.Get("sp")); m != nil {
+			return parse2(m[1], m[2])
+		}
+		if m := reBingCP.FindStringSubmatch(q.Get("cp")); m != nil {
+			return parse2(m[1], m[2])
+		}
+		for _, key := range []string{"where1", "q"} {
+			if m := rePlain.FindStringSubmatch(q.Get(key)); m != nil {
+				return parse2(m[1], m[2])
+			}
+		}
+		return 0, 0, false
+	}
+	return extractFromURL(s)
+}
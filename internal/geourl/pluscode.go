@@ -0,0 +1,89 @@
+package geourl
+
+import (
+	"errors"
+	"strings"
+)
+
+// Open Location Code (Plus Code) constants, see https://github.com/google/open-location-code.
+const (
+	olcAlphabet     = "23456789CFGHJMPQRVWX"
+	olcSeparator    = '+'
+	olcSeparatorPos = 8
+	olcPadding      = '0'
+	olcPairLength   = 10
+	olcGridRows     = 5
+	olcGridColumns  = 4
+	olcMaxLength    = 15
+)
+
+// olcPairResolutions are the degrees covered by one digit of each lat/lng pair.
+var olcPairResolutions = [...]float64{20, 1, 1.0 / 20, 1.0 / 400, 1.0 / 8000}
+
+// ErrShortPlusCode means a Plus Code lacks its leading digits, which can only be recovered with
+// a reference location (usually a place name that needs geocoding).
+var ErrShortPlusCode = errors.New("short plus codes need a reference location; use the full code (e.g. 8Q7XMM8R+2X)")
+
+// looksLikePlusCode reports whether s has the shape of a full or short Plus Code.
+func looksLikePlusCode(s string) bool {
+	sep := strings.IndexRune(s, olcSeparator)
+	if sep < 2 || sep > olcSeparatorPos || sep%2 != 0 || strings.Count(s, string(olcSeparator)) != 1 {
+		return false
+	}
+	for _, r := range strings.ToUpper(s) {
+		if r != olcSeparator && r != olcPadding && !strings.ContainsRune(olcAlphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodePlusCode returns the center of the area a full Plus Code refers to.
+func decodePlusCode(code string) (lat, lng float64, err error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !looksLikePlusCode(code) || len(code) > olcMaxLength+1 {
+		return 0, 0, errors.New("invalid plus code")
+	}
+	if strings.IndexRune(code, olcSeparator) != olcSeparatorPos {
+		return 0, 0, ErrShortPlusCode
+	}
+
+	// Padding is only allowed as whole pairs right before the separator, with nothing after it.
+	digits := strings.Replace(code, string(olcSeparator), "", 1)
+	if pad := strings.IndexRune(digits, olcPadding); pad >= 0 {
+		if pad == 0 || pad%2 != 0 || strings.TrimRight(digits[pad:], string(olcPadding)) != "" || len(digits) != olcSeparatorPos {
+			return 0, 0, errors.New("invalid plus code padding")
+		}
+		digits = digits[:pad]
+	}
+	if len(digits) < 2 || len(digits) == olcSeparatorPos+1 {
+		return 0, 0, errors.New("invalid plus code")
+	}
+
+	// The first pair is limited to latitude 0-180 and longitude 0-360.
+	if v := strings.IndexByte(olcAlphabet, digits[0]); v*20 >= 180 {
+		return 0, 0, errors.New("plus code latitude out of range")
+	}
+	if v := strings.IndexByte(olcAlphabet, digits[1]); v*20 >= 360 {
+		return 0, 0, errors.New("plus code longitude out of range")
+	}
+
+	lat, lng = -90, -180
+	var latRes, lngRes float64
+	for i := 0; i < len(digits) && i < olcPairLength; i += 2 {
+		res := olcPairResolutions[i/2]
+		lat += float64(strings.IndexByte(olcAlphabet, digits[i])) * res
+		latRes, lngRes = res, res
+		if i+1 < len(digits) {
+			lng += float64(strings.IndexByte(olcAlphabet, digits[i+1])) * res
+		}
+	}
+	for i := olcPairLength; i < len(digits); i++ {
+		v := strings.IndexByte(olcAlphabet, digits[i])
+		latRes /= olcGridRows
+		lngRes /= olcGridColumns
+		lat += float64(v/olcGridColumns) * latRes
+		lng += float64(v%olcGridColumns) * lngRes
+	}
+	return lat + latRes/2, lng + lngRes/2, nil
+}
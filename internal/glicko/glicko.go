@@ -0,0 +1,79 @@
+// Package glicko implements the Glicko rating system (Glickman, 1999): a rating with a rating
+// deviation (RD) that measures how uncertain it is. RD shrinks as a player plays and grows back
+// while they are away, so infrequent players move faster when they return.
+package glicko
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// DefaultRating and MaxRD are the rating and deviation of a new player.
+	DefaultRating = 1500.0
+	MaxRD         = 350.0
+	// MinRD keeps ratings of regular players from freezing.
+	MinRD = 30.0
+	// RDGrowthPerDay is the constant c of Glicko for periods of a day: an RD of 50 grows back
+	// to MaxRD after about 180 days without play.
+	RDGrowthPerDay = 25.8
+)
+
+// q is ln(10)/400.
+var q = math.Ln10 / 400
+
+// Rating is a player's rating and rating deviation.
+type Rating struct {
+	Rating float64
+	RD     float64
+}
+
+// Default is the rating of a player who has not played yet.
+func Default() Rating {
+	return Rating{Rating: DefaultRating, RD: MaxRD}
+}
+
+// Decay returns r with its deviation grown for elapsed time without play, up to MaxRD.
+func (r Rating) Decay(elapsed time.Duration) Rating {
+	days := elapsed.Hours() / 24
+	if days <= 0 {
+		return r
+	}
+	r.RD = math.Min(math.Sqrt(r.RD*r.RD+RDGrowthPerDay*RDGrowthPerDay*days), MaxRD)
+	return r
+}
+
+// Outcome is a game against one opponent: Score is 1 for a win, 0.5 for a draw and 0 for a loss.
+type Outcome struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update returns r after the outcomes of one rating period, with opponents rated as they were
+// before the period.
+func (r Rating) Update(outcomes []Outcome) Rating {
+	if len(outcomes) == 0 {
+		return r
+	}
+	var dInv, delta float64
+	for _, o := range outcomes {
+		g := gRD(o.Opponent.RD)
+		e := Expected(r, o.Opponent)
+		dInv += q * q * g * g * e * (1 - e)
+		delta += g * (o.Score - e)
+	}
+	precision := 1/(r.RD*r.RD) + dInv
+	return Rating{
+		Rating: r.Rating + q/precision*delta,
+		RD:     math.Max(math.Sqrt(1/precision), MinRD),
+	}
+}
+
+// Expected is the probability that r beats opponent.
+func Expected(r, opponent Rating) float64 {
+	return 1 / (1 + math.Pow(10, -gRD(opponent.RD)*(r.Rating-opponent.Rating)/400))
+}
+
+func gRD(rd float64) float64 {
+	return 1 / math.Sqrt(1+3*q*q*rd*rd/(math.Pi*math.Pi))
+}
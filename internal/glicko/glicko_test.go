@@ -0,0 +1,32 @@
+package glicko
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestUpdateGlickmanExample checks the worked example of Glickman's paper.
+func TestUpdateGlickmanExample(t *testing.T) {
+	got := Rating{Rating: 1500, RD: 200}.Update([]Outcome{
+		{Opponent: Rating{Rating: 1400, RD: 30}, Score: 1},
+		{Opponent: Rating{Rating: 1550, RD: 100}, Score: 0},
+		{Opponent: Rating{Rating: 1700, RD: 300}, Score: 0},
+	})
+	if math.Abs(got.Rating-1464) > 0.5 || math.Abs(got.RD-151.4) > 0.5 {
+		t.Fatalf("Update = %+v, want 1464 / 151.4", got)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	r := Rating{Rating: 1600, RD: 50}
+	if got := r.Decay(0); got != r {
+		t.Fatalf("Decay(0) = %+v", got)
+	}
+	if got := r.Decay(30 * 24 * time.Hour); got.RD <= 50 || got.RD >= MaxRD || got.Rating != 1600 {
+		t.Fatalf("Decay(30d) = %+v", got)
+	}
+	if got := r.Decay(365 * 24 * time.Hour); got.RD != MaxRD {
+		t.Fatalf("Decay(1y) = %+v", got)
+	}
+}
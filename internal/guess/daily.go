@@ -0,0 +1,346 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrDailyAnswer        = errors.New("デイリーチャレンジの正解は次のチャレンジの出題時に発表されます")
+	ErrDailyStop          = errors.New("デイリーチャレンジは終了できません。結果は次のチャレンジの出題時に発表されます（出題をやめるには `/guess daily disable`）")
+	ErrDailyRunning       = errors.New("このチャンネルではデイリーチャレンジが出題中です。次の出題で結果が発表されるまで、別のゲームは他のチャンネルで開始してください")
+	ErrDailyNotConfigured = errors.New("デイリーチャレンジは設定されていません")
+	ErrDailyDone          = errors.New("この日のデイリーチャレンジは出題済みです")
+	ErrPoolEmpty          = errors.New("出題できる場所がありません。`/guess pool add` で場所を登録してください")
+)
+
+// DailyZone is the time zone of daily challenge dates and post times.
+var DailyZone = time.FixedZone("JST", 9*60*60)
+
+// DailyDate returns the date of t in DailyZone, as midnight UTC.
+func DailyDate(t time.Time) time.Time {
+	y, m, d := t.In(DailyZone).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ParsePostTime reads a post time given as HH:MM and returns it in minutes after midnight.
+func ParsePostTime(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, errors.New("時刻は HH:MM の形式で指定してください（例: 09:00）")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatPostTime writes a post time in minutes after midnight as HH:MM.
+func FormatPostTime(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+// DailySettings configure a guild's daily challenge.
+type DailySettings struct {
+	GuildID   int64
+	ChannelID string
+	// PostMinute is when challenges are posted, in minutes after midnight in DailyZone.
+	PostMinute int
+	// Tag restricts the locations drawn to those with it; empty means the whole pool.
+	Tag     string
+	Enabled bool
+}
+
+// SetDailySettings posts the guild's daily challenges to the channel at postMinute from now on,
+// drawing locations with tag, or from the whole pool if it is empty.
+func (s *Service) SetDailySettings(ctx context.Context, guildID int64, channelID string, postMinute int, tag, userID string) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO guess_daily_settings (guild_id, channel_id, post_minute, tag, enabled, updated_by)
+		VALUES ($1, $2, $3, $4, TRUE, $5)
+		ON CONFLICT (guild_id)
+		DO UPDATE SET channel_id = EXCLUDED.channel_id, post_minute = EXCLUDED.post_minute, tag = EXCLUDED.tag,
+		              enabled = TRUE, updated_by = EXCLUDED.updated_by, updated_at = CURRENT_TIMESTAMP
+	`, guildID, channelID, postMinute, tag, userID)
+	return err
+}
+
+// DisableDaily stops posting the guild's daily challenges. The one that is open stays open;
+// see CloseDailyChallenges.
+func (s *Service) DisableDaily(ctx context.Context, guildID int64, userID string) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE guess_daily_settings SET enabled = FALSE, updated_by = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE guild_id = $1 AND enabled
+	`, guildID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDailyNotConfigured
+	}
+	return nil
+}
+
+// DailySettings returns the guild's daily challenge settings, or ErrDailyNotConfigured.
+func (s *Service) DailySettings(ctx context.Context, guildID int64) (*DailySettings, error) {
+	var ds DailySettings
+	err := s.db.QueryRow(ctx,
+		`SELECT guild_id, channel_id, post_minute, tag, enabled FROM guess_daily_settings WHERE guild_id = $1`,
+		guildID,
+	).Scan(&ds.GuildID, &ds.ChannelID, &ds.PostMinute, &ds.Tag, &ds.Enabled)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDailyNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ds, nil
+}
+
+// DueDailyChallenges returns the guilds whose challenge for the day of now has not run yet and
+// whose post time has passed.
+func (s *Service) DueDailyChallenges(ctx context.Context, now time.Time) ([]DailySettings, error) {
+	local := now.In(DailyZone)
+	rows, err := s.db.Query(ctx, `
+		SELECT guild_id, channel_id, post_minute, tag, enabled
+		FROM guess_daily_settings ds
+		WHERE enabled AND post_minute <= $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM guess_daily_challenges c WHERE c.guild_id = ds.guild_id AND c.challenge_date = $1
+		  )
+		ORDER BY guild_id
+	`, DailyDate(now), local.Hour()*60+local.Minute())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []DailySettings
+	for rows.Next() {
+		var ds DailySettings
+		if err := rows.Scan(&ds.GuildID, &ds.ChannelID, &ds.PostMinute, &ds.Tag, &ds.Enabled); err != nil {
+			return nil, err
+		}
+		out = append(out, ds)
+	}
+	return out, rows.Err()
+}
+
+// DailyChallenge is a posted daily challenge.
+type DailyChallenge struct {
+	GuildID   int64
+	Date      time.Time
+	SessionID int64
+	ChannelID string
+	// PostMinute is when the next challenge, which closes this one, is posted.
+	PostMinute int
+	// Clue is shown to players; AddedBy added the location and cannot guess it.
+	LocationID int64
+	Clue       string
+	AddedBy    string
+}
+
+// StartDailyChallenge posts the guild's challenge for date: a one-round game in the configured
+// channel whose answer, drawn from the pool like games drawing from it, is sealed until the next
+// challenge.
+//
+// If the pool has no such location the day is recorded without a challenge, so it is not
+// retried, and ErrPoolEmpty is returned. ErrSessionAlreadyExists means another game runs in the
+// channel; nothing is recorded and the challenge can be started once it ends.
+func (s *Service) StartDailyChallenge(ctx context.Context, guildID int64, date time.Time) (*DailyChallenge, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ch := &DailyChallenge{GuildID: guildID, Date: date}
+	var tag string
+	var enabled bool
+	err = tx.QueryRow(ctx,
+		`SELECT channel_id, post_minute, tag, enabled FROM guess_daily_settings WHERE guild_id = $1 FOR UPDATE`,
+		guildID,
+	).Scan(&ch.ChannelID, &ch.PostMinute, &tag, &enabled)
+	if err == pgx.ErrNoRows || (err == nil && !enabled) {
+		return nil, ErrDailyNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var challengeID int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO guess_daily_challenges (guild_id, challenge_date)
+		VALUES ($1, $2)
+		ON CONFLICT (guild_id, challenge_date) DO NOTHING
+		RETURNING id
+	`, guildID, date).Scan(&challengeID)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDailyDone
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	drawn, err := drawPoolLocations(ctx, tx, guildID, tag, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(drawn) == 0 {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, err
+		}
+		return nil, ErrPoolEmpty
+	}
+	loc := drawn[0]
+	ch.LocationID, ch.Clue, ch.AddedBy = loc.ID, loc.Clue, loc.AddedBy
+
+	sess := &Session{
+		ChannelID:        ch.ChannelID,
+		GuildID:          guildID,
+		OrganizerID:      ch.AddedBy,
+		MaxErrorDistance: World.MaxErrorDistance(),
+		MapName:          World.Name,
+		TotalRounds:      1,
+		CurrentRound:     1,
+		Mode:             ModeDistance,
+		Daily:            true,
+	}
+	if err := insertSession(ctx, tx, sess); err != nil {
+		return nil, err
+	}
+	ch.SessionID = sess.ID
+	if err := sealPoolLocations(ctx, tx, sess, drawn); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE guess_daily_challenges SET session_id = $2, location_id = $3 WHERE id = $1`,
+		challengeID, sess.ID, ch.LocationID,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// DailyResult is the outcome of a daily challenge.
+type DailyResult struct {
+	Date      time.Time
+	ChannelID string
+	Result    *RoundResult
+	// Streaks are the players' streaks in the order of Result.Results.
+	Streaks []DailyStreak
+}
+
+// DailyStreak is how many challenges in a row a player has guessed, up to and including the
+// one just closed. Days without a challenge do not break a streak.
+type DailyStreak struct {
+	UserID string
+	Days   int
+}
+
+// CloseDailyChallenges reveals the answers of the guild's daily challenges that are still open,
+// scoring and closing them, oldest first.
+func (s *Service) CloseDailyChallenges(ctx context.Context, guildID int64) ([]DailyResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.session_id, c.challenge_date
+		FROM guess_daily_challenges c
+		JOIN guess_sessions s ON s.id = c.session_id
+		WHERE c.guild_id = $1 AND s.status = 'active'
+		ORDER BY c.challenge_date
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	type open struct {
+		sessionID int64
+		date      time.Time
+	}
+	var challenges []open
+	for rows.Next() {
+		var o open
+		if err := rows.Scan(&o.sessionID, &o.date); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		challenges = append(challenges, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []DailyResult
+	for _, o := range challenges {
+		sess, err := scanSession(tx.QueryRow(ctx,
+			`SELECT `+sessionColumns+` FROM guess_sessions WHERE id = $1 AND status = 'active' FOR UPDATE`,
+			o.sessionID,
+		))
+		if err == ErrNoActiveSession {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res, err := finishRound(ctx, tx, sess, nil)
+		if err != nil {
+			return nil, err
+		}
+		streaks, err := dailyStreaks(ctx, tx, guildID, o.date, res.Results)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, DailyResult{Date: o.date, ChannelID: sess.ChannelID, Result: res, Streaks: streaks})
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// dailyStreaks counts, for each player of results, the challenges up to date they guessed in
+// since the last one they missed.
+func dailyStreaks(ctx context.Context, tx pgx.Tx, guildID int64, date time.Time, results []GuessResult) ([]DailyStreak, error) {
+	userIDs := make([]string, len(results))
+	for idx, r := range results {
+		userIDs[idx] = r.UserID
+	}
+	rows, err := tx.Query(ctx, `
+		WITH c AS (
+			SELECT session_id, row_number() OVER (ORDER BY challenge_date DESC) AS n
+			FROM guess_daily_challenges
+			WHERE guild_id = $1 AND challenge_date <= $2 AND session_id IS NOT NULL
+		)
+		SELECT u.user_id, COALESCE(
+			(SELECT MIN(c.n) - 1 FROM c WHERE NOT EXISTS (
+				SELECT 1 FROM guess_guesses g WHERE g.session_id = c.session_id AND g.user_id = u.user_id
+			)),
+			(SELECT COUNT(*) FROM c)
+		)
+		FROM unnest($3::text[]) WITH ORDINALITY AS u(user_id, ord)
+		ORDER BY u.ord
+	`, guildID, date, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]DailyStreak, 0, len(results))
+	for rows.Next() {
+		var st DailyStreak
+		if err := rows.Scan(&st.UserID, &st.Days); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,40 @@
+package guess
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDailyDate(t *testing.T) {
+	for _, tt := range []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 17, 14, 59, 0, 0, time.UTC), "2026-10-17"},
+		{time.Date(2026, 10, 17, 15, 0, 0, 0, time.UTC), "2026-10-18"},
+		{time.Date(2026, 10, 18, 0, 30, 0, 0, DailyZone), "2026-10-18"},
+	} {
+		got := DailyDate(tt.now)
+		if got.Format("2006-01-02") != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("DailyDate(%v) = %v, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestParsePostTime(t *testing.T) {
+	for in, want := range map[string]int{"00:00": 0, "09:30": 570, " 23:59 ": 1439} {
+		got, err := ParsePostTime(in)
+		if err != nil || got != want {
+			t.Errorf("ParsePostTime(%q) = %d, %v; want %d", in, got, err, want)
+		}
+		if FormatPostTime(got) != strings.TrimSpace(in) {
+			t.Errorf("FormatPostTime(%d) = %q", got, FormatPostTime(got))
+		}
+	}
+	for _, in := range []string{"", "24:00", "9時", "12:60"} {
+		if _, err := ParsePostTime(in); err == nil {
+			t.Errorf("ParsePostTime(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrDuelSelf      = errors.New("自分自身とはデュエルできません")
+	ErrNotDuelist    = errors.New("デュエルの対戦者のみが推測できます")
+	ErrDuelistAnswer = errors.New("デュエルの対戦者は正解を発表できません。対戦者以外のメンバーが `/guess answer` で発表してください")
+	ErrDuelSealed    = errors.New("デュエルでは正解を封印できません。対戦者以外のメンバーが `/guess answer` で発表してください")
+)
+
+// DuelStartHP is the HP both players start a duel with.
+const DuelStartHP = 6000
+
+// MaxDuelRounds ends a duel nobody has won yet; the player with more HP left wins.
+const MaxDuelRounds = 50
+
+// Damage multipliers: rounds up to duelFlatRounds deal the plain score difference, and each
+// later round adds duelMultiplierStep.
+const (
+	duelFlatRounds     = 3
+	duelMultiplierStep = 0.5
+)
+
+// DuelMultiplier returns the damage multiplier of a duel round.
+func DuelMultiplier(round int) float64 {
+	if round <= duelFlatRounds {
+		return 1
+	}
+	return 1 + duelMultiplierStep*float64(round-duelFlatRounds)
+}
+
+// Duel is the state of a head-to-head game.
+type Duel struct {
+	SessionID    int64
+	ChallengerID string
+	OpponentID   string
+	ChallengerHP int
+	OpponentHP   int
+	// WinnerID is set once a player's HP reaches 0, or when the round limit is hit with unequal HP.
+	WinnerID string
+}
+
+// IsDuelist reports whether userID is one of the two players.
+func (d *Duel) IsDuelist(userID string) bool {
+	return userID == d.ChallengerID || userID == d.OpponentID
+}
+
+// DuelRound is the damage dealt in a duel round.
+type DuelRound struct {
+	Round           int
+	Multiplier      float64
+	ChallengerScore int
+	OpponentScore   int
+	Damage          int
+	// DamagedID is the player who took Damage; it is empty when the scores were equal.
+	DamagedID string
+}
+
+// apply deals the damage of round to d: the lower scorer loses the score difference times the
+// round's multiplier, down to 0 HP at which the other player wins.
+func (d *Duel) apply(round, challengerScore, opponentScore int) DuelRound {
+	dr := DuelRound{
+		Round:           round,
+		Multiplier:      DuelMultiplier(round),
+		ChallengerScore: challengerScore,
+		OpponentScore:   opponentScore,
+	}
+	diff := challengerScore - opponentScore
+	if diff == 0 {
+		return dr
+	}
+	dr.Damage = int(math.Round(math.Abs(float64(diff)) * dr.Multiplier))
+	if diff > 0 {
+		dr.DamagedID = d.OpponentID
+		d.OpponentHP = max(d.OpponentHP-dr.Damage, 0)
+		if d.OpponentHP == 0 {
+			d.WinnerID = d.ChallengerID
+		}
+	} else {
+		dr.DamagedID = d.ChallengerID
+		d.ChallengerHP = max(d.ChallengerHP-dr.Damage, 0)
+		if d.ChallengerHP == 0 {
+			d.WinnerID = d.OpponentID
+		}
+	}
+	return dr
+}
+
+// StartDuel starts a duel between the challenger and the opponent in the channel and opens
+// round 1. Only the two players guess; anyone else gives the answers. Rounds and Teams of opts
+// are ignored.
+func (s *Service) StartDuel(ctx context.Context, channelID string, guildID int64, challengerID, opponentID string, opts StartOptions) error {
+	if challengerID == opponentID {
+		return ErrDuelSelf
+	}
+	if _, err := roundLimit(opts); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := startDuel(ctx, tx, channelID, guildID, challengerID, opponentID, opts); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// startDuel creates the duel session; opts must have been validated.
+func startDuel(ctx context.Context, tx pgx.Tx, channelID string, guildID int64, challengerID, opponentID string, opts StartOptions) error {
+	roundSeconds, err := roundLimit(opts)
+	if err != nil {
+		return err
+	}
+	mapName := opts.Map.Name
+	if mapName == "" {
+		mapName = World.Name
+	}
+	sess := &Session{
+		ChannelID:        channelID,
+		GuildID:          guildID,
+		OrganizerID:      challengerID,
+		MaxErrorDistance: opts.Map.MaxErrorDistance(),
+		MapName:          mapName,
+		TotalRounds:      MaxDuelRounds,
+		CurrentRound:     1,
+		RoundSeconds:     roundSeconds,
+		TimeBonus:        opts.TimeBonus,
+		Mode:             ModeDuel,
+		Scorer:           opts.Scorer,
+	}
+	if err := insertSession(ctx, tx, sess); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO guess_duels (session_id, challenger_id, opponent_id, challenger_hp, opponent_hp)
+		VALUES ($1, $2, $3, $4, $4)
+	`, sess.ID, challengerID, opponentID, DuelStartHP); err != nil {
+		return err
+	}
+	// Nobody in the channel waits for a match once a duel runs there.
+	_, err = tx.Exec(ctx, `DELETE FROM guess_duel_queue WHERE channel_id = $1`, channelID)
+	return err
+}
+
+// Duel matchmaking.
+const (
+	// DuelMatchWindow is the largest rating difference matchmaking pairs players across.
+	DuelMatchWindow = 400.0
+	// DuelQueueTimeout is how long a player waits for a match.
+	DuelQueueTimeout = 15 * time.Minute
+)
+
+// MatchDuel looks for the player waiting for a duel in the channel whose guild rating is closest
+// to the user's, within DuelMatchWindow. If there is one, a duel between them starts with the
+// waiting player as challenger and is returned. Otherwise the user waits in the queue and the
+// duel is nil. rating is the user's rating. The queue does not keep options: a matched duel is
+// played with the opts of the user who joined last.
+func (s *Service) MatchDuel(ctx context.Context, channelID string, guildID int64, userID string, opts StartOptions) (duel *Duel, rating float64, err error) {
+	if _, err := roundLimit(opts); err != nil {
+		return nil, 0, err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var active bool
+	if err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM guess_sessions WHERE channel_id = $1 AND status = 'active')`,
+		channelID,
+	).Scan(&active); err != nil {
+		return nil, 0, err
+	}
+	if active {
+		return nil, 0, ErrSessionAlreadyExists
+	}
+	if rating, err = userRating(ctx, tx, guildID, userID); err != nil {
+		return nil, 0, err
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM guess_duel_queue WHERE joined_at < LOCALTIMESTAMP - $1::float8 * INTERVAL '1 second'`,
+		DuelQueueTimeout.Seconds(),
+	); err != nil {
+		return nil, 0, err
+	}
+
+	var opponentID string
+	err = tx.QueryRow(ctx, `
+		SELECT user_id FROM guess_duel_queue
+		WHERE channel_id = $1 AND user_id <> $2 AND abs(rating - $3) <= $4
+		ORDER BY abs(rating - $3), joined_at
+		LIMIT 1
+		FOR UPDATE
+	`, channelID, userID, rating, DuelMatchWindow).Scan(&opponentID)
+	if err == pgx.ErrNoRows {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO guess_duel_queue (channel_id, user_id, guild_id, rating)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (channel_id, user_id)
+			DO UPDATE SET rating = EXCLUDED.rating, joined_at = LOCALTIMESTAMP
+		`, channelID, userID, guildID, rating)
+		if err != nil {
+			return nil, 0, err
+		}
+		return nil, rating, tx.Commit(ctx)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := startDuel(ctx, tx, channelID, guildID, opponentID, userID, opts); err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, 0, err
+	}
+	return &Duel{
+		ChallengerID: opponentID,
+		OpponentID:   userID,
+		ChallengerHP: DuelStartHP,
+		OpponentHP:   DuelStartHP,
+	}, rating, nil
+}
+
+// sessionDuel returns the duel of a session, locking it when lock is set.
+func sessionDuel(ctx context.Context, tx pgx.Tx, sessionID int64, lock bool) (*Duel, error) {
+	query := `
+		SELECT session_id, challenger_id, opponent_id, challenger_hp, opponent_hp, COALESCE(winner_id, '')
+		FROM guess_duels WHERE session_id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	var d Duel
+	err := tx.QueryRow(ctx, query, sessionID).Scan(&d.SessionID, &d.ChallengerID, &d.OpponentID, &d.ChallengerHP, &d.OpponentHP, &d.WinnerID)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// scoreDuel deals the damage of the session's current round from its scored results. A player
+// who did not guess scores 0. last is set when the round limit is reached, which ends the duel
+// in favor of the player with more HP.
+func scoreDuel(ctx context.Context, tx pgx.Tx, sess *Session, results []GuessResult, last bool) (*Duel, *DuelRound, error) {
+	d, err := sessionDuel(ctx, tx, sess.ID, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var challengerScore, opponentScore int
+	for _, r := range results {
+		switch r.UserID {
+		case d.ChallengerID:
+			challengerScore = r.Score + r.TimeBonus
+		case d.OpponentID:
+			opponentScore = r.Score + r.TimeBonus
+		}
+	}
+	dr := d.apply(sess.CurrentRound, challengerScore, opponentScore)
+	if last && d.WinnerID == "" {
+		switch {
+		case d.ChallengerHP > d.OpponentHP:
+			d.WinnerID = d.ChallengerID
+		case d.OpponentHP > d.ChallengerHP:
+			d.WinnerID = d.OpponentID
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE guess_duels SET challenger_hp = $2, opponent_hp = $3, winner_id = NULLIF($4, '')
+		WHERE session_id = $1
+	`, d.SessionID, d.ChallengerHP, d.OpponentHP, d.WinnerID); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO guess_duel_rounds (session_id, round_number, multiplier, challenger_score, opponent_score, damage, challenger_hp, opponent_hp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, d.SessionID, dr.Round, dr.Multiplier, dr.ChallengerScore, dr.OpponentScore, dr.Damage, d.ChallengerHP, d.OpponentHP); err != nil {
+		return nil, nil, err
+	}
+	return d, &dr, nil
+}
@@ -0,0 +1,31 @@
+package guess
+
+import "testing"
+
+func TestDuelMultiplier(t *testing.T) {
+	for round, want := range map[int]float64{1: 1, 3: 1, 4: 1.5, 5: 2, 7: 3} {
+		if got := DuelMultiplier(round); got != want {
+			t.Errorf("DuelMultiplier(%d) = %v, want %v", round, got, want)
+		}
+	}
+}
+
+func TestDuelApply(t *testing.T) {
+	d := &Duel{ChallengerID: "a", OpponentID: "b", ChallengerHP: DuelStartHP, OpponentHP: DuelStartHP}
+
+	if dr := d.apply(1, 4000, 4000); dr.Damage != 0 || dr.DamagedID != "" {
+		t.Fatalf("tie = %+v", dr)
+	}
+	if dr := d.apply(2, 1000, 4500); dr.Damage != 3500 || dr.DamagedID != "a" || d.ChallengerHP != 2500 {
+		t.Fatalf("round 2 = %+v, duel %+v", dr, d)
+	}
+	// 1000 points at x1.5 deal 1500.
+	if dr := d.apply(4, 4000, 3000); dr.Damage != 1500 || d.OpponentHP != 4500 || d.WinnerID != "" {
+		t.Fatalf("round 4 = %+v, duel %+v", dr, d)
+	}
+	// HP stops at 0 and the other player wins.
+	d.apply(5, 0, 5000)
+	if d.ChallengerHP != 0 || d.WinnerID != "b" {
+		t.Fatalf("knockout duel = %+v", d)
+	}
+}
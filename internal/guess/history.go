@@ -0,0 +1,230 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+)
+
+// ErrGameNotFound is returned for a game that does not exist in the guild.
+var ErrGameNotFound = errors.New("game not found")
+
+// DefaultHistoryLimit and MaxHistoryLimit bound how many games a history page lists.
+const (
+	DefaultHistoryLimit = 20
+	MaxHistoryLimit     = 100
+)
+
+// GameSummary is a game in a guild's history.
+type GameSummary struct {
+	ID          int64  `json:"id"`
+	ChannelID   string `json:"channel_id"`
+	OrganizerID string `json:"organizer_id"`
+	Status      string `json:"status"`
+	Mode        Mode   `json:"mode"`
+	MapName     string `json:"map_name"`
+	MapLabel    string `json:"map_label"`
+	TotalRounds int    `json:"total_rounds"`
+	// Scorer and ScorerParams are the scoring model; see geoscore.ParseScorer.
+	Scorer       geoscore.ScorerKind `json:"scorer"`
+	ScorerParams string              `json:"scorer_params"`
+	// RoundsPlayed counts the rounds whose answer was given.
+	RoundsPlayed int        `json:"rounds_played"`
+	Players      int        `json:"players"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+// Game is a game with the rounds whose answer was given. Guesses of a round that is still open,
+// or that ended without an answer, are never included.
+type Game struct {
+	GameSummary
+	MaxErrorDistance float64     `json:"max_error_distance"`
+	Rounds           []GameRound `json:"rounds"`
+}
+
+// GameRound is an answered round of a game and its scored guesses, best first.
+type GameRound struct {
+	Round     int         `json:"round"`
+	AnswerLat float64     `json:"answer_lat"`
+	AnswerLng float64     `json:"answer_lng"`
+	AnswerURL string      `json:"answer_url"`
+	OpenedAt  time.Time   `json:"opened_at"`
+	ClosedAt  *time.Time  `json:"closed_at,omitempty"`
+	Guesses   []GameGuess `json:"guesses"`
+}
+
+// GameGuess is a scored guess.
+type GameGuess struct {
+	UserID         string    `json:"user_id"`
+	Lat            float64   `json:"lat"`
+	Lng            float64   `json:"lng"`
+	URL            string    `json:"url"`
+	Score          int       `json:"score"`
+	TimeBonus      int       `json:"time_bonus"`
+	DistanceMeters float64   `json:"distance_meters"`
+	GuessedAt      time.Time `json:"guessed_at"`
+}
+
+// gameSummaryColumns selects a GameSummary from guess_sessions s.
+const gameSummaryColumns = `
+	s.id, s.channel_id, s.organizer_id, s.status, s.mode, s.map_name, s.total_rounds, s.scorer, s.scorer_params,
+	(SELECT COUNT(*) FROM guess_rounds r WHERE r.session_id = s.id AND r.status = 'closed'),
+	(SELECT COUNT(DISTINCT g.user_id) FROM guess_guesses g WHERE g.session_id = s.id AND g.score IS NOT NULL),
+	s.created_at, s.closed_at`
+
+// scanGameSummary scans gameSummaryColumns into g, followed by any extra columns.
+func scanGameSummary(row pgx.Row, g *GameSummary, extra ...any) error {
+	dest := append([]any{&g.ID, &g.ChannelID, &g.OrganizerID, &g.Status, &g.Mode, &g.MapName, &g.TotalRounds,
+		&g.Scorer, &g.ScorerParams, &g.RoundsPlayed, &g.Players, &g.CreatedAt, &g.ClosedAt}, extra...)
+	err := row.Scan(dest...)
+	g.MapLabel = MapLabel(g.MapName)
+	return err
+}
+
+// History lists the guild's games, newest first. before, when non-zero, pages back to the
+// games older than that game ID; limit 0 means DefaultHistoryLimit.
+func (s *Service) History(ctx context.Context, guildID, before int64, limit int) ([]GameSummary, error) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+	rows, err := s.db.Query(ctx, `
+		SELECT `+gameSummaryColumns+`
+		FROM guess_sessions s
+		WHERE s.guild_id = $1 AND ($2 = 0 OR s.id < $2)
+		ORDER BY s.id DESC
+		LIMIT $3
+	`, guildID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []GameSummary
+	for rows.Next() {
+		var g GameSummary
+		if err := scanGameSummary(rows, &g); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// Game returns a game of the guild with its answered rounds.
+func (s *Service) Game(ctx context.Context, guildID, sessionID int64) (*Game, error) {
+	g := &Game{}
+	err := scanGameSummary(s.db.QueryRow(ctx, `
+		SELECT `+gameSummaryColumns+`, s.max_error_distance
+		FROM guess_sessions s
+		WHERE s.guild_id = $1 AND s.id = $2
+	`, guildID, sessionID), &g.GameSummary, &g.MaxErrorDistance)
+	if err == pgx.ErrNoRows {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT r.round_number, r.answer_lat, r.answer_lng, COALESCE(r.answer_url, ''), r.opened_at, r.closed_at,
+		       g.user_id, g.guess_lat, g.guess_lng, g.guess_url, g.score, g.time_bonus, g.distance_meters, g.created_at
+		FROM guess_rounds r
+		LEFT JOIN guess_guesses g ON g.session_id = r.session_id AND g.round_number = r.round_number AND g.score IS NOT NULL
+		WHERE r.session_id = $1 AND r.status = 'closed' AND r.answer_lat IS NOT NULL AND r.answer_lng IS NOT NULL
+		ORDER BY r.round_number, g.score + g.time_bonus DESC, g.distance_meters, g.created_at
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	g.Rounds = []GameRound{}
+	for rows.Next() {
+		var round GameRound
+		var userID, guessURL *string
+		var lat, lng, distance *float64
+		var score, timeBonus *int
+		var guessedAt *time.Time
+		if err := rows.Scan(&round.Round, &round.AnswerLat, &round.AnswerLng, &round.AnswerURL, &round.OpenedAt, &round.ClosedAt,
+			&userID, &lat, &lng, &guessURL, &score, &timeBonus, &distance, &guessedAt); err != nil {
+			return nil, err
+		}
+		if n := len(g.Rounds); n == 0 || g.Rounds[n-1].Round != round.Round {
+			round.Guesses = []GameGuess{}
+			g.Rounds = append(g.Rounds, round)
+		}
+		if userID == nil {
+			continue
+		}
+		last := &g.Rounds[len(g.Rounds)-1]
+		last.Guesses = append(last.Guesses, GameGuess{
+			UserID:         *userID,
+			Lat:            *lat,
+			Lng:            *lng,
+			URL:            *guessURL,
+			Score:          *score,
+			TimeBonus:      *timeBonus,
+			DistanceMeters: *distance,
+			GuessedAt:      *guessedAt,
+		})
+	}
+	return g, rows.Err()
+}
+
+// FeatureCollection is a GeoJSON feature collection of points.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON point feature.
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Point          `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Point is a GeoJSON point; Coordinates are longitude, then latitude.
+type Point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func pointFeature(lat, lng float64, props map[string]any) Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   Point{Type: "Point", Coordinates: [2]float64{lng, lat}},
+		Properties: props,
+	}
+}
+
+// GeoJSON exports the answers and guesses of the game's answered rounds as points. The kind
+// property tells answers from guesses.
+func (g *Game) GeoJSON() FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for _, r := range g.Rounds {
+		fc.Features = append(fc.Features, pointFeature(r.AnswerLat, r.AnswerLng, map[string]any{
+			"kind":       "answer",
+			"session_id": g.ID,
+			"round":      r.Round,
+			"url":        r.AnswerURL,
+		}))
+		for _, gs := range r.Guesses {
+			fc.Features = append(fc.Features, pointFeature(gs.Lat, gs.Lng, map[string]any{
+				"kind":            "guess",
+				"session_id":      g.ID,
+				"round":           r.Round,
+				"user_id":         gs.UserID,
+				"score":           gs.Score + gs.TimeBonus,
+				"distance_meters": gs.DistanceMeters,
+				"url":             gs.URL,
+			}))
+		}
+	}
+	return fc
+}
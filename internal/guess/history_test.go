@@ -0,0 +1,44 @@
+package guess
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGameGeoJSON(t *testing.T) {
+	g := &Game{
+		GameSummary: GameSummary{ID: 7},
+		Rounds: []GameRound{
+			{Round: 1, AnswerLat: 35.68, AnswerLng: 139.76, AnswerURL: "https://example.com/a", Guesses: []GameGuess{
+				{UserID: "1", Lat: 34.7, Lng: 135.5, Score: 3000, TimeBonus: 100, DistanceMeters: 400000},
+			}},
+			{Round: 2, AnswerLat: 43.06, AnswerLng: 141.35, Guesses: []GameGuess{}},
+		},
+	}
+	fc := g.GeoJSON()
+	if len(fc.Features) != 3 {
+		t.Fatalf("features = %+v", fc.Features)
+	}
+	guess := fc.Features[1]
+	if guess.Geometry.Coordinates != [2]float64{135.5, 34.7} {
+		t.Errorf("coordinates = %v, want longitude first", guess.Geometry.Coordinates)
+	}
+	if guess.Properties["kind"] != "guess" || guess.Properties["score"] != 3100 || guess.Properties["round"] != 1 {
+		t.Errorf("guess properties = %v", guess.Properties)
+	}
+	if fc.Features[2].Properties["kind"] != "answer" || fc.Features[2].Properties["round"] != 2 {
+		t.Errorf("second answer = %+v", fc.Features[2])
+	}
+
+	b, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[139.76,35.68]}`) {
+		t.Errorf("json = %s", b)
+	}
+	if empty, _ := json.Marshal((&Game{}).GeoJSON()); string(empty) != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("empty json = %s", empty)
+	}
+}
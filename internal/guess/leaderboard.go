@@ -0,0 +1,329 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+)
+
+// PerfectScore is the score of a guess within the 5k radius.
+const PerfectScore = 5000
+
+// perfectFilter selects the perfect 5ks among guesses g of sessions s: full marks on GeoGuessr's
+// distance curve. Region hits and full marks from other scorers are not within a 5k radius.
+var perfectFilter = fmt.Sprintf(`g.score >= %d AND s.mode IN ('%s', '%s') AND s.scorer = '%s'`,
+	PerfectScore, ModeDistance, ModeDuel, geoscore.ScorerExponential)
+
+// Period limits a leaderboard to guesses made in the current calendar week or month.
+type Period string
+
+const (
+	PeriodAll   Period = "all"
+	PeriodMonth Period = "month"
+	PeriodWeek  Period = "week"
+)
+
+// ParsePeriod validates a period name; empty means all time.
+func ParsePeriod(v string) (Period, error) {
+	switch p := Period(strings.ToLower(strings.TrimSpace(v))); p {
+	case "":
+		return PeriodAll, nil
+	case PeriodAll, PeriodMonth, PeriodWeek:
+		return p, nil
+	}
+	return "", fmt.Errorf("期間は all, month, week のいずれかで指定してください")
+}
+
+// Label is the Japanese name of p shown in replies.
+func (p Period) Label() string {
+	switch p {
+	case PeriodMonth:
+		return "今月"
+	case PeriodWeek:
+		return "今週"
+	}
+	return "全期間"
+}
+
+// since is the SQL condition on the timestamp column for p.
+func (p Period) since(column string) string {
+	switch p {
+	case PeriodMonth:
+		return column + ` >= date_trunc('month', CURRENT_TIMESTAMP)`
+	case PeriodWeek:
+		return column + ` >= date_trunc('week', CURRENT_TIMESTAMP)`
+	}
+	return `TRUE`
+}
+
+// LeaderboardSort is the column a leaderboard is ranked by.
+type LeaderboardSort string
+
+const (
+	SortTotal   LeaderboardSort = "total"
+	SortAverage LeaderboardSort = "average"
+	SortPerfect LeaderboardSort = "perfect"
+	SortGames   LeaderboardSort = "games"
+)
+
+// leaderboardOrder maps each sort to its ORDER BY clause, with the total as tie-breaker.
+var leaderboardOrder = map[LeaderboardSort]string{
+	SortTotal:   `total DESC, rounds DESC, user_id`,
+	SortAverage: `average DESC, total DESC, user_id`,
+	SortPerfect: `perfect DESC, total DESC, user_id`,
+	SortGames:   `games DESC, total DESC, user_id`,
+}
+
+// ParseLeaderboardSort validates a sort name; empty means by total score.
+func ParseLeaderboardSort(v string) (LeaderboardSort, error) {
+	o := LeaderboardSort(strings.ToLower(strings.TrimSpace(v)))
+	if o == "" {
+		return SortTotal, nil
+	}
+	if _, ok := leaderboardOrder[o]; !ok {
+		return "", fmt.Errorf("並び順は total, average, perfect, games のいずれかで指定してください")
+	}
+	return o, nil
+}
+
+// Label is the Japanese name of o shown in replies.
+func (o LeaderboardSort) Label() string {
+	switch o {
+	case SortAverage:
+		return "平均スコア"
+	case SortPerfect:
+		return "5000点の回数"
+	case SortGames:
+		return "ゲーム数"
+	}
+	return "合計スコア"
+}
+
+// DefaultLeaderboardLimit and MaxLeaderboardLimit bound how many players a leaderboard lists.
+const (
+	DefaultLeaderboardLimit = 10
+	MaxLeaderboardLimit     = 100
+)
+
+// LeaderboardOptions select the period, ranking and length of a leaderboard.
+type LeaderboardOptions struct {
+	Period Period
+	Sort   LeaderboardSort
+	// Limit is the number of players; 0 means DefaultLeaderboardLimit.
+	Limit int
+}
+
+// LeaderboardEntry is a player's aggregate over the scored rounds in a guild.
+// Scores include time bonuses.
+type LeaderboardEntry struct {
+	UserID       string  `json:"user_id"`
+	TotalScore   int     `json:"total_score"`
+	AverageScore float64 `json:"average_score"`
+	Perfect      int     `json:"perfect"`
+	Rounds       int     `json:"rounds"`
+	Games        int     `json:"games"`
+}
+
+// Leaderboard ranks the guild's players over the scored rounds of its games.
+func (s *Service) Leaderboard(ctx context.Context, guildID int64, opts LeaderboardOptions) ([]LeaderboardEntry, error) {
+	if opts.Period == "" {
+		opts.Period = PeriodAll
+	}
+	if opts.Sort == "" {
+		opts.Sort = SortTotal
+	}
+	order, ok := leaderboardOrder[opts.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown leaderboard sort %q", opts.Sort)
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLeaderboardLimit
+	}
+	if limit > MaxLeaderboardLimit {
+		limit = MaxLeaderboardLimit
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT user_id, total, average, perfect, rounds, games
+		FROM (
+			SELECT g.user_id,
+			       SUM(g.score + g.time_bonus) AS total,
+			       AVG(g.score + g.time_bonus)::float8 AS average,
+			       COUNT(*) FILTER (WHERE `+perfectFilter+`) AS perfect,
+			       COUNT(*) AS rounds,
+			       COUNT(DISTINCT g.session_id) AS games
+			FROM guess_guesses g
+			JOIN guess_sessions s ON s.id = g.session_id
+			WHERE s.guild_id = $1 AND g.score IS NOT NULL AND `+opts.Period.since("g.created_at")+`
+			GROUP BY g.user_id
+		) t
+		ORDER BY `+order+`
+		LIMIT $2
+	`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []LeaderboardEntry
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.TotalScore, &e.AverageScore, &e.Perfect, &e.Rounds, &e.Games); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ErrTeamLeaderboardSort means a team ranking was asked for by perfect 5ks, which only players have.
+var ErrTeamLeaderboardSort = errors.New("チームのランキングは合計スコア・平均スコア・ゲーム数のいずれかで並べ替えてください")
+
+// teamLeaderboardOrder maps each sort teams can be ranked by to its ORDER BY clause.
+var teamLeaderboardOrder = map[LeaderboardSort]string{
+	SortTotal:   `total DESC, rounds DESC, name`,
+	SortAverage: `average DESC, total DESC, name`,
+	SortGames:   `games DESC, total DESC, name`,
+}
+
+// TeamLeaderboardEntry is a team's aggregate over the scored rounds of the guild's team games.
+// Teams of different games are the same team when their names match regardless of case.
+type TeamLeaderboardEntry struct {
+	Name         string  `json:"name"`
+	TotalScore   int     `json:"total_score"`
+	AverageScore float64 `json:"average_score"`
+	// Wins counts the games the team finished first in, ties included.
+	Wins   int `json:"wins"`
+	Rounds int `json:"rounds"`
+	Games  int `json:"games"`
+}
+
+// TeamLeaderboard ranks the guild's teams over the scored rounds of its team games.
+func (s *Service) TeamLeaderboard(ctx context.Context, guildID int64, opts LeaderboardOptions) ([]TeamLeaderboardEntry, error) {
+	if opts.Period == "" {
+		opts.Period = PeriodAll
+	}
+	if opts.Sort == "" {
+		opts.Sort = SortTotal
+	}
+	order, ok := teamLeaderboardOrder[opts.Sort]
+	if !ok {
+		return nil, ErrTeamLeaderboardSort
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLeaderboardLimit
+	}
+	if limit > MaxLeaderboardLimit {
+		limit = MaxLeaderboardLimit
+	}
+
+	rows, err := s.db.Query(ctx, `
+		WITH games AS (
+			SELECT ts.session_id, lower(t.name) AS key, t.name, SUM(ts.score) AS total, COUNT(*) AS rounds
+			FROM guess_team_scores ts
+			JOIN guess_teams t ON t.id = ts.team_id
+			JOIN guess_sessions s ON s.id = ts.session_id
+			JOIN guess_rounds r ON r.session_id = ts.session_id AND r.round_number = ts.round_number
+			WHERE s.guild_id = $1 AND `+opts.Period.since("r.closed_at")+`
+			GROUP BY ts.session_id, t.id, t.name
+		)
+		SELECT name, total, average, wins, rounds, games
+		FROM (
+			SELECT (array_agg(g.name ORDER BY g.session_id DESC))[1] AS name,
+			       SUM(g.total)::bigint AS total,
+			       (SUM(g.total)::float8 / SUM(g.rounds)) AS average,
+			       COUNT(*) FILTER (WHERE g.total = (SELECT MAX(o.total) FROM games o WHERE o.session_id = g.session_id)) AS wins,
+			       SUM(g.rounds)::bigint AS rounds,
+			       COUNT(*) AS games
+			FROM games g
+			GROUP BY g.key
+		) t
+		ORDER BY `+order+`
+		LIMIT $2
+	`, guildID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TeamLeaderboardEntry
+	for rows.Next() {
+		var e TeamLeaderboardEntry
+		if err := rows.Scan(&e.Name, &e.TotalScore, &e.AverageScore, &e.Wins, &e.Rounds, &e.Games); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// BestRound is a player's highest scoring round.
+type BestRound struct {
+	SessionID      int64
+	Round          int
+	Score          int
+	DistanceMeters float64
+	GuessURL       string
+	AnswerURL      string
+	PlayedAt       time.Time
+}
+
+// PlayerStats summarizes a player's scored rounds in a guild.
+type PlayerStats struct {
+	UserID          string
+	Games           int
+	Rounds          int
+	TotalScore      int
+	AverageScore    float64
+	AverageDistance float64
+	Perfect         int
+	// Best is nil if the player has no scored rounds.
+	Best *BestRound
+}
+
+// PlayerStats returns the player's statistics over all scored rounds in the guild.
+func (s *Service) PlayerStats(ctx context.Context, guildID int64, userID string) (*PlayerStats, error) {
+	st := &PlayerStats{UserID: userID}
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT g.session_id), COUNT(*),
+		       COALESCE(SUM(g.score + g.time_bonus), 0),
+		       COALESCE(AVG(g.score + g.time_bonus), 0)::float8,
+		       COALESCE(AVG(g.distance_meters), 0)::float8,
+		       COUNT(*) FILTER (WHERE `+perfectFilter+`)
+		FROM guess_guesses g
+		JOIN guess_sessions s ON s.id = g.session_id
+		WHERE s.guild_id = $1 AND g.user_id = $2 AND g.score IS NOT NULL
+	`, guildID, userID).Scan(&st.Games, &st.Rounds, &st.TotalScore, &st.AverageScore, &st.AverageDistance, &st.Perfect)
+	if err != nil {
+		return nil, err
+	}
+	if st.Rounds == 0 {
+		return st, nil
+	}
+
+	var best BestRound
+	err = s.db.QueryRow(ctx, `
+		SELECT g.session_id, g.round_number, g.score + g.time_bonus, COALESCE(g.distance_meters, 0),
+		       g.guess_url, COALESCE(r.answer_url, ''), g.created_at
+		FROM guess_guesses g
+		JOIN guess_sessions s ON s.id = g.session_id
+		LEFT JOIN guess_rounds r ON r.session_id = g.session_id AND r.round_number = g.round_number
+		WHERE s.guild_id = $1 AND g.user_id = $2 AND g.score IS NOT NULL
+		ORDER BY g.score + g.time_bonus DESC, g.distance_meters ASC, g.created_at DESC
+		LIMIT 1
+	`, guildID, userID).Scan(&best.SessionID, &best.Round, &best.Score, &best.DistanceMeters, &best.GuessURL, &best.AnswerURL, &best.PlayedAt)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		st.Best = &best
+	}
+	return st, nil
+}
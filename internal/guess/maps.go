@@ -0,0 +1,202 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+)
+
+var (
+	ErrMapNotFound      = errors.New("マップが見つかりません")
+	ErrMapAlreadyExists = errors.New("同じ名前のマップが既に登録されています")
+	ErrBuiltinMap       = errors.New("組み込みのマップは変更できません")
+	ErrNotMapOwner      = errors.New("マップを削除できるのは保存した本人かサーバー管理者のみです")
+)
+
+// CustomMapName is the map name of a game whose bounds were given at start.
+const CustomMapName = "custom"
+
+// MapScale is the area a game is played in. Its diagonal sets how quickly scores fall with distance.
+type MapScale struct {
+	Name  string
+	Label string
+	// Bounds; all zero for the world map.
+	SWLat, SWLng, NELat, NELng float64
+}
+
+// World is the default map.
+var World = MapScale{Name: "world", Label: "世界"}
+
+// BuiltinMaps are the presets available in every guild.
+var BuiltinMaps = []MapScale{
+	World,
+	{Name: "japan", Label: "日本", SWLat: 24.0, SWLng: 122.9, NELat: 45.6, NELng: 146.0},
+	{Name: "kanto", Label: "関東", SWLat: 34.85, SWLng: 138.4, NELat: 37.2, NELng: 140.9},
+	{Name: "tokyo23", Label: "東京23区", SWLat: 35.52, SWLng: 139.56, NELat: 35.82, NELng: 139.92},
+}
+
+// NewMapScale normalizes two opposite corners into a bounding box. Boxes wider than 180° of
+// longitude are rejected: they usually mean a box across the antimeridian, which bounds
+// cannot represent.
+func NewMapScale(name string, lat1, lng1, lat2, lng2 float64) (MapScale, error) {
+	for _, lat := range []float64{lat1, lat2} {
+		if lat < -90 || lat > 90 || math.IsNaN(lat) {
+			return MapScale{}, fmt.Errorf("緯度は -90〜90 で指定してください")
+		}
+	}
+	for _, lng := range []float64{lng1, lng2} {
+		if lng < -180 || lng > 180 || math.IsNaN(lng) {
+			return MapScale{}, fmt.Errorf("経度は -180〜180 で指定してください")
+		}
+	}
+	if math.Abs(lng1-lng2) > 180 {
+		return MapScale{}, fmt.Errorf("経度180度（日付変更線）をまたぐ範囲や、経度で180度を超える範囲は指定できません")
+	}
+	m := MapScale{
+		Name:  name,
+		Label: name,
+		SWLat: math.Min(lat1, lat2), SWLng: math.Min(lng1, lng2),
+		NELat: math.Max(lat1, lat2), NELng: math.Max(lng1, lng2),
+	}
+	if m.MaxErrorDistance() < 1 {
+		return MapScale{}, fmt.Errorf("範囲が狭すぎます。異なる2点を指定してください")
+	}
+	return m, nil
+}
+
+// IsWorld reports whether m covers the whole world.
+func (m MapScale) IsWorld() bool {
+	return m.SWLat == 0 && m.SWLng == 0 && m.NELat == 0 && m.NELng == 0
+}
+
+// MaxErrorDistance is the scoring scale of the map in meters.
+func (m MapScale) MaxErrorDistance() float64 {
+	if m.IsWorld() {
+		return DefaultMaxErrorDistance
+	}
+	return geoscore.MaxErrorDistanceFromBounds(m.SWLat, m.SWLng, m.NELat, m.NELng)
+}
+
+// FormatMapScale describes a map for results, e.g. "関東 (スケール 342.56km)".
+func FormatMapScale(label string, maxErrorDistance float64) string {
+	return fmt.Sprintf("%s (スケール %s)", label, FormatDistance(maxErrorDistance))
+}
+
+func builtinMap(name string) (MapScale, bool) {
+	for _, m := range BuiltinMaps {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return MapScale{}, false
+}
+
+// MapLabel returns the display name of a map name stored on a session.
+func MapLabel(name string) string {
+	if m, ok := builtinMap(name); ok {
+		return m.Label
+	}
+	if name == CustomMapName {
+		return "カスタム"
+	}
+	return name
+}
+
+// ResolveMap finds a built-in map or one of the guild's saved presets by name.
+func (s *Service) ResolveMap(ctx context.Context, guildID int64, name string) (MapScale, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if m, ok := builtinMap(name); ok {
+		return m, nil
+	}
+	m := MapScale{Name: name, Label: name}
+	err := s.db.QueryRow(ctx, `
+		SELECT sw_lat, sw_lng, ne_lat, ne_lng FROM guess_map_presets WHERE guild_id = $1 AND name = $2
+	`, guildID, name).Scan(&m.SWLat, &m.SWLng, &m.NELat, &m.NELng)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return MapScale{}, ErrMapNotFound
+		}
+		return MapScale{}, err
+	}
+	return m, nil
+}
+
+// ListMaps returns the built-in maps followed by the guild's saved presets.
+func (s *Service) ListMaps(ctx context.Context, guildID int64) ([]MapScale, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT name, sw_lat, sw_lng, ne_lat, ne_lng FROM guess_map_presets WHERE guild_id = $1 ORDER BY name
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	maps := append([]MapScale(nil), BuiltinMaps...)
+	for rows.Next() {
+		var m MapScale
+		if err := rows.Scan(&m.Name, &m.SWLat, &m.SWLng, &m.NELat, &m.NELng); err != nil {
+			return nil, err
+		}
+		m.Label = m.Name
+		maps = append(maps, m)
+	}
+	return maps, rows.Err()
+}
+
+// SaveMap stores a custom map preset for the guild under m.Name.
+func (s *Service) SaveMap(ctx context.Context, guildID int64, createdBy string, m MapScale) error {
+	name := strings.ToLower(strings.TrimSpace(m.Name))
+	if _, ok := builtinMap(name); ok || name == CustomMapName {
+		return ErrBuiltinMap
+	}
+	if name == "" {
+		return fmt.Errorf("マップ名を指定してください")
+	}
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO guess_map_presets (guild_id, name, sw_lat, sw_lng, ne_lat, ne_lng, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, guildID, name, m.SWLat, m.SWLng, m.NELat, m.NELng, createdBy)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrMapAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteMap removes a custom map preset. Only the user who saved it can, unless manager is set.
+// Games already played on it keep their scale.
+func (s *Service) DeleteMap(ctx context.Context, guildID int64, name, userID string, manager bool) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := builtinMap(name); ok {
+		return ErrBuiltinMap
+	}
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM guess_map_presets WHERE guild_id = $1 AND name = $2 AND ($3 OR created_by = $4)
+	`, guildID, name, manager, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	var exists bool
+	if err := s.db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM guess_map_presets WHERE guild_id = $1 AND name = $2)`,
+		guildID, name,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrNotMapOwner
+	}
+	return ErrMapNotFound
+}
@@ -0,0 +1,42 @@
+package guess
+
+import "testing"
+
+func TestNewMapScaleNormalizesCorners(t *testing.T) {
+	m, err := NewMapScale("x", 35.8, 139.9, 35.5, 139.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SWLat != 35.5 || m.SWLng != 139.5 || m.NELat != 35.8 || m.NELng != 139.9 {
+		t.Fatalf("bounds = %+v", m)
+	}
+	if _, err := NewMapScale("x", 35, 139, 35, 139); err == nil {
+		t.Fatal("zero-area bounds accepted")
+	}
+	if _, err := NewMapScale("x", 91, 139, 35, 139); err == nil {
+		t.Fatal("latitude out of range accepted")
+	}
+	// Across the antimeridian: min/max would turn it into a box around most of the globe.
+	if _, err := NewMapScale("x", -20, 170, -10, -170); err == nil {
+		t.Fatal("bounds across the antimeridian accepted")
+	}
+}
+
+func TestBuiltinMapScales(t *testing.T) {
+	if got := World.MaxErrorDistance(); got != DefaultMaxErrorDistance {
+		t.Fatalf("world = %f", got)
+	}
+	// Smaller maps must have a smaller scale, so the same miss scores lower.
+	prev := DefaultMaxErrorDistance
+	for _, name := range []string{"japan", "kanto", "tokyo23"} {
+		m, ok := builtinMap(name)
+		if !ok {
+			t.Fatalf("%s missing", name)
+		}
+		d := m.MaxErrorDistance()
+		if d <= 0 || d >= prev {
+			t.Fatalf("%s scale %f not below %f", name, d, prev)
+		}
+		prev = d
+	}
+}
@@ -0,0 +1,501 @@
+package guess
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/geourl"
+)
+
+var (
+	ErrPoolLocationNotFound = errors.New("場所が見つかりません")
+	ErrNotPoolOwner         = errors.New("場所を削除できるのは登録した本人かサーバー管理者のみです")
+)
+
+// Limits of pool locations.
+const (
+	// MaxClueLength is the longest clue a pool location can have.
+	MaxClueLength = 500
+	// MaxTags and MaxTagLength bound the tags of a location.
+	MaxTags      = 10
+	MaxTagLength = 32
+	// MaxPoolImport is the most locations one import can add.
+	MaxPoolImport = 1000
+	// MaxPoolShortLinks is the most short links one import may need expanded; each is fetched
+	// over the network, so larger imports must give coordinates.
+	MaxPoolShortLinks = 50
+)
+
+// PoolLocation is a location a guild collected for games the bot runs, such as the daily
+// challenge. URL is the answer; Clue is what players are shown.
+type PoolLocation struct {
+	ID      int64
+	GuildID int64
+	Location
+	Clue       string
+	Tags       []string
+	AddedBy    string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// PoolEntry is a location to add to a pool. Input, when set, is a map URL or coordinates that
+// must be resolved into Location before the entry is added.
+type PoolEntry struct {
+	Location
+	Input string
+	Clue  string
+	Tags  []string
+}
+
+// ParseTags reads tags separated by commas or spaces. Tags are lower-cased and deduplicated.
+func ParseTags(s string) ([]string, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == '、' || r == ';'
+	})
+	tags := make([]string, 0, len(fields))
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		tag := strings.ToLower(f)
+		if len([]rune(tag)) > MaxTagLength {
+			return nil, fmt.Errorf("タグは %d 文字以内で指定してください: %s", MaxTagLength, f)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTags {
+		return nil, fmt.Errorf("タグは %d 個までです", MaxTags)
+	}
+	return tags, nil
+}
+
+// validate checks the clue and tags of a resolved entry.
+func (e PoolEntry) validate() error {
+	if strings.TrimSpace(e.Clue) == "" {
+		return errors.New("ヒントを指定してください")
+	}
+	if len([]rune(e.Clue)) > MaxClueLength {
+		return fmt.Errorf("ヒントは %d 文字以内で指定してください", MaxClueLength)
+	}
+	if len(e.Tags) > MaxTags {
+		return fmt.Errorf("タグは %d 個までです", MaxTags)
+	}
+	return nil
+}
+
+// ValidatePoolEntries checks the clues and tags of entries and that there are not too many.
+func ValidatePoolEntries(entries []PoolEntry) error {
+	if len(entries) == 0 {
+		return errors.New("場所が指定されていません")
+	}
+	if len(entries) > MaxPoolImport {
+		return fmt.Errorf("一度に登録できる場所は %d 件までです", MaxPoolImport)
+	}
+	for idx, e := range entries {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("%d 件目: %w", idx+1, err)
+		}
+	}
+	return nil
+}
+
+// AddPoolLocations adds resolved entries to the guild's pool and returns their IDs.
+func (s *Service) AddPoolLocations(ctx context.Context, guildID int64, addedBy string, entries []PoolEntry) ([]int64, error) {
+	if err := ValidatePoolEntries(entries); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]int64, len(entries))
+	for idx, e := range entries {
+		tags := e.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		err := tx.QueryRow(ctx, `
+			INSERT INTO guess_pool_locations (guild_id, lat, lng, url, clue, tags, added_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, guildID, e.Lat, e.Lng, e.URL, strings.TrimSpace(e.Clue), tags, addedBy).Scan(&ids[idx])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit(ctx)
+}
+
+// RemovePoolLocation deletes a location from the guild's pool. Only the user who added it can,
+// unless manager is set.
+func (s *Service) RemovePoolLocation(ctx context.Context, guildID, id int64, userID string, manager bool) error {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM guess_pool_locations WHERE guild_id = $1 AND id = $2 AND ($3 OR added_by = $4)
+	`, guildID, id, manager, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+	var exists bool
+	if err := s.db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM guess_pool_locations WHERE guild_id = $1 AND id = $2)`,
+		guildID, id,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrNotPoolOwner
+	}
+	return ErrPoolLocationNotFound
+}
+
+const poolColumns = `id, guild_id, lat, lng, url, clue, tags, added_by, created_at, last_used_at`
+
+func scanPoolLocation(row pgx.Row) (PoolLocation, error) {
+	var l PoolLocation
+	err := row.Scan(&l.ID, &l.GuildID, &l.Lat, &l.Lng, &l.URL, &l.Clue, &l.Tags, &l.AddedBy, &l.CreatedAt, &l.LastUsedAt)
+	return l, err
+}
+
+// PoolLocations lists the guild's pool, oldest first. A non-empty tag lists only the locations
+// with it.
+func (s *Service) PoolLocations(ctx context.Context, guildID int64, tag string) ([]PoolLocation, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+poolColumns+`
+		FROM guess_pool_locations
+		WHERE guild_id = $1 AND ($2 = '' OR $2 = ANY(tags))
+		ORDER BY id
+	`, guildID, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PoolLocation
+	for rows.Next() {
+		l, err := scanPoolLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+// TagCount is how many locations of a pool have a tag.
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// PoolTags counts the locations of the guild's pool by tag, most used first.
+func (s *Service) PoolTags(ctx context.Context, guildID int64) ([]TagCount, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT tag, COUNT(*)
+		FROM guess_pool_locations, unnest(tags) AS tag
+		WHERE guild_id = $1
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag
+	`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TagCount
+	for rows.Next() {
+		var tc TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, tc)
+	}
+	return out, rows.Err()
+}
+
+// drawPoolLocations draws n locations with a clue from the guild's pool, restricted to tag
+// unless it is empty, and marks them used. Locations never drawn come first, then those drawn
+// longest ago, so a location repeats only once every other one with the tag has been drawn.
+// It returns fewer than n locations if the pool has fewer.
+func drawPoolLocations(ctx context.Context, tx pgx.Tx, guildID int64, tag string, n int) ([]PoolLocation, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT `+poolColumns+`
+		FROM guess_pool_locations
+		WHERE guild_id = $1 AND clue <> '' AND ($2 = '' OR $2 = ANY(tags))
+		ORDER BY last_used_at NULLS FIRST, random()
+		LIMIT $3
+		FOR UPDATE
+	`, guildID, tag, n)
+	if err != nil {
+		return nil, err
+	}
+	var out []PoolLocation
+	for rows.Next() {
+		l, err := scanPoolLocation(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(out))
+	for idx, l := range out {
+		ids[idx] = l.ID
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE guess_pool_locations SET last_used_at = CURRENT_TIMESTAMP WHERE id = ANY($1)`,
+		ids,
+	); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sealPoolLocations seals the drawn locations as the answers of the rounds of sess in order,
+// with their clues. Whoever added a location cannot guess its round.
+func sealPoolLocations(ctx context.Context, tx pgx.Tx, sess *Session, drawn []PoolLocation) error {
+	for idx, l := range drawn {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO guess_sealed_answers (channel_id, organizer_id, session_id, round_number, answer_lat, answer_lng, answer_url, clue)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, sess.ChannelID, l.AddedBy, sess.ID, idx+1, l.Lat, l.Lng, l.URL, l.Clue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poolShortage reports that a game needs more locations than the pool has for tag.
+func poolShortage(tag string, n int) error {
+	if tag == "" {
+		return fmt.Errorf("場所プールにヒント付きの場所が %d 件しかありません", n)
+	}
+	return fmt.Errorf("タグ「%s」のヒント付きの場所が %d 件しかありません", tag, n)
+}
+
+// CurrentClue returns the clue of the current round of the channel's active session, or "" if
+// its answer was not drawn from the pool.
+func (s *Service) CurrentClue(ctx context.Context, channelID string) (string, error) {
+	sess, err := s.GetActiveSession(ctx, channelID)
+	if err != nil {
+		return "", err
+	}
+	return roundClue(ctx, s.db, sess.ID, sess.CurrentRound)
+}
+
+// roundClue returns the clue of a round whose answer was drawn from the pool, or "".
+func roundClue(ctx context.Context, q rowQuerier, sessionID int64, round int) (string, error) {
+	var clue string
+	err := q.QueryRow(ctx,
+		`SELECT clue FROM guess_sealed_answers WHERE session_id = $1 AND round_number = $2`,
+		sessionID, round,
+	).Scan(&clue)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	return clue, err
+}
+
+// ParsePoolCSV reads pool entries from CSV with a header row. The columns are url (a map URL or
+// coordinates), or lat and lng, and clue and tags; tags are separated by spaces or semicolons.
+// Other columns are ignored.
+func ParsePoolCSV(r io.Reader) ([]PoolEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty CSV")
+	}
+	if err != nil {
+		return nil, err
+	}
+	col := make(map[string]int)
+	for idx, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "lon", "long", "longitude":
+			name = "lng"
+		case "latitude":
+			name = "lat"
+		}
+		col[name] = idx
+	}
+	_, hasURL := col["url"]
+	_, hasLat := col["lat"]
+	_, hasLng := col["lng"]
+	if !hasURL && !(hasLat && hasLng) {
+		return nil, errors.New("CSV needs a url column, or lat and lng columns")
+	}
+
+	var out []PoolEntry
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if idx, ok := col[name]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+		e := PoolEntry{Input: field("url"), Clue: field("clue")}
+		if lat, lng := field("lat"), field("lng"); lat != "" || lng != "" {
+			if e.Lat, err = strconv.ParseFloat(lat, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid lat %q", line, lat)
+			}
+			if e.Lng, err = strconv.ParseFloat(lng, 64); err != nil {
+				return nil, fmt.Errorf("line %d: invalid lng %q", line, lng)
+			}
+			if err := checkCoords(e.Lat, e.Lng); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			e.URL, e.Input = e.Input, ""
+		} else if e.Input == "" {
+			return nil, fmt.Errorf("line %d: no location", line)
+		}
+		if e.Tags, err = ParseTags(field("tags")); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		out = append(out, e)
+		if len(out) > MaxPoolImport {
+			return nil, fmt.Errorf("more than %d locations", MaxPoolImport)
+		}
+	}
+	return out, nil
+}
+
+// ParsePoolGeoJSON reads pool entries from the Point features of a GeoJSON feature collection, or
+// a single feature. The properties url, clue and tags (an array or a string) are read.
+func ParsePoolGeoJSON(r io.Reader) ([]PoolEntry, error) {
+	type feature struct {
+		Type     string `json:"type"`
+		Geometry *struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			URL  string          `json:"url"`
+			Clue string          `json:"clue"`
+			Tags json.RawMessage `json:"tags"`
+		} `json:"properties"`
+	}
+	var doc struct {
+		feature
+		Features []feature `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	features := doc.Features
+	switch doc.Type {
+	case "FeatureCollection":
+	case "Feature":
+		features = []feature{doc.feature}
+	default:
+		return nil, fmt.Errorf("GeoJSON must be a FeatureCollection or a Feature, not %q", doc.Type)
+	}
+	if len(features) > MaxPoolImport {
+		return nil, fmt.Errorf("more than %d locations", MaxPoolImport)
+	}
+
+	out := make([]PoolEntry, 0, len(features))
+	for idx, f := range features {
+		if f.Geometry == nil || f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: not a Point", idx+1)
+		}
+		e := PoolEntry{
+			Location: Location{Lat: f.Geometry.Coordinates[1], Lng: f.Geometry.Coordinates[0], URL: f.Properties.URL},
+			Clue:     strings.TrimSpace(f.Properties.Clue),
+		}
+		if err := checkCoords(e.Lat, e.Lng); err != nil {
+			return nil, fmt.Errorf("feature %d: %w", idx+1, err)
+		}
+		tags, err := geoJSONTags(f.Properties.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", idx+1, err)
+		}
+		if e.Tags, err = ParseTags(tags); err != nil {
+			return nil, fmt.Errorf("feature %d: %w", idx+1, err)
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// geoJSONTags joins the tags property, given as an array of strings or a single string.
+func geoJSONTags(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return strings.Join(list, ","), nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", errors.New("tags must be a string or an array of strings")
+	}
+	return s, nil
+}
+
+// ResolvePoolEntries resolves the Input of entries into their locations, expanding short links,
+// and gives entries without a URL one that points at their coordinates. It fails before fetching
+// anything if more than MaxPoolShortLinks entries are short links.
+func ResolvePoolEntries(ctx context.Context, entries []PoolEntry) error {
+	fetches := 0
+	for _, e := range entries {
+		if e.Input != "" && geourl.NeedsFetch(e.Input) {
+			fetches++
+		}
+	}
+	if fetches > MaxPoolShortLinks {
+		return fmt.Errorf("短縮リンクは一度に %d 件まで展開できます（%d 件あります）。それ以上は座標か座標を含む URL で指定してください", MaxPoolShortLinks, fetches)
+	}
+	for idx := range entries {
+		e := &entries[idx]
+		if e.Input != "" {
+			lat, lng, finalURL, err := geourl.DefaultExpander().ExpandAndExtractCoords(ctx, e.Input)
+			if err != nil {
+				return fmt.Errorf("%d 件目の座標の抽出に失敗しました: %w", idx+1, err)
+			}
+			e.Location = Location{Lat: lat, Lng: lng, URL: finalURL}
+			e.Input = ""
+		}
+		if e.URL == "" {
+			e.URL = geourl.CoordsURL(e.Lat, e.Lng)
+		}
+	}
+	return nil
+}
+
+func checkCoords(lat, lng float64) error {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return fmt.Errorf("coordinates out of range: %v,%v", lat, lng)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/susu3304/nkmzbot/internal/geourl"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestParseTags(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                  "",
+		"japan":             "japan",
+		"Japan, urban hard": "japan,urban,hard",
+		"japan、hard;JAPAN":  "japan,hard",
+	} {
+		got, err := ParseTags(in)
+		if err != nil || strings.Join(got, ",") != want {
+			t.Errorf("ParseTags(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseTags(strings.Repeat("x", MaxTagLength+1)); err == nil {
+		t.Error("ParseTags accepted a tag that is too long")
+	}
+	if _, err := ParseTags("a b c d e f g h i j k"); err == nil {
+		t.Error("ParseTags accepted too many tags")
+	}
+}
+
+func TestParsePoolCSV(t *testing.T) {
+	entries, err := ParsePoolCSV(strings.NewReader("\ufeffLatitude,Longitude,clue,tags,note\n" +
+		"35.681,139.767,photo 1,japan;urban,ignored\n" +
+		"-33.857,151.215,\"photo, 2\",,\n"))
+	if err != nil {
+		t.Fatalf("ParsePoolCSV: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Lat != 35.681 || e.Lng != 139.767 || e.Input != "" || e.Clue != "photo 1" || strings.Join(e.Tags, ",") != "japan,urban" {
+		t.Errorf("entry 1 = %+v", e)
+	}
+	if e := entries[1]; e.Lat != -33.857 || e.Clue != "photo, 2" || len(e.Tags) != 0 {
+		t.Errorf("entry 2 = %+v", e)
+	}
+
+	entries, err = ParsePoolCSV(strings.NewReader("url,clue\nhttps://maps.app.goo.gl/x,photo\n"))
+	if err != nil || len(entries) != 1 || entries[0].Input != "https://maps.app.goo.gl/x" {
+		t.Errorf("url CSV = %+v, %v", entries, err)
+	}
+
+	for _, in := range []string{
+		"",
+		"clue,tags\nphoto,japan\n",
+		"lat,lng,clue\n91,0,photo\n",
+		"lat,lng,clue\nabc,0,photo\n",
+		"url,clue\n,photo\n",
+	} {
+		if _, err := ParsePoolCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ParsePoolCSV(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestParsePoolGeoJSON(t *testing.T) {
+	entries, err := ParsePoolGeoJSON(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [139.767, 35.681]},
+		 "properties": {"clue": " photo 1 ", "tags": ["Japan", "urban"], "url": "https://example.com/1"}},
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [151.215, -33.857]},
+		 "properties": {"clue": "photo 2", "tags": "australia"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParsePoolGeoJSON: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.Lat != 35.681 || e.Lng != 139.767 || e.URL != "https://example.com/1" || e.Clue != "photo 1" || strings.Join(e.Tags, ",") != "japan,urban" {
+		t.Errorf("entry 1 = %+v", e)
+	}
+	if e := entries[1]; e.Lat != -33.857 || e.URL != "" || strings.Join(e.Tags, ",") != "australia" {
+		t.Errorf("entry 2 = %+v", e)
+	}
+
+	entries, err = ParsePoolGeoJSON(strings.NewReader(`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"clue": "c"}}`))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("single feature = %+v, %v", entries, err)
+	}
+
+	for _, in := range []string{
+		`{"type": "Point", "coordinates": [0, 0]}`,
+		`{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [[0, 0], [1, 1]]}}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}, "properties": {"tags": 1}}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [200, 0]}}`,
+		`not json`,
+	} {
+		if _, err := ParsePoolGeoJSON(strings.NewReader(in)); err == nil {
+			t.Errorf("ParsePoolGeoJSON(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestResolvePoolEntriesLimitsShortLinks(t *testing.T) {
+	requests := 0
+	prev := geourl.DefaultExpander()
+	geourl.SetDefaultExpander(geourl.NewExpander(geourl.Options{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		requests++
+		return nil, errors.New("offline")
+	})}))
+	t.Cleanup(func() { geourl.SetDefaultExpander(prev) })
+
+	var entries []PoolEntry
+	for n := 0; n < MaxPoolShortLinks; n++ {
+		entries = append(entries,
+			PoolEntry{Input: fmt.Sprintf("https://maps.app.goo.gl/link%d", n), Clue: "photo"},
+			PoolEntry{Input: "35.681236,139.767125", Clue: "photo"},
+		)
+	}
+	entries = append(entries, PoolEntry{Input: "https://maps.app.goo.gl/one-too-many", Clue: "photo"})
+	if err := ResolvePoolEntries(context.Background(), entries); err == nil || !strings.Contains(err.Error(), "座標") {
+		t.Errorf("ResolvePoolEntries = %v, want an error asking for coordinates", err)
+	}
+	if requests != 0 {
+		t.Errorf("%d short links were fetched before the limit was checked", requests)
+	}
+
+	coords := []PoolEntry{{Input: "35.681236,139.767125", Clue: "photo"}}
+	if err := ResolvePoolEntries(context.Background(), coords); err != nil || coords[0].Lat != 35.681236 || coords[0].URL == "" {
+		t.Errorf("coordinates = %+v, %v", coords[0], err)
+	}
+}
@@ -0,0 +1,352 @@
+package guess
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/glicko"
+)
+
+// ProvisionalRD is the rating deviation above which a rating is shown as provisional.
+const ProvisionalRD = 110.0
+
+// RatingChange is a player's rating before and after a rated round.
+type RatingChange struct {
+	UserID    string
+	SessionID int64
+	Round     int
+	Before    float64
+	RDBefore  float64
+	After     float64
+	RD        float64
+	At        time.Time
+}
+
+// ratingState is a player's stored rating while rounds are being rated.
+type ratingState struct {
+	glicko.Rating
+	Rounds    int
+	UpdatedAt time.Time
+}
+
+// rateScores rates one round from the scores of its players: every pair of players is a game
+// won by the higher score. states holds the players' ratings before the round, missing for new
+// players, and is updated in place. It returns the changes in order of user ID.
+func rateScores(states map[string]*ratingState, scores map[string]int, at time.Time) []RatingChange {
+	if len(scores) < 2 {
+		return nil
+	}
+	users := make([]string, 0, len(scores))
+	for userID := range scores {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+
+	before := make(map[string]glicko.Rating, len(users))
+	for _, userID := range users {
+		r := glicko.Default()
+		if st := states[userID]; st != nil {
+			r = st.Rating.Decay(at.Sub(st.UpdatedAt))
+		}
+		before[userID] = r
+	}
+
+	changes := make([]RatingChange, 0, len(users))
+	for _, userID := range users {
+		outcomes := make([]glicko.Outcome, 0, len(users)-1)
+		for _, other := range users {
+			if other == userID {
+				continue
+			}
+			o := glicko.Outcome{Opponent: before[other], Score: 0.5}
+			switch {
+			case scores[userID] > scores[other]:
+				o.Score = 1
+			case scores[userID] < scores[other]:
+				o.Score = 0
+			}
+			outcomes = append(outcomes, o)
+		}
+		after := before[userID].Update(outcomes)
+		st := states[userID]
+		if st == nil {
+			st = &ratingState{}
+			states[userID] = st
+		}
+		st.Rating, st.UpdatedAt = after, at
+		st.Rounds++
+		changes = append(changes, RatingChange{
+			UserID:   userID,
+			Before:   before[userID].Rating,
+			RDBefore: before[userID].RD,
+			After:    after.Rating,
+			RD:       after.RD,
+			At:       at,
+		})
+	}
+	return changes
+}
+
+// rateRound updates the guild ratings of the players of the session's current round, which must
+// be closed and scored.
+func rateRound(ctx context.Context, tx pgx.Tx, sess *Session, results []GuessResult) ([]RatingChange, error) {
+	if len(results) < 2 {
+		return nil, nil
+	}
+	var at time.Time
+	if err := tx.QueryRow(ctx,
+		`SELECT COALESCE(closed_at, LOCALTIMESTAMP) FROM guess_rounds WHERE session_id = $1 AND round_number = $2`,
+		sess.ID, sess.CurrentRound,
+	).Scan(&at); err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]int, len(results))
+	users := make([]string, 0, len(results))
+	for _, r := range results {
+		scores[r.UserID] = r.Score
+		users = append(users, r.UserID)
+	}
+	sort.Strings(users)
+	// FOR UPDATE only locks existing rows, so new players get a default rating to lock first;
+	// otherwise two rounds could rate the same new player at once. Rows are locked in order of
+	// user ID so that concurrent rounds cannot deadlock.
+	def := glicko.Default()
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO guess_ratings (guild_id, user_id, rating, rd, rounds, updated_at)
+		SELECT $1, user_id, $3, $4, 0, $5 FROM unnest($2::text[]) AS user_id ORDER BY user_id
+		ON CONFLICT (guild_id, user_id) DO NOTHING
+	`, sess.GuildID, users, def.Rating, def.RD, at); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT user_id, rating, rd, rounds, updated_at
+		FROM guess_ratings
+		WHERE guild_id = $1 AND user_id = ANY($2)
+		ORDER BY user_id
+		FOR UPDATE
+	`, sess.GuildID, users)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]*ratingState)
+	for rows.Next() {
+		var userID string
+		var st ratingState
+		if err := rows.Scan(&userID, &st.Rating.Rating, &st.RD, &st.Rounds, &st.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		states[userID] = &st
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	changes := rateScores(states, scores, at)
+	for idx := range changes {
+		c := &changes[idx]
+		c.SessionID, c.Round = sess.ID, sess.CurrentRound
+		st := states[c.UserID]
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO guess_ratings (guild_id, user_id, rating, rd, rounds, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (guild_id, user_id)
+			DO UPDATE SET rating = EXCLUDED.rating, rd = EXCLUDED.rd, rounds = EXCLUDED.rounds, updated_at = EXCLUDED.updated_at
+		`, sess.GuildID, c.UserID, st.Rating.Rating, st.RD, st.Rounds, st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := insertRatingHistory(ctx, tx, sess.GuildID, c); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func insertRatingHistory(ctx context.Context, tx pgx.Tx, guildID int64, c *RatingChange) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO guess_rating_history (guild_id, user_id, session_id, round_number, rating_before, rd_before, rating, rd, rated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, guildID, c.UserID, c.SessionID, c.Round, c.Before, c.RDBefore, c.After, c.RD, c.At)
+	return err
+}
+
+// RecomputeRatings rebuilds the guild's ratings and rating history by replaying every scored
+// round in guess_guesses in the order the rounds closed. It returns how many players were rated
+// and from how many rounds.
+func (s *Service) RecomputeRatings(ctx context.Context, guildID int64) (players, rounds int, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Hold off rounds closing meanwhile, so none is rated against the ratings being replaced.
+	if _, err := tx.Exec(ctx, `LOCK TABLE guess_ratings IN EXCLUSIVE MODE`); err != nil {
+		return 0, 0, err
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT g.session_id, g.round_number, COALESCE(r.closed_at, r.opened_at, s.created_at, LOCALTIMESTAMP) AS at,
+		       g.user_id, g.score
+		FROM guess_guesses g
+		JOIN guess_sessions s ON s.id = g.session_id
+		LEFT JOIN guess_rounds r ON r.session_id = g.session_id AND r.round_number = g.round_number
+		WHERE s.guild_id = $1 AND g.score IS NOT NULL
+		ORDER BY at, g.session_id, g.round_number
+	`, guildID)
+	if err != nil {
+		return 0, 0, err
+	}
+	type roundKey struct {
+		sessionID int64
+		round     int
+	}
+	states := make(map[string]*ratingState)
+	var changes []RatingChange
+	var current roundKey
+	var currentAt time.Time
+	scores := make(map[string]int)
+	flush := func() {
+		for _, c := range rateScores(states, scores, currentAt) {
+			c.SessionID, c.Round = current.sessionID, current.round
+			changes = append(changes, c)
+		}
+		if len(scores) >= 2 {
+			rounds++
+		}
+		scores = make(map[string]int)
+	}
+	for rows.Next() {
+		var key roundKey
+		var at time.Time
+		var userID string
+		var score int
+		if err := rows.Scan(&key.sessionID, &key.round, &at, &userID, &score); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if key != current {
+			flush()
+			current, currentAt = key, at
+		}
+		scores[userID] = score
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	flush()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM guess_rating_history WHERE guild_id = $1`, guildID); err != nil {
+		return 0, 0, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM guess_ratings WHERE guild_id = $1`, guildID); err != nil {
+		return 0, 0, err
+	}
+	for userID, st := range states {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO guess_ratings (guild_id, user_id, rating, rd, rounds, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, guildID, userID, st.Rating.Rating, st.RD, st.Rounds, st.UpdatedAt); err != nil {
+			return 0, 0, err
+		}
+	}
+	if _, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"guess_rating_history"},
+		[]string{"guild_id", "user_id", "session_id", "round_number", "rating_before", "rd_before", "rating", "rd", "rated_at"},
+		pgx.CopyFromSlice(len(changes), func(idx int) ([]any, error) {
+			c := changes[idx]
+			return []any{guildID, c.UserID, c.SessionID, c.Round, c.Before, c.RDBefore, c.After, c.RD, c.At}, nil
+		}),
+	); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return len(states), rounds, nil
+}
+
+// PlayerRating is a player's rating in a guild.
+type PlayerRating struct {
+	UserID string
+	// Rating has its deviation grown for the time since the player last played.
+	glicko.Rating
+	Rounds int
+	// Rank is the player's position by rating among the guild's Players rated players;
+	// it is 0 for an unrated player.
+	Rank    int
+	Players int
+	// History are the latest changes, newest first.
+	History []RatingChange
+}
+
+// Provisional reports whether the rating is still too uncertain to rank the player.
+func (p *PlayerRating) Provisional() bool {
+	return p.RD > ProvisionalRD
+}
+
+// ratingHistoryLimit is how many recent changes PlayerRating returns.
+const ratingHistoryLimit = 5
+
+// PlayerRating returns the player's rating in the guild; unrated players get the default rating.
+func (s *Service) PlayerRating(ctx context.Context, guildID int64, userID string) (*PlayerRating, error) {
+	p := &PlayerRating{UserID: userID, Rating: glicko.Default()}
+	var awaySeconds float64
+	err := s.db.QueryRow(ctx, `
+		SELECT rating, rd, rounds, GREATEST(EXTRACT(EPOCH FROM (LOCALTIMESTAMP - updated_at)), 0)::float8
+		FROM guess_ratings WHERE guild_id = $1 AND user_id = $2
+	`, guildID, userID).Scan(&p.Rating.Rating, &p.RD, &p.Rounds, &awaySeconds)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	rated := err == nil
+	p.Rating = p.Rating.Decay(time.Duration(awaySeconds * float64(time.Second)))
+
+	if err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE rating > $2) + 1, COUNT(*)
+		FROM guess_ratings WHERE guild_id = $1
+	`, guildID, p.Rating.Rating).Scan(&p.Rank, &p.Players); err != nil {
+		return nil, err
+	}
+	if !rated {
+		p.Rank = 0
+		return p, nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT session_id, round_number, rating_before, rd_before, rating, rd, rated_at
+		FROM guess_rating_history
+		WHERE guild_id = $1 AND user_id = $2
+		ORDER BY id DESC
+		LIMIT $3
+	`, guildID, userID, ratingHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := RatingChange{UserID: userID}
+		if err := rows.Scan(&c.SessionID, &c.Round, &c.Before, &c.RDBefore, &c.After, &c.RD, &c.At); err != nil {
+			return nil, err
+		}
+		p.History = append(p.History, c)
+	}
+	return p, rows.Err()
+}
+
+// userRating returns the stored rating of a player, or the default for an unrated one.
+func userRating(ctx context.Context, tx pgx.Tx, guildID int64, userID string) (float64, error) {
+	rating := glicko.DefaultRating
+	err := tx.QueryRow(ctx,
+		`SELECT rating FROM guess_ratings WHERE guild_id = $1 AND user_id = $2`,
+		guildID, userID,
+	).Scan(&rating)
+	if err != nil && err != pgx.ErrNoRows {
+		return 0, err
+	}
+	return rating, nil
+}
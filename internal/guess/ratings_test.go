@@ -0,0 +1,40 @@
+package guess
+
+import (
+	"testing"
+	"time"
+
+	"github.com/susu3304/nkmzbot/internal/glicko"
+)
+
+func TestRateScores(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	states := map[string]*ratingState{
+		"b": {Rating: glicko.Rating{Rating: 1600, RD: 80}, Rounds: 10, UpdatedAt: at.Add(-24 * time.Hour)},
+	}
+	if got := rateScores(states, map[string]int{"a": 4000}, at); got != nil {
+		t.Fatalf("single player rated: %+v", got)
+	}
+
+	changes := rateScores(states, map[string]int{"a": 4000, "b": 3000, "c": 3000}, at)
+	if len(changes) != 3 || changes[0].UserID != "a" || changes[1].UserID != "b" || changes[2].UserID != "c" {
+		t.Fatalf("changes = %+v", changes)
+	}
+	a, b, c := changes[0], changes[1], changes[2]
+	if a.Before != glicko.DefaultRating || a.After <= a.Before {
+		t.Errorf("winner a = %+v", a)
+	}
+	if b.RDBefore <= 80 || b.After >= b.Before {
+		t.Errorf("b = %+v, want decayed RD and a lower rating", b)
+	}
+	// c lost to a, so it cannot gain more than a did.
+	if c.After-c.Before >= a.After-a.Before {
+		t.Errorf("c gained %.1f, more than the winner's %.1f", c.After-c.Before, a.After-a.Before)
+	}
+	if st := states["a"]; st.Rounds != 1 || st.Rating.Rating != a.After || !st.UpdatedAt.Equal(at) {
+		t.Errorf("state a = %+v", st)
+	}
+	if states["b"].Rounds != 11 {
+		t.Errorf("state b rounds = %d", states["b"].Rounds)
+	}
+}
@@ -0,0 +1,128 @@
+package guess
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/susu3304/nkmzbot/internal/georegion"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+)
+
+// Mode is how a game scores guesses.
+type Mode string
+
+const (
+	// ModeDistance scores by distance from the answer.
+	ModeDistance Mode = "distance"
+	// ModeRegion scores by whether the guess is in the answer's country, or on maps of
+	// Japan its prefecture.
+	ModeRegion Mode = "region"
+	// ModeStreak is region mode that goes on until someone misses.
+	ModeStreak Mode = "streak"
+	// ModeDuel is a head-to-head game started with StartDuel; it scores by distance.
+	ModeDuel Mode = "duel"
+)
+
+// MaxStreakRounds ends a streak game that nobody has missed yet.
+const MaxStreakRounds = 100
+
+// ParseMode validates a mode name; empty means distance.
+func ParseMode(v string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(v))); m {
+	case "":
+		return ModeDistance, nil
+	case ModeDistance, ModeRegion, ModeStreak:
+		return m, nil
+	}
+	return "", fmt.Errorf("モードは distance, region, streak のいずれかで指定してください")
+}
+
+// ByRegion reports whether m scores by region instead of distance.
+func (m Mode) ByRegion() bool {
+	return m == ModeRegion || m == ModeStreak
+}
+
+// Label is the Japanese name of m shown in replies; byPrefecture is the session's setting.
+func (m Mode) Label(byPrefecture bool) string {
+	unit := "国"
+	if byPrefecture {
+		unit = "都道府県"
+	}
+	switch m {
+	case ModeRegion:
+		return unit + "当て"
+	case ModeStreak:
+		return unit + "ストリーク"
+	case ModeDuel:
+		return "デュエル"
+	}
+	return "距離"
+}
+
+// FormatRound shows the round number of a game, out of the total unless the game goes on
+// until someone loses (streaks and duels).
+func FormatRound(round, total int, mode Mode) string {
+	if mode == ModeStreak || mode == ModeDuel {
+		return fmt.Sprintf("ラウンド %d", round)
+	}
+	return fmt.Sprintf("ラウンド %d/%d", round, total)
+}
+
+// FormatPlace shows a region the way results do, e.g. "東京都（日本）", or 海上 for nil.
+func FormatPlace(p *georegion.Place) string {
+	if p == nil {
+		return "海上"
+	}
+	if p.Prefecture != nil {
+		return p.Prefecture.Name + "（" + p.Country.Name + "）"
+	}
+	return p.Country.Name
+}
+
+// InJapan reports whether the map is centered in Japan, where region modes judge by prefecture.
+func (m MapScale) InJapan() bool {
+	if m.IsWorld() {
+		return false
+	}
+	p, ok := georegion.Locate((m.SWLat+m.NELat)/2, (m.SWLng+m.NELng)/2)
+	return ok && p.Country.Code == georegion.Japan
+}
+
+// locate returns where a point is, or nil in the open sea.
+func locate(lat, lng float64) *georegion.Place {
+	p, ok := georegion.Locate(lat, lng)
+	if !ok {
+		return nil
+	}
+	return &p
+}
+
+// regionCodes returns the country and prefecture codes of p, empty where unknown.
+func regionCodes(p *georegion.Place) (country, prefecture string) {
+	if p == nil {
+		return "", ""
+	}
+	if p.Prefecture != nil {
+		prefecture = p.Prefecture.Code
+	}
+	return p.Country.Code, prefecture
+}
+
+// regionCode is the most specific code of p that is stored with answers and guesses.
+func regionCode(p *georegion.Place) *string {
+	if p == nil {
+		return nil
+	}
+	code := p.Country.Code
+	if p.Prefecture != nil {
+		code = p.Prefecture.Code
+	}
+	return &code
+}
+
+// matchRegion grades a guess at guess against the answer at answer.
+func matchRegion(answer, guess *georegion.Place, byPrefecture bool) geoscore.RegionMatch {
+	ac, ap := regionCodes(answer)
+	gc, gp := regionCodes(guess)
+	return geoscore.MatchRegion(ac, ap, gc, gp, byPrefecture)
+}
@@ -0,0 +1,54 @@
+package guess
+
+import (
+	"testing"
+
+	"github.com/susu3304/nkmzbot/internal/georegion"
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+)
+
+func TestInJapan(t *testing.T) {
+	if World.InJapan() {
+		t.Fatal("world map judged by prefecture")
+	}
+	for _, name := range []string{"japan", "kanto", "tokyo23"} {
+		m, _ := builtinMap(name)
+		if !m.InJapan() {
+			t.Errorf("%s not in Japan", name)
+		}
+	}
+}
+
+func TestMatchRegion(t *testing.T) {
+	tokyo := locate(35.681236, 139.767125)
+	chiba := locate(35.6074, 140.1065)
+	seoul := locate(37.5665, 126.978)
+	for _, tt := range []struct {
+		name         string
+		guess        *georegion.Place
+		byPrefecture bool
+		want         geoscore.RegionMatch
+	}{
+		{"same prefecture", locate(35.6895, 139.6917), true, geoscore.RegionExact},
+		{"other prefecture", chiba, true, geoscore.RegionCountry},
+		{"other prefecture by country", chiba, false, geoscore.RegionExact},
+		{"other country", seoul, true, geoscore.RegionMiss},
+		{"sea", locate(30, 150), true, geoscore.RegionMiss},
+	} {
+		if got := matchRegion(tokyo, tt.guess, tt.byPrefecture); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if m, err := ParseMode(""); err != nil || m != ModeDistance {
+		t.Fatalf("empty = %q, %v", m, err)
+	}
+	if m, err := ParseMode(" Streak "); err != nil || m != ModeStreak {
+		t.Fatalf("streak = %q, %v", m, err)
+	}
+	if _, err := ParseMode("country"); err == nil {
+		t.Fatal("unknown mode accepted")
+	}
+}
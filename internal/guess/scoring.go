@@ -0,0 +1,60 @@
+package guess
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/susu3304/nkmzbot/internal/geoscore"
+)
+
+// ParseScorer returns the scorer of a game from the names users give; see geoscore.ParseScorer.
+func ParseScorer(kind, params string) (geoscore.Scorer, error) {
+	s, err := geoscore.ParseScorer(geoscore.ScorerKind(strings.ToLower(strings.TrimSpace(kind))), params)
+	if err != nil {
+		return nil, fmt.Errorf("採点方法の指定が正しくありません: %w", err)
+	}
+	return s, nil
+}
+
+// ScorerLabel is the display name of a scoring model.
+func ScorerLabel(kind geoscore.ScorerKind) string {
+	switch kind {
+	case geoscore.ScorerExponential:
+		return "指数（GeoGuessr方式）"
+	case geoscore.ScorerLinear:
+		return "直線"
+	case geoscore.ScorerStepped:
+		return "段階"
+	case geoscore.ScorerLog:
+		return "対数"
+	}
+	return string(kind)
+}
+
+// FormatScorer describes a scorer and its parameters for results, e.g. "直線 (zero=0.5)".
+// Stepped scorers list their bands.
+func FormatScorer(s geoscore.Scorer) string {
+	if s == nil {
+		s = geoscore.DefaultScorer
+	}
+	label := ScorerLabel(s.Kind())
+	if stepped, ok := s.(geoscore.Stepped); ok {
+		bands := make([]string, len(stepped.Bands))
+		for idx, b := range stepped.Bands {
+			bands[idx] = fmt.Sprintf("〜%s: %d点", formatBandDistance(b.MaxMeters), b.Score)
+		}
+		return label + " (" + strings.Join(bands, " / ") + ")"
+	}
+	if p := s.Params(); p != "" {
+		return label + " (" + p + ")"
+	}
+	return label
+}
+
+// formatBandDistance writes a band limit without FormatDistance's fixed decimals.
+func formatBandDistance(meters float64) string {
+	if meters >= 1000 {
+		return fmt.Sprintf("%gkm", meters/1000)
+	}
+	return fmt.Sprintf("%gm", meters)
+}
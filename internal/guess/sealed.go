@@ -0,0 +1,165 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNotOrganizer = errors.New("主催者のみが正解を登録できます")
+	ErrOwnRound     = errors.New("自分が正解を登録したラウンドには推測できません")
+)
+
+// Location is a point on the map with the URL it was read from.
+type Location struct {
+	Lat float64
+	Lng float64
+	URL string
+}
+
+// SealedAnswer is an answer submitted before its round closes. RoundNumber is nil while the
+// answer is queued for the organizer's next game.
+type SealedAnswer struct {
+	ID          int64
+	ChannelID   string
+	OrganizerID string
+	SessionID   *int64
+	RoundNumber *int
+	Location
+	CreatedAt time.Time
+}
+
+// SealAnswers stores answers privately for organizerID. If the channel has an active game they
+// fill its open and upcoming rounds that have no answer yet; the rest are queued for the next
+// game the organizer starts in the channel.
+func (s *Service) SealAnswers(ctx context.Context, channelID, organizerID string, answers []Location) (attached, queued int, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := scanSession(tx.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM guess_sessions WHERE channel_id = $1 AND status = 'active' FOR UPDATE`,
+		channelID,
+	))
+	if err != nil && err != ErrNoActiveSession {
+		return 0, 0, err
+	}
+	if sess != nil && sess.Mode == ModeDuel {
+		return 0, 0, ErrDuelSealed
+	}
+	if sess != nil && sess.OrganizerID != organizerID {
+		return 0, 0, ErrNotOrganizer
+	}
+
+	for _, a := range answers {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO guess_sealed_answers (channel_id, organizer_id, answer_lat, answer_lng, answer_url)
+			VALUES ($1, $2, $3, $4, $5)
+		`, channelID, organizerID, a.Lat, a.Lng, a.URL)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if sess != nil {
+		if attached, err = attachQueuedAnswers(ctx, tx, sess); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return attached, len(answers) - attached, nil
+}
+
+// attachQueuedAnswers assigns the organizer's queued answers, oldest first, to the rounds of
+// sess from the current one onward that do not have a sealed answer yet.
+func attachQueuedAnswers(ctx context.Context, tx pgx.Tx, sess *Session) (int, error) {
+	tag, err := tx.Exec(ctx, `
+		WITH free AS (
+			SELECT r AS round_number, row_number() OVER (ORDER BY r) AS n
+			FROM generate_series($2::int, $3::int) AS r
+			WHERE NOT EXISTS (
+				SELECT 1 FROM guess_sealed_answers a WHERE a.session_id = $1 AND a.round_number = r
+			)
+		), queued AS (
+			SELECT id, row_number() OVER (ORDER BY id) AS n
+			FROM guess_sealed_answers
+			WHERE channel_id = $4 AND organizer_id = $5 AND session_id IS NULL
+		)
+		UPDATE guess_sealed_answers a
+		SET session_id = $1, round_number = free.round_number
+		FROM queued JOIN free ON free.n = queued.n
+		WHERE a.id = queued.id
+	`, sess.ID, sess.CurrentRound, sess.TotalRounds, sess.ChannelID, sess.OrganizerID)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// sealedAnswer returns the sealed answer for a round, or nil if there is none.
+func sealedAnswer(ctx context.Context, q pgx.Tx, sessionID int64, round int) (*SealedAnswer, error) {
+	var a SealedAnswer
+	err := q.QueryRow(ctx, `
+		SELECT id, channel_id, organizer_id, session_id, round_number, answer_lat, answer_lng, answer_url, created_at
+		FROM guess_sealed_answers
+		WHERE session_id = $1 AND round_number = $2
+	`, sessionID, round).Scan(
+		&a.ID, &a.ChannelID, &a.OrganizerID, &a.SessionID, &a.RoundNumber,
+		&a.Lat, &a.Lng, &a.URL, &a.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// PendingSealedAnswers lists organizerID's answers in the channel that have not been revealed:
+// those for the current and later rounds of the active game, then the queue for the next game.
+func (s *Service) PendingSealedAnswers(ctx context.Context, channelID, organizerID string) ([]SealedAnswer, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT a.id, a.channel_id, a.organizer_id, a.session_id, a.round_number,
+		       a.answer_lat, a.answer_lng, a.answer_url, a.created_at
+		FROM guess_sealed_answers a
+		LEFT JOIN guess_sessions gs ON gs.id = a.session_id
+		WHERE a.channel_id = $1 AND a.organizer_id = $2
+		  AND (a.session_id IS NULL OR (gs.status = 'active' AND a.round_number >= gs.current_round))
+		ORDER BY a.session_id NULLS LAST, a.round_number, a.id
+	`, channelID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SealedAnswer
+	for rows.Next() {
+		var a SealedAnswer
+		if err := rows.Scan(&a.ID, &a.ChannelID, &a.OrganizerID, &a.SessionID, &a.RoundNumber,
+			&a.Lat, &a.Lng, &a.URL, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// ClearQueuedAnswers deletes organizerID's answers queued for the next game in the channel.
+// Answers already assigned to rounds of the active game are kept.
+func (s *Service) ClearQueuedAnswers(ctx context.Context, channelID, organizerID string) (int, error) {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM guess_sealed_answers
+		WHERE channel_id = $1 AND organizer_id = $2 AND session_id IS NULL
+	`, channelID, organizerID)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/susu3304/nkmzbot/internal/db"
+	"github.com/susu3304/nkmzbot/internal/georegion"
 	"github.com/susu3304/nkmzbot/internal/geoscore"
 )
 
@@ -17,6 +19,7 @@ var (
 	ErrSessionAlreadyExists = errors.New("このチャンネルには既にセッションが開始されています")
 	ErrNoActiveSession      = errors.New("このチャンネルにはアクティブなセッションがありません")
 	ErrAnswerNotSet         = errors.New("正解が設定されていません")
+	ErrRoundClosed          = errors.New("このラウンドの推測は締め切られました")
 )
 
 // World map default: half Earth circumference
@@ -30,46 +33,250 @@ func NewService(database *db.DB) *Service {
 	return &Service{db: database}
 }
 
+// MaxRounds is the largest number of rounds a game can have.
+const MaxRounds = 20
+
 type Session struct {
-	ID                 int64
-	ChannelID          string
-	GuildID            int64
-	OrganizerID        string
-	Status             string
-	AnswerLat          *float64
-	AnswerLng          *float64
-	AnswerURL          *string
-	MaxErrorDistance   float64
-	CreatedAt          time.Time
-	ClosedAt           *time.Time
+	ID               int64
+	ChannelID        string
+	GuildID          int64
+	OrganizerID      string
+	Status           string
+	AnswerLat        *float64
+	AnswerLng        *float64
+	AnswerURL        *string
+	MaxErrorDistance float64
+	MapName          string
+	TotalRounds      int
+	CurrentRound     int
+	// RoundSeconds is the time limit of each round, or nil for no limit.
+	RoundSeconds *int
+	// TimeBonus adds a bonus or penalty to each score depending on how fast the guess was.
+	TimeBonus bool
+	Mode      Mode
+	// ByPrefecture is set when region modes judge guesses by prefecture (maps of Japan).
+	ByPrefecture bool
+	// Streak is the number of rounds cleared so far in a streak game.
+	Streak int
+	// TeamScoring is set in team games.
+	TeamScoring TeamScoring
+	// Scorer scores guesses by distance; region modes do not use it.
+	Scorer geoscore.Scorer
+	// Daily is set on daily challenges, which the bot closes when the next one starts.
+	Daily     bool
+	CreatedAt time.Time
+	ClosedAt  *time.Time
 }
 
 type Guess struct {
-	ID              int64
-	SessionID       int64
-	UserID          string
-	GuessLat        float64
-	GuessLng        float64
-	GuessURL        string
-	Score           *int
-	DistanceMeters  *float64
-	CreatedAt       time.Time
+	ID             int64
+	SessionID      int64
+	RoundNumber    int
+	UserID         string
+	GuessLat       float64
+	GuessLng       float64
+	GuessURL       string
+	Score          *int
+	DistanceMeters *float64
+	CreatedAt      time.Time
 }
 
 type GuessResult struct {
 	UserID         string
 	GuessURL       string
+	GuessLat       float64
+	GuessLng       float64
 	Score          int
 	DistanceMeters float64
+	// TimeBonus is added to Score in time bonus games; it is negative for slow guesses.
+	TimeBonus int
+	// Match and Place are set in region modes; Place is nil for a guess in the open sea.
+	Match geoscore.RegionMatch
+	Place *georegion.Place
 }
 
-// StartSession creates a new guess session in the channel.
-func (s *Service) StartSession(ctx context.Context, channelID string, guildID int64, organizerID string) error {
-	query := `
-		INSERT INTO guess_sessions (channel_id, guild_id, organizer_id, status, max_error_distance)
-		VALUES ($1, $2, $3, 'active', $4)
-	`
-	_, err := s.db.Exec(ctx, query, channelID, guildID, organizerID, DefaultMaxErrorDistance)
+// Standing is a player's cumulative score over the scored rounds of a game.
+type Standing struct {
+	UserID       string
+	TotalScore   int
+	RoundsPlayed int
+}
+
+// RoundResult is the outcome of closing a round.
+type RoundResult struct {
+	SessionID   int64
+	Round       int
+	TotalRounds int
+	AnswerURL   string
+	AnswerLat   float64
+	AnswerLng   float64
+	// MapName and MaxErrorDistance are the scale the round was scored on.
+	MapName          string
+	MaxErrorDistance float64
+	// Sealed is true when the answer was sealed in advance and revealed by closing the round.
+	Sealed bool
+	// Mode and ByPrefecture are the session's scoring; AnswerPlace is set in region modes.
+	Mode         Mode
+	ByPrefecture bool
+	// Scorer scored the distances; region modes do not use it.
+	Scorer      geoscore.Scorer
+	AnswerPlace *georegion.Place
+	// Streak is the number of rounds cleared so far in a streak game.
+	Streak int
+	// Duel and DuelRound are the HP after this round and its damage in duels.
+	Duel      *Duel
+	DuelRound *DuelRound
+	// Results are the round's guesses ordered by score, best first.
+	Results []GuessResult
+	// Standings are the running totals after this round, best first.
+	Standings []Standing
+	// RatingChanges are the players' guild ratings before and after this round, in order of
+	// user ID; rounds with fewer than two players are not rated.
+	RatingChanges []RatingChange
+	// TeamScoring is set in team games, with the teams' scores in this round and running
+	// totals, best first.
+	TeamScoring   TeamScoring
+	Teams         []TeamResult
+	TeamStandings []TeamStanding
+	// Finished is true when this was the last round and the game has ended.
+	Finished bool
+	// NextClue is the clue of the round that was opened, if its answer was drawn from the pool.
+	NextClue string
+}
+
+// StartOptions configure a new game.
+type StartOptions struct {
+	// Rounds is the number of rounds; 0 means 1.
+	Rounds int
+	// Map sets the scoring scale; the zero value is the world map.
+	Map MapScale
+	// RoundSeconds is the time limit of each round; 0 means no limit.
+	RoundSeconds int
+	// TimeBonus enables the time bonus and penalty; it requires RoundSeconds.
+	TimeBonus bool
+	// Mode is how guesses are scored; empty means by distance. Streak games take no Rounds.
+	Mode Mode
+	// Teams makes a team game scored this way; empty means an individual game.
+	Teams TeamScoring
+	// Scorer scores guesses by distance; nil means geoscore.DefaultScorer.
+	Scorer geoscore.Scorer
+	// FromPool draws the answers of all rounds from the guild's location pool, restricted to
+	// PoolTag unless it is empty, instead of taking the organizer's sealed answers.
+	FromPool bool
+	PoolTag  string
+}
+
+// StartSession creates a new game in the channel and opens round 1.
+// Answers the organizer queued with SealAnswers are assigned to the rounds in order, or with
+// opts.FromPool every round gets a location drawn from the pool; it returns how many rounds
+// received one.
+func (s *Service) StartSession(ctx context.Context, channelID string, guildID int64, organizerID string, opts StartOptions) (int, error) {
+	rounds := opts.Rounds
+	if rounds == 0 {
+		rounds = 1
+	}
+	mapName := opts.Map.Name
+	if mapName == "" {
+		mapName = World.Name
+	}
+	if rounds < 1 || rounds > MaxRounds {
+		return 0, fmt.Errorf("ラウンド数は 1〜%d で指定してください", MaxRounds)
+	}
+	mode := opts.Mode
+	if mode == "" {
+		mode = ModeDistance
+	}
+	if mode == ModeStreak {
+		if opts.Rounds > 1 {
+			return 0, fmt.Errorf("ストリークは誰かが外すまで続くため、ラウンド数は指定できません")
+		}
+		rounds = MaxStreakRounds
+	}
+	if mode.ByRegion() && opts.Scorer != nil {
+		return 0, errors.New("国・都道府県当てとストリークでは採点方法を指定できません")
+	}
+	if opts.FromPool && mode == ModeStreak {
+		return 0, errors.New("ストリークでは場所プールから出題できません")
+	}
+	roundSeconds, err := roundLimit(opts)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := ParseTeamScoring(string(opts.Teams)); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess := &Session{
+		ChannelID:        channelID,
+		GuildID:          guildID,
+		OrganizerID:      organizerID,
+		MaxErrorDistance: opts.Map.MaxErrorDistance(),
+		MapName:          mapName,
+		TotalRounds:      rounds,
+		CurrentRound:     1,
+		RoundSeconds:     roundSeconds,
+		TimeBonus:        opts.TimeBonus,
+		Mode:             mode,
+		ByPrefecture:     mode.ByRegion() && opts.Map.InJapan(),
+		TeamScoring:      opts.Teams,
+		Scorer:           opts.Scorer,
+	}
+	if err := insertSession(ctx, tx, sess); err != nil {
+		return 0, err
+	}
+	if opts.FromPool {
+		drawn, err := drawPoolLocations(ctx, tx, guildID, opts.PoolTag, rounds)
+		if err != nil {
+			return 0, err
+		}
+		if len(drawn) < rounds {
+			return 0, poolShortage(opts.PoolTag, len(drawn))
+		}
+		if err := sealPoolLocations(ctx, tx, sess, drawn); err != nil {
+			return 0, err
+		}
+		return len(drawn), tx.Commit(ctx)
+	}
+	sealed, err := attachQueuedAnswers(ctx, tx, sess)
+	if err != nil {
+		return 0, err
+	}
+	return sealed, tx.Commit(ctx)
+}
+
+// roundLimit validates the time limit and time bonus of opts; nil means no limit.
+func roundLimit(opts StartOptions) (*int, error) {
+	var roundSeconds *int
+	if opts.RoundSeconds != 0 {
+		if opts.RoundSeconds < MinRoundSeconds || opts.RoundSeconds > MaxRoundSeconds {
+			return nil, fmt.Errorf("制限時間は %d〜%d 秒で指定してください", MinRoundSeconds, MaxRoundSeconds)
+		}
+		roundSeconds = &opts.RoundSeconds
+	}
+	if opts.TimeBonus && roundSeconds == nil {
+		return nil, fmt.Errorf("タイムボーナスを使うには制限時間を指定してください")
+	}
+	return roundSeconds, nil
+}
+
+// insertSession creates the active game sess, setting its ID, and opens round 1.
+func insertSession(ctx context.Context, tx pgx.Tx, sess *Session) error {
+	if sess.Scorer == nil {
+		sess.Scorer = geoscore.DefaultScorer
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO guess_sessions (channel_id, guild_id, organizer_id, status, max_error_distance, map_name, total_rounds, current_round, round_seconds, time_bonus, mode, by_prefecture, team_scoring, scorer, scorer_params, daily)
+		VALUES ($1, $2, $3, 'active', $4, $5, $6, 1, $7, $8, $9, $10, NULLIF($11, ''), $12, $13, $14)
+		RETURNING id
+	`, sess.ChannelID, sess.GuildID, sess.OrganizerID, sess.MaxErrorDistance, sess.MapName, sess.TotalRounds,
+		sess.RoundSeconds, sess.TimeBonus, sess.Mode, sess.ByPrefecture, sess.TeamScoring,
+		sess.Scorer.Kind(), sess.Scorer.Params(), sess.Daily).Scan(&sess.ID)
 	if err != nil {
 		// Check for unique constraint violation
 		var pgErr *pgconn.PgError
@@ -78,39 +285,69 @@ func (s *Service) StartSession(ctx context.Context, channelID string, guildID in
 		}
 		return err
 	}
-	return nil
+	return openRound(ctx, tx, sess, 1)
 }
 
-// StopSession ends the active session in the channel.
-func (s *Service) StopSession(ctx context.Context, channelID string) error {
-	query := `
-		UPDATE guess_sessions
-		SET status = 'closed', closed_at = CURRENT_TIMESTAMP
-		WHERE channel_id = $1 AND status = 'active'
-	`
-	result, err := s.db.Exec(ctx, query, channelID)
+// StopSession ends the active game in the channel, even if rounds remain,
+// and returns the player and team standings over the rounds that were scored.
+// A daily challenge cannot be stopped: it closes when the next one is posted or the daily
+// challenge is disabled, which reveals its answer and updates streaks.
+func (s *Service) StopSession(ctx context.Context, channelID string) ([]Standing, []TeamStanding, error) {
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := scanSession(tx.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM guess_sessions WHERE channel_id = $1 AND status = 'active' FOR UPDATE`,
+		channelID,
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+	if sess.Daily {
+		return nil, nil, ErrDailyStop
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE guess_sessions SET status = 'closed', closed_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, sess.ID); err != nil {
+		return nil, nil, err
 	}
-	if result.RowsAffected() == 0 {
-		return ErrNoActiveSession
+	if _, err := tx.Exec(ctx, `
+		UPDATE guess_rounds SET status = 'closed', closed_at = CURRENT_TIMESTAMP
+		WHERE session_id = $1 AND status IN ('open', 'locked')
+	`, sess.ID); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+	standings, err := s.Standings(ctx, sess.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	teams, err := s.TeamStandings(ctx, sess.ID)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil
+	return standings, teams, nil
 }
 
-// GetActiveSession retrieves the active session for the channel.
-func (s *Service) GetActiveSession(ctx context.Context, channelID string) (*Session, error) {
-	query := `
-		SELECT id, channel_id, guild_id, organizer_id, status, answer_lat, answer_lng, 
-		       answer_url, max_error_distance, created_at, closed_at
-		FROM guess_sessions
-		WHERE channel_id = $1 AND status = 'active'
-	`
+const sessionColumns = `id, channel_id, guild_id, organizer_id, status, answer_lat, answer_lng,
+	answer_url, max_error_distance, map_name, total_rounds, current_round, round_seconds, time_bonus,
+	mode, by_prefecture, streak, COALESCE(team_scoring, ''), scorer, scorer_params, daily, created_at, closed_at`
+
+func scanSession(row pgx.Row) (*Session, error) {
 	var sess Session
-	err := s.db.QueryRow(ctx, query, channelID).Scan(
+	var scorer geoscore.ScorerKind
+	var scorerParams string
+	err := row.Scan(
 		&sess.ID, &sess.ChannelID, &sess.GuildID, &sess.OrganizerID, &sess.Status,
-		&sess.AnswerLat, &sess.AnswerLng, &sess.AnswerURL, &sess.MaxErrorDistance,
-		&sess.CreatedAt, &sess.ClosedAt,
+		&sess.AnswerLat, &sess.AnswerLng, &sess.AnswerURL, &sess.MaxErrorDistance, &sess.MapName,
+		&sess.TotalRounds, &sess.CurrentRound, &sess.RoundSeconds, &sess.TimeBonus,
+		&sess.Mode, &sess.ByPrefecture, &sess.Streak, &sess.TeamScoring, &scorer, &scorerParams,
+		&sess.Daily, &sess.CreatedAt, &sess.ClosedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -118,102 +355,401 @@ func (s *Service) GetActiveSession(ctx context.Context, channelID string) (*Sess
 		}
 		return nil, err
 	}
+	if sess.Scorer, err = geoscore.ParseScorer(scorer, scorerParams); err != nil {
+		return nil, fmt.Errorf("session %d: %w", sess.ID, err)
+	}
 	return &sess, nil
 }
 
-// AddGuess records a user's guess for the active session.
-// If the user has already guessed, it updates with the new guess.
+// GetActiveSession retrieves the active session for the channel.
+func (s *Service) GetActiveSession(ctx context.Context, channelID string) (*Session, error) {
+	return scanSession(s.db.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM guess_sessions WHERE channel_id = $1 AND status = 'active'`,
+		channelID,
+	))
+}
+
+// AddGuess records a user's guess for the current round of the active session.
+// If the user has already guessed this round, it updates with the new guess.
 func (s *Service) AddGuess(ctx context.Context, channelID, userID string, guessLat, guessLng float64, guessURL string) error {
-	sess, err := s.GetActiveSession(ctx, channelID)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Share-lock the session so the round cannot be closed (which locks it for update) until the
+	// guess is in and will be scored.
+	sess, err := scanSession(tx.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM guess_sessions WHERE channel_id = $1 AND status = 'active' FOR SHARE`,
+		channelID,
+	))
 	if err != nil {
 		return err
 	}
 
+	// Whoever sealed this round's answer already knows it.
+	var sealedBy string
+	err = tx.QueryRow(ctx,
+		`SELECT organizer_id FROM guess_sealed_answers WHERE session_id = $1 AND round_number = $2`,
+		sess.ID, sess.CurrentRound,
+	).Scan(&sealedBy)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+	if sealedBy == userID {
+		return ErrOwnRound
+	}
+	if sess.Mode == ModeDuel {
+		var duelist bool
+		err = tx.QueryRow(ctx,
+			`SELECT $2 IN (challenger_id, opponent_id) FROM guess_duels WHERE session_id = $1`,
+			sess.ID, userID,
+		).Scan(&duelist)
+		if err != nil {
+			return err
+		}
+		if !duelist {
+			return ErrNotDuelist
+		}
+	}
+
+	// Rounds with a time limit stop taking guesses at the deadline, even before the timer locks them.
+	var open bool
+	err = tx.QueryRow(ctx, `
+		SELECT status = 'open' AND (deadline IS NULL OR deadline > CURRENT_TIMESTAMP)
+		FROM guess_rounds WHERE session_id = $1 AND round_number = $2
+	`, sess.ID, sess.CurrentRound).Scan(&open)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+	if err == nil && !open {
+		return ErrRoundClosed
+	}
+
 	query := `
-		INSERT INTO guess_guesses (session_id, user_id, guess_lat, guess_lng, guess_url)
-		VALUES ($1, $2, $3, $4, $5)
-		ON CONFLICT (session_id, user_id) 
-		DO UPDATE SET 
-			guess_lat = EXCLUDED.guess_lat, 
-			guess_lng = EXCLUDED.guess_lng, 
+		INSERT INTO guess_guesses (session_id, round_number, user_id, guess_lat, guess_lng, guess_url)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (session_id, round_number, user_id)
+		DO UPDATE SET
+			guess_lat = EXCLUDED.guess_lat,
+			guess_lng = EXCLUDED.guess_lng,
 			guess_url = EXCLUDED.guess_url,
 			score = NULL,
 			distance_meters = NULL,
 			created_at = CURRENT_TIMESTAMP
 	`
-	_, err = s.db.Exec(ctx, query, sess.ID, userID, guessLat, guessLng, guessURL)
-	return err
+	if _, err := tx.Exec(ctx, query, sess.ID, sess.CurrentRound, userID, guessLat, guessLng, guessURL); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetAnswer sets the correct answer for the current round of the active session, scores it
+// and closes it. The next round is opened, or the game ends after the last round.
+// The given answer takes precedence over a sealed one. answererID is who gives the answer;
+// players of a duel cannot.
+func (s *Service) SetAnswer(ctx context.Context, channelID, answererID string, answerLat, answerLng float64, answerURL string) (*RoundResult, error) {
+	return s.closeRound(ctx, channelID, answererID, &Location{Lat: answerLat, Lng: answerLng, URL: answerURL})
+}
+
+// RevealAnswer closes the current round of the active session with its sealed answer.
+// It returns ErrAnswerNotSet if the round has none.
+func (s *Service) RevealAnswer(ctx context.Context, channelID string) (*RoundResult, error) {
+	return s.closeRound(ctx, channelID, "", nil)
 }
 
-// SetAnswer sets the correct answer for the active session and calculates scores.
-func (s *Service) SetAnswer(ctx context.Context, channelID string, answerLat, answerLng float64, answerURL string) ([]GuessResult, error) {
-	sess, err := s.GetActiveSession(ctx, channelID)
+// closeRound scores and closes the current round with answer, or with its sealed answer if nil.
+func (s *Service) closeRound(ctx context.Context, channelID, answererID string, answer *Location) (*RoundResult, error) {
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback(ctx)
 
-	// Update session with answer
-	updateQuery := `
-		UPDATE guess_sessions
-		SET answer_lat = $1, answer_lng = $2, answer_url = $3
-		WHERE id = $4
-	`
-	_, err = s.db.Exec(ctx, updateQuery, answerLat, answerLng, answerURL, sess.ID)
+	// Lock the session so concurrent answers cannot close the same round twice.
+	sess, err := scanSession(tx.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM guess_sessions WHERE channel_id = $1 AND status = 'active' FOR UPDATE`,
+		channelID,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if sess.Daily {
+		return nil, ErrDailyAnswer
+	}
+	if sess.Mode == ModeDuel && answererID != "" {
+		d, err := sessionDuel(ctx, tx, sess.ID, false)
+		if err != nil {
+			return nil, err
+		}
+		if d.IsDuelist(answererID) {
+			return nil, ErrDuelistAnswer
+		}
+	}
+	res, err := finishRound(ctx, tx, sess, answer)
 	if err != nil {
 		return nil, err
 	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
 
-	// Get all guesses for this session
-	guessQuery := `
-		SELECT id, user_id, guess_lat, guess_lng, guess_url
-		FROM guess_guesses
-		WHERE session_id = $1
-		ORDER BY created_at ASC
-	`
-	rows, err := s.db.Query(ctx, guessQuery, sess.ID)
+// finishRound scores and closes the current round of the locked session sess and opens the next
+// one, or ends the game after the last round. A nil answer uses the round's sealed answer.
+func finishRound(ctx context.Context, tx pgx.Tx, sess *Session, answer *Location) (*RoundResult, error) {
+	sealed := false
+	if answer == nil {
+		a, err := sealedAnswer(ctx, tx, sess.ID, sess.CurrentRound)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			return nil, ErrAnswerNotSet
+		}
+		answer = &a.Location
+		sealed = true
+	}
+	answerLat, answerLng, answerURL := answer.Lat, answer.Lng, answer.URL
+	var answerPlace *georegion.Place
+	if sess.Mode.ByRegion() {
+		answerPlace = locate(answerLat, answerLng)
+	}
+
+	// Close the round with its answer
+	_, err := tx.Exec(ctx, `
+		UPDATE guess_rounds
+		SET answer_lat = $1, answer_lng = $2, answer_url = $3, answer_region = $4, status = 'closed', closed_at = CURRENT_TIMESTAMP
+		WHERE session_id = $5 AND round_number = $6
+	`, answerLat, answerLng, answerURL, regionCode(answerPlace), sess.ID, sess.CurrentRound)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var results []GuessResult
-	for rows.Next() {
-		var guessID int64
-		var userID string
-		var guessLat, guessLng float64
-		var guessURL string
-		if err := rows.Scan(&guessID, &userID, &guessLat, &guessLng, &guessURL); err != nil {
+	results, err := scoreRound(ctx, tx, sess, answerLat, answerLng, answerPlace)
+	if err != nil {
+		return nil, err
+	}
+	ratings, err := rateRound(ctx, tx, sess, results)
+	if err != nil {
+		return nil, err
+	}
+	var teams []TeamResult
+	if sess.TeamScoring != "" {
+		if teams, err = scoreTeams(ctx, tx, sess, results); err != nil {
 			return nil, err
 		}
+	}
 
-		// Calculate score and distance
-		distance := geoscore.DistanceMeters(answerLat, answerLng, guessLat, guessLng)
-		score := geoscore.GeoGuessrScore(answerLat, answerLng, guessLat, guessLng, sess.MaxErrorDistance)
+	finished := sess.CurrentRound >= sess.TotalRounds
+	streak := sess.Streak
+	if sess.Mode == ModeStreak {
+		// The streak survives a round only if there were guesses and all of them hit.
+		cleared := len(results) > 0
+		for _, r := range results {
+			cleared = cleared && r.Match == geoscore.RegionExact
+		}
+		if cleared {
+			streak++
+		}
+		finished = finished || !cleared
+	}
+	var duel *Duel
+	var duelRound *DuelRound
+	if sess.Mode == ModeDuel {
+		if duel, duelRound, err = scoreDuel(ctx, tx, sess, results, finished); err != nil {
+			return nil, err
+		}
+		finished = finished || duel.WinnerID != ""
+	}
+	if finished {
+		_, err = tx.Exec(ctx, `
+			UPDATE guess_sessions
+			SET answer_lat = $1, answer_lng = $2, answer_url = $3, streak = $4, status = 'closed', closed_at = CURRENT_TIMESTAMP
+			WHERE id = $5
+		`, answerLat, answerLng, answerURL, streak, sess.ID)
+	} else {
+		_, err = tx.Exec(ctx, `
+			UPDATE guess_sessions
+			SET answer_lat = $1, answer_lng = $2, answer_url = $3, streak = $4, current_round = current_round + 1
+			WHERE id = $5
+		`, answerLat, answerLng, answerURL, streak, sess.ID)
+		if err == nil {
+			err = openRound(ctx, tx, sess, sess.CurrentRound+1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nextClue string
+	if !finished {
+		if nextClue, err = roundClue(ctx, tx, sess.ID, sess.CurrentRound+1); err != nil {
+			return nil, err
+		}
+	}
 
-		// Update guess with score and distance
-		scoreUpdateQuery := `
-			UPDATE guess_guesses
-			SET score = $1, distance_meters = $2
-			WHERE id = $3
-		`
-		_, err := s.db.Exec(ctx, scoreUpdateQuery, score, distance, guessID)
-		if err != nil {
+	standings, err := standings(ctx, tx, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	var teamTotals []TeamStanding
+	if sess.TeamScoring != "" {
+		if teamTotals, err = teamStandings(ctx, tx, sess.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RoundResult{
+		SessionID:        sess.ID,
+		Round:            sess.CurrentRound,
+		AnswerURL:        answerURL,
+		AnswerLat:        answerLat,
+		AnswerLng:        answerLng,
+		MapName:          sess.MapName,
+		MaxErrorDistance: sess.MaxErrorDistance,
+		Sealed:           sealed,
+		Mode:             sess.Mode,
+		ByPrefecture:     sess.ByPrefecture,
+		Scorer:           sess.Scorer,
+		AnswerPlace:      answerPlace,
+		Streak:           streak,
+		Duel:             duel,
+		DuelRound:        duelRound,
+		TotalRounds:      sess.TotalRounds,
+		Results:          results,
+		Standings:        standings,
+		RatingChanges:    ratings,
+		TeamScoring:      sess.TeamScoring,
+		Teams:            teams,
+		TeamStandings:    teamTotals,
+		Finished:         finished,
+		NextClue:         nextClue,
+	}, nil
+}
+
+// openRound opens round number round of sess, with a deadline if the game has a time limit.
+func openRound(ctx context.Context, tx pgx.Tx, sess *Session, round int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO guess_rounds (session_id, round_number, deadline)
+		VALUES ($1, $2, CURRENT_TIMESTAMP + $3::int * INTERVAL '1 second')
+	`, sess.ID, round, sess.RoundSeconds)
+	return err
+}
+
+// scoreRound scores every guess of the session's current round against the answer.
+// answerPlace is only used in region modes.
+func scoreRound(ctx context.Context, tx pgx.Tx, sess *Session, answerLat, answerLng float64, answerPlace *georegion.Place) ([]GuessResult, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT g.id, g.user_id, g.guess_lat, g.guess_lng, g.guess_url,
+		       COALESCE(EXTRACT(EPOCH FROM (g.created_at - r.opened_at)), 0)::float8
+		FROM guess_guesses g
+		JOIN guess_rounds r ON r.session_id = g.session_id AND r.round_number = g.round_number
+		WHERE g.session_id = $1 AND g.round_number = $2
+		ORDER BY g.created_at ASC
+	`, sess.ID, sess.CurrentRound)
+	if err != nil {
+		return nil, err
+	}
+	var guesses []Guess
+	var elapsed []float64
+	for rows.Next() {
+		var g Guess
+		var secs float64
+		if err := rows.Scan(&g.ID, &g.UserID, &g.GuessLat, &g.GuessLng, &g.GuessURL, &secs); err != nil {
+			rows.Close()
 			return nil, err
 		}
+		guesses = append(guesses, g)
+		elapsed = append(elapsed, secs)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	results := make([]GuessResult, 0, len(guesses))
+	for idx, g := range guesses {
+		distance := geoscore.DistanceMeters(answerLat, answerLng, g.GuessLat, g.GuessLng)
+		score := sess.Scorer.Score(distance, sess.MaxErrorDistance)
+		var match geoscore.RegionMatch
+		var place *georegion.Place
+		if sess.Mode.ByRegion() {
+			place = locate(g.GuessLat, g.GuessLng)
+			match = matchRegion(answerPlace, place, sess.ByPrefecture)
+			score = geoscore.RegionScore(match)
+		}
+		bonus := 0
+		if sess.TimeBonus && sess.RoundSeconds != nil {
+			limit := time.Duration(*sess.RoundSeconds) * time.Second
+			bonus = TimeBonus(score, time.Duration(elapsed[idx]*float64(time.Second)), limit)
+		}
+		var matchCol *string
+		if match != "" {
+			m := string(match)
+			matchCol = &m
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE guess_guesses SET score = $1, distance_meters = $2, time_bonus = $3, guess_region = $4, region_match = $5
+			WHERE id = $6
+		`, score, distance, bonus, regionCode(place), matchCol, g.ID); err != nil {
+			return nil, err
+		}
 		results = append(results, GuessResult{
-			UserID:         userID,
-			GuessURL:       guessURL,
+			UserID:         g.UserID,
+			GuessURL:       g.GuessURL,
+			GuessLat:       g.GuessLat,
+			GuessLng:       g.GuessLng,
 			Score:          score,
 			DistanceMeters: distance,
+			TimeBonus:      bonus,
+			Match:          match,
+			Place:          place,
 		})
 	}
 
-	if err := rows.Err(); err != nil {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score+results[i].TimeBonus > results[j].Score+results[j].TimeBonus
+	})
+	return results, nil
+}
+
+// Standings returns each player's total over the scored rounds of a session, best first.
+func (s *Service) Standings(ctx context.Context, sessionID int64) ([]Standing, error) {
+	return standings(ctx, s.db, sessionID)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+func standings(ctx context.Context, q querier, sessionID int64) ([]Standing, error) {
+	rows, err := q.Query(ctx, `
+		SELECT user_id, SUM(score + time_bonus), COUNT(*)
+		FROM guess_guesses
+		WHERE session_id = $1 AND score IS NOT NULL
+		GROUP BY user_id
+		ORDER BY SUM(score + time_bonus) DESC, user_id
+	`, sessionID)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return results, nil
+	var out []Standing
+	for rows.Next() {
+		var st Standing
+		if err := rows.Scan(&st.UserID, &st.TotalScore, &st.RoundsPlayed); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
 }
 
 // FormatDistance formats distance in a human-readable way.
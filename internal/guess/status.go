@@ -0,0 +1,66 @@
+package guess
+
+import (
+	"context"
+)
+
+// GuessStatus is how far the current round of a session has got, without revealing any guess.
+type GuessStatus struct {
+	SessionID   int64
+	Round       int
+	TotalRounds int
+	Mode        Mode
+	Guessed     int
+	// Players is how many players are expected to guess: the duelists, the team members and
+	// everyone who guessed in an earlier round. It is 0 while nobody is known in advance, as in
+	// the first round of a plain game.
+	Players int
+	// MessageID is the channel message showing the status, or empty until one is posted.
+	MessageID string
+}
+
+// GuessStatus returns the guess count of the current round of the channel's active session.
+func (s *Service) GuessStatus(ctx context.Context, channelID string) (*GuessStatus, error) {
+	sess, err := s.GetActiveSession(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	st := &GuessStatus{
+		SessionID:   sess.ID,
+		Round:       sess.CurrentRound,
+		TotalRounds: sess.TotalRounds,
+		Mode:        sess.Mode,
+	}
+	var known int
+	err = s.db.QueryRow(ctx, `
+		WITH known AS (
+			SELECT user_id FROM guess_guesses WHERE session_id = $1 AND round_number < $2
+			UNION SELECT user_id FROM guess_team_members WHERE session_id = $1
+			UNION SELECT challenger_id FROM guess_duels WHERE session_id = $1
+			UNION SELECT opponent_id FROM guess_duels WHERE session_id = $1
+		), current AS (
+			SELECT user_id FROM guess_guesses WHERE session_id = $1 AND round_number = $2
+		)
+		SELECT
+			(SELECT COUNT(*) FROM current),
+			(SELECT COUNT(*) FROM known),
+			(SELECT COUNT(*) FROM (SELECT user_id FROM known UNION SELECT user_id FROM current) p),
+			COALESCE((SELECT status_message_id FROM guess_rounds WHERE session_id = $1 AND round_number = $2), '')
+	`, sess.ID, sess.CurrentRound).Scan(&st.Guessed, &known, &st.Players, &st.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if known == 0 {
+		st.Players = 0
+	}
+	return st, nil
+}
+
+// SetGuessStatusMessage records the channel message showing the status of a round.
+func (s *Service) SetGuessStatusMessage(ctx context.Context, sessionID int64, round int, messageID string) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE guess_rounds SET status_message_id = $3 WHERE session_id = $1 AND round_number = $2`,
+		sessionID, round, messageID,
+	)
+	return err
+}
@@ -0,0 +1,473 @@
+package guess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNotTeamGame      = errors.New("このゲームはチーム戦ではありません。`/guess start teams:` でチーム戦を開始できます")
+	ErrTeamNotFound     = errors.New("チームが見つかりません。`/guess team list` で確認できます")
+	ErrTeamExists       = errors.New("同じ名前のチームが既にあります")
+	ErrTooManyTeams     = fmt.Errorf("チームは %d 個までです", MaxTeams)
+	ErrTeamsLocked      = errors.New("チーム分けは最初のラウンドが終わる前にのみできます")
+	ErrNotTeamOrganizer = errors.New("主催者のみがチーム分けできます")
+)
+
+// MaxTeams is the largest number of teams in a game.
+const MaxTeams = 10
+
+// MaxTeamNameLength is the longest team name in characters.
+const MaxTeamNameLength = 32
+
+// TeamScoring is how a team's round score is made from its members' guesses.
+// The empty value means an individual game without teams.
+type TeamScoring string
+
+const (
+	// TeamBest takes the best guess of the team.
+	TeamBest TeamScoring = "best"
+	// TeamAverage averages the guesses of the members who guessed.
+	TeamAverage TeamScoring = "average"
+)
+
+// ParseTeamScoring validates a team scoring name; empty means no teams.
+func ParseTeamScoring(v string) (TeamScoring, error) {
+	switch t := TeamScoring(strings.ToLower(strings.TrimSpace(v))); t {
+	case "", TeamBest, TeamAverage:
+		return t, nil
+	}
+	return "", fmt.Errorf("チームのスコアは best, average のいずれかで指定してください")
+}
+
+// Label is the Japanese name of t shown in replies.
+func (t TeamScoring) Label() string {
+	if t == TeamAverage {
+		return "平均"
+	}
+	return "ベスト"
+}
+
+// Team is a team of a game and its members' user IDs.
+type Team struct {
+	ID      int64
+	Name    string
+	Members []string
+}
+
+// TeamResult is a team's score in a round.
+type TeamResult struct {
+	Name  string
+	Score int
+	// Guesses is how many of the team's Members guessed this round.
+	Guesses int
+	Members int
+}
+
+// TeamStanding is a team's cumulative score over the scored rounds of a game.
+type TeamStanding struct {
+	Name         string
+	TotalScore   int
+	RoundsPlayed int
+}
+
+// teamSession returns the active session of the channel, or ErrNotTeamGame if it has no teams.
+func teamSession(ctx context.Context, tx pgx.Tx, channelID string, lock bool) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM guess_sessions WHERE channel_id = $1 AND status = 'active'`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	sess, err := scanSession(tx.QueryRow(ctx, query, channelID))
+	if err != nil {
+		return nil, err
+	}
+	if sess.TeamScoring == "" {
+		return nil, ErrNotTeamGame
+	}
+	return sess, nil
+}
+
+// CreateTeam adds a team to the channel's team game and moves the creator into it.
+func (s *Service) CreateTeam(ctx context.Context, channelID, userID, name string) (*Team, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxTeamNameLength {
+		return nil, fmt.Errorf("チーム名は 1〜%d 文字で指定してください", MaxTeamNameLength)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := teamSession(ctx, tx, channelID, true)
+	if err != nil {
+		return nil, err
+	}
+	var count int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM guess_teams WHERE session_id = $1`, sess.ID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count >= MaxTeams {
+		return nil, ErrTooManyTeams
+	}
+	team := &Team{Name: name}
+	if err := tx.QueryRow(ctx,
+		`INSERT INTO guess_teams (session_id, name) VALUES ($1, $2) RETURNING id`,
+		sess.ID, name,
+	).Scan(&team.ID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrTeamExists
+		}
+		return nil, err
+	}
+	if err := joinTeam(ctx, tx, sess.ID, team.ID, userID); err != nil {
+		return nil, err
+	}
+	if team.Members, err = teamMembers(ctx, tx, team.ID); err != nil {
+		return nil, err
+	}
+	return team, tx.Commit(ctx)
+}
+
+// JoinTeam moves the user into the named team of the channel's team game.
+// Rounds already scored keep counting for the team the user was in at the time.
+func (s *Service) JoinTeam(ctx context.Context, channelID, userID, name string) (*Team, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := teamSession(ctx, tx, channelID, false)
+	if err != nil {
+		return nil, err
+	}
+	team := &Team{}
+	err = tx.QueryRow(ctx,
+		`SELECT id, name FROM guess_teams WHERE session_id = $1 AND lower(name) = lower($2)`,
+		sess.ID, strings.TrimSpace(name),
+	).Scan(&team.ID, &team.Name)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrTeamNotFound
+		}
+		return nil, err
+	}
+	if err := joinTeam(ctx, tx, sess.ID, team.ID, userID); err != nil {
+		return nil, err
+	}
+	if team.Members, err = teamMembers(ctx, tx, team.ID); err != nil {
+		return nil, err
+	}
+	return team, tx.Commit(ctx)
+}
+
+func joinTeam(ctx context.Context, tx pgx.Tx, sessionID, teamID int64, userID string) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO guess_team_members (session_id, team_id, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (session_id, user_id)
+		DO UPDATE SET team_id = EXCLUDED.team_id, joined_at = CURRENT_TIMESTAMP
+	`, sessionID, teamID, userID)
+	return err
+}
+
+func teamMembers(ctx context.Context, tx pgx.Tx, teamID int64) ([]string, error) {
+	rows, err := tx.Query(ctx, `SELECT user_id FROM guess_team_members WHERE team_id = $1 ORDER BY joined_at, user_id`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// Teams returns the teams of the channel's team game in the order they were created.
+func (s *Service) Teams(ctx context.Context, channelID string) ([]Team, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := teamSession(ctx, tx, channelID, false)
+	if err != nil {
+		return nil, err
+	}
+	return sessionTeams(ctx, tx, sess.ID)
+}
+
+func sessionTeams(ctx context.Context, tx pgx.Tx, sessionID int64) ([]Team, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT t.id, t.name, m.user_id
+		FROM guess_teams t
+		LEFT JOIN guess_team_members m ON m.team_id = t.id
+		WHERE t.session_id = $1
+		ORDER BY t.id, m.joined_at, m.user_id
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		var id int64
+		var name string
+		var userID *string
+		if err := rows.Scan(&id, &name, &userID); err != nil {
+			return nil, err
+		}
+		if len(teams) == 0 || teams[len(teams)-1].ID != id {
+			teams = append(teams, Team{ID: id, Name: name})
+		}
+		if userID != nil {
+			t := &teams[len(teams)-1]
+			t.Members = append(t.Members, *userID)
+		}
+	}
+	return teams, rows.Err()
+}
+
+// BalanceTeams splits everyone in a team or with a guess in the channel's game into count teams
+// of even strength, judged by each player's average score in the guild. Existing team names are
+// kept where possible. Only the organizer can balance, and only before round 1 is scored.
+func (s *Service) BalanceTeams(ctx context.Context, channelID, organizerID string, count int) ([]Team, error) {
+	if count < 2 || count > MaxTeams {
+		return nil, fmt.Errorf("チーム数は 2〜%d で指定してください", MaxTeams)
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := teamSession(ctx, tx, channelID, true)
+	if err != nil {
+		return nil, err
+	}
+	if sess.OrganizerID != organizerID {
+		return nil, ErrNotTeamOrganizer
+	}
+	if sess.CurrentRound != 1 {
+		return nil, ErrTeamsLocked
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT p.user_id, AVG(g.score + g.time_bonus)::float8
+		FROM (
+			SELECT user_id FROM guess_team_members WHERE session_id = $1
+			UNION
+			SELECT user_id FROM guess_guesses WHERE session_id = $1
+		) p
+		LEFT JOIN guess_guesses g ON g.user_id = p.user_id AND g.score IS NOT NULL
+		  AND g.session_id IN (SELECT id FROM guess_sessions WHERE guild_id = $2)
+		GROUP BY p.user_id
+	`, sess.ID, sess.GuildID)
+	if err != nil {
+		return nil, err
+	}
+	var players []ratedPlayer
+	for rows.Next() {
+		var p ratedPlayer
+		if err := rows.Scan(&p.UserID, &p.Average); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(players) < count {
+		return nil, fmt.Errorf("%d チームに分けるには %d 人以上のプレイヤーが必要です（現在 %d 人）", count, count, len(players))
+	}
+
+	existing, err := sessionTeams(ctx, tx, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM guess_teams WHERE session_id = $1`, sess.ID); err != nil {
+		return nil, err
+	}
+	names := teamNames(existing, count)
+	teams := make([]Team, count)
+	for idx, members := range balanceTeams(players, count) {
+		teams[idx] = Team{Name: names[idx], Members: members}
+		if err := tx.QueryRow(ctx,
+			`INSERT INTO guess_teams (session_id, name) VALUES ($1, $2) RETURNING id`,
+			sess.ID, names[idx],
+		).Scan(&teams[idx].ID); err != nil {
+			return nil, err
+		}
+		for _, userID := range members {
+			if err := joinTeam(ctx, tx, sess.ID, teams[idx].ID, userID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return teams, tx.Commit(ctx)
+}
+
+// ratedPlayer is a player and their average score, nil without scored rounds.
+type ratedPlayer struct {
+	UserID  string
+	Average *float64
+}
+
+// balanceTeams deals players, strongest first, to the team with the fewest members and then the
+// lowest total. Players without history count as the average of those with one.
+func balanceTeams(players []ratedPlayer, count int) [][]string {
+	known, sum := 0, 0.0
+	for _, p := range players {
+		if p.Average != nil {
+			known++
+			sum += *p.Average
+		}
+	}
+	fallback := 0.0
+	if known > 0 {
+		fallback = sum / float64(known)
+	}
+	type entry struct {
+		userID string
+		rating float64
+	}
+	entries := make([]entry, len(players))
+	for idx, p := range players {
+		entries[idx] = entry{p.UserID, fallback}
+		if p.Average != nil {
+			entries[idx].rating = *p.Average
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].rating != entries[j].rating {
+			return entries[i].rating > entries[j].rating
+		}
+		return entries[i].userID < entries[j].userID
+	})
+
+	teams := make([][]string, count)
+	totals := make([]float64, count)
+	for _, e := range entries {
+		pick := 0
+		for idx := 1; idx < count; idx++ {
+			if len(teams[idx]) < len(teams[pick]) ||
+				len(teams[idx]) == len(teams[pick]) && totals[idx] < totals[pick] {
+				pick = idx
+			}
+		}
+		teams[pick] = append(teams[pick], e.userID)
+		totals[pick] += e.rating
+	}
+	return teams
+}
+
+// teamNames returns count names for balanced teams: the existing teams' names first, then
+// チームA, チームB, ... skipping names already taken.
+func teamNames(existing []Team, count int) []string {
+	taken := make(map[string]bool)
+	var names []string
+	for _, t := range existing {
+		if len(names) == count {
+			break
+		}
+		names = append(names, t.Name)
+		taken[strings.ToLower(t.Name)] = true
+	}
+	for letter := 'A'; len(names) < count; letter++ {
+		name := "チーム" + string(letter)
+		if !taken[strings.ToLower(name)] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scoreTeams records each team's score in the session's current round from the scored results,
+// best first. Teams where nobody guessed score 0.
+func scoreTeams(ctx context.Context, tx pgx.Tx, sess *Session, results []GuessResult) ([]TeamResult, error) {
+	teams, err := sessionTeams(ctx, tx, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := teamScores(sess.TeamScoring, teams, results)
+	for idx, t := range teams {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO guess_team_scores (session_id, round_number, team_id, score, guesses)
+			VALUES ($1, $2, $3, $4, $5)
+		`, sess.ID, sess.CurrentRound, t.ID, out[idx].Score, out[idx].Guesses); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
+
+// teamScores computes the round score of each team, in the order of teams.
+func teamScores(scoring TeamScoring, teams []Team, results []GuessResult) []TeamResult {
+	scores := make(map[string]int, len(results))
+	for _, r := range results {
+		scores[r.UserID] = r.Score + r.TimeBonus
+	}
+	out := make([]TeamResult, len(teams))
+	for idx, t := range teams {
+		res := TeamResult{Name: t.Name, Members: len(t.Members)}
+		sum := 0
+		for _, userID := range t.Members {
+			score, ok := scores[userID]
+			if !ok {
+				continue
+			}
+			if res.Guesses == 0 || score > res.Score {
+				res.Score = score
+			}
+			res.Guesses++
+			sum += score
+		}
+		if scoring == TeamAverage && res.Guesses > 0 {
+			res.Score = int(math.Round(float64(sum) / float64(res.Guesses)))
+		}
+		out[idx] = res
+	}
+	return out
+}
+
+// TeamStandings returns each team's total over the scored rounds of a session, best first.
+func (s *Service) TeamStandings(ctx context.Context, sessionID int64) ([]TeamStanding, error) {
+	return teamStandings(ctx, s.db, sessionID)
+}
+
+func teamStandings(ctx context.Context, q querier, sessionID int64) ([]TeamStanding, error) {
+	rows, err := q.Query(ctx, `
+		SELECT t.name, SUM(ts.score), COUNT(*)
+		FROM guess_team_scores ts
+		JOIN guess_teams t ON t.id = ts.team_id
+		WHERE ts.session_id = $1
+		GROUP BY t.id, t.name
+		ORDER BY SUM(ts.score) DESC, t.name
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TeamStanding
+	for rows.Next() {
+		var st TeamStanding
+		if err := rows.Scan(&st.Name, &st.TotalScore, &st.RoundsPlayed); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,60 @@
+package guess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTeamScores(t *testing.T) {
+	teams := []Team{
+		{Name: "A", Members: []string{"1", "2", "3"}},
+		{Name: "B", Members: []string{"4"}},
+		{Name: "C", Members: []string{"5"}},
+	}
+	results := []GuessResult{
+		{UserID: "1", Score: 4000, TimeBonus: 100},
+		{UserID: "2", Score: 3000},
+		{UserID: "4", Score: 2500},
+		{UserID: "6", Score: 5000},
+	}
+	best := teamScores(TeamBest, teams, results)
+	if want := (TeamResult{Name: "A", Score: 4100, Guesses: 2, Members: 3}); best[0] != want {
+		t.Errorf("best A = %+v, want %+v", best[0], want)
+	}
+	if best[2].Score != 0 || best[2].Guesses != 0 {
+		t.Errorf("team without guesses = %+v", best[2])
+	}
+	avg := teamScores(TeamAverage, teams, results)
+	if avg[0].Score != 3550 || avg[1].Score != 2500 {
+		t.Errorf("average = %+v", avg)
+	}
+}
+
+func TestBalanceTeams(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	players := []ratedPlayer{
+		{UserID: "a", Average: f(4800)},
+		{UserID: "b", Average: f(4000)},
+		{UserID: "c", Average: f(3000)},
+		{UserID: "d", Average: f(1000)},
+		{UserID: "new"},
+	}
+	got := balanceTeams(players, 2)
+	// new counts as 3200, the average of the others. Each player joins the smaller team,
+	// or the weaker one when they are the same size.
+	want := [][]string{{"a", "c"}, {"b", "new", "d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("balanceTeams = %v, want %v", got, want)
+	}
+}
+
+func TestTeamNames(t *testing.T) {
+	got := teamNames([]Team{{Name: "チームB"}, {Name: "Red"}}, 4)
+	want := []string{"チームB", "Red", "チームA", "チームC"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("teamNames = %v, want %v", got, want)
+	}
+	if got := teamNames([]Team{{Name: "x"}, {Name: "y"}, {Name: "z"}}, 2); !reflect.DeepEqual(got, []string{"x", "y"}) {
+		t.Fatalf("teamNames = %v", got)
+	}
+}
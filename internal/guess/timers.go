@@ -0,0 +1,140 @@
+package guess
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Bounds of a round's time limit in seconds.
+const (
+	MinRoundSeconds = 10
+	MaxRoundSeconds = 3600
+)
+
+// MaxTimeBonusRate is the largest time bonus or penalty as a fraction of the score:
+// an instant guess earns +10%, a guess at the deadline loses 10%.
+const MaxTimeBonusRate = 0.1
+
+// TimeBonus is the bonus (or penalty, if negative) for a guess scoring score that was made
+// elapsed after the round opened, in a round lasting limit. It falls linearly from
+// +MaxTimeBonusRate of the score at the start to -MaxTimeBonusRate at the deadline.
+func TimeBonus(score int, elapsed, limit time.Duration) int {
+	if limit <= 0 {
+		return 0
+	}
+	frac := float64(elapsed) / float64(limit)
+	frac = math.Max(0, math.Min(1, frac))
+	return int(math.Round(float64(score) * MaxTimeBonusRate * (1 - 2*frac)))
+}
+
+// RoundTimer is the countdown state of an open round with a time limit.
+type RoundTimer struct {
+	SessionID    int64
+	ChannelID    string
+	Round        int
+	TotalRounds  int
+	RoundSeconds int
+	Mode         Mode
+	// Remaining is the time until the deadline; it is zero or negative once it has passed.
+	Remaining time.Duration
+	// LastWarning is the smallest countdown announcement already posted, in seconds.
+	LastWarning *int
+}
+
+// DueTimers returns the open rounds of active games whose deadline is within the given duration.
+// Deadlines live in the database, so timers carry over bot restarts.
+func (s *Service) DueTimers(ctx context.Context, within time.Duration) ([]RoundTimer, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT r.session_id, s.channel_id, r.round_number, s.total_rounds, s.round_seconds, s.mode,
+		       EXTRACT(EPOCH FROM (r.deadline - CURRENT_TIMESTAMP))::float8, r.last_warning_seconds
+		FROM guess_rounds r
+		JOIN guess_sessions s ON s.id = r.session_id
+		WHERE r.status = 'open' AND s.status = 'active' AND s.current_round = r.round_number
+		  AND r.deadline IS NOT NULL AND s.round_seconds IS NOT NULL
+		  AND r.deadline <= CURRENT_TIMESTAMP + $1::float8 * INTERVAL '1 second'
+		ORDER BY r.deadline
+	`, within.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RoundTimer
+	for rows.Next() {
+		var t RoundTimer
+		var remaining float64
+		if err := rows.Scan(&t.SessionID, &t.ChannelID, &t.Round, &t.TotalRounds, &t.RoundSeconds, &t.Mode, &remaining, &t.LastWarning); err != nil {
+			return nil, err
+		}
+		t.Remaining = time.Duration(remaining * float64(time.Second))
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// MarkWarned records that the countdown announcement for seconds remaining was posted.
+// It reports false if that or a later announcement was already recorded, so only one
+// caller posts each announcement.
+func (s *Service) MarkWarned(ctx context.Context, sessionID int64, round, seconds int) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE guess_rounds SET last_warning_seconds = $3
+		WHERE session_id = $1 AND round_number = $2 AND status = 'open'
+		  AND (last_warning_seconds IS NULL OR last_warning_seconds > $3)
+	`, sessionID, round, seconds)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ExpireRound stops a round whose deadline has passed from taking guesses. If the round has a
+// sealed answer it is revealed and the round is closed like RevealAnswer; otherwise the round is
+// locked until the organizer gives the answer, and the result is nil.
+// It returns ErrRoundClosed if the round is no longer open or its deadline has not passed.
+func (s *Service) ExpireRound(ctx context.Context, sessionID int64, round int) (*RoundResult, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sess, err := scanSession(tx.QueryRow(ctx,
+		`SELECT `+sessionColumns+` FROM guess_sessions WHERE id = $1 AND status = 'active' FOR UPDATE`,
+		sessionID,
+	))
+	if err != nil {
+		if err == ErrNoActiveSession {
+			return nil, ErrRoundClosed
+		}
+		return nil, err
+	}
+	if sess.CurrentRound != round {
+		return nil, ErrRoundClosed
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE guess_rounds SET status = 'locked'
+		WHERE session_id = $1 AND round_number = $2 AND status = 'open' AND deadline <= CURRENT_TIMESTAMP
+	`, sessionID, round)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrRoundClosed
+	}
+
+	var res *RoundResult
+	a, err := sealedAnswer(ctx, tx, sess.ID, sess.CurrentRound)
+	if err != nil {
+		return nil, err
+	}
+	if a != nil {
+		if res, err = finishRound(ctx, tx, sess, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,29 @@
+package guess
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeBonus(t *testing.T) {
+	limit := time.Minute
+	cases := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{0, 500},
+		{15 * time.Second, 250},
+		{30 * time.Second, 0},
+		{time.Minute, -500},
+		{2 * time.Minute, -500}, // guesses recorded just after the deadline aren't penalized further
+		{-time.Second, 500},
+	}
+	for _, c := range cases {
+		if got := TimeBonus(5000, c.elapsed, limit); got != c.want {
+			t.Errorf("TimeBonus(5000, %v) = %d, want %d", c.elapsed, got, c.want)
+		}
+	}
+	if got := TimeBonus(5000, time.Second, 0); got != 0 {
+		t.Errorf("no limit = %d", got)
+	}
+}
@@ -0,0 +1,115 @@
+package guessmap
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// canvas is an RGBA image with the few drawing primitives the map needs.
+type canvas struct {
+	img  *image.RGBA
+	w, h int
+}
+
+func newCanvas(w, h int) *canvas {
+	return &canvas{img: image.NewRGBA(image.Rect(0, 0, w, h)), w: w, h: h}
+}
+
+func (c *canvas) fill(col color.RGBA) {
+	c.rect(0, 0, c.w, c.h, col)
+}
+
+func (c *canvas) rect(x, y, w, h int, col color.RGBA) {
+	for py := max(y, 0); py < min(y+h, c.h); py++ {
+		for px := max(x, 0); px < min(x+w, c.w); px++ {
+			c.img.SetRGBA(px, py, col)
+		}
+	}
+}
+
+// blendRect paints col over a rectangle with the given opacity.
+func (c *canvas) blendRect(x, y, w, h int, col color.RGBA, alpha float64) {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-alpha) + float64(b)*alpha))
+	}
+	for py := max(y, 0); py < min(y+h, c.h); py++ {
+		for px := max(x, 0); px < min(x+w, c.w); px++ {
+			old := c.img.RGBAAt(px, py)
+			c.img.SetRGBA(px, py, color.RGBA{mix(old.R, col.R), mix(old.G, col.G), mix(old.B, col.B), 255})
+		}
+	}
+}
+
+// disc fills a circle centered at (cx, cy).
+func (c *canvas) disc(cx, cy, r float64, col color.RGBA) {
+	for py := max(int(cy-r), 0); py <= min(int(cy+r), c.h-1); py++ {
+		for px := max(int(cx-r), 0); px <= min(int(cx+r), c.w-1); px++ {
+			dx, dy := float64(px)+0.5-cx, float64(py)+0.5-cy
+			if dx*dx+dy*dy <= r*r {
+				c.img.SetRGBA(px, py, col)
+			}
+		}
+	}
+}
+
+// line draws a segment width pixels thick. The part outside the image is clipped first.
+func (c *canvas) line(x0, y0, x1, y1, width float64, col color.RGBA) {
+	x0, y0, x1, y1, ok := clipSegment(x0, y0, x1, y1, -width, -width, float64(c.w)+width, float64(c.h)+width)
+	if !ok {
+		return
+	}
+	steps := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+	if width <= 1 {
+		for s := 0; s <= steps; s++ {
+			t := float64(s) / float64(max(steps, 1))
+			px, py := int(x0+(x1-x0)*t), int(y0+(y1-y0)*t)
+			if px >= 0 && px < c.w && py >= 0 && py < c.h {
+				c.img.SetRGBA(px, py, col)
+			}
+		}
+		return
+	}
+	for s := 0; s <= steps; s++ {
+		t := float64(s) / float64(max(steps, 1))
+		c.disc(x0+(x1-x0)*t, y0+(y1-y0)*t, width/2, col)
+	}
+}
+
+// pin draws a round marker with a white border and a label in the middle.
+func (c *canvas) pin(x, y float64, col color.RGBA, label string) {
+	c.disc(x, y, pinRadius+2, white)
+	c.disc(x, y, pinRadius, col)
+	scale := 2
+	if textWidth(label, scale) > 2*pinRadius-4 {
+		scale = 1
+	}
+	tx := int(math.Round(x)) - textWidth(label, scale)/2
+	ty := int(math.Round(y)) - glyphHeight*scale/2
+	c.text(tx, ty, label, scale, white)
+}
+
+// clipSegment clips a segment to a rectangle (Liang-Barsky).
+func clipSegment(x0, y0, x1, y1, minX, minY, maxX, maxY float64) (float64, float64, float64, float64, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := x1-x0, y1-y0
+	for _, e := range [][2]float64{{-dx, x0 - minX}, {dx, maxX - x0}, {-dy, y0 - minY}, {dy, maxY - y0}} {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, 0, 0, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return 0, 0, 0, 0, false
+		}
+	}
+	return x0 + t0*dx, y0 + t0*dy, x0 + t1*dx, y0 + t1*dy, true
+}
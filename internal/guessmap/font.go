@@ -0,0 +1,56 @@
+package guessmap
+
+import "image/color"
+
+// glyphs is a 5x7 bitmap font covering the characters the map labels use.
+var glyphs = map[rune][7]string{
+	'0': {"01110", "10001", "10011", "10101", "11001", "10001", "01110"},
+	'1': {"00100", "01100", "00100", "00100", "00100", "00100", "01110"},
+	'2': {"01110", "10001", "00001", "00010", "00100", "01000", "11111"},
+	'3': {"11110", "00001", "00001", "01110", "00001", "00001", "11110"},
+	'4': {"00010", "00110", "01010", "10010", "11111", "00010", "00010"},
+	'5': {"11111", "10000", "11110", "00001", "00001", "10001", "01110"},
+	'6': {"00110", "01000", "10000", "11110", "10001", "10001", "01110"},
+	'7': {"11111", "00001", "00010", "00100", "01000", "01000", "01000"},
+	'8': {"01110", "10001", "10001", "01110", "10001", "10001", "01110"},
+	'9': {"01110", "10001", "10001", "01111", "00001", "00010", "01100"},
+	'.': {"00000", "00000", "00000", "00000", "00000", "01100", "01100"},
+	'+': {"00000", "00100", "00100", "11111", "00100", "00100", "00000"},
+	'A': {"01110", "10001", "10001", "11111", "10001", "10001", "10001"},
+	'k': {"10000", "10000", "10010", "10100", "11000", "10100", "10010"},
+	'm': {"00000", "00000", "11010", "10101", "10101", "10101", "10101"},
+	' ': {"00000", "00000", "00000", "00000", "00000", "00000", "00000"},
+}
+
+const (
+	glyphWidth   = 5
+	glyphHeight  = 7
+	glyphAdvance = glyphWidth + 1
+)
+
+// textWidth is the width in pixels of s drawn at the given scale.
+func textWidth(s string, scale int) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return (n*glyphAdvance - 1) * scale
+}
+
+// text draws s with its top-left corner at (x, y), each font pixel scale pixels wide.
+// Characters the font lacks are left blank.
+func (c *canvas) text(x, y int, s string, scale int, col color.RGBA) {
+	for _, r := range s {
+		g, ok := glyphs[r]
+		if ok {
+			for row, bits := range g {
+				for colIdx, bit := range bits {
+					if bit == '1' {
+						c.rect(x+colIdx*scale, y+row*scale, scale, scale, col)
+					}
+				}
+			}
+		}
+		x += glyphAdvance * scale
+	}
+}
@@ -0,0 +1,34 @@
+package guessmap
+
+import (
+	_ "embed"
+	"encoding/json"
+	"sort"
+)
+
+// land.json holds hand-simplified coastlines, a few hundred points in all. They are only
+// meant to show roughly where on Earth the pins are, not to be accurate at city scale.
+//
+//go:embed land.json
+var landJSON []byte
+
+// landRings are the coastline rings as {lng, lat} points. Inland seas are rings of their own;
+// the even-odd fill leaves them as water.
+var landRings = func() [][][2]float64 {
+	var data struct {
+		Rings map[string][][2]float64 `json:"rings"`
+	}
+	if err := json.Unmarshal(landJSON, &data); err != nil {
+		panic("guessmap: invalid land.json: " + err.Error())
+	}
+	names := make([]string, 0, len(data.Rings))
+	for name := range data.Rings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rings := make([][][2]float64, 0, len(names))
+	for _, name := range names {
+		rings = append(rings, data.Rings[name])
+	}
+	return rings
+}()
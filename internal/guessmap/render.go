@@ -0,0 +1,285 @@
+// Package guessmap draws the result of a guess round as a PNG: the answer, every guess, the
+// lines between them and a distance legend. The basemap is a coarse coastline bundled with the
+// binary, so no map service is involved.
+package guessmap
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+// Size of the rendered image in pixels.
+const (
+	Width  = 800
+	Height = 480
+)
+
+const (
+	// margin keeps pins and the legend clear of the points at the edge of the view.
+	margin = 48
+	// minSpan is the narrowest view, as a fraction of the world's width (about 20 km at the
+	// equator), so close guesses still show some surroundings.
+	minSpan = 0.0005
+	// answerOnlySpan is the view of a round nobody guessed (about 800 km at the equator).
+	answerOnlySpan = 0.02
+	// maxLat is the latitude where Web Mercator is usually cut off.
+	maxLat = 85.05112878
+	// legendRows is how many guesses the legend lists before summarizing the rest.
+	legendRows = 10
+
+	earthRadius = 6371008.8
+	pinRadius   = 10
+)
+
+var (
+	oceanColor  = color.RGBA{170, 211, 223, 255}
+	landColor   = color.RGBA{242, 239, 233, 255}
+	coastColor  = color.RGBA{140, 165, 175, 255}
+	answerColor = color.RGBA{220, 38, 38, 255}
+	white       = color.RGBA{255, 255, 255, 255}
+	black       = color.RGBA{30, 30, 30, 255}
+	// guessColors are assigned by rank and repeat after the last one.
+	guessColors = []color.RGBA{
+		{37, 99, 235, 255},
+		{22, 163, 74, 255},
+		{234, 88, 12, 255},
+		{147, 51, 234, 255},
+		{13, 148, 136, 255},
+		{161, 98, 7, 255},
+		{219, 39, 119, 255},
+		{71, 85, 105, 255},
+	}
+)
+
+// RenderRound draws res as a PNG of Width x Height pixels, zoomed to fit the answer and guesses.
+func RenderRound(res *guess.RoundResult) ([]byte, error) {
+	if res == nil {
+		return nil, fmt.Errorf("no round result")
+	}
+	answer := project(res.AnswerLat, res.AnswerLng)
+	guesses := make([]point, len(res.Results))
+	for idx, r := range res.Results {
+		// Take the short way around the antimeridian.
+		lng := r.GuessLng
+		for lng-res.AnswerLng > 180 {
+			lng -= 360
+		}
+		for lng-res.AnswerLng < -180 {
+			lng += 360
+		}
+		guesses[idx] = project(r.GuessLat, lng)
+	}
+	v := fitView(answer, guesses)
+
+	c := newCanvas(Width, Height)
+	c.fill(oceanColor)
+	drawLand(c, v)
+
+	ax, ay := v.pixel(answer)
+	for idx, g := range guesses {
+		gx, gy := v.pixel(g)
+		c.line(gx, gy, ax, ay, 3, guessColor(idx))
+	}
+	// Draw the worst guesses first so the best ones end up on top.
+	for idx := len(guesses) - 1; idx >= 0; idx-- {
+		gx, gy := v.pixel(guesses[idx])
+		c.pin(gx, gy, guessColor(idx), fmt.Sprint(idx+1))
+	}
+	c.pin(ax, ay, answerColor, "A")
+
+	drawLegend(c, res.Results)
+	drawScaleBar(c, v, res.AnswerLat)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func guessColor(rank int) color.RGBA {
+	return guessColors[rank%len(guessColors)]
+}
+
+// point is a Web Mercator position, with the world spanning 0..1 on both axes.
+// x may fall outside that range for longitudes unwrapped across the antimeridian.
+type point struct{ x, y float64 }
+
+func project(lat, lng float64) point {
+	lat = math.Max(-maxLat, math.Min(maxLat, lat))
+	phi := lat * math.Pi / 180
+	return point{
+		x: (lng + 180) / 360,
+		y: (1 - math.Log(math.Tan(phi)+1/math.Cos(phi))/math.Pi) / 2,
+	}
+}
+
+// view maps world positions to pixels: origin is the world position of the top-left pixel.
+type view struct {
+	origin point
+	// scale is pixels per world width.
+	scale float64
+}
+
+func (v view) pixel(p point) (float64, float64) {
+	return (p.x - v.origin.x) * v.scale, (p.y - v.origin.y) * v.scale
+}
+
+// fitView zooms to show every point inside the margins, within the minSpan and whole-world limits.
+func fitView(answer point, guesses []point) view {
+	minX, maxX, minY, maxY := answer.x, answer.x, answer.y, answer.y
+	for _, g := range guesses {
+		minX, maxX = math.Min(minX, g.x), math.Max(maxX, g.x)
+		minY, maxY = math.Min(minY, g.y), math.Max(maxY, g.y)
+	}
+	span := minSpan
+	if len(guesses) == 0 {
+		span = answerOnlySpan
+	}
+	innerW, innerH := float64(Width-2*margin), float64(Height-2*margin)
+	scale := math.Min(innerW/math.Max(maxX-minX, span), innerH/math.Max(maxY-minY, span*innerH/innerW))
+	// Never zoom out past the whole world.
+	scale = math.Max(scale, Width)
+
+	center := point{(minX + maxX) / 2, (minY + maxY) / 2}
+	halfW, halfH := Width/2/scale, Height/2/scale
+	// Keep the view vertically inside the map; there is nothing beyond the cut-off latitudes.
+	if 2*halfH >= 1 {
+		center.y = 0.5
+	} else {
+		center.y = math.Max(halfH, math.Min(1-halfH, center.y))
+	}
+	return view{origin: point{center.x - halfW, center.y - halfH}, scale: scale}
+}
+
+// drawLand fills the coastline rings and outlines them, repeating the world to either side so
+// views across the antimeridian are covered.
+func drawLand(c *canvas, v view) {
+	var edges [][4]float64
+	for shift := -1.0; shift <= 1; shift++ {
+		for _, ring := range landRings {
+			pts := make([][2]float64, len(ring))
+			for idx, ll := range ring {
+				p := project(ll[1], ll[0])
+				p.x += shift
+				x, y := v.pixel(p)
+				pts[idx] = [2]float64{x, y}
+			}
+			for idx := range pts {
+				a, b := pts[idx], pts[(idx+1)%len(pts)]
+				edges = append(edges, [4]float64{a[0], a[1], b[0], b[1]})
+			}
+		}
+	}
+
+	// Even-odd scanline fill over all rings at once, so inland seas stay water.
+	var xs []float64
+	for y := 0; y < c.h; y++ {
+		sy := float64(y) + 0.5
+		xs = xs[:0]
+		for _, e := range edges {
+			y0, y1 := e[1], e[3]
+			if (y0 <= sy) == (y1 <= sy) {
+				continue
+			}
+			xs = append(xs, e[0]+(sy-y0)/(y1-y0)*(e[2]-e[0]))
+		}
+		sort.Float64s(xs)
+		for idx := 0; idx+1 < len(xs); idx += 2 {
+			from := int(math.Ceil(xs[idx] - 0.5))
+			to := int(math.Ceil(xs[idx+1] - 0.5))
+			for x := max(from, 0); x < min(to, c.w); x++ {
+				c.img.SetRGBA(x, y, landColor)
+			}
+		}
+	}
+
+	for _, e := range edges {
+		// Skip edges far outside the image; they can be huge at high zoom.
+		if math.Max(e[0], e[2]) < 0 || math.Min(e[0], e[2]) > float64(c.w) ||
+			math.Max(e[1], e[3]) < 0 || math.Min(e[1], e[3]) > float64(c.h) {
+			continue
+		}
+		c.line(e[0], e[1], e[2], e[3], 1, coastColor)
+	}
+}
+
+// drawLegend lists each guess's rank pin and distance in the top-left corner.
+func drawLegend(c *canvas, results []guess.GuessResult) {
+	if len(results) == 0 {
+		return
+	}
+	const (
+		rowHeight = 24
+		textScale = 2
+		pad       = 8
+	)
+	rows := len(results)
+	if rows > legendRows {
+		rows = legendRows + 1
+	}
+	width := 0
+	for _, r := range results[:min(len(results), legendRows)] {
+		width = max(width, textWidth(guess.FormatDistance(r.DistanceMeters), textScale))
+	}
+	more := ""
+	if len(results) > legendRows {
+		more = fmt.Sprintf("+%d", len(results)-legendRows)
+		width = max(width, textWidth(more, textScale))
+	}
+	boxW := pad + 2*pinRadius + pad + width + pad
+	boxH := pad + rows*rowHeight
+	c.blendRect(pad, pad, boxW, boxH, white, 0.85)
+
+	for idx := 0; idx < rows; idx++ {
+		top := 2*pad + idx*rowHeight
+		textY := top + (rowHeight-glyphHeight*textScale)/2 - pad/2
+		if idx == legendRows {
+			c.text(2*pad, textY, more, textScale, black)
+			break
+		}
+		r := results[idx]
+		cy := float64(top + rowHeight/2 - pad/2)
+		c.pin(float64(2*pad+pinRadius), cy, guessColor(idx), fmt.Sprint(idx+1))
+		c.text(3*pad+2*pinRadius, textY, guess.FormatDistance(r.DistanceMeters), textScale, black)
+	}
+}
+
+// drawScaleBar draws a bar of a round distance in the bottom-left corner, measured at lat.
+func drawScaleBar(c *canvas, v view, lat float64) {
+	metersPerPixel := 2 * math.Pi * earthRadius * math.Cos(lat*math.Pi/180) / v.scale
+	if metersPerPixel <= 0 {
+		return
+	}
+	meters := niceDistance(150 * metersPerPixel)
+	barW := int(math.Round(meters / metersPerPixel))
+	label := fmt.Sprintf("%.0f m", meters)
+	if meters >= 1000 {
+		label = fmt.Sprintf("%.0f km", meters/1000)
+	}
+
+	const textScale = 2
+	x, y := 12, c.h-40
+	c.blendRect(x-4, y-4, max(barW, textWidth(label, textScale))+8, 36, white, 0.85)
+	c.text(x, y, label, textScale, black)
+	c.rect(x, y+22, barW, 3, black)
+	c.rect(x, y+17, 2, 8, black)
+	c.rect(x+barW-2, y+17, 2, 8, black)
+}
+
+// niceDistance rounds m down to 1, 2 or 5 times a power of ten.
+func niceDistance(m float64) float64 {
+	pow := math.Pow(10, math.Floor(math.Log10(m)))
+	for _, f := range []float64{5, 2, 1} {
+		if f*pow <= m {
+			return f * pow
+		}
+	}
+	return pow
+}
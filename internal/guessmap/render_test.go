@@ -0,0 +1,80 @@
+package guessmap
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/susu3304/nkmzbot/internal/guess"
+)
+
+func TestRenderRound(t *testing.T) {
+	res := &guess.RoundResult{
+		AnswerLat: 35.681236,
+		AnswerLng: 139.767125,
+		Results: []guess.GuessResult{
+			{UserID: "1", GuessLat: 34.702485, GuessLng: 135.495951, DistanceMeters: 403000},
+			{UserID: "2", GuessLat: 43.068661, GuessLng: 141.350755, DistanceMeters: 831000},
+		},
+	}
+	data, err := RenderRound(res)
+	if err != nil {
+		t.Fatalf("RenderRound: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != Width || b.Dy() != Height {
+		t.Fatalf("size = %dx%d, want %dx%d", b.Dx(), b.Dy(), Width, Height)
+	}
+
+	v := fitView(project(res.AnswerLat, res.AnswerLng), []point{
+		project(res.Results[0].GuessLat, res.Results[0].GuessLng),
+		project(res.Results[1].GuessLat, res.Results[1].GuessLng),
+	})
+	// Sample just inside each pin's edge, clear of the label.
+	at := func(lat, lng float64) (r, g, b uint8) {
+		x, y := v.pixel(project(lat, lng))
+		c := img.At(int(x)-pinRadius+3, int(y))
+		cr, cg, cb, _ := c.RGBA()
+		return uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8)
+	}
+	if r, g, b := at(res.AnswerLat, res.AnswerLng); r != answerColor.R || g != answerColor.G || b != answerColor.B {
+		t.Errorf("answer pin = (%d,%d,%d), want %v", r, g, b, answerColor)
+	}
+	want := guessColor(1)
+	if r, g, b := at(res.Results[1].GuessLat, res.Results[1].GuessLng); r != want.R || g != want.G || b != want.B {
+		t.Errorf("second guess pin = (%d,%d,%d), want %v", r, g, b, want)
+	}
+}
+
+func TestFitViewAntimeridian(t *testing.T) {
+	// Wellington and Honolulu are closer across the antimeridian than around the world.
+	answer := project(-41.2865, 174.7762)
+	guessPoint := project(21.3069, -157.8583+360)
+	v := fitView(answer, []point{guessPoint})
+	for _, p := range []point{answer, guessPoint} {
+		x, y := v.pixel(p)
+		if x < 0 || x > Width || y < 0 || y > Height {
+			t.Errorf("point %v drawn outside the image at (%.0f, %.0f)", p, x, y)
+		}
+	}
+	if v.scale <= Width {
+		t.Errorf("scale = %.0f, want zoomed in past the whole world", v.scale)
+	}
+}
+
+func TestNiceDistance(t *testing.T) {
+	for _, tt := range []struct{ in, want float64 }{
+		{149, 100},
+		{230, 200},
+		{999, 500},
+		{1000, 1000},
+		{7300, 5000},
+	} {
+		if got := niceDistance(tt.in); got != tt.want {
+			t.Errorf("niceDistance(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
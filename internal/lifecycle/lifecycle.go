@@ -0,0 +1,123 @@
+// Package lifecycle coordinates startup and graceful shutdown of long-running components.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type stopHook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager runs components and stops them in the reverse order they were registered,
+// so dependencies registered first (e.g. the database) are closed last.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	hooks  []stopHook
+	failed chan error
+
+	// exit is called when a second signal arrives during shutdown.
+	exit func(code int)
+}
+
+// New returns a manager whose context is canceled when shutdown begins.
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ctx:    ctx,
+		cancel: cancel,
+		failed: make(chan error, 1),
+		exit:   os.Exit,
+	}
+}
+
+// Context is canceled as soon as shutdown begins.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnStop registers a stop hook. Hooks run in reverse registration order.
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, stopHook{name: name, stop: stop})
+}
+
+// Go runs fn in the background. If it returns an error before shutdown has begun,
+// Wait treats it like a signal and shuts everything down.
+func (m *Manager) Go(name string, fn func(ctx context.Context) error) {
+	go func() {
+		err := fn(m.ctx)
+		if err == nil || m.ctx.Err() != nil {
+			return
+		}
+		select {
+		case m.failed <- fmt.Errorf("%s: %w", name, err):
+		default:
+		}
+	}()
+}
+
+// Wait blocks until SIGINT/SIGTERM arrives or a component started with Go fails, then runs
+// the stop hooks within timeout. A second signal during shutdown exits immediately.
+func (m *Manager) Wait(timeout time.Duration) error {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	var cause error
+	select {
+	case sig := <-sigs:
+		slog.Info("received signal, shutting down", "signal", sig.String(), "timeout", timeout)
+	case cause = <-m.failed:
+		slog.Error("component failed, shutting down", "err", cause)
+	}
+
+	go func() {
+		sig := <-sigs
+		slog.Warn("received second signal, forcing exit", "signal", sig.String())
+		m.exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// Shutdown cancels Context and runs every stop hook in reverse order. Hooks share ctx's deadline;
+// a failing hook is logged and does not prevent later hooks from running.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.hooks = nil
+	m.mu.Unlock()
+
+	var errs []error
+	for idx := len(hooks) - 1; idx >= 0; idx-- {
+		h := hooks[idx]
+		started := time.Now()
+		if err := h.stop(ctx); err != nil {
+			slog.Error("failed to stop component", "component", h.name, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		slog.Info("stopped component", "component", h.name, "duration", time.Since(started))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownRunsHooksInReverseOrder(t *testing.T) {
+	m := New()
+	var order []string
+	for _, name := range []string{"database", "discord", "api"} {
+		name := name
+		m.OnStop(name, func(ctx context.Context) error {
+			order = append(order, name)
+			if name == "discord" {
+				return errors.New("boom")
+			}
+			return nil
+		})
+	}
+
+	err := m.Shutdown(context.Background())
+	if got := strings.Join(order, ","); got != "api,discord,database" {
+		t.Fatalf("stop order = %s", got)
+	}
+	if err == nil || !strings.Contains(err.Error(), "discord: boom") {
+		t.Fatalf("err = %v", err)
+	}
+	if m.Context().Err() == nil {
+		t.Fatal("context not canceled by Shutdown")
+	}
+}
+
+func TestWaitStopsWhenComponentFails(t *testing.T) {
+	m := New()
+	stopped := make(chan struct{})
+	m.OnStop("api", func(ctx context.Context) error {
+		close(stopped)
+		return nil
+	})
+	m.Go("api", func(ctx context.Context) error {
+		return errors.New("address already in use")
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- m.Wait(time.Second) }()
+
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "address already in use") {
+			t.Fatalf("Wait err = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return after component failure")
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("stop hook not run")
+	}
+}
+
+func TestGoIgnoresErrorsAfterShutdown(t *testing.T) {
+	m := New()
+	release := make(chan struct{})
+	m.Go("api", func(ctx context.Context) error {
+		<-release
+		return errors.New("closed")
+	})
+	_ = m.Shutdown(context.Background())
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case err := <-m.failed:
+		t.Fatalf("unexpected failure after shutdown: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,89 @@
+// Package metrics holds the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "nkmzbot"
+
+// Registry is the registry served on /metrics. It is separate from the global default
+// registry so tests and other packages can't accidentally register into it.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Interactions counts handled Discord interactions by command and interaction type.
+	Interactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "interactions_total",
+		Help:      "Discord interactions handled, by command and interaction type.",
+	}, []string{"command", "type"})
+
+	// HandlerDuration observes how long interaction handlers take.
+	HandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "handler_duration_seconds",
+		Help:      "Time spent in interaction handlers, by command.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10, 20},
+	}, []string{"command"})
+
+	// ReminderSends counts unpaid reminders posted by the reminder worker.
+	ReminderSends = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reminder_sends_total",
+		Help:      "Unpaid settlement reminders posted.",
+	})
+
+	// ReminderFailures counts reminders that could not be built or sent.
+	ReminderFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reminder_failures_total",
+		Help:      "Reminder worker failures, by stage (load, build, send, update).",
+	}, []string{"stage"})
+
+	// ScheduledTaskLag observes how late /jikan tasks run compared to their scheduled time.
+	ScheduledTaskLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduled_task_lag_seconds",
+		Help:      "Delay between a scheduled task's due time and its execution.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 15, 60, 300},
+	})
+
+	// ScheduledTaskRuns counts executed scheduled tasks.
+	ScheduledTaskRuns = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduled_task_runs_total",
+		Help:      "Scheduled tasks executed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Interactions,
+		HandlerDuration,
+		ReminderSends,
+		ReminderFailures,
+		ScheduledTaskLag,
+		ScheduledTaskRuns,
+	)
+}
+
+// ObserveHandler records one handled interaction.
+func ObserveHandler(command, interactionType string, started time.Time) {
+	Interactions.WithLabelValues(command, interactionType).Inc()
+	HandlerDuration.WithLabelValues(command).Observe(time.Since(started).Seconds())
+}
+
+// RegisterGaugeFunc exports a value that is read on every scrape, e.g. gateway latency.
+func RegisterGaugeFunc(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
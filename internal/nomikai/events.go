@@ -0,0 +1,142 @@
+package nomikai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/susu3304/nkmzbot/internal/db"
+)
+
+// MaxEventNameLength is the longest name an event can have.
+const MaxEventNameLength = 32
+
+// Target selects the event a command acts on among the active events of ChannelID: the one
+// Event names (by name or as #id), or else UserID's current event there, or else the most
+// recent one.
+type Target struct {
+	ChannelID string
+	UserID    string
+	Event     string
+}
+
+// noEventError means the channel has no active event to act on, or none named Event.
+type noEventError struct {
+	Event string
+}
+
+func (e *noEventError) Error() string {
+	if e.Event == "" {
+		return "セッションが開始されていません"
+	}
+	return fmt.Sprintf("飲み会「%s」は開催されていません", e.Event)
+}
+
+// IsNoEvent reports whether err means there was no active event to act on.
+func IsNoEvent(err error) bool {
+	var e *noEventError
+	return errors.As(err, &e)
+}
+
+// ValidateEventName checks the name of a new event. Names starting with # would be mistaken for
+// event IDs.
+func ValidateEventName(name string) error {
+	if len([]rune(name)) > MaxEventNameLength {
+		return fmt.Errorf("飲み会の名前は %d 文字以内で指定してください", MaxEventNameLength)
+	}
+	if strings.HasPrefix(name, "#") {
+		return errors.New("飲み会の名前は # で始められません")
+	}
+	return nil
+}
+
+// EventLabel names an event in messages: 「name」, or #id for unnamed events.
+func EventLabel(ev *db.NomikaiEvent) string {
+	if ev.Name != "" {
+		return "「" + ev.Name + "」"
+	}
+	return fmt.Sprintf("#%d", ev.ID)
+}
+
+// matchesEvent reports whether ev is the event given as ref, by name or as #id.
+func matchesEvent(ev *db.NomikaiEvent, ref string) bool {
+	if strings.HasPrefix(ref, "#") {
+		id, err := strconv.ParseInt(ref[1:], 10, 64)
+		return err == nil && id == ev.ID
+	}
+	return strings.EqualFold(ev.Name, ref)
+}
+
+// ResolveEvent returns the active event t selects.
+func (s *Service) ResolveEvent(ctx context.Context, t Target) (*db.NomikaiEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.activeEvent(ctx, t)
+}
+
+func (s *Service) activeEvent(ctx context.Context, t Target) (*db.NomikaiEvent, error) {
+	events, err := s.db.ActiveEvents(ctx, t.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	ref := strings.TrimSpace(t.Event)
+	if ref != "" {
+		for idx := range events {
+			if matchesEvent(&events[idx], ref) {
+				return &events[idx], nil
+			}
+		}
+		return nil, &noEventError{Event: ref}
+	}
+	if len(events) == 0 {
+		return nil, &noEventError{}
+	}
+	if t.UserID != "" {
+		current, err := s.db.CurrentEventID(ctx, t.ChannelID, t.UserID)
+		if err != nil {
+			return nil, err
+		}
+		for idx := range events {
+			if events[idx].ID == current {
+				return &events[idx], nil
+			}
+		}
+	}
+	return &events[0], nil
+}
+
+// ActiveEvents lists the active events of the channel, most recent first, and which of them
+// is userID's current event.
+func (s *Service) ActiveEvents(ctx context.Context, channelID, userID string) ([]db.NomikaiEvent, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events, err := s.db.ActiveEvents(ctx, channelID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(events) == 0 {
+		return nil, 0, nil
+	}
+	current, err := s.activeEvent(ctx, Target{ChannelID: channelID, UserID: userID})
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, current.ID, nil
+}
+
+// Use makes the event t selects the user's current event in the channel, which their commands
+// act on when they do not name one.
+func (s *Service) Use(ctx context.Context, t Target) (*db.NomikaiEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev, err := s.activeEvent(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.SetCurrentEvent(ctx, t.ChannelID, t.UserID, ev.ID); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
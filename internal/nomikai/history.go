@@ -0,0 +1,200 @@
+package nomikai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/db"
+)
+
+// MaxHistoryEvents is how many events /nomikai history lists.
+const MaxHistoryEvents = 20
+
+// History lists the guild's most recent events with their totals and unpaid amounts, newest
+// first. A non-empty channelID lists only the events of that channel.
+func (s *Service) History(ctx context.Context, guildID int64, channelID string) (string, error) {
+	events, err := s.db.ListEvents(ctx, guildID, channelID, MaxHistoryEvents)
+	if err != nil {
+		return "", err
+	}
+	if len(events) == 0 {
+		if channelID != "" {
+			return "このチャンネルの飲み会はまだありません", nil
+		}
+		return "このサーバーの飲み会はまだありません", nil
+	}
+
+	var b strings.Builder
+	if channelID != "" {
+		b.WriteString("このチャンネルの飲み会 (新しい順):\n")
+	} else {
+		b.WriteString("このサーバーの飲み会 (新しい順):\n")
+	}
+	for _, e := range events {
+		fmt.Fprintf(&b, "#%d", e.ID)
+		if e.Name != "" {
+			fmt.Fprintf(&b, " 「%s」", e.Name)
+		}
+		fmt.Fprintf(&b, " %s %s", e.CreatedAt.Format("2006-01-02"), eventStatusLabel(e.Status))
+		if channelID == "" {
+			fmt.Fprintf(&b, " <#%s>", e.ChannelID)
+		}
+		fmt.Fprintf(&b, " 参加 %d名 / 総支出 %d 円", e.Members, e.Total)
+		if e.Unpaid > 0 {
+			fmt.Fprintf(&b, " / 未払い %d 円", e.Unpaid)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("詳細は /nomikai show で確認できます")
+	return b.String(), nil
+}
+
+// Show describes an event of the guild in full: its members, payments, settlement payments
+// and pending settlement tasks. Closed events can be shown too.
+func (s *Service) Show(ctx context.Context, guildID, eventID int64) (string, error) {
+	ev, err := s.eventByID(ctx, guildID, eventID)
+	if err != nil {
+		return "", err
+	}
+	members, err := s.db.Members(ctx, ev.ID)
+	if err != nil {
+		return "", err
+	}
+	pays, err := s.db.Payments(ctx, ev.ID)
+	if err != nil {
+		return "", err
+	}
+	settlements, err := s.db.SettlementPayments(ctx, ev.ID)
+	if err != nil {
+		return "", err
+	}
+	tasks, err := s.db.ListPendingSettlementTasks(ctx, ev.ID)
+	if err != nil {
+		return "", err
+	}
+
+	paidSum := make(map[string]int64)
+	var total int64
+	for _, p := range pays {
+		paidSum[p.PayerID] += p.Amount
+		total += p.Amount
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "飲み会 #%d", ev.ID)
+	if ev.Name != "" {
+		fmt.Fprintf(&b, "「%s」", ev.Name)
+	}
+	fmt.Fprintf(&b, " <#%s> (%s)\n", ev.ChannelID, eventStatusLabel(ev.Status))
+	fmt.Fprintf(&b, "主催: <@%s>\n", ev.OrganizerID)
+	fmt.Fprintf(&b, "開始: %s", ev.CreatedAt.Format("2006-01-02 15:04"))
+	if ev.ClosedAt != nil {
+		fmt.Fprintf(&b, " / 終了: %s", ev.ClosedAt.Format("2006-01-02 15:04"))
+	}
+	fmt.Fprintf(&b, "\n総支出: %d 円\n", total)
+
+	fmt.Fprintf(&b, "\n参加者 (%d名):\n", len(members))
+	for _, m := range members {
+		fmt.Fprintf(&b, "<@%s> weight=%.2f paid=%d\n", m.UserID, m.Weight, paidSum[m.UserID])
+	}
+	if len(pays) > 0 {
+		b.WriteString("\n立替:\n")
+		for _, p := range pays {
+			fmt.Fprintf(&b, "・<@%s> %d 円", p.PayerID, p.Amount)
+			if p.Memo != "" {
+				fmt.Fprintf(&b, " (%s)", p.Memo)
+			}
+			ben, err := s.db.PaymentBeneficiaries(ctx, p.ID)
+			if err != nil {
+				return "", err
+			}
+			if len(ben) > 0 {
+				sort.Strings(ben)
+				b.WriteString(" 対象: " + mentions(ben))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(settlements) > 0 {
+		b.WriteString("\n精算の支払い:\n")
+		for _, p := range settlements {
+			fmt.Fprintf(&b, "・<@%s> → <@%s> %d 円", p.PayerID, p.PayeeID, p.Amount)
+			if p.Memo != "" {
+				fmt.Fprintf(&b, " (%s)", p.Memo)
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(tasks) > 0 {
+		b.WriteString("\n未払いタスク:\n")
+		for _, t := range tasks {
+			fmt.Fprintf(&b, "<@%s> → <@%s>: %d 円\n", t.PayerID, t.PayeeID, t.Amount)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// Reopen makes a closed event of the guild active again in its channel, so that late
+// settlement payments can be recorded. Only its organizer can, unless manager is set, and
+// not while another event with the same name is active there. The event becomes userID's
+// current event in the channel, so their commands act on it rather than on other events there.
+func (s *Service) Reopen(ctx context.Context, guildID, eventID int64, userID string, manager bool) (*db.NomikaiEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ev, err := s.eventByID(ctx, guildID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if ev.Status == "active" {
+		return nil, fmt.Errorf("飲み会 #%d は開催中です", ev.ID)
+	}
+	if !manager && ev.OrganizerID != userID {
+		return nil, errors.New("再開できるのは主催者かサーバー管理者のみです")
+	}
+	active, err := s.db.ActiveEvents(ctx, ev.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range active {
+		if strings.EqualFold(a.Name, ev.Name) {
+			return nil, fmt.Errorf("<#%s> では同じ名前の飲み会 %s が開催中です。先に /nomikai stop で終了してください", ev.ChannelID, EventLabel(&a))
+		}
+	}
+	if err := s.db.ReopenEvent(ctx, ev.ID); err != nil {
+		return nil, err
+	}
+	if err := s.db.SetCurrentEvent(ctx, ev.ChannelID, userID, ev.ID); err != nil {
+		return nil, err
+	}
+	ev.Status, ev.ClosedAt = "active", nil
+	return ev, nil
+}
+
+func (s *Service) eventByID(ctx context.Context, guildID, eventID int64) (*db.NomikaiEvent, error) {
+	ev, err := s.db.EventByID(ctx, guildID, eventID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("飲み会 #%d が見つかりません", eventID)
+	}
+	return ev, err
+}
+
+func eventStatusLabel(status string) string {
+	if status == "active" {
+		return "開催中"
+	}
+	return "終了"
+}
+
+func mentions(ids []string) string {
+	parts := make([]string, len(ids))
+	for idx, id := range ids {
+		parts[idx] = fmt.Sprintf("<@%s>", id)
+	}
+	return strings.Join(parts, ", ")
+}
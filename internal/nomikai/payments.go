@@ -0,0 +1,242 @@
+package nomikai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/susu3304/nkmzbot/internal/db"
+)
+
+// PaymentEdit is a change to a recorded payment. Empty and nil fields are left as they are.
+type PaymentEdit struct {
+	PayerID string
+	Amount  *int64
+	Memo    *string
+	// Beneficiaries replaces who the payment was for; AllMembers makes it everyone's again.
+	Beneficiaries []string
+	AllMembers    bool
+}
+
+func (e PaymentEdit) empty() bool {
+	return e.PayerID == "" && e.Amount == nil && e.Memo == nil && len(e.Beneficiaries) == 0 && !e.AllMembers
+}
+
+// Payments lists the payments of the event t selects with their IDs, followed by the edits and
+// deletions made to them.
+func (s *Service) Payments(ctx context.Context, t Target) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev, err := s.activeEvent(ctx, t)
+	if IsNoEvent(err) {
+		return err.Error(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	pays, err := s.db.Payments(ctx, ev.ID)
+	if err != nil {
+		return "", err
+	}
+	audits, err := s.db.PaymentAudits(ctx, ev.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(pays) == 0 && len(audits) == 0 {
+		return "立替はまだ記録されていません", nil
+	}
+	edited := make(map[int64]bool, len(audits))
+	for _, a := range audits {
+		edited[a.PaymentID] = true
+	}
+
+	var b strings.Builder
+	if ev.Name != "" {
+		fmt.Fprintf(&b, "飲み会%s\n", EventLabel(ev))
+	}
+	fmt.Fprintf(&b, "立替 (%d件):\n", len(pays))
+	for _, p := range pays {
+		ben, err := s.db.PaymentBeneficiaries(ctx, p.ID)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "#%d %s", p.ID, formatPaymentValues(paymentValues(&p, ben)))
+		if p.RecordedBy != "" && p.RecordedBy != p.PayerID {
+			fmt.Fprintf(&b, " 記録: <@%s>", p.RecordedBy)
+		}
+		if edited[p.ID] {
+			b.WriteString(" ✏️")
+		}
+		b.WriteString("\n")
+	}
+	if len(audits) > 0 {
+		b.WriteString("\n変更履歴:\n")
+		for _, a := range audits {
+			fmt.Fprintf(&b, "・%s #%d <@%s>: ", a.CreatedAt.Format("01-02 15:04"), a.PaymentID, a.ActorID)
+			if a.New == nil {
+				fmt.Fprintf(&b, "削除 %s\n", formatPaymentValues(a.Old))
+			} else {
+				fmt.Fprintf(&b, "編集 %s → %s\n", formatPaymentValues(a.Old), formatPaymentValues(*a.New))
+			}
+		}
+	}
+	b.WriteString("修正は /nomikai payment edit、取り消しは /nomikai payment delete で行えます")
+	return b.String(), nil
+}
+
+// EditPayment changes a payment of an active event in the guild. Only its payer, whoever
+// recorded it and the event's organizer can. The change is kept in the audit trail, and the
+// event's settlement has to be computed again.
+func (s *Service) EditPayment(ctx context.Context, guildID, paymentID int64, actorID string, edit PaymentEdit) (string, error) {
+	if edit.empty() {
+		return "", errors.New("変更する項目を指定してください")
+	}
+	if edit.Amount != nil && *edit.Amount == 0 {
+		return "", errors.New("金額に 0 は指定できません。取り消すには /nomikai payment delete を使ってください")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, old, err := s.paymentForChange(ctx, guildID, paymentID, actorID)
+	if err != nil {
+		return "", err
+	}
+	next := old
+	if edit.PayerID != "" {
+		next.PayerID = edit.PayerID
+	}
+	if edit.Amount != nil {
+		next.Amount = *edit.Amount
+	}
+	if edit.Memo != nil {
+		next.Memo = strings.TrimSpace(*edit.Memo)
+	}
+	if edit.AllMembers {
+		next.Beneficiaries = nil
+	} else if len(edit.Beneficiaries) > 0 {
+		next.Beneficiaries = edit.Beneficiaries
+	}
+
+	joined, err := s.ensureMembers(ctx, p.EventID, append([]string{next.PayerID}, next.Beneficiaries...))
+	if err != nil {
+		return "", err
+	}
+	cleared, err := s.db.UpdatePayment(ctx, &db.NomikaiPaymentAudit{
+		EventID:   p.EventID,
+		PaymentID: p.ID,
+		Action:    db.NomikaiPaymentEdited,
+		ActorID:   actorID,
+		Old:       old,
+		New:       &next,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "立替 #%d を編集しました\n変更前: %s\n変更後: %s", p.ID, formatPaymentValues(old), formatPaymentValues(next))
+	if len(joined) > 0 {
+		b.WriteString("\n参加登録: " + mentions(joined))
+	}
+	if cleared {
+		b.WriteString("\n" + settlementInvalidated)
+	}
+	return b.String(), nil
+}
+
+// DeletePayment deletes a payment of an active event in the guild, under the same rules as
+// EditPayment.
+func (s *Service) DeletePayment(ctx context.Context, guildID, paymentID int64, actorID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, old, err := s.paymentForChange(ctx, guildID, paymentID, actorID)
+	if err != nil {
+		return "", err
+	}
+	cleared, err := s.db.DeletePayment(ctx, &db.NomikaiPaymentAudit{
+		EventID:   p.EventID,
+		PaymentID: p.ID,
+		Action:    db.NomikaiPaymentDeleted,
+		ActorID:   actorID,
+		Old:       old,
+	})
+	if err != nil {
+		return "", err
+	}
+	msg := fmt.Sprintf("立替 #%d を削除しました: %s", p.ID, formatPaymentValues(old))
+	if cleared {
+		msg += "\n" + settlementInvalidated
+	}
+	return msg, nil
+}
+
+const settlementInvalidated = "これまでの精算結果は無効になりました。/nomikai settle で精算し直してください"
+
+// paymentForChange loads a payment actorID may change, with its current values.
+func (s *Service) paymentForChange(ctx context.Context, guildID, paymentID int64, actorID string) (*db.NomikaiPayment, db.NomikaiPaymentValues, error) {
+	p, err := s.db.PaymentByID(ctx, guildID, paymentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, db.NomikaiPaymentValues{}, fmt.Errorf("立替 #%d が見つかりません", paymentID)
+	}
+	if err != nil {
+		return nil, db.NomikaiPaymentValues{}, err
+	}
+	ev, err := s.eventByID(ctx, guildID, p.EventID)
+	if err != nil {
+		return nil, db.NomikaiPaymentValues{}, err
+	}
+	if ev.Status != "active" {
+		return nil, db.NomikaiPaymentValues{}, fmt.Errorf("飲み会 #%d は終了しています。変更するには /nomikai reopen で再開してください", ev.ID)
+	}
+	if actorID != p.PayerID && actorID != p.RecordedBy && actorID != ev.OrganizerID {
+		return nil, db.NomikaiPaymentValues{}, errors.New("立替を変更できるのは支払者・記録した人・主催者のみです")
+	}
+	ben, err := s.db.PaymentBeneficiaries(ctx, p.ID)
+	if err != nil {
+		return nil, db.NomikaiPaymentValues{}, err
+	}
+	return p, paymentValues(p, ben), nil
+}
+
+// ensureMembers adds the users who are not members of the event yet and returns them.
+func (s *Service) ensureMembers(ctx context.Context, eventID int64, ids []string) ([]string, error) {
+	members, err := s.db.Members(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[string]bool, len(members))
+	for _, m := range members {
+		present[m.UserID] = true
+	}
+	var joined []string
+	for _, id := range ids {
+		if id == "" || present[id] {
+			continue
+		}
+		if err := s.db.UpsertMember(ctx, eventID, id, 1.0); err != nil {
+			return nil, err
+		}
+		present[id] = true
+		joined = append(joined, id)
+	}
+	return joined, nil
+}
+
+func paymentValues(p *db.NomikaiPayment, beneficiaries []string) db.NomikaiPaymentValues {
+	sort.Strings(beneficiaries)
+	return db.NomikaiPaymentValues{PayerID: p.PayerID, Amount: p.Amount, Memo: p.Memo, Beneficiaries: beneficiaries}
+}
+
+func formatPaymentValues(v db.NomikaiPaymentValues) string {
+	out := fmt.Sprintf("<@%s> %d 円", v.PayerID, v.Amount)
+	if v.Memo != "" {
+		out += fmt.Sprintf(" (%s)", v.Memo)
+	}
+	if len(v.Beneficiaries) > 0 {
+		out += " 対象: " + mentions(v.Beneficiaries)
+	}
+	return out
+}
@@ -22,46 +22,72 @@ func NewService(database *db.DB) *Service {
 	return &Service{db: database}
 }
 
-func (s *Service) StartSession(ctx context.Context, channelID string, guildID int64, organizerID string, roundingUnit int, remainderStrategy string) error {
+// StartSession starts an event called name in the channel, which may already have other
+// events, and makes it the organizer's current event there. If an event with the name is
+// already active it becomes the current event instead.
+func (s *Service) StartSession(ctx context.Context, channelID string, guildID int64, organizerID, name string, roundingUnit int, remainderStrategy string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if channelID == "" || guildID == 0 || organizerID == "" {
 		return errors.New("必要な情報が不足しています")
 	}
-	if _, err := s.db.ActiveEventByChannel(ctx, channelID); err == nil {
-		// already active; do nothing
-		return nil
+	if err := ValidateEventName(name); err != nil {
+		return err
 	}
-	_, err := s.db.CreateEvent(ctx, guildID, channelID, organizerID, roundingUnit, remainderStrategy)
-	return err
+	events, err := s.db.ActiveEvents(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	var id int64
+	for _, ev := range events {
+		if strings.EqualFold(ev.Name, name) {
+			// already active; do nothing
+			id = ev.ID
+		}
+	}
+	if id == 0 {
+		if id, err = s.db.CreateEvent(ctx, guildID, channelID, name, organizerID, roundingUnit, remainderStrategy); err != nil {
+			return err
+		}
+	}
+	return s.db.SetCurrentEvent(ctx, channelID, organizerID, id)
 }
 
-func (s *Service) StopSession(ctx context.Context, channelID string) error {
+// StopSession closes the event t selects and returns it.
+func (s *Service) StopSession(ctx context.Context, t Target) (*db.NomikaiEvent, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return errors.New("セッションが存在しません")
+		return nil, err
 	}
-	return s.db.CloseEvent(ctx, ev.ID)
+	return ev, s.db.CloseEvent(ctx, ev.ID)
 }
 
-func (s *Service) Join(ctx context.Context, channelID, userID string) error {
+// Join adds userID to the event t selects. Users joining by themselves also make it their
+// current event.
+func (s *Service) Join(ctx context.Context, t Target, userID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return errors.New("セッションが開始されていません")
+		return err
 	}
-	return s.db.UpsertMember(ctx, ev.ID, userID, 1.0)
+	if err := s.db.UpsertMember(ctx, ev.ID, userID, 1.0); err != nil {
+		return err
+	}
+	if userID == t.UserID {
+		return s.db.SetCurrentEvent(ctx, t.ChannelID, userID, ev.ID)
+	}
+	return nil
 }
 
-func (s *Service) SetWeight(ctx context.Context, channelID, userID string, w float64) (bool, error) {
+func (s *Service) SetWeight(ctx context.Context, t Target, userID string, w float64) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return false, errors.New("セッションが開始されていません")
+		return false, err
 	}
 	// detect existing
 	members, err := s.db.Members(ctx, ev.ID)
@@ -81,12 +107,12 @@ func (s *Service) SetWeight(ctx context.Context, channelID, userID string, w flo
 	return joined, s.db.UpsertMember(ctx, ev.ID, userID, w)
 }
 
-func (s *Service) AddPayment(ctx context.Context, channelID, userID string, amount int64, memo string) (bool, error) {
+func (s *Service) AddPayment(ctx context.Context, t Target, userID string, amount int64, memo string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return false, errors.New("セッションが開始されていません")
+		return false, err
 	}
 	// auto-join if not exists
 	members, err := s.db.Members(ctx, ev.ID)
@@ -108,7 +134,7 @@ func (s *Service) AddPayment(ctx context.Context, channelID, userID string, amou
 	if amount == 0 {
 		return joined, nil
 	}
-	_, err = s.db.AddPayment(ctx, ev.ID, userID, amount, memo, nil)
+	_, err = s.db.AddPayment(ctx, ev.ID, userID, t.UserID, amount, memo, nil)
 	if err != nil {
 		return false, err
 	}
@@ -117,12 +143,12 @@ func (s *Service) AddPayment(ctx context.Context, channelID, userID string, amou
 
 // AddPaymentFor records a payment by payer for specific beneficiaries. If beneficiaries is empty, use AddPayment instead.
 // Returns: payerJoined, beneficiariesJoinedIDs, error
-func (s *Service) AddPaymentFor(ctx context.Context, channelID, payerID string, amount int64, memo string, beneficiaries []string) (bool, []string, error) {
+func (s *Service) AddPaymentFor(ctx context.Context, t Target, payerID string, amount int64, memo string, beneficiaries []string) (bool, []string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return false, nil, errors.New("セッションが開始されていません")
+		return false, nil, err
 	}
 	members, err := s.db.Members(ctx, ev.ID)
 	if err != nil {
@@ -170,18 +196,21 @@ func (s *Service) AddPaymentFor(ctx context.Context, channelID, payerID string,
 	if amount == 0 {
 		return joined, benJoined, nil
 	}
-	if _, err := s.db.AddPayment(ctx, ev.ID, payerID, amount, memo, ben); err != nil {
+	if _, err := s.db.AddPayment(ctx, ev.ID, payerID, t.UserID, amount, memo, ben); err != nil {
 		return false, nil, err
 	}
 	return joined, benJoined, nil
 }
 
-func (s *Service) Status(ctx context.Context, channelID string) (string, error) {
+func (s *Service) Status(ctx context.Context, t Target) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
+	if IsNoEvent(err) {
+		return err.Error(), nil
+	}
 	if err != nil {
-		return "セッションが開始されていません", nil
+		return "", err
 	}
 	members, err := s.db.Members(ctx, ev.ID)
 	if err != nil {
@@ -204,6 +233,9 @@ func (s *Service) Status(ctx context.Context, channelID string) (string, error)
 		total += p.Amount
 	}
 	var b strings.Builder
+	if ev.Name != "" {
+		fmt.Fprintf(&b, "飲み会%s\n", EventLabel(ev))
+	}
 	fmt.Fprintf(&b, "総支出: %d 円\n", total)
 	for _, m := range members {
 		fmt.Fprintf(&b, "<@%s> weight=%.2f paid=%d\n", m.UserID, m.Weight, paidSum[m.UserID])
@@ -223,13 +255,13 @@ func (s *Service) Status(ctx context.Context, channelID string) (string, error)
 	return b.String(), nil
 }
 
-// Members returns the list of participant user IDs for the channel session.
-func (s *Service) Members(ctx context.Context, channelID string) ([]string, error) {
+// Members returns the list of participant user IDs for the event t selects.
+func (s *Service) Members(ctx context.Context, t Target) ([]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return nil, errors.New("セッションが開始されていません")
+		return nil, err
 	}
 	members, err := s.db.Members(ctx, ev.ID)
 	if err != nil {
@@ -243,12 +275,12 @@ func (s *Service) Members(ctx context.Context, channelID string) ([]string, erro
 	return ids, nil
 }
 
-func (s *Service) Settle(ctx context.Context, channelID string) (*SettleResult, error) {
+func (s *Service) Settle(ctx context.Context, t Target) (*SettleResult, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
 	if err != nil {
-		return nil, errors.New("セッションが開始されていません")
+		return nil, err
 	}
 	members, err := s.db.Members(ctx, ev.ID)
 	if err != nil {
@@ -364,6 +396,9 @@ func (s *Service) Settle(ctx context.Context, channelID string) (*SettleResult,
 		return nil, err
 	}
 	var b strings.Builder
+	if ev.Name != "" {
+		fmt.Fprintf(&b, "飲み会%s\n", EventLabel(ev))
+	}
 	if len(tasks) == 0 {
 		b.WriteString("精算は不要です")
 	} else {
@@ -376,13 +411,16 @@ func (s *Service) Settle(ctx context.Context, channelID string) (*SettleResult,
 }
 
 // ConfigureReminder enables or disables periodic reminders and schedules the next run.
-func (s *Service) ConfigureReminder(ctx context.Context, channelID string, intervalMinutes int, disable bool, sendNow bool) (string, error) {
+func (s *Service) ConfigureReminder(ctx context.Context, t Target, intervalMinutes int, disable bool, sendNow bool) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
+	if IsNoEvent(err) {
+		return err.Error(), nil
+	}
 	if err != nil {
-		return "セッションが開始されていません", nil
+		return "", err
 	}
 	if intervalMinutes <= 0 {
 		cfg, err := s.db.ReminderConfig(ctx, ev.ID)
@@ -407,7 +445,7 @@ func (s *Service) ConfigureReminder(ctx context.Context, channelID string, inter
 	}
 
 	if sendNow {
-		msg, err := s.reminderMessage(ctx, ev.ID)
+		msg, err := s.reminderMessage(ctx, ev.ID, ev.Name)
 		if err != nil {
 			return "リマインド本文の生成に失敗しました", err
 		}
@@ -434,28 +472,31 @@ func (s *Service) ConfigureReminder(ctx context.Context, channelID string, inter
 	return fmt.Sprintf("リマインドを有効化しました。次回は約 %d 分後に送信します", intervalMinutes), nil
 }
 
-// ReminderMessage creates the current unpaid summary for a channel's event.
-func (s *Service) ReminderMessage(ctx context.Context, channelID string) (string, error) {
+// ReminderMessage creates the current unpaid summary for the event t selects.
+func (s *Service) ReminderMessage(ctx context.Context, t Target) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
+	if IsNoEvent(err) {
+		return err.Error(), nil
+	}
 	if err != nil {
-		return "セッションが開始されていません", nil
+		return "", err
 	}
-	return s.reminderMessage(ctx, ev.ID)
+	return s.reminderMessage(ctx, ev.ID, ev.Name)
 }
 
-// ReminderMessageByEventID builds a reminder body for a known event.
-func (s *Service) ReminderMessageByEventID(ctx context.Context, eventID int64) (string, error) {
+// ReminderMessageByEventID builds a reminder body for a known event called name.
+func (s *Service) ReminderMessageByEventID(ctx context.Context, eventID int64, name string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.reminderMessage(ctx, eventID)
+	return s.reminderMessage(ctx, eventID, name)
 }
 
 // RegisterPayment records a settlement payment between payer and payee and updates tasks.
 // If payAll is true, amount is ignored and the full outstanding amount is used.
-func (s *Service) RegisterPayment(ctx context.Context, channelID, payerID, payeeID string, amount int64, memo string, actorID string, payAll bool) (string, error) {
+func (s *Service) RegisterPayment(ctx context.Context, t Target, payerID, payeeID string, amount int64, memo string, actorID string, payAll bool) (string, error) {
 	if !payAll && amount <= 0 {
 		return "金額は正の値で指定してください", nil
 	}
@@ -463,9 +504,12 @@ func (s *Service) RegisterPayment(ctx context.Context, channelID, payerID, payee
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	ev, err := s.db.ActiveEventByChannel(ctx, channelID)
+	ev, err := s.activeEvent(ctx, t)
+	if IsNoEvent(err) {
+		return err.Error(), nil
+	}
 	if err != nil {
-		return "セッションが開始されていません", nil
+		return "", err
 	}
 
 	if payAll {
@@ -503,7 +547,7 @@ func (s *Service) RegisterPayment(ctx context.Context, channelID, payerID, payee
 	return b.String(), nil
 }
 
-func (s *Service) reminderMessage(ctx context.Context, eventID int64) (string, error) {
+func (s *Service) reminderMessage(ctx context.Context, eventID int64, name string) (string, error) {
 	tasks, err := s.db.ListPendingSettlementTasks(ctx, eventID)
 	if err != nil {
 		return "", err
@@ -513,7 +557,11 @@ func (s *Service) reminderMessage(ctx context.Context, eventID int64) (string, e
 	}
 
 	var b strings.Builder
-	b.WriteString("未払いのリマインドです。対応をお願いします。\n")
+	if name != "" {
+		fmt.Fprintf(&b, "飲み会「%s」の未払いのリマインドです。対応をお願いします。\n", name)
+	} else {
+		b.WriteString("未払いのリマインドです。対応をお願いします。\n")
+	}
 	for _, t := range tasks {
 		fmt.Fprintf(&b, "<@%s> → <@%s>: %d 円\n", t.PayerID, t.PayeeID, t.Amount)
 	}
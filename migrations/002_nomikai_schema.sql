@@ -13,7 +13,7 @@ CREATE TABLE IF NOT EXISTS nomikai_events (
     closed_at TIMESTAMP NULL
 );
 CREATE INDEX IF NOT EXISTS idx_nomikai_events_guild ON nomikai_events(guild_id);
-CREATE UNIQUE INDEX IF NOT EXISTS uniq_nomikai_events_active_channel ON nomikai_events(channel_id) WHERE status = 'active';
+-- Active events are unique per channel and name; see 020_nomikai_named_events.sql.
 
 -- Event Members
 CREATE TABLE IF NOT EXISTS nomikai_event_members (
@@ -31,4 +31,4 @@ CREATE TABLE IF NOT EXISTS guess_guesses (
     UNIQUE(session_id, user_id)
 );
 CREATE INDEX IF NOT EXISTS idx_guess_guesses_session ON guess_guesses(session_id);
-CREATE INDEX IF NOT EXISTS idx_guess_guesses_user ON guess_guesses(user_id);
+-- Guesses are found by player through idx_guess_guesses_scored_user; see 022_drop_guess_guesses_user_index.sql.
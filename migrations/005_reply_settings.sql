@@ -0,0 +1,10 @@
+-- Per-guild reply visibility overrides.
+-- command is a command key ("nomikai status"), a top-level command ("nomikai"),
+-- "*" for every command, or "error" for error replies.
+CREATE TABLE IF NOT EXISTS reply_settings (
+    guild_id BIGINT NOT NULL,
+    command TEXT NOT NULL,
+    visibility TEXT NOT NULL CHECK (visibility IN ('public', 'ephemeral', 'dm')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, command)
+);
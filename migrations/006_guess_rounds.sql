@@ -0,0 +1,30 @@
+-- Multi-round guess games.
+-- A session is a game of total_rounds rounds; guesses and answers belong to a round.
+-- guess_sessions.answer_* keep the answer of the most recently closed round.
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS total_rounds INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS current_round INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS guess_rounds (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    round_number INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    answer_lat DOUBLE PRECISION NULL,
+    answer_lng DOUBLE PRECISION NULL,
+    answer_url TEXT NULL,
+    opened_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP NULL,
+    UNIQUE(session_id, round_number)
+);
+
+-- Sessions created before rounds existed become one-round games.
+INSERT INTO guess_rounds (session_id, round_number, status, answer_lat, answer_lng, answer_url, opened_at, closed_at)
+SELECT id, 1,
+       CASE WHEN status = 'active' THEN 'open' ELSE 'closed' END,
+       answer_lat, answer_lng, answer_url, created_at, closed_at
+FROM guess_sessions
+ON CONFLICT (session_id, round_number) DO NOTHING;
+
+ALTER TABLE guess_guesses ADD COLUMN IF NOT EXISTS round_number INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE guess_guesses DROP CONSTRAINT IF EXISTS guess_guesses_session_id_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_guess_guesses_round_user ON guess_guesses(session_id, round_number, user_id);
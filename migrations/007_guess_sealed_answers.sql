@@ -0,0 +1,15 @@
+-- Answers submitted in advance by the organizer, revealed when their round closes.
+-- Rows with session_id NULL are queued for the organizer's next game in the channel.
+CREATE TABLE IF NOT EXISTS guess_sealed_answers (
+    id BIGSERIAL PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    organizer_id TEXT NOT NULL,
+    session_id BIGINT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    round_number INTEGER NULL,
+    answer_lat DOUBLE PRECISION NOT NULL,
+    answer_lng DOUBLE PRECISION NOT NULL,
+    answer_url TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_guess_sealed_answers_round ON guess_sealed_answers(session_id, round_number) WHERE session_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_guess_sealed_answers_queue ON guess_sealed_answers(channel_id, organizer_id) WHERE session_id IS NULL;
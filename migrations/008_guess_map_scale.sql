@@ -0,0 +1,14 @@
+-- Map scale of a guess game, and custom map presets saved per guild.
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS map_name TEXT NOT NULL DEFAULT 'world';
+
+CREATE TABLE IF NOT EXISTS guess_map_presets (
+    guild_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    sw_lat DOUBLE PRECISION NOT NULL,
+    sw_lng DOUBLE PRECISION NOT NULL,
+    ne_lat DOUBLE PRECISION NOT NULL,
+    ne_lng DOUBLE PRECISION NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (guild_id, name)
+);
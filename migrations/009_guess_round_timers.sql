@@ -0,0 +1,13 @@
+-- Optional per-round time limit and time bonus for guess games.
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS round_seconds INTEGER NULL;
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS time_bonus BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- deadline is when an open round stops accepting guesses.
+-- last_warning_seconds is the smallest countdown announcement already posted.
+-- A round whose deadline passed without a sealed answer is 'locked' until /guess answer.
+ALTER TABLE guess_rounds ADD COLUMN IF NOT EXISTS deadline TIMESTAMP NULL;
+ALTER TABLE guess_rounds ADD COLUMN IF NOT EXISTS last_warning_seconds INTEGER NULL;
+CREATE INDEX IF NOT EXISTS idx_guess_rounds_open_deadline ON guess_rounds(deadline) WHERE status = 'open' AND deadline IS NOT NULL;
+
+-- Bonus (or penalty, if negative) for how quickly the guess was made; totals use score + time_bonus.
+ALTER TABLE guess_guesses ADD COLUMN IF NOT EXISTS time_bonus INTEGER NOT NULL DEFAULT 0;
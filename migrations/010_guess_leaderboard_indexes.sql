@@ -0,0 +1,5 @@
+-- Leaderboards and player stats aggregate scored guesses; sessions are found by guild through
+-- idx_guess_sessions_guild (004). Weekly and monthly leaderboards start from the recent scored
+-- guesses, and player stats from one player's scored guesses.
+CREATE INDEX IF NOT EXISTS idx_guess_guesses_scored_created ON guess_guesses(created_at) WHERE score IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_guess_guesses_scored_user ON guess_guesses(user_id, session_id) WHERE score IS NOT NULL;
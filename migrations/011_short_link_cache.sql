@@ -0,0 +1,8 @@
+-- Coordinates resolved from map short links, so each link is fetched only once.
+CREATE TABLE IF NOT EXISTS short_link_cache (
+    short_url TEXT PRIMARY KEY,
+    lat DOUBLE PRECISION NOT NULL,
+    lng DOUBLE PRECISION NOT NULL,
+    final_url TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
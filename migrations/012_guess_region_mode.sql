@@ -0,0 +1,10 @@
+-- Region mode scores a guess by the country it lands in, or on maps of Japan by the prefecture.
+-- Streak games go on round after round until someone misses.
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'distance';
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS by_prefecture BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS streak INTEGER NOT NULL DEFAULT 0;
+
+-- Country code (JP) or prefecture code (JP-13) of the answer and of each guess.
+ALTER TABLE guess_rounds ADD COLUMN IF NOT EXISTS answer_region TEXT NULL;
+ALTER TABLE guess_guesses ADD COLUMN IF NOT EXISTS guess_region TEXT NULL;
+ALTER TABLE guess_guesses ADD COLUMN IF NOT EXISTS region_match TEXT NULL;
@@ -0,0 +1,32 @@
+-- Team play for guess games.
+-- team_scoring is how a team's round score is computed from its members' guesses
+-- ('best' or 'average'); NULL means an individual game without teams.
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS team_scoring TEXT NULL;
+
+CREATE TABLE IF NOT EXISTS guess_teams (
+    id BIGSERIAL PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(session_id, name)
+);
+
+-- A player is in at most one team per game.
+CREATE TABLE IF NOT EXISTS guess_team_members (
+    session_id BIGINT NOT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    team_id BIGINT NOT NULL REFERENCES guess_teams(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_guess_team_members_team ON guess_team_members(team_id);
+
+-- Team scores are fixed when a round closes, so later team changes do not rewrite past rounds.
+CREATE TABLE IF NOT EXISTS guess_team_scores (
+    session_id BIGINT NOT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    round_number INTEGER NOT NULL,
+    team_id BIGINT NOT NULL REFERENCES guess_teams(id) ON DELETE CASCADE,
+    score INTEGER NOT NULL,
+    guesses INTEGER NOT NULL,
+    PRIMARY KEY (session_id, round_number, team_id)
+);
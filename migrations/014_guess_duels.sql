@@ -0,0 +1,25 @@
+-- Head-to-head duels: two players start with the same HP and lose the score difference of each
+-- round, times a multiplier that grows in later rounds. The game is a guess session in mode 'duel'.
+CREATE TABLE IF NOT EXISTS guess_duels (
+    session_id BIGINT PRIMARY KEY REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    challenger_id TEXT NOT NULL,
+    opponent_id TEXT NOT NULL,
+    challenger_hp INTEGER NOT NULL,
+    opponent_hp INTEGER NOT NULL,
+    -- Set when the duel ends with a winner; NULL while it runs, after a draw or when stopped.
+    winner_id TEXT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS guess_duel_rounds (
+    session_id BIGINT NOT NULL REFERENCES guess_duels(session_id) ON DELETE CASCADE,
+    round_number INTEGER NOT NULL,
+    multiplier DOUBLE PRECISION NOT NULL,
+    challenger_score INTEGER NOT NULL,
+    opponent_score INTEGER NOT NULL,
+    damage INTEGER NOT NULL,
+    -- HP left after this round's damage.
+    challenger_hp INTEGER NOT NULL,
+    opponent_hp INTEGER NOT NULL,
+    PRIMARY KEY (session_id, round_number)
+);
@@ -0,0 +1,38 @@
+-- Glicko ratings of guess players per guild, updated from pairwise comparisons of the scores in
+-- every scored round. updated_at is when the rating was last updated, from which its deviation
+-- grows back while the player is away.
+CREATE TABLE IF NOT EXISTS guess_ratings (
+    guild_id BIGINT NOT NULL,
+    user_id TEXT NOT NULL,
+    rating DOUBLE PRECISION NOT NULL,
+    rd DOUBLE PRECISION NOT NULL,
+    rounds INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (guild_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_guess_ratings_guild_rating ON guess_ratings(guild_id, rating DESC);
+
+-- One row per player and rated round. Rebuilt from guess_guesses when ratings are recomputed.
+CREATE TABLE IF NOT EXISTS guess_rating_history (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id BIGINT NOT NULL,
+    user_id TEXT NOT NULL,
+    session_id BIGINT NOT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    round_number INTEGER NOT NULL,
+    rating_before DOUBLE PRECISION NOT NULL,
+    rd_before DOUBLE PRECISION NOT NULL,
+    rating DOUBLE PRECISION NOT NULL,
+    rd DOUBLE PRECISION NOT NULL,
+    rated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_guess_rating_history_user ON guess_rating_history(guild_id, user_id, id DESC);
+
+-- Players waiting in a channel for /guess duel to match them with someone of a similar rating.
+CREATE TABLE IF NOT EXISTS guess_duel_queue (
+    channel_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    guild_id BIGINT NOT NULL,
+    rating DOUBLE PRECISION NOT NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (channel_id, user_id)
+);
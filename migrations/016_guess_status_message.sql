@@ -0,0 +1,3 @@
+-- Guesses are acknowledged privately; each round has one public message counting how many
+-- players have guessed, which is edited as guesses come in.
+ALTER TABLE guess_rounds ADD COLUMN IF NOT EXISTS status_message_id TEXT NULL;
@@ -0,0 +1,4 @@
+-- The scoring model of a game and its parameters (see geoscore.ParseScorer); games before
+-- scorers existed used GeoGuessr's exponential curve.
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS scorer TEXT NOT NULL DEFAULT 'exponential';
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS scorer_params TEXT NOT NULL DEFAULT '';
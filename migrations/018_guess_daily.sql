@@ -0,0 +1,40 @@
+-- Locations a guild collects for games the bot runs itself. The answer is the URL; clue is what
+-- players are shown (a photo or a link that does not give the place away).
+CREATE TABLE IF NOT EXISTS guess_pool_locations (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id BIGINT NOT NULL,
+    lat DOUBLE PRECISION NOT NULL,
+    lng DOUBLE PRECISION NOT NULL,
+    url TEXT NOT NULL,
+    clue TEXT NOT NULL DEFAULT '',
+    added_by TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_guess_pool_locations_guild ON guess_pool_locations(guild_id);
+
+-- The daily challenge of a guild is posted to channel_id at post_minute minutes after midnight JST.
+CREATE TABLE IF NOT EXISTS guess_daily_settings (
+    guild_id BIGINT PRIMARY KEY,
+    channel_id TEXT NOT NULL,
+    post_minute INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_by TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per guild and day a challenge was run. A challenge is a one-round guess session with
+-- the pool location sealed as its answer; it is closed when the next one starts. session_id is
+-- NULL for a day the pool had nothing to post.
+CREATE TABLE IF NOT EXISTS guess_daily_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    guild_id BIGINT NOT NULL,
+    challenge_date DATE NOT NULL,
+    session_id BIGINT NULL REFERENCES guess_sessions(id) ON DELETE CASCADE,
+    location_id BIGINT NULL REFERENCES guess_pool_locations(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(guild_id, challenge_date)
+);
+CREATE INDEX IF NOT EXISTS idx_guess_daily_challenges_session ON guess_daily_challenges(session_id);
+CREATE INDEX IF NOT EXISTS idx_guess_daily_challenges_location ON guess_daily_challenges(location_id);
+
+ALTER TABLE guess_sessions ADD COLUMN IF NOT EXISTS daily BOOLEAN NOT NULL DEFAULT FALSE;
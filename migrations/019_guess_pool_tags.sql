@@ -0,0 +1,18 @@
+-- Pool locations carry tags such as 'japan' or 'hard' that games draw from. last_used_at is when
+-- the location was last drawn; draws take the least recently used first so that none repeats
+-- until every location with the tag has been used.
+ALTER TABLE guess_pool_locations ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE guess_pool_locations ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP NULL;
+CREATE INDEX IF NOT EXISTS idx_guess_pool_locations_tags ON guess_pool_locations USING GIN (tags);
+
+-- Daily challenges drew least recently used locations by challenge date before last_used_at existed.
+UPDATE guess_pool_locations l
+SET last_used_at = (SELECT MAX(c.challenge_date) FROM guess_daily_challenges c WHERE c.location_id = l.id)
+WHERE l.last_used_at IS NULL
+  AND EXISTS (SELECT 1 FROM guess_daily_challenges c WHERE c.location_id = l.id);
+
+-- The daily challenge can be restricted to a tag; '' draws from the whole pool.
+ALTER TABLE guess_daily_settings ADD COLUMN IF NOT EXISTS tag TEXT NOT NULL DEFAULT '';
+
+-- The clue shown to players when the round of a sealed answer drawn from the pool opens.
+ALTER TABLE guess_sealed_answers ADD COLUMN IF NOT EXISTS clue TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+-- A channel can host several active events at once, such as a party and its after-party, told
+-- apart by name. Events started before names existed are unnamed ('').
+ALTER TABLE nomikai_events ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS uniq_nomikai_events_active_channel;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_nomikai_events_active_channel_name
+    ON nomikai_events(channel_id, lower(name)) WHERE status = 'active';
+
+-- The event each user's /nomikai commands in a channel act on when no event is given.
+CREATE TABLE IF NOT EXISTS nomikai_current_events (
+    channel_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    event_id BIGINT NOT NULL REFERENCES nomikai_events(id) ON DELETE CASCADE,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (channel_id, user_id)
+);
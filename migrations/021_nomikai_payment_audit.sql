@@ -0,0 +1,22 @@
+-- Who recorded each payment, so that they can correct it later. Older payments have none.
+ALTER TABLE nomikai_payments ADD COLUMN IF NOT EXISTS recorded_by TEXT;
+
+-- Edits and deletions of recorded payments. Deleted payments are gone from nomikai_payments,
+-- so payment_id is not a foreign key. The new_ columns are NULL for deletions.
+CREATE TABLE IF NOT EXISTS nomikai_payment_audit (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES nomikai_events(id) ON DELETE CASCADE,
+    payment_id BIGINT NOT NULL,
+    action TEXT NOT NULL,
+    actor_id TEXT NOT NULL,
+    old_payer_id TEXT NOT NULL,
+    old_amount BIGINT NOT NULL,
+    old_memo TEXT NOT NULL DEFAULT '',
+    old_beneficiaries TEXT[] NOT NULL DEFAULT '{}',
+    new_payer_id TEXT,
+    new_amount BIGINT,
+    new_memo TEXT,
+    new_beneficiaries TEXT[],
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_nomikai_payment_audit_event ON nomikai_payment_audit(event_id);
@@ -0,0 +1,3 @@
+-- Player stats and leaderboards read a player's guesses through idx_guess_guesses_scored_user
+-- (010), so the plain user_id index is no longer needed.
+DROP INDEX IF EXISTS idx_guess_guesses_user;
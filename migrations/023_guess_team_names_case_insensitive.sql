@@ -0,0 +1,9 @@
+-- Team names are matched case-insensitively when joining, so they must be unique that way too.
+-- Teams that already clash get their ID appended, keeping the first one's name.
+UPDATE guess_teams t SET name = t.name || ' #' || t.id
+WHERE EXISTS (
+    SELECT 1 FROM guess_teams o
+    WHERE o.session_id = t.session_id AND lower(o.name) = lower(t.name) AND o.id < t.id
+);
+ALTER TABLE guess_teams DROP CONSTRAINT IF EXISTS guess_teams_session_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_guess_teams_session_name ON guess_teams(session_id, lower(name));